@@ -7,6 +7,7 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -77,7 +78,7 @@ var (
 	configAuthKey = flagutil.NewPassword("configAuthKey", "Authorization key for accessing /config page. It must be passed via authKey query arg. It overrides -httpAuth.*")
 	reloadAuthKey = flagutil.NewPassword("reloadAuthKey", "Auth key for /-/reload http endpoint. It must be passed via authKey query arg. It overrides -httpAuth.*")
 	dryRun        = flag.Bool("dryRun", false, "Whether to check config files without running vmagent. The following files are checked: "+
-		"-promscrape.config, -remoteWrite.relabelConfig, -remoteWrite.urlRelabelConfig, -remoteWrite.streamAggr.config . "+
+		"-promscrape.config, -remoteWrite.relabelConfig, -remoteWrite.urlRelabelConfig, -remoteWrite.streamAggr.config, -remoteWrite.tenantQuotasConfig . "+
 		"Unknown config entries aren't allowed in -promscrape.config by default. This can be changed by passing -promscrape.config.strictParse=false command-line flag")
 	maxLabelsPerTimeseries = flag.Int("maxLabelsPerTimeseries", 0, "The maximum number of labels per time series to be accepted. Series with superfluous labels are ignored. In this case the vm_rows_ignored_total{reason=\"too_many_labels\"} metric at /metrics page is incremented")
 	maxLabelNameLen        = flag.Int("maxLabelNameLen", 0, "The maximum length of label names in the accepted time series. Series with longer label name are ignored. In this case the vm_rows_ignored_total{reason=\"too_long_label_name\"} metric at /metrics page is incremented")
@@ -133,6 +134,9 @@ func main() {
 		if err := remotewrite.CheckStreamAggrConfigs(); err != nil {
 			logger.Fatalf("error when checking -streamAggr.config and -remoteWrite.streamAggr.config: %s", err)
 		}
+		if err := remotewrite.CheckTenantQuotasConfig(); err != nil {
+			logger.Fatalf("error when checking -remoteWrite.tenantQuotasConfig: %s", err)
+		}
 		logger.Infof("all the configs are ok; exiting with 0 status code")
 		return
 	}
@@ -251,7 +255,9 @@ func requestHandler(w http.ResponseWriter, r *http.Request) bool {
 			{"service-discovery", "labels before and after relabeling for discovered targets"},
 			{"metric-relabel-debug", "debug metric relabeling"},
 			{"api/v1/targets", "advanced information about discovered targets in JSON format"},
+			{"api/v1/targets/cardinality", "cardinality guard reports for scrape targets in JSON format"},
 			{"config", "-promscrape.config contents"},
+			{"remotewrite-dlq", "blocks rejected by remote storage systems"},
 			{"metrics", "available service metrics"},
 			{"flags", "command-line flags"},
 			{"-/reload", "reload configuration"},
@@ -448,7 +454,14 @@ func requestHandler(w http.ResponseWriter, r *http.Request) bool {
 		// https://prometheus.io/docs/prometheus/latest/querying/api/#targets
 		state := r.FormValue("state")
 		scrapePool := r.FormValue("scrapePool")
-		promscrape.WriteAPIV1Targets(w, state, scrapePool)
+		showHistory, _ := strconv.ParseBool(r.FormValue("history"))
+		promscrape.WriteAPIV1Targets(w, state, scrapePool, showHistory)
+		return true
+	case "/prometheus/api/v1/targets/cardinality", "/api/v1/targets/cardinality":
+		promscrapeAPIV1TargetsCardinalityRequests.Inc()
+		w.Header().Set("Content-Type", "application/json")
+		scrapePool := r.FormValue("scrapePool")
+		promscrape.WriteAPIV1TargetsCardinality(w, scrapePool)
 		return true
 	case "/prometheus/target_response", "/target_response":
 		promscrapeTargetResponseRequests.Inc()
@@ -477,6 +490,20 @@ func requestHandler(w http.ResponseWriter, r *http.Request) bool {
 		promscrape.WriteConfigData(&bb)
 		fmt.Fprintf(w, `{"status":"success","data":{"yaml":%s}}`, stringsutil.JSONString(string(bb.B)))
 		return true
+	case "/remotewrite-dlq":
+		remoteWriteDLQRequests.Inc()
+		remotewrite.WriteDeadLetterQueue(w, r)
+		return true
+	case "/remotewrite-dlq/replay":
+		remoteWriteDLQReplayRequests.Inc()
+		if !httpserver.CheckAuthFlag(w, r, reloadAuthKey) {
+			return true
+		}
+		if err := remotewrite.ReplayDeadLetterQueue(w, r); err != nil {
+			remoteWriteDLQReplayErrors.Inc()
+			httpserver.Errorf(w, r, "%s", err)
+		}
+		return true
 	case "/prometheus/-/reload", "/-/reload":
 		if !httpserver.CheckAuthFlag(w, r, reloadAuthKey) {
 			return true
@@ -739,7 +766,8 @@ var (
 	promscrapeMetricRelabelDebugRequests = metrics.NewCounter(`vmagent_http_requests_total{path="/metric-relabel-debug"}`)
 	promscrapeTargetRelabelDebugRequests = metrics.NewCounter(`vmagent_http_requests_total{path="/target-relabel-debug"}`)
 
-	promscrapeAPIV1TargetsRequests = metrics.NewCounter(`vmagent_http_requests_total{path="/api/v1/targets"}`)
+	promscrapeAPIV1TargetsRequests            = metrics.NewCounter(`vmagent_http_requests_total{path="/api/v1/targets"}`)
+	promscrapeAPIV1TargetsCardinalityRequests = metrics.NewCounter(`vmagent_http_requests_total{path="/api/v1/targets/cardinality"}`)
 
 	promscrapeTargetResponseRequests = metrics.NewCounter(`vmagent_http_requests_total{path="/target_response"}`)
 	promscrapeTargetResponseErrors   = metrics.NewCounter(`vmagent_http_request_errors_total{path="/target_response"}`)
@@ -748,6 +776,10 @@ var (
 	promscrapeStatusConfigRequests = metrics.NewCounter(`vmagent_http_requests_total{path="/api/v1/status/config"}`)
 
 	promscrapeConfigReloadRequests = metrics.NewCounter(`vmagent_http_requests_total{path="/-/reload"}`)
+
+	remoteWriteDLQRequests       = metrics.NewCounter(`vmagent_http_requests_total{path="/remotewrite-dlq"}`)
+	remoteWriteDLQReplayRequests = metrics.NewCounter(`vmagent_http_requests_total{path="/remotewrite-dlq/replay"}`)
+	remoteWriteDLQReplayErrors   = metrics.NewCounter(`vmagent_http_request_errors_total{path="/remotewrite-dlq/replay"}`)
 )
 
 func usage() {
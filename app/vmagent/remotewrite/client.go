@@ -98,6 +98,12 @@ type client struct {
 	fq *persistentqueue.FastQueue
 	hc *http.Client
 
+	// fw is set only for file:///path urls.
+	fw *fileWriter
+
+	// dlq stores blocks rejected by remote storage if -remoteWrite.deadLetterDir is set.
+	dlq *deadLetterQueue
+
 	retryMinInterval time.Duration
 	retryMaxInterval time.Duration
 
@@ -166,6 +172,7 @@ func newHTTPClient(argIdx int, remoteWriteURL, sanitizedURL string, fq *persiste
 		hc:               hc,
 		retryMinInterval: retryMinInterval.GetOptionalArg(argIdx),
 		retryMaxInterval: retryMaxIntervalFlag.GetOptionalArg(argIdx),
+		dlq:              newDeadLetterQueue(argIdx, sanitizedURL, fq),
 		stopCh:           make(chan struct{}),
 	}
 	c.sendBlock = c.sendBlockHTTP
@@ -218,6 +225,9 @@ func (c *client) init(argIdx, concurrency int, sanitizedURL string) {
 func (c *client) MustStop() {
 	close(c.stopCh)
 	c.wg.Wait()
+	if c.fw != nil {
+		c.fw.MustStop()
+	}
 	logger.Infof("stopped client for -remoteWrite.url=%q", c.sanitizedURL)
 }
 
@@ -450,7 +460,8 @@ again:
 	metrics.GetOrCreateCounter(fmt.Sprintf(`vmagent_remotewrite_requests_total{url=%q, status_code="%d"}`, c.sanitizedURL, statusCode)).Inc()
 	switch statusCode {
 	case 409:
-		logBlockRejected(block, c.sanitizedURL, resp)
+		body := logBlockRejected(block, c.sanitizedURL, resp)
+		c.dlq.add(block, statusCode, body)
 
 		// Just drop block on 409 status code like Prometheus does.
 		// See https://github.com/VictoriaMetrics/VictoriaMetrics/issues/873
@@ -495,7 +506,8 @@ again:
 		// Just drop snappy blocks on 400 or 415 status codes like Prometheus does.
 		// See https://github.com/VictoriaMetrics/VictoriaMetrics/issues/873
 		// and https://github.com/VictoriaMetrics/VictoriaMetrics/issues/1149
-		logBlockRejected(block, c.sanitizedURL, resp)
+		body := logBlockRejected(block, c.sanitizedURL, resp)
+		c.dlq.add(block, statusCode, body)
 		_ = resp.Body.Close()
 		c.packetsDropped.Inc()
 		return true
@@ -568,7 +580,8 @@ func repackBlockFromZstdToSnappy(zstdBlock []byte) ([]byte, error) {
 	return snappy.Encode(nil, plainBlock), nil
 }
 
-func logBlockRejected(block []byte, sanitizedURL string, resp *http.Response) {
+// logBlockRejected logs the rejected block and returns the response body.
+func logBlockRejected(block []byte, sanitizedURL string, resp *http.Response) []byte {
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		remoteWriteRejectedLogger.Errorf("sending a block with size %d bytes to %q was rejected (skipping the block): status code %d; "+
@@ -578,6 +591,7 @@ func logBlockRejected(block []byte, sanitizedURL string, resp *http.Response) {
 		remoteWriteRejectedLogger.Errorf("sending a block with size %d bytes to %q was rejected (skipping the block): status code %d; response body: %s",
 			len(block), sanitizedURL, resp.StatusCode, string(body))
 	}
+	return body
 }
 
 // parseRetryAfterHeader parses `Retry-After` value retrieved from HTTP response header.
@@ -0,0 +1,422 @@
+package remotewrite
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/golang/snappy"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding/zstd"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/persistentqueue"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+var (
+	deadLetterDir = flagutil.NewArrayString("remoteWrite.deadLetterDir", "Optional path to a directory for storing blocks of data rejected "+
+		"by the corresponding -remoteWrite.url with 400, 409 or 415 status codes together with the response body. By default rejected blocks are dropped. "+
+		"Stored blocks can be inspected at /remotewrite-dlq page and replayed via /remotewrite-dlq/replay endpoint. "+
+		"See https://docs.victoriametrics.com/victoriametrics/vmagent/#dead-letter-queue")
+	deadLetterMaxDiskUsage = flagutil.NewArrayBytes("remoteWrite.deadLetterMaxDiskUsage", 1024*1024*1024, "The maximum disk space, which can be occupied "+
+		"by the corresponding -remoteWrite.deadLetterDir. Newly rejected blocks are dropped when this limit is reached. Zero value disables the limit")
+)
+
+const (
+	deadLetterBlockSuffix = ".block"
+	deadLetterMetaSuffix  = ".json"
+
+	// deadLetterMaxResponseBodyLen is the maximum length of the response body stored for every rejected block.
+	deadLetterMaxResponseBodyLen = 64 * 1024
+)
+
+// deadLetterQueue stores blocks rejected by remote storage at the given directory.
+//
+// Every entry consists of two files: <id>.block with the rejected block and <id>.json with deadLetterMeta.
+// The block file is written before the meta file, so entries without meta file are incomplete and are ignored.
+type deadLetterQueue struct {
+	dir          string
+	sanitizedURL string
+	maxDiskUsage int64
+
+	fq *persistentqueue.FastQueue
+
+	// mu serializes modifications of dir contents.
+	mu sync.Mutex
+
+	lastID    atomic.Int64
+	totalSize atomic.Int64
+
+	entriesAdded    *metrics.Counter
+	entriesDropped  *metrics.Counter
+	entriesReplayed *metrics.Counter
+}
+
+// deadLetterMeta contains information about the rejected block.
+type deadLetterMeta struct {
+	Timestamp    int64  `json:"timestamp"`
+	URL          string `json:"url"`
+	StatusCode   int    `json:"status_code"`
+	ResponseBody string `json:"response_body"`
+	BlockSize    int    `json:"block_size"`
+}
+
+// deadLetterEntry is a single entry at deadLetterQueue.
+type deadLetterEntry struct {
+	ID string `json:"id"`
+	deadLetterMeta
+}
+
+func newDeadLetterQueue(argIdx int, sanitizedURL string, fq *persistentqueue.FastQueue) *deadLetterQueue {
+	dir := deadLetterDir.GetOptionalArg(argIdx)
+	if dir == "" {
+		return nil
+	}
+	// Use distinct subdirectory per -remoteWrite.url, since the same -remoteWrite.deadLetterDir may be shared among multiple urls.
+	h := xxhash.Sum64([]byte(sanitizedURL))
+	dir = filepath.Join(dir, fmt.Sprintf("%d_%016X", argIdx+1, h))
+	fs.MustMkdirIfNotExist(dir)
+	dlq := &deadLetterQueue{
+		dir:          dir,
+		sanitizedURL: sanitizedURL,
+		maxDiskUsage: deadLetterMaxDiskUsage.GetOptionalArg(argIdx),
+		fq:           fq,
+
+		entriesAdded:    metrics.GetOrCreateCounter(fmt.Sprintf(`vmagent_remotewrite_dead_letter_entries_added_total{url=%q}`, sanitizedURL)),
+		entriesDropped:  metrics.GetOrCreateCounter(fmt.Sprintf(`vmagent_remotewrite_dead_letter_entries_dropped_total{url=%q}`, sanitizedURL)),
+		entriesReplayed: metrics.GetOrCreateCounter(fmt.Sprintf(`vmagent_remotewrite_dead_letter_entries_replayed_total{url=%q}`, sanitizedURL)),
+	}
+	for _, name := range dlq.listFiles() {
+		dlq.totalSize.Add(int64(fs.MustFileSize(filepath.Join(dir, name))))
+	}
+	_ = metrics.GetOrCreateGauge(fmt.Sprintf(`vmagent_remotewrite_dead_letter_size_bytes{url=%q}`, sanitizedURL), func() float64 {
+		return float64(dlq.totalSize.Load())
+	})
+	logger.Infof("storing blocks rejected by -remoteWrite.url=%q at %q", sanitizedURL, dir)
+	return dlq
+}
+
+// add stores the block rejected with the given statusCode and responseBody at dlq.
+//
+// It is safe calling add on nil dlq.
+func (dlq *deadLetterQueue) add(block []byte, statusCode int, responseBody []byte) {
+	if dlq == nil {
+		return
+	}
+	if len(responseBody) > deadLetterMaxResponseBodyLen {
+		responseBody = responseBody[:deadLetterMaxResponseBodyLen]
+	}
+	meta := deadLetterMeta{
+		Timestamp:    time.Now().Unix(),
+		URL:          dlq.sanitizedURL,
+		StatusCode:   statusCode,
+		ResponseBody: string(responseBody),
+		BlockSize:    len(block),
+	}
+	metaData, err := json.Marshal(&meta)
+	if err != nil {
+		logger.Panicf("BUG: cannot marshal dead letter meta: %s", err)
+	}
+	size := int64(len(block) + len(metaData))
+	if dlq.maxDiskUsage > 0 && dlq.totalSize.Load()+size > dlq.maxDiskUsage {
+		dlq.entriesDropped.Inc()
+		remoteWriteRejectedLogger.Warnf("dropping the block with size %d bytes rejected by %q, since -remoteWrite.deadLetterMaxDiskUsage=%d is reached at %q",
+			len(block), dlq.sanitizedURL, dlq.maxDiskUsage, dlq.dir)
+		return
+	}
+
+	id := dlq.nextID()
+	dlq.mu.Lock()
+	fs.MustWriteAtomic(filepath.Join(dlq.dir, id+deadLetterBlockSuffix), block, false)
+	fs.MustWriteAtomic(filepath.Join(dlq.dir, id+deadLetterMetaSuffix), metaData, false)
+	dlq.mu.Unlock()
+
+	dlq.totalSize.Add(size)
+	dlq.entriesAdded.Inc()
+}
+
+// nextID returns unique id for the next entry.
+//
+// Ids are ordered by entry creation time.
+func (dlq *deadLetterQueue) nextID() string {
+	for {
+		lastID := dlq.lastID.Load()
+		id := time.Now().UnixNano()
+		if id <= lastID {
+			id = lastID + 1
+		}
+		if dlq.lastID.CompareAndSwap(lastID, id) {
+			return fmt.Sprintf("%016X", id)
+		}
+	}
+}
+
+// entries returns dlq entries sorted by creation time.
+func (dlq *deadLetterQueue) entries() []deadLetterEntry {
+	dlq.mu.Lock()
+	defer dlq.mu.Unlock()
+
+	var entries []deadLetterEntry
+	for _, name := range dlq.listFiles() {
+		id, ok := strings.CutSuffix(name, deadLetterMetaSuffix)
+		if !ok {
+			continue
+		}
+		path := filepath.Join(dlq.dir, name)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			logger.Errorf("cannot read dead letter entry: %s", err)
+			continue
+		}
+		e := deadLetterEntry{
+			ID: id,
+		}
+		if err := json.Unmarshal(data, &e.deadLetterMeta); err != nil {
+			logger.Errorf("cannot parse dead letter entry %q: %s", path, err)
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// replay returns entries with the given ids back to the queue for sending them to remote storage.
+//
+// All the entries are replayed if ids contains `all`.
+// Successfully replayed entries are removed from dlq. The number of replayed entries is returned.
+func (dlq *deadLetterQueue) replay(ids []string) (int, error) {
+	if slices.Contains(ids, "all") {
+		ids = nil
+		for _, e := range dlq.entries() {
+			ids = append(ids, e.ID)
+		}
+	}
+
+	dlq.mu.Lock()
+	defer dlq.mu.Unlock()
+
+	replayed := 0
+	for _, id := range ids {
+		if !isValidDeadLetterID(id) {
+			return replayed, fmt.Errorf("invalid entry id %q", id)
+		}
+		metaPath := filepath.Join(dlq.dir, id+deadLetterMetaSuffix)
+		if !fs.IsPathExist(metaPath) {
+			return replayed, fmt.Errorf("cannot find entry %q", id)
+		}
+		blockPath := filepath.Join(dlq.dir, id+deadLetterBlockSuffix)
+		block, err := os.ReadFile(blockPath)
+		if err != nil {
+			return replayed, fmt.Errorf("cannot read entry %q: %w", id, err)
+		}
+		if !dlq.fq.TryWriteBlock(block) {
+			return replayed, fmt.Errorf("cannot replay entry %q, since the queue for %q is full; try again later", id, dlq.sanitizedURL)
+		}
+		size := fs.MustFileSize(metaPath) + uint64(len(block))
+		// Remove the meta file at first, so the entry becomes invisible even if the block file cannot be removed.
+		fs.MustRemovePath(metaPath)
+		fs.MustRemovePath(blockPath)
+		dlq.totalSize.Add(-int64(size))
+		dlq.entriesReplayed.Inc()
+		replayed++
+	}
+	return replayed, nil
+}
+
+func (dlq *deadLetterQueue) listFiles() []string {
+	var names []string
+	for _, de := range fs.MustReadDir(dlq.dir) {
+		if !de.Type().IsRegular() {
+			continue
+		}
+		name := de.Name()
+		if fs.IsTemporaryFileName(name) {
+			continue
+		}
+		if strings.HasSuffix(name, deadLetterBlockSuffix) || strings.HasSuffix(name, deadLetterMetaSuffix) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+func isValidDeadLetterID(id string) bool {
+	if len(id) != 16 {
+		return false
+	}
+	_, err := strconv.ParseUint(id, 16, 64)
+	return err == nil
+}
+
+// getBlockSeries returns up to maxSeries series from the given block together with the total number of series in the block.
+func getBlockSeries(block []byte, maxSeries int) ([]string, int, error) {
+	var data []byte
+	var err error
+	if encoding.IsZstd(block) {
+		data, err = zstd.Decompress(nil, block)
+	} else {
+		data, err = snappy.Decode(nil, block)
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("cannot decompress block: %w", err)
+	}
+
+	wru := getWriteRequestUnmarshaller()
+	defer putWriteRequestUnmarshaller(wru)
+
+	wr, err := wru.UnmarshalProtobuf(data)
+	if err != nil {
+		return nil, 0, fmt.Errorf("cannot unmarshal block: %w", err)
+	}
+	var series []string
+	for i := range wr.Timeseries {
+		if len(series) >= maxSeries {
+			break
+		}
+		series = append(series, prompb.LabelsToString(wr.Timeseries[i].Labels))
+	}
+	return series, len(wr.Timeseries), nil
+}
+
+func getDeadLetterQueues() []*deadLetterQueue {
+	var dlqs []*deadLetterQueue
+	for _, rwctx := range rwctxsGlobal {
+		if c := rwctx.c; c != nil && c.dlq != nil {
+			dlqs = append(dlqs, c.dlq)
+		}
+	}
+	return dlqs
+}
+
+func getDeadLetterQueue(r *http.Request) (*deadLetterQueue, error) {
+	s := r.FormValue("url")
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse `url` query arg %q: %w", s, err)
+	}
+	if n < 1 || n > len(rwctxsGlobal) {
+		return nil, fmt.Errorf("`url` query arg must be in the range [1..%d]; got %d", len(rwctxsGlobal), n)
+	}
+	c := rwctxsGlobal[n-1].c
+	if c == nil || c.dlq == nil {
+		return nil, fmt.Errorf("-remoteWrite.deadLetterDir isn't set for -remoteWrite.url #%d", n)
+	}
+	return c.dlq, nil
+}
+
+// WriteDeadLetterQueue writes blocks rejected by remote storage systems to w.
+//
+// The blocks are stored only for -remoteWrite.url with the configured -remoteWrite.deadLetterDir.
+// The response is written in JSON if `format=json` query arg is passed.
+func WriteDeadLetterQueue(w http.ResponseWriter, r *http.Request) {
+	const maxSeriesPerEntry = 5
+
+	dlqs := getDeadLetterQueues()
+	if r.FormValue("format") == "json" {
+		type urlEntries struct {
+			URL     string            `json:"url"`
+			Dir     string            `json:"dir"`
+			Entries []deadLetterEntry `json:"entries"`
+		}
+		result := make([]urlEntries, 0, len(dlqs))
+		for _, dlq := range dlqs {
+			result = append(result, urlEntries{
+				URL:     dlq.sanitizedURL,
+				Dir:     dlq.dir,
+				Entries: dlq.entries(),
+			})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(result)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, "<h2>Blocks rejected by remote storage</h2>")
+	if len(dlqs) == 0 {
+		fmt.Fprintf(w, "<p>-remoteWrite.deadLetterDir isn't set for any -remoteWrite.url. "+
+			"See <a href='https://docs.victoriametrics.com/victoriametrics/vmagent/#dead-letter-queue'>these docs</a>.</p>")
+		return
+	}
+	for i, rwctx := range rwctxsGlobal {
+		c := rwctx.c
+		if c == nil || c.dlq == nil {
+			continue
+		}
+		dlq := c.dlq
+		entries := dlq.entries()
+		fmt.Fprintf(w, "<h3>-remoteWrite.url #%d: %s</h3>", i+1, html.EscapeString(dlq.sanitizedURL))
+		fmt.Fprintf(w, "<p>%d entries stored at %s</p>", len(entries), html.EscapeString(dlq.dir))
+		if len(entries) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "<form method='post' action='remotewrite-dlq/replay'><input type='hidden' name='url' value='%d'>", i+1)
+		fmt.Fprintf(w, "<table border='1'><tr><th></th><th>id</th><th>time</th><th>status code</th><th>block size</th><th>series</th><th>response body</th></tr>")
+		for _, e := range entries {
+			block, err := os.ReadFile(filepath.Join(dlq.dir, e.ID+deadLetterBlockSuffix))
+			var seriesInfo string
+			if err == nil {
+				var series []string
+				var seriesCount int
+				series, seriesCount, err = getBlockSeries(block, maxSeriesPerEntry)
+				if err == nil {
+					for j := range series {
+						series[j] = html.EscapeString(series[j])
+					}
+					seriesInfo = strings.Join(series, "<br>")
+					if seriesCount > len(series) {
+						seriesInfo += fmt.Sprintf("<br>... and %d more series", seriesCount-len(series))
+					}
+				}
+			}
+			if err != nil {
+				seriesInfo = html.EscapeString(err.Error())
+			}
+			fmt.Fprintf(w, "<tr><td><input type='checkbox' name='id' value='%s'></td><td>%s</td><td>%s</td><td>%d</td><td>%d</td><td><code>%s</code></td><td><pre>%s</pre></td></tr>",
+				e.ID, e.ID, time.Unix(e.Timestamp, 0).UTC().Format(time.RFC3339), e.StatusCode, e.BlockSize, seriesInfo, html.EscapeString(e.ResponseBody))
+		}
+		fmt.Fprintf(w, "</table><input type='submit' value='Replay selected entries'></form>")
+	}
+}
+
+// ReplayDeadLetterQueue returns blocks rejected by remote storage back to the queue for sending them again.
+//
+// The -remoteWrite.url index is passed via `url` query arg starting from 1.
+// Entries to replay are passed via `id` query args. All the entries are replayed if `id=all` is passed.
+func ReplayDeadLetterQueue(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		return fmt.Errorf("unsupported method %s; use POST", r.Method)
+	}
+	dlq, err := getDeadLetterQueue(r)
+	if err != nil {
+		return err
+	}
+	// ParseForm has been already called by r.FormValue() inside getDeadLetterQueue()
+	ids := r.Form["id"]
+	if len(ids) == 0 {
+		return fmt.Errorf("missing `id` query arg; pass `id=all` for replaying all the entries")
+	}
+	n, err := dlq.replay(ids)
+	if err != nil {
+		return fmt.Errorf("replayed %d entries for %q: %w", n, dlq.sanitizedURL, err)
+	}
+	logger.Infof("replayed %d dead letter entries for -remoteWrite.url=%q", n, dlq.sanitizedURL)
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"status":"success","replayed":%d}`, n)
+	return nil
+}
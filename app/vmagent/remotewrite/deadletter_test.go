@@ -0,0 +1,123 @@
+package remotewrite
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/snappy"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/persistentqueue"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
+)
+
+func TestDeadLetterQueue(t *testing.T) {
+	tmpDir := t.TempDir()
+	fq := persistentqueue.MustOpenFastQueue(filepath.Join(tmpDir, "queue"), "test", 10, 0, true)
+	defer fq.MustClose()
+
+	*deadLetterDir = flagutil.ArrayString{filepath.Join(tmpDir, "dlq")}
+	defer func() {
+		*deadLetterDir = nil
+	}()
+
+	wr := &prompb.WriteRequest{
+		Timeseries: []prompb.TimeSeries{
+			{
+				Labels:  []prompb.Label{{Name: "__name__", Value: "foo"}},
+				Samples: []prompb.Sample{{Value: 1, Timestamp: 1000}},
+			},
+			{
+				Labels:  []prompb.Label{{Name: "__name__", Value: "bar"}},
+				Samples: []prompb.Sample{{Value: 2, Timestamp: 2000}},
+			},
+		},
+	}
+	block := snappy.Encode(nil, wr.MarshalProtobuf(nil))
+
+	dlq := newDeadLetterQueue(0, "test", fq)
+	dlq.add(block, 400, []byte("bad request"))
+	dlq.add(block, 409, []byte("conflict"))
+
+	entries := dlq.entries()
+	if len(entries) != 2 {
+		t.Fatalf("unexpected number of entries; got %d; want 2", len(entries))
+	}
+	if e := entries[0]; e.StatusCode != 400 || e.ResponseBody != "bad request" || e.BlockSize != len(block) {
+		t.Fatalf("unexpected first entry: %+v", e)
+	}
+	if e := entries[1]; e.StatusCode != 409 || e.ResponseBody != "conflict" {
+		t.Fatalf("unexpected second entry: %+v", e)
+	}
+
+	series, seriesCount, err := getBlockSeries(block, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if seriesCount != 2 || len(series) != 1 || series[0] != `{__name__="foo"}` {
+		t.Fatalf("unexpected series; got %q out of %d", series, seriesCount)
+	}
+
+	// Reopen the queue in order to verify it picks up the existing entries.
+	dlq = newDeadLetterQueue(0, "test", fq)
+	if n := dlq.totalSize.Load(); n <= int64(2*len(block)) {
+		t.Fatalf("unexpected total size for existing entries: %d", n)
+	}
+
+	// Invalid ids
+	if _, err := dlq.replay([]string{"../foo"}); err == nil {
+		t.Fatalf("expecting non-nil error for invalid id")
+	}
+	if _, err := dlq.replay([]string{"0000000000000000"}); err == nil {
+		t.Fatalf("expecting non-nil error for missing id")
+	}
+
+	n, err := dlq.replay([]string{entries[0].ID})
+	if err != nil {
+		t.Fatalf("unexpected error when replaying entry: %s", err)
+	}
+	if n != 1 {
+		t.Fatalf("unexpected number of replayed entries; got %d; want 1", n)
+	}
+	n, err = dlq.replay([]string{"all"})
+	if err != nil {
+		t.Fatalf("unexpected error when replaying all the entries: %s", err)
+	}
+	if n != 1 {
+		t.Fatalf("unexpected number of replayed entries; got %d; want 1", n)
+	}
+	if entries := dlq.entries(); len(entries) != 0 {
+		t.Fatalf("unexpected entries left after replay: %+v", entries)
+	}
+	if n := dlq.totalSize.Load(); n != 0 {
+		t.Fatalf("unexpected total size after replay: %d", n)
+	}
+	if n := fq.GetInmemoryQueueLen(); n != 2 {
+		t.Fatalf("unexpected number of replayed blocks in the queue; got %d; want 2", n)
+	}
+}
+
+func TestDeadLetterQueueSharedDir(t *testing.T) {
+	tmpDir := t.TempDir()
+	fq := persistentqueue.MustOpenFastQueue(filepath.Join(tmpDir, "queue"), "test", 10, 0, true)
+	defer fq.MustClose()
+
+	*deadLetterDir = flagutil.ArrayString{filepath.Join(tmpDir, "dlq")}
+	defer func() {
+		*deadLetterDir = nil
+	}()
+
+	dlq1 := newDeadLetterQueue(0, "1:http://foo/api/v1/write", fq)
+	dlq2 := newDeadLetterQueue(1, "2:http://bar/api/v1/write", fq)
+	if dlq1.dir == dlq2.dir {
+		t.Fatalf("distinct urls must use distinct dirs; got %q", dlq1.dir)
+	}
+
+	dlq1.add([]byte("foo"), 400, []byte("bad request"))
+	if n := len(dlq1.entries()); n != 1 {
+		t.Fatalf("unexpected number of entries for the first url; got %d; want 1", n)
+	}
+	if n := len(dlq2.entries()); n != 0 {
+		t.Fatalf("unexpected number of entries for the second url; got %d; want 0", n)
+	}
+}
@@ -0,0 +1,591 @@
+package remotewrite
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"io"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/valyala/quicktemplate"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/decimal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding/zstd"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/persistentqueue"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promrelabel"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/timerpool"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/timeutil"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+var (
+	fileFormat = flagutil.NewArrayString("remoteWrite.file.format", "Format for the data written to the corresponding -remoteWrite.url=file:///path/to/dir . "+
+		"Supported values: native, jsonline. The native format is used by default. "+
+		"See https://docs.victoriametrics.com/victoriametrics/vmagent/#writing-data-to-local-files")
+	fileMaxSize = flagutil.NewArrayBytes("remoteWrite.file.maxSize", 128*1024*1024, "The maximum size of uncompressed data written to a single file "+
+		"at the corresponding -remoteWrite.url=file:///path/to/dir . The file is rotated when its size exceeds this value. See also -remoteWrite.file.rotationInterval")
+	fileRotationInterval = flagutil.NewArrayDuration("remoteWrite.file.rotationInterval", time.Hour, "The maximum duration for writing to a single file "+
+		"at the corresponding -remoteWrite.url=file:///path/to/dir . The file is rotated after this duration. See also -remoteWrite.file.maxSize")
+	fileCompress = flagutil.NewArrayBool("remoteWrite.file.compress", "Whether to compress files written to the corresponding "+
+		"-remoteWrite.url=file:///path/to/dir with zstd")
+	fileMaxTotalSize = flagutil.NewArrayBytes("remoteWrite.file.maxTotalSize", 0, "The maximum total size of files at the corresponding "+
+		"-remoteWrite.url=file:///path/to/dir . The oldest files are deleted when the total size exceeds this value. "+
+		"Disk usage is unlimited if the value is set to 0")
+)
+
+const (
+	fileFormatNative   = "native"
+	fileFormatJSONLine = "jsonline"
+
+	// fileTmpSuffix is added to the name of the file, which is currently written.
+	// It is removed when the file is rotated, so only complete files are visible to readers.
+	fileTmpSuffix = ".tmp"
+)
+
+func newFileClient(argIdx int, remoteWriteURL *url.URL, sanitizedURL string, fq *persistentqueue.FastQueue) *client {
+	if remoteWriteURL.Host != "" {
+		logger.Fatalf("unexpected host in -remoteWrite.url=%q; the url must be in the form file:///path/to/dir", sanitizedURL)
+	}
+	if remoteWriteURL.Path == "" {
+		logger.Fatalf("missing directory path in -remoteWrite.url=%q; the url must be in the form file:///path/to/dir", sanitizedURL)
+	}
+	format := fileFormat.GetOptionalArg(argIdx)
+	if format == "" {
+		format = fileFormatNative
+	}
+	if format != fileFormatNative && format != fileFormatJSONLine {
+		logger.Fatalf("unsupported -remoteWrite.file.format=%q for -remoteWrite.url=%q; supported values: %s, %s", format, sanitizedURL, fileFormatNative, fileFormatJSONLine)
+	}
+	fw := newFileWriter(remoteWriteURL.Path, sanitizedURL, format, fileCompress.GetOptionalArg(argIdx),
+		fileMaxSize.GetOptionalArg(argIdx), fileRotationInterval.GetOptionalArg(argIdx), fileMaxTotalSize.GetOptionalArg(argIdx))
+
+	retryMaxIntervalFlag := retryMaxTime
+	if retryMaxInterval.String() != "" {
+		retryMaxIntervalFlag = retryMaxInterval
+	}
+	c := &client{
+		sanitizedURL:     sanitizedURL,
+		remoteWriteURL:   remoteWriteURL.String(),
+		fq:               fq,
+		fw:               fw,
+		retryMinInterval: retryMinInterval.GetOptionalArg(argIdx),
+		retryMaxInterval: retryMaxIntervalFlag.GetOptionalArg(argIdx),
+		stopCh:           make(chan struct{}),
+	}
+	c.sendBlock = c.sendBlockFile
+
+	// Blocks are re-encoded before writing them to files, so use the cheaper VictoriaMetrics remote write protocol for the queue.
+	c.useVMProto.Store(true)
+
+	return c
+}
+
+// sendBlockFile writes the given block to files at c.fw.
+//
+// The function returns false only if c.stopCh is closed.
+// Otherwise, it tries writing the block indefinitely.
+func (c *client) sendBlockFile(block []byte) bool {
+	c.rl.Register(len(block))
+	maxRetryDuration := timeutil.AddJitterToDuration(c.retryMaxInterval)
+	retryDuration := timeutil.AddJitterToDuration(c.retryMinInterval)
+
+	bb := fileBlockBufPool.Get()
+	defer fileBlockBufPool.Put(bb)
+
+	var err error
+	bb.B, err = c.fw.marshalBlock(bb.B[:0], block)
+	if err != nil {
+		// The block cannot be decoded, so there is no sense in retrying it.
+		remoteWriteRejectedLogger.Errorf("cannot decode a block with size %d bytes for %q (skipping the block): %s", len(block), c.sanitizedURL, err)
+		c.packetsDropped.Inc()
+		return true
+	}
+
+	for {
+		startTime := time.Now()
+		err := c.fw.write(bb.B)
+		c.requestDuration.UpdateDuration(startTime)
+		if err == nil {
+			c.bytesSent.Add(len(block))
+			c.blocksSent.Inc()
+			return true
+		}
+
+		c.errorsCount.Inc()
+		retryDuration *= 2
+		if retryDuration > maxRetryDuration {
+			retryDuration = maxRetryDuration
+		}
+		remoteWriteRetryLogger.Warnf("couldn't write a block with size %d bytes to %q: %s; re-writing the block in %.3f seconds",
+			len(block), c.sanitizedURL, err, retryDuration.Seconds())
+		t := timerpool.Get(retryDuration)
+		select {
+		case <-c.stopCh:
+			timerpool.Put(t)
+			return false
+		case <-t.C:
+			timerpool.Put(t)
+		}
+		c.retriesCount.Inc()
+	}
+}
+
+var fileBlockBufPool bytesutil.ByteBufferPool
+
+// fileWriter writes blocks to size- and time-rotated files at the given directory.
+type fileWriter struct {
+	dir          string
+	sanitizedURL string
+	format       string
+	compress     bool
+
+	maxFileSize      int64
+	rotationInterval time.Duration
+	maxTotalSize     int64
+
+	// mu protects the fields below.
+	mu sync.Mutex
+
+	// f is the currently written file. It is nil if there is no opened file.
+	f         *os.File
+	path      string
+	size      int64
+	bw        *bufio.Writer
+	zw        *zstd.Writer
+	createdAt time.Time
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+
+	filesCreated *metrics.Counter
+	filesDeleted *metrics.Counter
+	bytesWritten *metrics.Counter
+}
+
+func newFileWriter(dir, sanitizedURL, format string, compress bool, maxFileSize int64, rotationInterval time.Duration, maxTotalSize int64) *fileWriter {
+	fs.MustMkdirIfNotExist(dir)
+
+	fw := &fileWriter{
+		dir:          dir,
+		sanitizedURL: sanitizedURL,
+		format:       format,
+		compress:     compress,
+
+		maxFileSize:      maxFileSize,
+		rotationInterval: rotationInterval,
+		maxTotalSize:     maxTotalSize,
+
+		stopCh: make(chan struct{}),
+
+		filesCreated: metrics.GetOrCreateCounter(fmt.Sprintf(`vmagent_remotewrite_file_created_total{url=%q}`, sanitizedURL)),
+		filesDeleted: metrics.GetOrCreateCounter(fmt.Sprintf(`vmagent_remotewrite_file_deleted_total{url=%q}`, sanitizedURL)),
+		bytesWritten: metrics.GetOrCreateCounter(fmt.Sprintf(`vmagent_remotewrite_file_written_bytes_total{url=%q}`, sanitizedURL)),
+	}
+	_ = metrics.GetOrCreateGauge(fmt.Sprintf(`vmagent_remotewrite_file_total_size_bytes{url=%q}`, sanitizedURL), func() float64 {
+		return float64(fw.getTotalSize())
+	})
+
+	fw.finalizeTmpFiles()
+
+	fw.wg.Add(1)
+	go func() {
+		defer fw.wg.Done()
+		fw.periodicFlusher()
+	}()
+	return fw
+}
+
+// finalizeTmpFiles makes visible files left after unclean shutdown.
+func (fw *fileWriter) finalizeTmpFiles() {
+	for _, de := range fs.MustReadDir(fw.dir) {
+		name := de.Name()
+		if !de.Type().IsRegular() || !strings.HasSuffix(name, fileTmpSuffix) {
+			continue
+		}
+		tmpPath := filepath.Join(fw.dir, name)
+		path := strings.TrimSuffix(tmpPath, fileTmpSuffix)
+		logger.Warnf("finalizing file %q left after unclean shutdown; the file may contain incomplete data at its end", tmpPath)
+		if err := os.Rename(tmpPath, path); err != nil {
+			logger.Panicf("FATAL: cannot rename %q to %q: %s", tmpPath, path, err)
+		}
+	}
+}
+
+func (fw *fileWriter) periodicFlusher() {
+	d := timeutil.AddJitterToDuration(time.Second)
+	ticker := time.NewTicker(d)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-fw.stopCh:
+			return
+		case <-ticker.C:
+		}
+		fw.mu.Lock()
+		if fw.f != nil {
+			if time.Since(fw.createdAt) >= fw.rotationInterval {
+				fw.mustCloseFileLocked()
+			} else if err := fw.flushLocked(); err != nil {
+				logger.Errorf("cannot flush data to %q: %s", fw.path, err)
+			}
+		}
+		fw.mu.Unlock()
+	}
+}
+
+// MustStop stops fw and closes the currently written file.
+func (fw *fileWriter) MustStop() {
+	close(fw.stopCh)
+	fw.wg.Wait()
+
+	fw.mu.Lock()
+	if fw.f != nil {
+		fw.mustCloseFileLocked()
+	}
+	fw.mu.Unlock()
+}
+
+// marshalBlock appends the block sent by pendingSeries to dst in fw.format and returns the result.
+func (fw *fileWriter) marshalBlock(dst, block []byte) ([]byte, error) {
+	bb := fileBlockBufPool.Get()
+	defer fileBlockBufPool.Put(bb)
+
+	var err error
+	if encoding.IsZstd(block) {
+		bb.B, err = zstd.Decompress(bb.B[:0], block)
+	} else {
+		bb.B, err = snappy.Decode(bb.B[:cap(bb.B)], block)
+	}
+	if err != nil {
+		return dst, fmt.Errorf("cannot decompress block: %w", err)
+	}
+
+	wru := getWriteRequestUnmarshaller()
+	defer putWriteRequestUnmarshaller(wru)
+
+	wr, err := wru.UnmarshalProtobuf(bb.B)
+	if err != nil {
+		return dst, fmt.Errorf("cannot unmarshal block: %w", err)
+	}
+	switch fw.format {
+	case fileFormatNative:
+		ctx := getNativeMarshalCtx()
+		for i := range wr.Timeseries {
+			dst = ctx.appendTimeSeries(dst, &wr.Timeseries[i])
+		}
+		putNativeMarshalCtx(ctx)
+	case fileFormatJSONLine:
+		for i := range wr.Timeseries {
+			dst = appendJSONLineTimeSeries(dst, &wr.Timeseries[i])
+		}
+	default:
+		logger.Panicf("BUG: unexpected file format: %q", fw.format)
+	}
+	return dst, nil
+}
+
+// write writes data obtained via marshalBlock to the current file.
+//
+// The file is rotated if needed.
+func (fw *fileWriter) write(data []byte) error {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	if fw.f != nil && (fw.size >= fw.maxFileSize || time.Since(fw.createdAt) >= fw.rotationInterval) {
+		fw.mustCloseFileLocked()
+	}
+	if fw.f == nil {
+		if err := fw.openFileLocked(); err != nil {
+			return err
+		}
+	}
+	if _, err := fw.getWriterLocked().Write(data); err != nil {
+		return fmt.Errorf("cannot write %d bytes to %q: %w", len(data), fw.path, err)
+	}
+	fw.size += int64(len(data))
+	fw.bytesWritten.Add(len(data))
+	return nil
+}
+
+func (fw *fileWriter) openFileLocked() error {
+	now := time.Now().UTC()
+	name := fmt.Sprintf("%s_%09d.%s", now.Format("20060102T150405"), now.Nanosecond(), fw.format)
+	if fw.compress {
+		name += ".zst"
+	}
+	path := filepath.Join(fw.dir, name)
+	f, err := os.OpenFile(path+fileTmpSuffix, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("cannot create file: %w", err)
+	}
+	fw.f = f
+	fw.path = path
+	fw.createdAt = now
+	fw.size = 0
+	fw.bw = bufio.NewWriterSize(f, 64*1024)
+	if fw.compress {
+		fw.zw = zstd.NewWriterLevel(fw.bw, 1)
+	}
+	fw.filesCreated.Inc()
+
+	if fw.format == fileFormatNative {
+		// Every native file starts with the time range for the stored blocks.
+		// Use the widest time range, so all the written samples are imported.
+		hdr := encoding.MarshalInt64(nil, math.MinInt64)
+		hdr = encoding.MarshalInt64(hdr, math.MaxInt64)
+		if _, err := fw.getWriterLocked().Write(hdr); err != nil {
+			fw.mustCloseFileLocked()
+			return fmt.Errorf("cannot write native header to %q: %w", path, err)
+		}
+	}
+	return nil
+}
+
+func (fw *fileWriter) getWriterLocked() io.Writer {
+	if fw.zw != nil {
+		return fw.zw
+	}
+	return fw.bw
+}
+
+func (fw *fileWriter) flushLocked() error {
+	if fw.zw != nil {
+		if err := fw.zw.Flush(); err != nil {
+			return err
+		}
+	}
+	return fw.bw.Flush()
+}
+
+// mustCloseFileLocked closes the currently written file, makes it visible to readers
+// and removes the oldest files if their total size exceeds fw.maxTotalSize.
+func (fw *fileWriter) mustCloseFileLocked() {
+	if fw.zw != nil {
+		if err := fw.zw.Close(); err != nil {
+			logger.Errorf("cannot finish zstd stream at %q: %s", fw.path, err)
+		}
+		fw.zw.Release()
+		fw.zw = nil
+	}
+	if err := fw.bw.Flush(); err != nil {
+		logger.Errorf("cannot flush data to %q: %s", fw.path, err)
+	}
+	fw.bw = nil
+	if err := fw.f.Sync(); err != nil {
+		logger.Errorf("cannot sync %q: %s", fw.path, err)
+	}
+	fs.MustClose(fw.f)
+	fw.f = nil
+
+	tmpPath := fw.path + fileTmpSuffix
+	if err := os.Rename(tmpPath, fw.path); err != nil {
+		logger.Panicf("FATAL: cannot rename %q to %q: %s", tmpPath, fw.path, err)
+	}
+	fs.MustSyncPath(fw.dir)
+
+	fw.removeOldFiles()
+}
+
+// removeOldFiles removes the oldest files at fw.dir until their total size becomes smaller than fw.maxTotalSize.
+func (fw *fileWriter) removeOldFiles() {
+	if fw.maxTotalSize <= 0 {
+		return
+	}
+	files, totalSize := fw.listFiles()
+	for len(files) > 0 && totalSize > fw.maxTotalSize {
+		path := filepath.Join(fw.dir, files[0].name)
+		fs.MustRemovePath(path)
+		totalSize -= files[0].size
+		files = files[1:]
+		fw.filesDeleted.Inc()
+		logger.Infof("removed %q from -remoteWrite.url=%q, since the total size of files exceeds -remoteWrite.file.maxTotalSize=%d", path, fw.sanitizedURL, fw.maxTotalSize)
+	}
+}
+
+type fileInfo struct {
+	name string
+	size int64
+}
+
+// listFiles returns finished files at fw.dir sorted by creation time together with their total size.
+func (fw *fileWriter) listFiles() ([]fileInfo, int64) {
+	var files []fileInfo
+	var totalSize int64
+	for _, de := range fs.MustReadDir(fw.dir) {
+		name := de.Name()
+		if !de.Type().IsRegular() || strings.HasSuffix(name, fileTmpSuffix) {
+			continue
+		}
+		fi, err := de.Info()
+		if err != nil {
+			// The file may be removed concurrently.
+			continue
+		}
+		files = append(files, fileInfo{
+			name: name,
+			size: fi.Size(),
+		})
+		totalSize += fi.Size()
+	}
+	// File names start with the creation timestamp, so the lexicographical order matches the creation order.
+	slices.SortFunc(files, func(a, b fileInfo) int {
+		return strings.Compare(a.name, b.name)
+	})
+	return files, totalSize
+}
+
+func (fw *fileWriter) getTotalSize() int64 {
+	_, totalSize := fw.listFiles()
+	return totalSize
+}
+
+type nativeMarshalCtx struct {
+	labels        []prompb.Label
+	mn            storage.MetricName
+	metricNameBuf []byte
+
+	timestamps []int64
+	values     []float64
+	mantissas  []int64
+	block      storage.Block
+	blockBuf   []byte
+}
+
+// appendTimeSeries appends ts to dst in the format accepted by /api/v1/import/native and returns the result.
+func (ctx *nativeMarshalCtx) appendTimeSeries(dst []byte, ts *prompb.TimeSeries) []byte {
+	if len(ts.Samples) == 0 {
+		return dst
+	}
+
+	// MetricName.Marshal expects sorted labels.
+	ctx.labels = append(ctx.labels[:0], ts.Labels...)
+	promrelabel.SortLabels(ctx.labels)
+	mn := &ctx.mn
+	mn.Reset()
+	for _, label := range ctx.labels {
+		mn.AddTag(label.Name, label.Value)
+	}
+	ctx.metricNameBuf = mn.Marshal(ctx.metricNameBuf[:0])
+
+	// Block.Init expects samples sorted by timestamp.
+	samples := ts.Samples
+	if !slices.IsSortedFunc(samples, compareSamplesByTimestamp) {
+		samples = slices.Clone(samples)
+		slices.SortStableFunc(samples, compareSamplesByTimestamp)
+	}
+	timestamps := ctx.timestamps[:0]
+	values := ctx.values[:0]
+	for _, s := range samples {
+		timestamps = append(timestamps, s.Timestamp)
+		values = append(values, s.Value)
+	}
+	ctx.timestamps = timestamps
+	ctx.values = values
+
+	var scale int16
+	ctx.mantissas, scale = decimal.AppendFloatToDecimal(ctx.mantissas[:0], values)
+	var tsid storage.TSID
+	ctx.block.Init(&tsid, timestamps, ctx.mantissas, scale, 64)
+	ctx.blockBuf = ctx.block.MarshalPortable(ctx.blockBuf[:0])
+
+	dst = encoding.MarshalUint32(dst, uint32(len(ctx.metricNameBuf)))
+	dst = append(dst, ctx.metricNameBuf...)
+	dst = encoding.MarshalUint32(dst, uint32(len(ctx.blockBuf)))
+	dst = append(dst, ctx.blockBuf...)
+	return dst
+}
+
+func compareSamplesByTimestamp(a, b prompb.Sample) int {
+	return cmp.Compare(a.Timestamp, b.Timestamp)
+}
+
+var nativeMarshalCtxPool sync.Pool
+
+func getNativeMarshalCtx() *nativeMarshalCtx {
+	v := nativeMarshalCtxPool.Get()
+	if v == nil {
+		return &nativeMarshalCtx{}
+	}
+	return v.(*nativeMarshalCtx)
+}
+
+func putNativeMarshalCtx(ctx *nativeMarshalCtx) {
+	clear(ctx.labels)
+	ctx.labels = ctx.labels[:0]
+	ctx.mn.Reset()
+	nativeMarshalCtxPool.Put(ctx)
+}
+
+// appendJSONLineTimeSeries appends ts to dst in the format accepted by /api/v1/import and returns the result.
+//
+// NaN values, including staleness markers, are written as null, since JSON doesn't support NaN.
+func appendJSONLineTimeSeries(dst []byte, ts *prompb.TimeSeries) []byte {
+	if len(ts.Samples) == 0 {
+		return dst
+	}
+	dst = append(dst, `{"metric":{`...)
+	for i, label := range ts.Labels {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		dst = quicktemplate.AppendJSONString(dst, label.Name, true)
+		dst = append(dst, ':')
+		dst = quicktemplate.AppendJSONString(dst, label.Value, true)
+	}
+	dst = append(dst, `},"values":[`...)
+	for i, s := range ts.Samples {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		switch {
+		case math.IsNaN(s.Value):
+			dst = append(dst, "null"...)
+		case math.IsInf(s.Value, 1):
+			dst = append(dst, `"Infinity"`...)
+		case math.IsInf(s.Value, -1):
+			dst = append(dst, `"-Infinity"`...)
+		default:
+			dst = strconv.AppendFloat(dst, s.Value, 'g', -1, 64)
+		}
+	}
+	dst = append(dst, `],"timestamps":[`...)
+	for i, s := range ts.Samples {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		dst = strconv.AppendInt(dst, s.Timestamp, 10)
+	}
+	dst = append(dst, "]}\n"...)
+	return dst
+}
+
+var writeRequestUnmarshallerPool sync.Pool
+
+func getWriteRequestUnmarshaller() *prompb.WriteRequestUnmarshaller {
+	v := writeRequestUnmarshallerPool.Get()
+	if v == nil {
+		return &prompb.WriteRequestUnmarshaller{}
+	}
+	return v.(*prompb.WriteRequestUnmarshaller)
+}
+
+func putWriteRequestUnmarshaller(wru *prompb.WriteRequestUnmarshaller) {
+	wru.Reset()
+	writeRequestUnmarshallerPool.Put(wru)
+}
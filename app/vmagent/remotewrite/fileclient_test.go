@@ -0,0 +1,139 @@
+package remotewrite
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/native/stream"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/protoparserutil"
+)
+
+func TestAppendJSONLineTimeSeries(t *testing.T) {
+	f := func(ts *prompb.TimeSeries, resultExpected string) {
+		t.Helper()
+
+		result := appendJSONLineTimeSeries(nil, ts)
+		if string(result) != resultExpected {
+			t.Fatalf("unexpected result\ngot\n%s\nwant\n%s", result, resultExpected)
+		}
+	}
+
+	// empty samples
+	f(&prompb.TimeSeries{
+		Labels: []prompb.Label{{Name: "__name__", Value: "foo"}},
+	}, "")
+
+	// regular samples
+	f(&prompb.TimeSeries{
+		Labels: []prompb.Label{
+			{Name: "__name__", Value: "foo"},
+			{Name: "job", Value: `a"b`},
+		},
+		Samples: []prompb.Sample{
+			{Value: 1.5, Timestamp: 1000},
+			{Value: math.NaN(), Timestamp: 2000},
+			{Value: math.Inf(-1), Timestamp: 3000},
+		},
+	}, `{"metric":{"__name__":"foo","job":"a\"b"},"values":[1.5,null,"-Infinity"],"timestamps":[1000,2000,3000]}`+"\n")
+}
+
+func TestFileWriter(t *testing.T) {
+	protoparserutil.StartUnmarshalWorkers()
+	defer protoparserutil.StopUnmarshalWorkers()
+
+	wr := &prompb.WriteRequest{
+		Timeseries: []prompb.TimeSeries{
+			{
+				Labels: []prompb.Label{
+					{Name: "job", Value: "bar"},
+					{Name: "__name__", Value: "foo"},
+				},
+				Samples: []prompb.Sample{
+					{Value: 2, Timestamp: 2000},
+					{Value: 1, Timestamp: 1000},
+				},
+			},
+			{
+				Labels: []prompb.Label{
+					{Name: "__name__", Value: "baz"},
+				},
+				Samples: []prompb.Sample{
+					{Value: 3.25, Timestamp: 3000},
+				},
+			},
+		},
+	}
+	block := snappy.Encode(nil, wr.MarshalProtobuf(nil))
+
+	f := func(format string, compress bool) {
+		t.Helper()
+
+		dir := filepath.Join(t.TempDir(), "data")
+		fw := newFileWriter(dir, "test", format, compress, 1, time.Hour, 0)
+		for i := 0; i < 2; i++ {
+			data, err := fw.marshalBlock(nil, block)
+			if err != nil {
+				t.Fatalf("unexpected error when marshaling block: %s", err)
+			}
+			if err := fw.write(data); err != nil {
+				t.Fatalf("unexpected error when writing block: %s", err)
+			}
+		}
+		fw.MustStop()
+
+		// Every write must be stored in a separate file, since maxFileSize=1
+		files, _ := fw.listFiles()
+		if len(files) != 2 {
+			t.Fatalf("unexpected number of files; got %d; want 2", len(files))
+		}
+		for _, fi := range files {
+			data, err := os.ReadFile(filepath.Join(dir, fi.name))
+			if err != nil {
+				t.Fatalf("cannot read %q: %s", fi.name, err)
+			}
+			contentEncoding := ""
+			if compress {
+				contentEncoding = "zstd"
+			}
+			switch format {
+			case fileFormatNative:
+				var mu sync.Mutex
+				rows := 0
+				err := stream.Parse(bytes.NewReader(data), contentEncoding, func(block *stream.Block) error {
+					mu.Lock()
+					rows += len(block.Timestamps)
+					mu.Unlock()
+					return nil
+				})
+				if err != nil {
+					t.Fatalf("cannot parse native file %q: %s", fi.name, err)
+				}
+				if rows != 3 {
+					t.Fatalf("unexpected number of rows in %q; got %d; want 3", fi.name, rows)
+				}
+			case fileFormatJSONLine:
+				if compress {
+					return
+				}
+				dataExpected := `{"metric":{"job":"bar","__name__":"foo"},"values":[2,1],"timestamps":[2000,1000]}` + "\n" +
+					`{"metric":{"__name__":"baz"},"values":[3.25],"timestamps":[3000]}` + "\n"
+				if string(data) != dataExpected {
+					t.Fatalf("unexpected data in %q\ngot\n%s\nwant\n%s", fi.name, data, dataExpected)
+				}
+			}
+		}
+	}
+
+	f(fileFormatNative, false)
+	f(fileFormatNative, true)
+	f(fileFormatJSONLine, false)
+	f(fileFormatJSONLine, true)
+}
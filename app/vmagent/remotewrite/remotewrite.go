@@ -37,7 +37,9 @@ var (
 	remoteWriteURLs = flagutil.NewArrayString("remoteWrite.url", "Remote storage URL to write data to. It must support either VictoriaMetrics remote write protocol "+
 		"or Prometheus remote_write protocol. Example url: http://<victoriametrics-host>:8428/api/v1/write . "+
 		"Pass multiple -remoteWrite.url options in order to replicate the collected data to multiple remote storage systems. "+
-		"The data can be sharded among the configured remote storage systems if -remoteWrite.shardByURL flag is set")
+		"The data can be sharded among the configured remote storage systems if -remoteWrite.shardByURL flag is set. "+
+		"The data can be written to local files instead of remote storage by passing file:///path/to/dir url. "+
+		"See https://docs.victoriametrics.com/victoriametrics/vmagent/#writing-data-to-local-files")
 	enableMultitenantHandlers = flag.Bool("enableMultitenantHandlers", false, "Whether to process incoming data via multitenant insert handlers according to "+
 		"https://docs.victoriametrics.com/victoriametrics/cluster-victoriametrics/#url-format . By default incoming data is processed via single-node insert handlers "+
 		"according to https://docs.victoriametrics.com/victoriametrics/single-server-victoriametrics/#how-to-import-time-series-data ."+
@@ -198,6 +200,8 @@ func Init() {
 
 	initRelabelConfigs()
 
+	initTenantQuotas()
+
 	initStreamAggrConfigGlobal()
 
 	initRemoteWriteCtxs(*remoteWriteURLs)
@@ -224,6 +228,7 @@ func Init() {
 			case <-sighupCh:
 			}
 			reloadRelabelConfigs()
+			reloadTenantQuotas()
 			reloadStreamAggrConfigs()
 		}
 	}()
@@ -365,6 +370,7 @@ func Stop() {
 	if sl := dailySeriesLimiter; sl != nil {
 		sl.MustStop()
 	}
+	stopTenantQuotas()
 }
 
 // PushDropSamplesOnFailure pushes wr to the configured remote storage systems set via -remoteWrite.url
@@ -440,8 +446,6 @@ func tryPush(at *auth.Token, wr *prompb.WriteRequest, forceDropSamplesOnFailure
 			}
 		}
 
-		ingestionRateLimiter.Register(samplesCount)
-
 		tssBlock := tss
 		if i < len(tss) {
 			tssBlock = tss[:i]
@@ -449,6 +453,7 @@ func tryPush(at *auth.Token, wr *prompb.WriteRequest, forceDropSamplesOnFailure
 		} else {
 			tss = nil
 		}
+		tssBlock = limitIngestionRate(at, tssBlock, samplesCount)
 		if tenantRctx != nil {
 			tenantRctx.tenantToLabels(tssBlock, at.AccountID, at.ProjectID)
 		}
@@ -708,6 +713,19 @@ func putTSSShards(x *tssShards) {
 var tssShardsPool sync.Pool
 
 // sortLabelsIfNeeded sorts labels if -sortLabels command-line flag is set.
+// limitIngestionRate applies per-tenant quotas to tss and then registers the remaining samplesCount samples at -maxIngestionRate limiter.
+//
+// Tenant quotas are applied first, so samples dropped by them do not consume the global ingestion rate shared among all the tenants.
+func limitIngestionRate(at *auth.Token, tss []prompb.TimeSeries, samplesCount int) []prompb.TimeSeries {
+	seriesCount := len(tss)
+	tss = applyTenantQuotas(at, tss)
+	if len(tss) != seriesCount {
+		samplesCount = getRowsCount(tss)
+	}
+	ingestionRateLimiter.Register(samplesCount)
+	return tss
+}
+
 func sortLabelsIfNeeded(tss []prompb.TimeSeries) {
 	if !*sortLabels {
 		return
@@ -834,8 +852,10 @@ func newRemoteWriteCtx(argIdx int, remoteWriteURL *url.URL, maxInmemoryBlocks in
 	switch remoteWriteURL.Scheme {
 	case "http", "https":
 		c = newHTTPClient(argIdx, remoteWriteURL.String(), sanitizedURL, fq, *queues)
+	case "file":
+		c = newFileClient(argIdx, remoteWriteURL, sanitizedURL, fq)
 	default:
-		logger.Fatalf("unsupported scheme: %s for remoteWriteURL: %s, want `http`, `https`", remoteWriteURL.Scheme, sanitizedURL)
+		logger.Fatalf("unsupported scheme: %s for remoteWriteURL: %s, want `http`, `https`, `file`", remoteWriteURL.Scheme, sanitizedURL)
 	}
 	c.init(argIdx, *queues, sanitizedURL)
 
@@ -0,0 +1,384 @@
+package remotewrite
+
+import (
+	"flag"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/auth"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bloomfilter"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/envtemplate"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs/fscore"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/ratelimiter"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/tenantmetrics"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+var tenantQuotasConfigPath = flag.String("remoteWrite.tenantQuotasConfig", "", "Optional path to file with per-tenant ingestion quotas. "+
+	"The quotas are applied to data received via multitenant insert handlers when -enableMultitenantHandlers is set. "+
+	"The path can point either to local file or to http url. See https://docs.victoriametrics.com/victoriametrics/vmagent/#per-tenant-ingestion-quotas")
+
+// tenantQuota contains ingestion limits for a single tenant.
+//
+// Zero value for a limit means the limit is disabled.
+type tenantQuota struct {
+	MaxSamplesPerSecond int `yaml:"max_samples_per_second,omitempty"`
+	MaxHourlySeries     int `yaml:"max_hourly_series,omitempty"`
+	MaxDailySeries      int `yaml:"max_daily_series,omitempty"`
+}
+
+// tenantQuotasConfig represents -remoteWrite.tenantQuotasConfig file contents.
+type tenantQuotasConfig struct {
+	// Default is applied individually to every tenant missing in Tenants.
+	Default *tenantQuota `yaml:"default,omitempty"`
+
+	// Tenants contains quotas keyed by tenant in the form `accountID[:projectID]`.
+	Tenants map[string]*tenantQuota `yaml:"tenants,omitempty"`
+}
+
+// tenantQuotas applies per-tenant ingestion quotas.
+type tenantQuotas struct {
+	defaultQuota *tenantQuota
+	quotas       map[auth.Token]*tenantQuota
+
+	// mu protects limiters and lastCleanupTime
+	mu       sync.Mutex
+	limiters map[auth.Token]*tenantLimiter
+
+	// lastCleanupTime is the last time in unix seconds when idle limiters were removed from limiters.
+	lastCleanupTime uint64
+}
+
+// tenantLimiter applies tenantQuota to a single tenant.
+type tenantLimiter struct {
+	quota tenantQuota
+
+	rl            *ratelimiter.RateLimiter
+	rlStopCh      chan struct{}
+	hourlyLimiter *bloomfilter.Limiter
+	dailyLimiter  *bloomfilter.Limiter
+
+	// lastAccessTime is the last time in unix seconds when the limiter has been obtained via getLimiter.
+	//
+	// It is protected by tenantQuotas.mu.
+	lastAccessTime uint64
+}
+
+var (
+	tenantQuotasGlobal atomic.Pointer[tenantQuotas]
+
+	tenantRowsDroppedByRateLimit   = tenantmetrics.NewCounterMap(`vmagent_tenant_quota_rows_dropped_total{reason="max_samples_per_second"}`)
+	tenantRowsDroppedByHourlyLimit = tenantmetrics.NewCounterMap(`vmagent_tenant_quota_rows_dropped_total{reason="max_hourly_series"}`)
+	tenantRowsDroppedByDailyLimit  = tenantmetrics.NewCounterMap(`vmagent_tenant_quota_rows_dropped_total{reason="max_daily_series"}`)
+	tenantRateLimitReached         = tenantmetrics.NewCounterMap(`vmagent_tenant_quota_rate_limit_reached_total`)
+)
+
+var (
+	tenantQuotasConfigReloads      = metrics.NewCounter(`vmagent_tenant_quotas_config_reloads_total`)
+	tenantQuotasConfigReloadErrors = metrics.NewCounter(`vmagent_tenant_quotas_config_reloads_errors_total`)
+	tenantQuotasConfigSuccess      = metrics.NewGauge(`vmagent_tenant_quotas_config_last_reload_successful`, nil)
+	tenantQuotasConfigTimestamp    = metrics.NewCounter(`vmagent_tenant_quotas_config_last_reload_success_timestamp_seconds`)
+)
+
+// CheckTenantQuotasConfig checks -remoteWrite.tenantQuotasConfig.
+func CheckTenantQuotasConfig() error {
+	_, err := loadTenantQuotas()
+	return err
+}
+
+func initTenantQuotas() {
+	tq, err := loadTenantQuotas()
+	if err != nil {
+		logger.Fatalf("cannot initialize tenant quotas: %s", err)
+	}
+	if tq == nil {
+		return
+	}
+	if !*enableMultitenantHandlers {
+		logger.Warnf("-remoteWrite.tenantQuotasConfig has no effect without -enableMultitenantHandlers")
+	}
+	tenantQuotasGlobal.Store(tq)
+	tenantQuotasConfigSuccess.Set(1)
+	tenantQuotasConfigTimestamp.Set(fasttime.UnixTimestamp())
+}
+
+func reloadTenantQuotas() {
+	if *tenantQuotasConfigPath == "" {
+		return
+	}
+	tenantQuotasConfigReloads.Inc()
+	logger.Infof("reloading tenant quotas pointed by -remoteWrite.tenantQuotasConfig=%q", *tenantQuotasConfigPath)
+	tq, err := loadTenantQuotas()
+	if err != nil {
+		tenantQuotasConfigReloadErrors.Inc()
+		tenantQuotasConfigSuccess.Set(0)
+		logger.Errorf("cannot reload tenant quotas; preserving the previous quotas; error: %s", err)
+		return
+	}
+	tqOld := tenantQuotasGlobal.Load()
+	tq.moveLimitersFrom(tqOld)
+	tenantQuotasGlobal.Store(tq)
+	tqOld.mustStop()
+	tenantQuotasConfigSuccess.Set(1)
+	tenantQuotasConfigTimestamp.Set(fasttime.UnixTimestamp())
+	logger.Infof("successfully reloaded tenant quotas")
+}
+
+func stopTenantQuotas() {
+	tq := tenantQuotasGlobal.Swap(nil)
+	tq.mustStop()
+}
+
+func loadTenantQuotas() (*tenantQuotas, error) {
+	path := *tenantQuotasConfigPath
+	if path == "" {
+		return nil, nil
+	}
+	data, err := fscore.ReadFileOrHTTP(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read -remoteWrite.tenantQuotasConfig=%q: %w", path, err)
+	}
+	data, err = envtemplate.ReplaceBytes(data)
+	if err != nil {
+		return nil, fmt.Errorf("cannot expand environment vars at -remoteWrite.tenantQuotasConfig=%q: %w", path, err)
+	}
+	tq, err := parseTenantQuotas(data)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse -remoteWrite.tenantQuotasConfig=%q: %w", path, err)
+	}
+	return tq, nil
+}
+
+func parseTenantQuotas(data []byte) (*tenantQuotas, error) {
+	var cfg tenantQuotasConfig
+	if err := yaml.UnmarshalStrict(data, &cfg); err != nil {
+		return nil, err
+	}
+	if err := cfg.Default.validate(); err != nil {
+		return nil, fmt.Errorf("invalid `default` quota: %w", err)
+	}
+	quotas := make(map[auth.Token]*tenantQuota, len(cfg.Tenants))
+	for tenant, q := range cfg.Tenants {
+		at, err := auth.NewToken(tenant)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse tenant %q: %w", tenant, err)
+		}
+		if _, ok := quotas[*at]; ok {
+			return nil, fmt.Errorf("duplicate quota for tenant %q", at)
+		}
+		if q == nil {
+			q = &tenantQuota{}
+		}
+		if err := q.validate(); err != nil {
+			return nil, fmt.Errorf("invalid quota for tenant %q: %w", tenant, err)
+		}
+		quotas[*at] = q
+	}
+	return &tenantQuotas{
+		defaultQuota: cfg.Default,
+		quotas:       quotas,
+		limiters:     make(map[auth.Token]*tenantLimiter),
+	}, nil
+}
+
+func (q *tenantQuota) validate() error {
+	if q == nil {
+		return nil
+	}
+	if q.MaxSamplesPerSecond < 0 {
+		return fmt.Errorf("max_samples_per_second cannot be negative; got %d", q.MaxSamplesPerSecond)
+	}
+	if q.MaxHourlySeries < 0 {
+		return fmt.Errorf("max_hourly_series cannot be negative; got %d", q.MaxHourlySeries)
+	}
+	if q.MaxDailySeries < 0 {
+		return fmt.Errorf("max_daily_series cannot be negative; got %d", q.MaxDailySeries)
+	}
+	return nil
+}
+
+func (q *tenantQuota) isEmpty() bool {
+	return q == nil || *q == tenantQuota{}
+}
+
+// getLimiter returns limiter for the given tenant.
+//
+// nil is returned if the tenant has no quota.
+func (tq *tenantQuotas) getLimiter(at *auth.Token) *tenantLimiter {
+	q := tq.getQuota(*at)
+	if q.isEmpty() {
+		// Do not cache tenants without quotas, since their number may be unbounded.
+		return nil
+	}
+
+	currentTime := fasttime.UnixTimestamp()
+
+	tq.mu.Lock()
+	defer tq.mu.Unlock()
+
+	if currentTime-tq.lastCleanupTime >= 60 {
+		tq.removeIdleLimitersLocked(currentTime)
+		tq.lastCleanupTime = currentTime
+	}
+	tl, ok := tq.limiters[*at]
+	if !ok {
+		tl = newTenantLimiter(at, q)
+		tq.limiters[*at] = tl
+	}
+	tl.lastAccessTime = currentTime
+	return tl
+}
+
+func (tq *tenantQuotas) getQuota(at auth.Token) *tenantQuota {
+	if q, ok := tq.quotas[at]; ok {
+		return q
+	}
+	return tq.defaultQuota
+}
+
+// removeIdleLimitersLocked stops and removes limiters, which weren't accessed for longer than their max idle duration.
+//
+// This prevents from unbounded growth of limiters when tenants are rotated over time.
+func (tq *tenantQuotas) removeIdleLimitersLocked(currentTime uint64) {
+	for at, tl := range tq.limiters {
+		if currentTime-tl.lastAccessTime > tl.getMaxIdleSeconds() {
+			tl.mustStop()
+			delete(tq.limiters, at)
+		}
+	}
+}
+
+// moveLimitersFrom moves limiters with unchanged quotas from src to tq,
+// so the already registered samples and series are preserved across config reloads.
+func (tq *tenantQuotas) moveLimitersFrom(src *tenantQuotas) {
+	if src == nil {
+		return
+	}
+	src.mu.Lock()
+	defer src.mu.Unlock()
+
+	for at, tl := range src.limiters {
+		q := tq.getQuota(at)
+		if q.isEmpty() || *q != tl.quota {
+			continue
+		}
+		tq.limiters[at] = tl
+		delete(src.limiters, at)
+	}
+}
+
+func (tq *tenantQuotas) mustStop() {
+	if tq == nil {
+		return
+	}
+	tq.mu.Lock()
+	defer tq.mu.Unlock()
+
+	for at, tl := range tq.limiters {
+		tl.mustStop()
+		delete(tq.limiters, at)
+	}
+}
+
+func newTenantLimiter(at *auth.Token, q *tenantQuota) *tenantLimiter {
+	tl := &tenantLimiter{
+		quota: *q,
+	}
+	if q.MaxSamplesPerSecond > 0 {
+		tl.rlStopCh = make(chan struct{})
+		tl.rl = ratelimiter.New(int64(q.MaxSamplesPerSecond), tenantRateLimitReached.Get(at), tl.rlStopCh)
+	}
+	if q.MaxHourlySeries > 0 {
+		tl.hourlyLimiter = bloomfilter.NewLimiter(q.MaxHourlySeries, time.Hour)
+	}
+	if q.MaxDailySeries > 0 {
+		tl.dailyLimiter = bloomfilter.NewLimiter(q.MaxDailySeries, 24*time.Hour)
+	}
+	return tl
+}
+
+// getMaxIdleSeconds returns the duration in seconds after which idle tl can be removed without losing its state.
+//
+// Series limiters are reset every hour or day, so they become empty after being idle for the reset interval.
+func (tl *tenantLimiter) getMaxIdleSeconds() uint64 {
+	if tl.dailyLimiter != nil {
+		return 24 * 3600
+	}
+	if tl.hourlyLimiter != nil {
+		return 3600
+	}
+	return 60
+}
+
+func (tl *tenantLimiter) mustStop() {
+	if tl.rlStopCh != nil {
+		close(tl.rlStopCh)
+	}
+	if tl.hourlyLimiter != nil {
+		tl.hourlyLimiter.MustStop()
+	}
+	if tl.dailyLimiter != nil {
+		tl.dailyLimiter.MustStop()
+	}
+}
+
+// applyTenantQuotas drops samples from tss, which exceed quotas for the given tenant.
+//
+// The samples are dropped instead of pausing the ingestion like -maxIngestionRate does,
+// so a noisy tenant cannot block data ingestion for the remaining tenants.
+//
+// tss contents may be modified in place.
+func applyTenantQuotas(at *auth.Token, tss []prompb.TimeSeries) []prompb.TimeSeries {
+	if at == nil {
+		return tss
+	}
+	tq := tenantQuotasGlobal.Load()
+	if tq == nil {
+		return tss
+	}
+	tl := tq.getLimiter(at)
+	if tl == nil {
+		return tss
+	}
+	if tl.rl != nil {
+		if rowsCount := getRowsCount(tss); !tl.rl.TryRegister(rowsCount) {
+			tenantRowsDroppedByRateLimit.Get(at).Add(rowsCount)
+			return tss[:0]
+		}
+	}
+	if tl.hourlyLimiter == nil && tl.dailyLimiter == nil {
+		return tss
+	}
+	dst := tss[:0]
+	for i := range tss {
+		labels := tss[i].Labels
+		h := getLabelsHash(labels)
+		if tl.hourlyLimiter != nil && !tl.hourlyLimiter.Add(h) {
+			tenantRowsDroppedByHourlyLimit.Get(at).Add(len(tss[i].Samples))
+			logSkippedTenantSeries(at, labels, "max_hourly_series", tl.hourlyLimiter.MaxItems())
+			continue
+		}
+		if tl.dailyLimiter != nil && !tl.dailyLimiter.Add(h) {
+			tenantRowsDroppedByDailyLimit.Get(at).Add(len(tss[i].Samples))
+			logSkippedTenantSeries(at, labels, "max_daily_series", tl.dailyLimiter.MaxItems())
+			continue
+		}
+		dst = append(dst, tss[i])
+	}
+	return dst
+}
+
+func logSkippedTenantSeries(at *auth.Token, labels []prompb.Label, quotaName string, quotaValue int) {
+	select {
+	case <-logSkippedSeriesTicker.C:
+		logger.Warnf("skip series %s for tenant %s because %s=%d quota reached", prompb.LabelsToString(labels), at, quotaName, quotaValue)
+	default:
+	}
+}
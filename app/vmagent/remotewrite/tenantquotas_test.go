@@ -0,0 +1,282 @@
+package remotewrite
+
+import (
+	"testing"
+
+	"github.com/VictoriaMetrics/metrics"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/auth"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/ratelimiter"
+)
+
+func TestParseTenantQuotasFailure(t *testing.T) {
+	f := func(data string) {
+		t.Helper()
+
+		if _, err := parseTenantQuotas([]byte(data)); err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+
+	// unknown field
+	f(`foo: bar`)
+	f(`
+default:
+  max_samples: 10
+`)
+
+	// invalid tenant
+	f(`
+tenants:
+  "foo": {max_samples_per_second: 10}
+`)
+
+	// duplicate tenant
+	f(`
+tenants:
+  "1": {max_samples_per_second: 10}
+  "1:0": {max_samples_per_second: 20}
+`)
+
+	// negative limits
+	f(`
+default:
+  max_samples_per_second: -1
+`)
+	f(`
+tenants:
+  "1:2": {max_hourly_series: -1}
+`)
+	f(`
+tenants:
+  "1:2": {max_daily_series: -1}
+`)
+}
+
+func TestParseTenantQuotasSuccess(t *testing.T) {
+	tq, err := parseTenantQuotas([]byte(`
+default:
+  max_samples_per_second: 100
+tenants:
+  "1:2":
+    max_hourly_series: 10
+    max_daily_series: 20
+  "3": {}
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer tq.mustStop()
+
+	f := func(tenant string, quotaExpected *tenantQuota) {
+		t.Helper()
+
+		at, err := auth.NewToken(tenant)
+		if err != nil {
+			t.Fatalf("cannot parse tenant %q: %s", tenant, err)
+		}
+		tl := tq.getLimiter(at)
+		if quotaExpected == nil {
+			if tl != nil {
+				t.Fatalf("unexpected limiter for tenant %q; got quota %+v", tenant, tl.quota)
+			}
+			return
+		}
+		if tl == nil {
+			t.Fatalf("missing limiter for tenant %q", tenant)
+		}
+		if tl.quota != *quotaExpected {
+			t.Fatalf("unexpected quota for tenant %q; got %+v; want %+v", tenant, tl.quota, *quotaExpected)
+		}
+	}
+
+	f("1:2", &tenantQuota{
+		MaxHourlySeries: 10,
+		MaxDailySeries:  20,
+	})
+	f("3:0", nil)
+	f("4:5", &tenantQuota{
+		MaxSamplesPerSecond: 100,
+	})
+}
+
+func TestApplyTenantQuotas(t *testing.T) {
+	tq, err := parseTenantQuotas([]byte(`
+tenants:
+  "1":
+    max_samples_per_second: 5
+  "2":
+    max_hourly_series: 2
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	tenantQuotasGlobal.Store(tq)
+	defer stopTenantQuotas()
+
+	newSeries := func(names ...string) []prompb.TimeSeries {
+		tss := make([]prompb.TimeSeries, 0, len(names))
+		for _, name := range names {
+			tss = append(tss, prompb.TimeSeries{
+				Labels: []prompb.Label{{Name: "__name__", Value: name}},
+				Samples: []prompb.Sample{
+					{Value: 1, Timestamp: 1000},
+					{Value: 2, Timestamp: 2000},
+				},
+			})
+		}
+		return tss
+	}
+	f := func(tenant string, tss []prompb.TimeSeries, seriesExpected int) {
+		t.Helper()
+
+		var at *auth.Token
+		if tenant != "" {
+			at, err = auth.NewToken(tenant)
+			if err != nil {
+				t.Fatalf("cannot parse tenant %q: %s", tenant, err)
+			}
+		}
+		result := applyTenantQuotas(at, tss)
+		if len(result) != seriesExpected {
+			t.Fatalf("unexpected number of series for tenant %q; got %d; want %d", tenant, len(result), seriesExpected)
+		}
+	}
+
+	// data without tenant isn't limited
+	f("", newSeries("a", "b", "c", "d"), 4)
+
+	// the rate limit for tenant 1 is exceeded by the second block
+	f("1:0", newSeries("a", "b", "c"), 3)
+	f("1:0", newSeries("a"), 0)
+
+	// tenant 2 cannot have more than 2 unique series
+	f("2:0", newSeries("a", "b", "c"), 2)
+	f("2:0", newSeries("a", "b"), 2)
+	f("2:0", newSeries("d"), 0)
+
+	// tenant 3 has no quotas
+	f("3:0", newSeries("a", "b", "c", "d"), 4)
+}
+
+func TestTenantQuotasRemoveIdleLimiters(t *testing.T) {
+	tq, err := parseTenantQuotas([]byte(`
+default:
+  max_samples_per_second: 100
+tenants:
+  "1": {max_hourly_series: 10}
+  "2": {max_daily_series: 10}
+  "3": {}
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer tq.mustStop()
+
+	getLimiter := func(tenant string) *tenantLimiter {
+		t.Helper()
+		at, err := auth.NewToken(tenant)
+		if err != nil {
+			t.Fatalf("cannot parse tenant %q: %s", tenant, err)
+		}
+		return tq.getLimiter(at)
+	}
+	for _, tenant := range []string{"1", "2", "3", "4"} {
+		getLimiter(tenant)
+	}
+
+	// tenants without quotas mustn't be cached
+	if n := len(tq.limiters); n != 3 {
+		t.Fatalf("unexpected number of limiters; got %d; want 3", n)
+	}
+
+	f := func(idleSeconds uint64, limitersExpected int) {
+		t.Helper()
+		var lastAccessTime uint64
+		for _, tl := range tq.limiters {
+			lastAccessTime = max(lastAccessTime, tl.lastAccessTime)
+		}
+		tq.mu.Lock()
+		tq.removeIdleLimitersLocked(lastAccessTime + idleSeconds)
+		n := len(tq.limiters)
+		tq.mu.Unlock()
+		if n != limitersExpected {
+			t.Fatalf("unexpected number of limiters after %d idle seconds; got %d; want %d", idleSeconds, n, limitersExpected)
+		}
+	}
+
+	f(30, 3)
+
+	// the limiter with max_samples_per_second only is removed after a minute
+	f(120, 2)
+
+	// the limiter with max_hourly_series is removed after an hour
+	f(2*3600, 1)
+
+	// the limiter with max_daily_series is removed after a day
+	f(48*3600, 0)
+}
+
+func TestLimitIngestionRateAppliesTenantQuotasFirst(t *testing.T) {
+	tq, err := parseTenantQuotas([]byte(`
+tenants:
+  "1":
+    max_samples_per_second: 5
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	tenantQuotasGlobal.Store(tq)
+	defer stopTenantQuotas()
+
+	// Closed stopCh prevents from blocking in Register() calls, while limitReached is still increased when the limit is exceeded.
+	limitReached := &metrics.Counter{}
+	stopCh := make(chan struct{})
+	close(stopCh)
+	rlOrig := ingestionRateLimiter
+	ingestionRateLimiter = ratelimiter.New(10, limitReached, stopCh)
+	defer func() {
+		ingestionRateLimiter = rlOrig
+	}()
+
+	newSeries := func(samplesCount int) []prompb.TimeSeries {
+		samples := make([]prompb.Sample, samplesCount)
+		return []prompb.TimeSeries{{
+			Labels:  []prompb.Label{{Name: "__name__", Value: "foo"}},
+			Samples: samples,
+		}}
+	}
+	f := func(tenant string, samplesCount, seriesExpected int) {
+		t.Helper()
+
+		var at *auth.Token
+		if tenant != "" {
+			at, err = auth.NewToken(tenant)
+			if err != nil {
+				t.Fatalf("cannot parse tenant %q: %s", tenant, err)
+			}
+		}
+		result := limitIngestionRate(at, newSeries(samplesCount), samplesCount)
+		if len(result) != seriesExpected {
+			t.Fatalf("unexpected number of series for tenant %q; got %d; want %d", tenant, len(result), seriesExpected)
+		}
+	}
+
+	// tenant 1 exhausts its quota
+	f("1:0", 5, 1)
+
+	// samples dropped by tenant 1 quota mustn't consume the global ingestion rate
+	f("1:0", 100, 0)
+	f("", 4, 1)
+	if n := limitReached.Get(); n != 0 {
+		t.Fatalf("unexpected number of reached global ingestion rate limits; got %d; want 0", n)
+	}
+
+	// the global ingestion rate is exceeded by samples without tenant quotas
+	f("", 100, 1)
+	f("", 1, 1)
+	if n := limitReached.Get(); n != 1 {
+		t.Fatalf("unexpected number of reached global ingestion rate limits; got %d; want 1", n)
+	}
+}
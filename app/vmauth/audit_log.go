@@ -0,0 +1,358 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+)
+
+var (
+	auditLogPath = flag.String("auditLog.path", "", "Optional path to file for writing audit log of requests proxied to backends in JSON lines format. "+
+		"Audit log is disabled by default. See https://docs.victoriametrics.com/victoriametrics/vmauth/#audit-log")
+	auditLogMaxFileSize = flagutil.NewBytes("auditLog.maxFileSize", 100*1024*1024, "The maximum size of the audit log file at -auditLog.path. "+
+		"The file is rotated when its size exceeds this value. See also -auditLog.maxFiles")
+	auditLogMaxFiles = flag.Int("auditLog.maxFiles", 10, "The maximum number of rotated audit log files to keep next to -auditLog.path. "+
+		"The oldest files are deleted when the number of rotated files exceeds this value. See also -auditLog.maxFileSize")
+	auditLogOnlyMutatingRequests = flag.Bool("auditLog.onlyMutatingRequests", false, "Whether to write to -auditLog.path only requests to mutating endpoints "+
+		"such as /api/v1/admin/tsdb/delete_series and requests with DELETE method. See https://docs.victoriametrics.com/victoriametrics/vmauth/#audit-log")
+	auditLogJWTUserClaim = flag.String("auditLog.jwtUserClaim", "sub", "The name of JWT claim, which value is written into user field at -auditLog.path for requests authorized with JWT tokens. "+
+		"Nested claims can be referred via dot-separated path such as parent.child. See https://docs.victoriametrics.com/victoriametrics/vmauth/#audit-log")
+	auditLogRedactQueryArgs = flagutil.NewArrayString("auditLog.redactQueryArgs", "Names of additional query args, which values must be redacted in -auditLog.path. "+
+		"Values for authKey, auth_key, password, token, access_token and secret query args are always redacted. See https://docs.victoriametrics.com/victoriametrics/vmauth/#audit-log")
+)
+
+// auditLogAlwaysRedactedQueryArgs contains names of query args, which may contain secrets.
+var auditLogAlwaysRedactedQueryArgs = []string{"authKey", "auth_key", "password", "token", "access_token", "secret"}
+
+// mutatingPathSuffixes contains path suffixes for endpoints, which modify the data at VictoriaMetrics components.
+var mutatingPathSuffixes = []string{
+	"/api/v1/admin/tsdb/delete_series",
+	"/api/v1/admin/status/metric_names_stats/reset",
+	"/tags/delSeries",
+	"/snapshot/create",
+	"/snapshot/delete",
+	"/snapshot/delete_all",
+	"/internal/force_merge",
+	"/internal/force_flush",
+	"/internal/resetRollupResultCache",
+}
+
+var (
+	auditLogRecordsWritten = metrics.NewCounter(`vmauth_audit_log_records_total`)
+	auditLogWriteErrors    = metrics.NewCounter(`vmauth_audit_log_write_errors_total`)
+)
+
+var (
+	auditLog     *auditLogWriter
+	auditLogOnce sync.Once
+)
+
+func getAuditLog() *auditLogWriter {
+	if *auditLogPath == "" {
+		return nil
+	}
+	auditLogOnce.Do(func() {
+		auditLog = newAuditLogWriter(*auditLogPath, auditLogMaxFileSize.N, *auditLogMaxFiles)
+	})
+	return auditLog
+}
+
+// stopAuditLog closes the audit log file if it is opened.
+func stopAuditLog() {
+	if auditLog != nil {
+		auditLog.mustClose()
+	}
+}
+
+// auditRecord is a single record in the audit log.
+type auditRecord struct {
+	Timestamp    string     `json:"ts"`
+	User         string     `json:"user"`
+	RemoteAddr   string     `json:"remote_addr"`
+	ForwardedFor string     `json:"forwarded_for,omitempty"`
+	Route        string     `json:"route,omitempty"`
+	Backend      string     `json:"backend,omitempty"`
+	Method       string     `json:"method"`
+	Path         string     `json:"path"`
+	Args         url.Values `json:"args,omitempty"`
+	Status       int        `json:"status"`
+	Duration     float64    `json:"duration_seconds"`
+
+	startTime time.Time
+}
+
+// newAuditRecord returns a record for r from ui, which must be written to the audit log after the request is processed.
+//
+// claims must contain verified JWT claims if the request is authorized with JWT token.
+//
+// nil is returned if the request mustn't be written to the audit log.
+func newAuditRecord(r *http.Request, u *url.URL, ui *UserInfo, claims jwtClaims) *auditRecord {
+	if getAuditLog() == nil {
+		return nil
+	}
+	if *auditLogOnlyMutatingRequests && !isMutatingRequest(r.Method, u.Path) {
+		return nil
+	}
+	startTime := time.Now()
+	ar := &auditRecord{
+		Timestamp:    startTime.UTC().Format(time.RFC3339Nano),
+		User:         getAuditLogUser(ui, claims),
+		RemoteAddr:   r.RemoteAddr,
+		ForwardedFor: r.Header.Get("X-Forwarded-For"),
+		Method:       r.Method,
+		Path:         u.Path,
+		startTime:    startTime,
+	}
+	args := u.Query()
+	if postArgs, err := getPostFormArgs(r); err == nil {
+		for k, vs := range postArgs {
+			args[k] = append(args[k], vs...)
+		}
+	}
+	ar.Args = redactQueryArgs(args)
+	return ar
+}
+
+// getAuditLogUser returns the user name for the audit log.
+//
+// The value of -auditLog.jwtUserClaim claim is returned for requests authorized with JWT token,
+// since ui.name() doesn't identify the token owner in this case.
+func getAuditLogUser(ui *UserInfo, claims jwtClaims) string {
+	if claims != nil && *auditLogJWTUserClaim != "" {
+		if v, err := claims.getValue(*auditLogJWTUserClaim); err == nil {
+			return v
+		}
+	}
+	return ui.name()
+}
+
+func isMutatingRequest(method, path string) bool {
+	if method == http.MethodDelete {
+		return true
+	}
+	return hasPathSuffix(path, mutatingPathSuffixes)
+}
+
+// redactQueryArgs replaces values for query args with secrets in args with `***`.
+//
+// args mustn't be modified by the caller after the call, since the returned result may refer to them.
+func redactQueryArgs(args url.Values) url.Values {
+	if len(args) == 0 {
+		return nil
+	}
+	for k, vs := range args {
+		if !slices.ContainsFunc(auditLogAlwaysRedactedQueryArgs, func(s string) bool { return strings.EqualFold(s, k) }) &&
+			!slices.ContainsFunc(*auditLogRedactQueryArgs, func(s string) bool { return strings.EqualFold(s, k) }) {
+			continue
+		}
+		redacted := make([]string, len(vs))
+		for i := range redacted {
+			redacted[i] = "***"
+		}
+		args[k] = redacted
+	}
+	return args
+}
+
+// setRoute sets the name of ui route for up.
+func (ar *auditRecord) setRoute(ui *UserInfo, up *URLPrefix) {
+	if ar == nil {
+		return
+	}
+	ar.Route = ui.getRouteName(up)
+}
+
+// setBackend sets the backend the request is proxied to.
+//
+// Only the scheme and the host are stored, since the path and query args are already stored in ar.
+func (ar *auditRecord) setBackend(u *url.URL) {
+	if ar == nil {
+		return
+	}
+	ar.Backend = u.Scheme + "://" + u.Host
+}
+
+// wrapResponseWriter returns w, which registers the response status code in ar.
+func (ar *auditRecord) wrapResponseWriter(w http.ResponseWriter) http.ResponseWriter {
+	if ar == nil {
+		return w
+	}
+	return &auditResponseWriter{
+		ResponseWriter: w,
+		ar:             ar,
+	}
+}
+
+// write writes ar to the audit log.
+func (ar *auditRecord) write() {
+	if ar == nil {
+		return
+	}
+	if ar.Status == 0 {
+		ar.Status = http.StatusOK
+	}
+	ar.Duration = time.Since(ar.startTime).Seconds()
+	data, err := json.Marshal(ar)
+	if err != nil {
+		logger.Panicf("BUG: cannot marshal audit record: %s", err)
+	}
+	data = append(data, '\n')
+	getAuditLog().write(data)
+}
+
+func (ui *UserInfo) getRouteName(up *URLPrefix) string {
+	for i := range ui.URLMaps {
+		if ui.URLMaps[i].URLPrefix == up {
+			return fmt.Sprintf("url_map[%d]", i)
+		}
+	}
+	switch up {
+	case ui.URLPrefix:
+		return "url_prefix"
+	case ui.DefaultURL:
+		return "default_url"
+	default:
+		return ""
+	}
+}
+
+// auditResponseWriter registers the response status code in the audit record.
+type auditResponseWriter struct {
+	http.ResponseWriter
+
+	ar *auditRecord
+}
+
+// WriteHeader implements http.ResponseWriter interface.
+func (aw *auditResponseWriter) WriteHeader(statusCode int) {
+	if aw.ar.Status == 0 {
+		aw.ar.Status = statusCode
+	}
+	aw.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Write implements http.ResponseWriter interface.
+func (aw *auditResponseWriter) Write(p []byte) (int, error) {
+	if aw.ar.Status == 0 {
+		aw.ar.Status = http.StatusOK
+	}
+	return aw.ResponseWriter.Write(p)
+}
+
+// auditLogWriter writes audit records to size-rotated file.
+type auditLogWriter struct {
+	path        string
+	maxFileSize int64
+	maxFiles    int
+
+	// mu protects the fields below
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+func newAuditLogWriter(path string, maxFileSize int64, maxFiles int) *auditLogWriter {
+	fs.MustMkdirIfNotExist(filepath.Dir(path))
+	return &auditLogWriter{
+		path:        path,
+		maxFileSize: maxFileSize,
+		maxFiles:    maxFiles,
+	}
+}
+
+func (aw *auditLogWriter) write(data []byte) {
+	aw.mu.Lock()
+	defer aw.mu.Unlock()
+
+	if err := aw.writeLocked(data); err != nil {
+		auditLogWriteErrors.Inc()
+		auditLogErrorLogger.Errorf("cannot write audit record to %q: %s", aw.path, err)
+		return
+	}
+	auditLogRecordsWritten.Inc()
+}
+
+var auditLogErrorLogger = logger.WithThrottler("auditLog", 5*time.Second)
+
+func (aw *auditLogWriter) writeLocked(data []byte) error {
+	if aw.f != nil && aw.size > 0 && aw.size+int64(len(data)) > aw.maxFileSize {
+		if err := aw.rotateLocked(); err != nil {
+			return err
+		}
+	}
+	if aw.f == nil {
+		f, err := os.OpenFile(aw.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+		if err != nil {
+			return fmt.Errorf("cannot open audit log file: %w", err)
+		}
+		fi, err := f.Stat()
+		if err != nil {
+			_ = f.Close()
+			return fmt.Errorf("cannot stat audit log file: %w", err)
+		}
+		aw.f = f
+		aw.size = fi.Size()
+		if aw.size > 0 && aw.size+int64(len(data)) > aw.maxFileSize {
+			// The file left after the previous run is full.
+			return aw.writeLocked(data)
+		}
+	}
+	n, err := aw.f.Write(data)
+	aw.size += int64(n)
+	return err
+}
+
+// rotateLocked renames the current audit log file and deletes the oldest rotated files exceeding maxFiles.
+func (aw *auditLogWriter) rotateLocked() error {
+	if err := aw.f.Close(); err != nil {
+		return fmt.Errorf("cannot close audit log file: %w", err)
+	}
+	aw.f = nil
+	aw.size = 0
+
+	rotatedPath := aw.path + "." + time.Now().UTC().Format("2006-01-02T15-04-05.000")
+	if err := os.Rename(aw.path, rotatedPath); err != nil {
+		return fmt.Errorf("cannot rename audit log file to %q: %w", rotatedPath, err)
+	}
+
+	rotatedFiles, err := filepath.Glob(aw.path + ".*")
+	if err != nil {
+		logger.Panicf("BUG: unexpected error when searching for rotated audit log files: %s", err)
+	}
+	// Rotated files are sorted by the creation time, since their names end with timestamps.
+	slices.Sort(rotatedFiles)
+	for len(rotatedFiles) > aw.maxFiles {
+		if err := os.Remove(rotatedFiles[0]); err != nil {
+			logger.Errorf("cannot remove rotated audit log file: %s", err)
+		}
+		rotatedFiles = rotatedFiles[1:]
+	}
+	return nil
+}
+
+func (aw *auditLogWriter) mustClose() {
+	aw.mu.Lock()
+	defer aw.mu.Unlock()
+
+	if aw.f == nil {
+		return
+	}
+	if err := aw.f.Close(); err != nil {
+		logger.Errorf("cannot close audit log file %q: %s", aw.path, err)
+	}
+	aw.f = nil
+}
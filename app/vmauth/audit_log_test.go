@@ -0,0 +1,279 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestRedactQueryArgs(t *testing.T) {
+	f := func(argsStr, resultExpected string) {
+		t.Helper()
+		args, err := url.ParseQuery(argsStr)
+		if err != nil {
+			t.Fatalf("cannot parse args: %s", err)
+		}
+		result := redactQueryArgs(args).Encode()
+		if result != resultExpected {
+			t.Fatalf("unexpected result\ngot\n%s\nwant\n%s", result, resultExpected)
+		}
+	}
+
+	f("", "")
+	f("query=up&step=1m", "query=up&step=1m")
+	f("query=up&authKey=foo&Password=bar&password=baz", "Password=%2A%2A%2A&authKey=%2A%2A%2A&password=%2A%2A%2A&query=up")
+}
+
+func TestIsMutatingRequest(t *testing.T) {
+	f := func(method, path string, resultExpected bool) {
+		t.Helper()
+		result := isMutatingRequest(method, path)
+		if result != resultExpected {
+			t.Fatalf("unexpected result for %s %s; got %v; want %v", method, path, result, resultExpected)
+		}
+	}
+
+	f(http.MethodGet, "/api/v1/query", false)
+	f(http.MethodPost, "/api/v1/write", false)
+	f(http.MethodPost, "/api/v1/admin/tsdb/delete_series", true)
+	f(http.MethodGet, "/delete/0/prometheus/api/v1/admin/tsdb/delete_series", true)
+	f(http.MethodPost, "/select/0/graphite/tags/delSeries", true)
+	f(http.MethodDelete, "/foo", true)
+}
+
+func TestAuditLogWriterRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "audit.log")
+
+	// Create a file left from the previous run
+	if err := os.WriteFile(path, []byte("0123456789\n"), 0600); err != nil {
+		t.Fatalf("cannot create file: %s", err)
+	}
+
+	aw := newAuditLogWriter(path, 20, 2)
+	defer aw.mustClose()
+
+	for i := 0; i < 10; i++ {
+		aw.write([]byte(fmt.Sprintf("record_%03d\n", i)))
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("cannot read audit log: %s", err)
+	}
+	if string(data) != "record_009\n" {
+		t.Fatalf("unexpected audit log contents: %q", data)
+	}
+	rotatedFiles, err := filepath.Glob(path + ".*")
+	if err != nil {
+		t.Fatalf("cannot search for rotated files: %s", err)
+	}
+	if len(rotatedFiles) > 2 {
+		t.Fatalf("unexpected number of rotated files; got %d; want up to 2; files: %s", len(rotatedFiles), rotatedFiles)
+	}
+}
+
+func TestRequestHandlerAuditLog(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/select/api/v1/admin/tsdb/delete_series" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		fmt.Fprintf(w, "requested_url=%s", r.URL)
+	}))
+	defer ts.Close()
+
+	cfgStr := strings.ReplaceAll(`
+users:
+- username: foo
+  password: bar
+  url_map:
+  - src_paths: ["/api/v1/query"]
+    url_prefix: {BACKEND}/select
+  - src_paths: ["/api/v1/admin/.+"]
+    url_prefix: {BACKEND}/select
+- name: jwt
+  jwt:
+    hmac_secret: secret
+  url_prefix: {BACKEND}/select
+`, "{BACKEND}", ts.URL)
+
+	cfgOrigP := authConfigData.Load()
+	if _, err := reloadAuthConfigData([]byte(cfgStr)); err != nil {
+		t.Fatalf("cannot load config data: %s", err)
+	}
+	defer func() {
+		cfgOrig := []byte("unauthorized_user:\n  url_prefix: http://foo/bar")
+		if cfgOrigP != nil {
+			cfgOrig = *cfgOrigP
+		}
+		if _, err := reloadAuthConfigData(cfgOrig); err != nil {
+			t.Fatalf("cannot load the original config: %s", err)
+		}
+	}()
+
+	path := filepath.Join(t.TempDir(), "audit.log")
+	*auditLogPath = path
+	defer func() {
+		stopAuditLog()
+		*auditLogPath = ""
+	}()
+
+	f := func(method, requestURL, authHeader, body string) {
+		t.Helper()
+		r, err := http.NewRequest(method, requestURL, strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("cannot initialize http request: %s", err)
+		}
+		if body != "" {
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		r.RequestURI = r.URL.RequestURI()
+		r.RemoteAddr = "42.2.3.84:6789"
+		r.Header.Set("Authorization", authHeader)
+		w := &fakeResponseWriter{}
+		if !requestHandler(w, r) {
+			t.Fatalf("unexpected false is returned from requestHandler")
+		}
+	}
+
+	basicAuth := "Basic Zm9vOmJhcg=="
+	f(http.MethodGet, "http://vmauth/api/v1/query?query=up&authKey=secret", basicAuth, "")
+	f(http.MethodPost, "http://vmauth/api/v1/admin/tsdb/delete_series", basicAuth, "match[]=foo")
+	f(http.MethodGet, "http://vmauth/missing/route", basicAuth, "")
+
+	*auditLogOnlyMutatingRequests = true
+	f(http.MethodGet, "http://vmauth/api/v1/query?query=up", basicAuth, "")
+	f(http.MethodPost, "http://vmauth/api/v1/admin/tsdb/delete_series", basicAuth, "match[]=bar")
+	*auditLogOnlyMutatingRequests = false
+
+	// The user is obtained from -auditLog.jwtUserClaim for requests with JWT tokens.
+	signToken := func(claims jwt.MapClaims) string {
+		t.Helper()
+		claims["exp"] = time.Now().Add(time.Hour).Unix()
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("secret"))
+		if err != nil {
+			t.Fatalf("cannot sign token: %s", err)
+		}
+		return "Bearer " + token
+	}
+	f(http.MethodGet, "http://vmauth/api/v1/query?query=up", signToken(jwt.MapClaims{"sub": "alice"}), "")
+	*auditLogJWTUserClaim = "user.email"
+	f(http.MethodGet, "http://vmauth/api/v1/query?query=up", signToken(jwt.MapClaims{"user": map[string]any{"email": "bob@example.com"}}), "")
+	*auditLogJWTUserClaim = "sub"
+
+	// The user name from config is used if the claim is missing.
+	f(http.MethodGet, "http://vmauth/api/v1/query?query=up", signToken(jwt.MapClaims{}), "")
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("cannot read audit log: %s", err)
+	}
+	backend := strings.TrimPrefix(ts.URL, "http://")
+	re := regexp.MustCompile(`"ts":"[^"]+"|"duration_seconds":[^}]+`)
+	result := re.ReplaceAllStringFunc(string(data), func(s string) string {
+		if strings.HasPrefix(s, `"ts"`) {
+			return `"ts":"TS"`
+		}
+		return `"duration_seconds":0`
+	})
+	result = strings.ReplaceAll(result, backend, "BACKEND")
+	resultExpected := `{"ts":"TS","user":"foo","remote_addr":"42.2.3.84:6789","route":"url_map[0]","backend":"http://BACKEND","method":"GET","path":"/api/v1/query","args":{"authKey":["***"],"query":["up"]},"status":200,"duration_seconds":0}
+{"ts":"TS","user":"foo","remote_addr":"42.2.3.84:6789","route":"url_map[1]","backend":"http://BACKEND","method":"POST","path":"/api/v1/admin/tsdb/delete_series","args":{"match[]":["foo"]},"status":204,"duration_seconds":0}
+{"ts":"TS","user":"foo","remote_addr":"42.2.3.84:6789","method":"GET","path":"/missing/route","status":400,"duration_seconds":0}
+{"ts":"TS","user":"foo","remote_addr":"42.2.3.84:6789","route":"url_map[1]","backend":"http://BACKEND","method":"POST","path":"/api/v1/admin/tsdb/delete_series","args":{"match[]":["bar"]},"status":204,"duration_seconds":0}
+{"ts":"TS","user":"alice","remote_addr":"42.2.3.84:6789","route":"url_prefix","backend":"http://BACKEND","method":"GET","path":"/api/v1/query","args":{"query":["up"]},"status":200,"duration_seconds":0}
+{"ts":"TS","user":"bob@example.com","remote_addr":"42.2.3.84:6789","route":"url_prefix","backend":"http://BACKEND","method":"GET","path":"/api/v1/query","args":{"query":["up"]},"status":200,"duration_seconds":0}
+{"ts":"TS","user":"jwt","remote_addr":"42.2.3.84:6789","route":"url_prefix","backend":"http://BACKEND","method":"GET","path":"/api/v1/query","args":{"query":["up"]},"status":200,"duration_seconds":0}
+`
+	if !reflect.DeepEqual(result, resultExpected) {
+		t.Fatalf("unexpected audit log\ngot\n%s\nwant\n%s", result, resultExpected)
+	}
+}
+
+func TestRequestHandlerAuditLogUnparsableFormBody(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("cannot read request body: %s", err)
+		}
+		fmt.Fprintf(w, "content_length=%d body=%s", r.ContentLength, body)
+	}))
+	defer ts.Close()
+
+	cfgStr := strings.ReplaceAll(`
+users:
+- username: foo
+  password: bar
+  url_prefix: {BACKEND}/select
+`, "{BACKEND}", ts.URL)
+
+	cfgOrigP := authConfigData.Load()
+	if _, err := reloadAuthConfigData([]byte(cfgStr)); err != nil {
+		t.Fatalf("cannot load config data: %s", err)
+	}
+	defer func() {
+		cfgOrig := []byte("unauthorized_user:\n  url_prefix: http://foo/bar")
+		if cfgOrigP != nil {
+			cfgOrig = *cfgOrigP
+		}
+		if _, err := reloadAuthConfigData(cfgOrig); err != nil {
+			t.Fatalf("cannot load the original config: %s", err)
+		}
+	}()
+
+	*auditLogPath = filepath.Join(t.TempDir(), "audit.log")
+	defer func() {
+		stopAuditLog()
+		*auditLogPath = ""
+	}()
+
+	// The audit log mustn't change the request body proxied to the backend.
+	f := func(body string) {
+		t.Helper()
+		r, err := http.NewRequest(http.MethodPost, "http://vmauth/api/v1/query", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("cannot initialize http request: %s", err)
+		}
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.RequestURI = r.URL.RequestURI()
+		r.RemoteAddr = "42.2.3.84:6789"
+		r.Header.Set("Authorization", "Basic Zm9vOmJhcg==")
+		w := &fakeResponseWriter{}
+		if !requestHandler(w, r) {
+			t.Fatalf("unexpected false is returned from requestHandler")
+		}
+		response := w.getResponse()
+		responseExpected := fmt.Sprintf("statusCode=200\ncontent_length=%d body=%s", len(body), body)
+		if response != responseExpected {
+			t.Fatalf("unexpected response (-want, +got):\n%s\n%s", trimLongString(responseExpected), trimLongString(response))
+		}
+	}
+
+	// invalid form body
+	f("query=up&a=%zz")
+
+	// too big form body
+	f("query=" + strings.Repeat("x", maxPostFormSize))
+
+	// valid form body mustn't be re-encoded
+	f("query=up&a=b+c&a=%2F")
+}
+
+func trimLongString(s string) string {
+	if len(s) <= 200 {
+		return s
+	}
+	return s[:100] + "..." + s[len(s)-100:]
+}
@@ -20,9 +20,11 @@ import (
 	"time"
 
 	"github.com/VictoriaMetrics/metrics"
+	"github.com/VictoriaMetrics/metricsql"
 	"github.com/cespare/xxhash/v2"
 	"gopkg.in/yaml.v2"
 
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/consistenthash"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/envtemplate"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
@@ -30,6 +32,8 @@ import (
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/netutil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/procutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/ratelimiter"
 )
 
 var (
@@ -40,7 +44,7 @@ var (
 	defaultRetryStatusCodes = flagutil.NewArrayInt("retryStatusCodes", 0, "Comma-separated list of default HTTP response status codes when vmauth re-tries the request on other backends. "+
 		"See https://docs.victoriametrics.com/victoriametrics/vmauth/#load-balancing for details")
 	defaultLoadBalancingPolicy = flag.String("loadBalancingPolicy", "least_loaded", "The default load balancing policy to use for backend urls specified inside url_prefix section. "+
-		"Supported policies: least_loaded, first_available. See https://docs.victoriametrics.com/victoriametrics/vmauth/#load-balancing")
+		"Supported policies: least_loaded, first_available, weighted_round_robin, consistent_hash. See https://docs.victoriametrics.com/victoriametrics/vmauth/#load-balancing")
 	discoverBackendIPsGlobal = flag.Bool("discoverBackendIPs", false, "Whether to discover backend IPs via periodic DNS queries to hostnames specified in url_prefix. "+
 		"This may be useful when url_prefix points to a hostname with dynamically scaled instances behind it. See https://docs.victoriametrics.com/victoriametrics/vmauth/#discovering-backend-ips")
 	discoverBackendIPsInterval = flag.Duration("discoverBackendIPsInterval", 10*time.Second, "The interval for re-discovering backend IPs if -discoverBackendIPs command-line flag is set. "+
@@ -55,6 +59,13 @@ type AuthConfig struct {
 
 	// ms holds all the metrics for the given AuthConfig
 	ms *metrics.Set
+
+	// jwtUsers contains users authorized via JWT tokens
+	jwtUsers []*UserInfo
+
+	// healthChecksStopCh and healthChecksWG are used for stopping active health checks for backends.
+	healthChecksStopCh chan struct{}
+	healthChecksWG     sync.WaitGroup
 }
 
 // UserInfo is user information read from authConfigPath
@@ -66,6 +77,23 @@ type UserInfo struct {
 	Username    string `yaml:"username,omitempty"`
 	Password    string `yaml:"password,omitempty"`
 
+	// JWT is an optional config for authorizing the user by JWT bearer tokens.
+	JWT *JWTConfig `yaml:"jwt,omitempty"`
+
+	// HealthCheck is an optional config for active health checks of url_prefix backends.
+	HealthCheck *HealthCheckConfig `yaml:"health_check,omitempty"`
+
+	// MirrorURLPrefix contains optional backends for mirroring requests.
+	// See https://docs.victoriametrics.com/victoriametrics/vmauth/#request-mirroring
+	MirrorURLPrefix *URLPrefix `yaml:"mirror_url_prefix,omitempty"`
+
+	// MirrorSampleRatio is the ratio of requests to mirror to MirrorURLPrefix. By default all the requests are mirrored.
+	MirrorSampleRatio *float64 `yaml:"mirror_sample_ratio,omitempty"`
+
+	// EnforcedLabelFilters contains label filters, which are added to series selectors in queries to Prometheus querying APIs.
+	// See https://docs.victoriametrics.com/victoriametrics/vmauth/#enforcing-label-filters
+	EnforcedLabelFilters []string `yaml:"enforced_label_filters,omitempty"`
+
 	URLPrefix              *URLPrefix  `yaml:"url_prefix,omitempty"`
 	DiscoverBackendIPs     *bool       `yaml:"discover_backend_ips,omitempty"`
 	URLMaps                []URLMap    `yaml:"url_map,omitempty"`
@@ -75,6 +103,7 @@ type UserInfo struct {
 	DefaultURL             *URLPrefix  `yaml:"default_url,omitempty"`
 	RetryStatusCodes       []int       `yaml:"retry_status_codes,omitempty"`
 	LoadBalancingPolicy    string      `yaml:"load_balancing_policy,omitempty"`
+	ConsistentHashKey      string      `yaml:"consistent_hash_key,omitempty"`
 	DropSrcPathPrefixParts *int        `yaml:"drop_src_path_prefix_parts,omitempty"`
 	TLSCAFile              string      `yaml:"tls_ca_file,omitempty"`
 	TLSCertFile            string      `yaml:"tls_cert_file,omitempty"`
@@ -84,9 +113,21 @@ type UserInfo struct {
 
 	MetricLabels map[string]string `yaml:"metric_labels,omitempty"`
 
+	// Per-user rate limits. See https://docs.victoriametrics.com/victoriametrics/vmauth/#rate-limiting
+	MaxRequestsPerSecond      int `yaml:"max_requests_per_second,omitempty"`
+	MaxRequestBytesPerSecond  int `yaml:"max_request_bytes_per_second,omitempty"`
+	MaxResponseBytesPerSecond int `yaml:"max_response_bytes_per_second,omitempty"`
+
 	concurrencyLimitCh      chan struct{}
 	concurrencyLimitReached *metrics.Counter
 
+	requestsRateLimiter      *ratelimiter.RateLimiter
+	requestBytesRateLimiter  *ratelimiter.RateLimiter
+	responseBytesRateLimiter *ratelimiter.RateLimiter
+
+	// mirrorMetrics is set only if mirror_url_prefix is configured for the user.
+	mirrorMetrics *mirrorMetrics
+
 	rt http.RoundTripper
 
 	requests         *metrics.Counter
@@ -115,6 +156,52 @@ func (ui *UserInfo) endConcurrencyLimit() {
 	<-ui.concurrencyLimitCh
 }
 
+// checkRateLimits returns non-nil error if ui exceeds the configured rate limits.
+//
+// It also returns the duration after which the request can be retried.
+func (ui *UserInfo) checkRateLimits() (time.Duration, error) {
+	// Check bytes limits before registering the request, since they do not consume the budget,
+	// while the request mustn't be registered if it is rejected by bytes limits.
+	if !ui.requestBytesRateLimiter.TryRegister(0) {
+		return ui.requestBytesRateLimiter.RetryAfter(), fmt.Errorf("cannot read more than max_request_bytes_per_second=%d request bytes per second from user %s",
+			ui.MaxRequestBytesPerSecond, ui.name())
+	}
+	if !ui.responseBytesRateLimiter.TryRegister(0) {
+		return ui.responseBytesRateLimiter.RetryAfter(), fmt.Errorf("cannot return more than max_response_bytes_per_second=%d response bytes per second to user %s",
+			ui.MaxResponseBytesPerSecond, ui.name())
+	}
+	if !ui.requestsRateLimiter.TryRegister(1) {
+		return ui.requestsRateLimiter.RetryAfter(), fmt.Errorf("cannot serve more than max_requests_per_second=%d requests per second from user %s",
+			ui.MaxRequestsPerSecond, ui.name())
+	}
+	return 0, nil
+}
+
+func (ui *UserInfo) initRateLimiters(ms *metrics.Set, metricPrefix, metricLabels string) error {
+	if ui.MaxRequestsPerSecond < 0 {
+		return fmt.Errorf("max_requests_per_second cannot be negative; got %d", ui.MaxRequestsPerSecond)
+	}
+	if ui.MaxRequestBytesPerSecond < 0 {
+		return fmt.Errorf("max_request_bytes_per_second cannot be negative; got %d", ui.MaxRequestBytesPerSecond)
+	}
+	if ui.MaxResponseBytesPerSecond < 0 {
+		return fmt.Errorf("max_response_bytes_per_second cannot be negative; got %d", ui.MaxResponseBytesPerSecond)
+	}
+	if ui.MaxRequestsPerSecond > 0 {
+		limitReached := ms.GetOrCreateCounter(metricPrefix + `_requests_rate_limit_reached_total` + metricLabels)
+		ui.requestsRateLimiter = ratelimiter.New(int64(ui.MaxRequestsPerSecond), limitReached, nil)
+	}
+	if ui.MaxRequestBytesPerSecond > 0 {
+		limitReached := ms.GetOrCreateCounter(metricPrefix + `_request_bytes_rate_limit_reached_total` + metricLabels)
+		ui.requestBytesRateLimiter = ratelimiter.New(int64(ui.MaxRequestBytesPerSecond), limitReached, nil)
+	}
+	if ui.MaxResponseBytesPerSecond > 0 {
+		limitReached := ms.GetOrCreateCounter(metricPrefix + `_response_bytes_rate_limit_reached_total` + metricLabels)
+		ui.responseBytesRateLimiter = ratelimiter.New(int64(ui.MaxResponseBytesPerSecond), limitReached, nil)
+	}
+	return nil
+}
+
 func (ui *UserInfo) getMaxConcurrentRequests() int {
 	mcr := ui.MaxConcurrentRequests
 	if mcr <= 0 {
@@ -182,8 +269,28 @@ type URLMap struct {
 	// LoadBalancingPolicy is load balancing policy among UrlPrefix backends.
 	LoadBalancingPolicy string `yaml:"load_balancing_policy,omitempty"`
 
+	// ConsistentHashKey is the request header or query arg used for selecting the backend with consistent_hash load balancing policy.
+	ConsistentHashKey string `yaml:"consistent_hash_key,omitempty"`
+
+	// HealthCheck is an optional config for active health checks of UrlPrefix backends.
+	HealthCheck *HealthCheckConfig `yaml:"health_check,omitempty"`
+
+	// MirrorURLPrefix contains optional backends for mirroring requests matching the given url_map entry.
+	MirrorURLPrefix *URLPrefix `yaml:"mirror_url_prefix,omitempty"`
+
+	// MirrorSampleRatio is the ratio of requests to mirror to MirrorURLPrefix. By default all the requests are mirrored.
+	MirrorSampleRatio *float64 `yaml:"mirror_sample_ratio,omitempty"`
+
+	// EnforcedLabelFilters contains label filters, which are added to series selectors in queries matching the given url_map entry.
+	EnforcedLabelFilters []string `yaml:"enforced_label_filters,omitempty"`
+
 	// DropSrcPathPrefixParts is the number of `/`-delimited request path prefix parts to drop before proxying the request to backend.
 	DropSrcPathPrefixParts *int `yaml:"drop_src_path_prefix_parts,omitempty"`
+
+	// ResponseCacheTTL is the maximum duration for caching responses for GET requests matching the given url_map entry.
+	//
+	// See https://docs.victoriametrics.com/victoriametrics/vmauth/#response-caching
+	ResponseCacheTTL *promutil.Duration `yaml:"response_cache_ttl,omitempty"`
 }
 
 // QueryArg represents HTTP query arg
@@ -191,6 +298,11 @@ type QueryArg struct {
 	Name  string
 	Value *Regex
 
+	// valueTemplate contains the value with JWT claim placeholders.
+	//
+	// See https://docs.victoriametrics.com/victoriametrics/vmauth/#jwt-authorization
+	valueTemplate string
+
 	sOriginal string
 }
 
@@ -210,6 +322,9 @@ func (qa *QueryArg) UnmarshalYAML(f func(any) error) error {
 	qa.Name = s[:n]
 	expr := s[n+1:]
 	if !strings.HasPrefix(expr, "~") {
+		if hasClaimPlaceholders(expr) {
+			qa.valueTemplate = expr
+		}
 		expr = regexp.QuoteMeta(expr)
 	} else {
 		expr = expr[1:]
@@ -236,12 +351,56 @@ type URLPrefix struct {
 	// load balancing policy used
 	loadBalancingPolicy string
 
+	// the request header name for selecting the backend with consistent_hash load balancing policy
+	consistentHashHeader string
+
+	// the request query arg name for selecting the backend with consistent_hash load balancing policy
+	consistentHashQueryArg string
+
+	// consistentHash is used for selecting the backend with consistent_hash load balancing policy.
+	//
+	// It is re-created when bus is changed.
+	consistentHash atomic.Pointer[consistentHashState]
+
+	// wrrLock protects backendURL.currentWeight for weighted_round_robin load balancing policy.
+	wrrLock sync.Mutex
+
+	// the config for active health checks of backends
+	healthCheck *HealthCheckConfig
+
+	// the config for mirroring requests to other backends
+	mirror *mirrorConfig
+
+	// label filters to add to series selectors in the proxied queries
+	labelFilters []metricsql.LabelFilter
+
 	// how many request path prefix parts to drop before routing the request to backendURL
 	dropSrcPathPrefixParts int
 
+	// the maximum duration for caching responses from backends
+	//
+	// it is set only for url_map entries with response_cache_ttl option
+	responseCacheTTL time.Duration
+
+	// the index of url_map entry for the given URLPrefix
+	//
+	// it is used in the response cache key, so responses from distinct url_map entries aren't shared
+	urlMapIdx int
+
+	// src_headers from url_map entry for the given URLPrefix
+	//
+	// the values for these request headers are used in the response cache key,
+	// since distinct values may result in distinct responses
+	srcHeaders []*Header
+
 	// busOriginal contains the original list of backends specified in yaml config.
 	busOriginal []*url.URL
 
+	// weightsOriginal contains weights for busOriginal backends specified in yaml config.
+	//
+	// The weights are used by weighted_round_robin load balancing policy.
+	weightsOriginal []int
+
 	// n is an atomic counter, which is used for balancing load among available backends.
 	n atomic.Uint32
 
@@ -260,15 +419,33 @@ type URLPrefix struct {
 	vOriginal any
 }
 
-func (up *URLPrefix) setLoadBalancingPolicy(loadBalancingPolicy string) error {
+func (up *URLPrefix) setLoadBalancingPolicy(loadBalancingPolicy, consistentHashKey string) error {
 	switch loadBalancingPolicy {
 	case "", // empty string is equivalent to least_loaded
 		"least_loaded",
-		"first_available":
+		"first_available",
+		"weighted_round_robin":
+		up.loadBalancingPolicy = loadBalancingPolicy
+		return nil
+	case "consistent_hash":
+		if consistentHashKey == "" {
+			return fmt.Errorf("missing `consistent_hash_key` for `load_balancing_policy: consistent_hash`")
+		}
+		switch {
+		case strings.HasPrefix(consistentHashKey, "header:"):
+			up.consistentHashHeader = strings.TrimPrefix(consistentHashKey, "header:")
+		case strings.HasPrefix(consistentHashKey, "query_arg:"):
+			up.consistentHashQueryArg = strings.TrimPrefix(consistentHashKey, "query_arg:")
+		default:
+			return fmt.Errorf("unexpected `consistent_hash_key: %q`; want `header:<name>` or `query_arg:<name>`", consistentHashKey)
+		}
+		if up.consistentHashHeader == "" && up.consistentHashQueryArg == "" {
+			return fmt.Errorf("missing header or query arg name in `consistent_hash_key: %q`", consistentHashKey)
+		}
 		up.loadBalancingPolicy = loadBalancingPolicy
 		return nil
 	default:
-		return fmt.Errorf("unexpected load_balancing_policy: %q; want least_loaded or first_available", loadBalancingPolicy)
+		return fmt.Errorf("unexpected load_balancing_policy: %q; want least_loaded, first_available, weighted_round_robin or consistent_hash", loadBalancingPolicy)
 	}
 }
 
@@ -277,9 +454,25 @@ type backendURL struct {
 	concurrentRequests atomic.Int32
 
 	url *url.URL
+
+	// weight is the backend weight for weighted_round_robin load balancing policy.
+	weight int
+
+	// currentWeight is the current weight for weighted_round_robin load balancing policy.
+	//
+	// It is protected by URLPrefix.wrrLock.
+	currentWeight int
+
+	// healthStatus contains the result of active health checks for the backend.
+	//
+	// It is nil if active health checks are disabled or weren't performed yet.
+	healthStatus atomic.Pointer[backendHealthStatus]
 }
 
 func (bu *backendURL) isBroken() bool {
+	if hs := bu.healthStatus.Load(); hs != nil && !hs.healthy {
+		return true
+	}
 	ct := fasttime.UnixTimestamp()
 	return ct < bu.brokenDeadline.Load()
 }
@@ -306,6 +499,9 @@ func (up *URLPrefix) getBackendsCount() int {
 //
 // It can return nil if there are no backend urls available at the moment.
 //
+// The least loaded backendURL is returned for consistent_hash load balancing policy,
+// since the request isn't known. Use getBackendURLForRequest for consistent_hash policy.
+//
 // backendURL.put() must be called on the returned backendURL after the request is complete.
 func (up *URLPrefix) getBackendURL() *backendURL {
 	up.discoverBackendAddrsIfNeeded()
@@ -316,10 +512,115 @@ func (up *URLPrefix) getBackendURL() *backendURL {
 		return nil
 	}
 
-	if up.loadBalancingPolicy == "first_available" {
+	switch up.loadBalancingPolicy {
+	case "first_available":
 		return getFirstAvailableBackendURL(bus)
+	case "weighted_round_robin":
+		return up.getWeightedRoundRobinBackendURL(bus)
+	default:
+		return getLeastLoadedBackendURL(bus, &up.n)
+	}
+}
+
+// getBackendURLForRequest returns the backendURL for the given request r depending on the load balance policy.
+//
+// It can return nil if there are no backend urls available at the moment.
+//
+// backendURL.put() must be called on the returned backendURL after the request is complete.
+func (up *URLPrefix) getBackendURLForRequest(r *http.Request) *backendURL {
+	if up.loadBalancingPolicy != "consistent_hash" {
+		return up.getBackendURL()
 	}
-	return getLeastLoadedBackendURL(bus, &up.n)
+
+	var key string
+	if up.consistentHashHeader != "" {
+		key = r.Header.Get(up.consistentHashHeader)
+	} else {
+		key = r.URL.Query().Get(up.consistentHashQueryArg)
+	}
+	if key == "" {
+		// Fall back to the least loaded backend if the request doesn't contain the hash key.
+		return up.getBackendURL()
+	}
+
+	up.discoverBackendAddrsIfNeeded()
+
+	pbus := up.bus.Load()
+	bus := *pbus
+	if len(bus) == 0 {
+		return nil
+	}
+	return up.getConsistentHashBackendURL(pbus, key)
+}
+
+// consistentHashState holds consistent hash for the given list of backend urls.
+type consistentHashState struct {
+	pbus *[]*backendURL
+	ch   *consistenthash.ConsistentHash
+}
+
+// getConsistentHashBackendURL returns the backendURL for the given key from *pbus.
+//
+// The same backend is returned for the same key until it is broken or the list of backends is changed.
+//
+// backendURL.put() must be called on the returned backendURL after the request is complete.
+func (up *URLPrefix) getConsistentHashBackendURL(pbus *[]*backendURL, key string) *backendURL {
+	bus := *pbus
+	chs := up.consistentHash.Load()
+	if chs == nil || chs.pbus != pbus {
+		nodes := make([]string, len(bus))
+		for i, bu := range bus {
+			nodes[i] = bu.url.String()
+		}
+		chs = &consistentHashState{
+			pbus: pbus,
+			ch:   consistenthash.NewConsistentHash(nodes, 0),
+		}
+		up.consistentHash.Store(chs)
+	}
+
+	var excludeIdxs []int
+	for i, bu := range bus {
+		if bu.isBroken() {
+			excludeIdxs = append(excludeIdxs, i)
+		}
+	}
+	h := xxhash.Sum64String(key)
+	idx := chs.ch.GetNodeIdx(h, excludeIdxs)
+	bu := bus[idx]
+	bu.get()
+	return bu
+}
+
+// getWeightedRoundRobinBackendURL returns the backendURL according to backend weights.
+//
+// It uses smooth weighted round-robin algorithm, which evenly interleaves backends with different weights.
+//
+// backendURL.put() must be called on the returned backendURL after the request is complete.
+func (up *URLPrefix) getWeightedRoundRobinBackendURL(bus []*backendURL) *backendURL {
+	up.wrrLock.Lock()
+	var buBest *backendURL
+	totalWeight := 0
+	for _, bu := range bus {
+		if bu.isBroken() {
+			continue
+		}
+		bu.currentWeight += bu.weight
+		totalWeight += bu.weight
+		if buBest == nil || bu.currentWeight > buBest.currentWeight {
+			buBest = bu
+		}
+	}
+	if buBest == nil {
+		// All the backends are broken. Return the first one.
+		buBest = bus[0]
+	} else {
+		buBest.currentWeight -= totalWeight
+	}
+	up.wrrLock.Unlock()
+
+	buBest.get()
+	return buBest
 }
 
 func (up *URLPrefix) discoverBackendAddrsIfNeeded() {
@@ -394,13 +695,14 @@ func (up *URLPrefix) discoverBackendAddrsIfNeeded() {
 
 	// generate new backendURLs for the resolved IPs
 	var busNew []*backendURL
-	for _, bu := range up.busOriginal {
+	for i, bu := range up.busOriginal {
 		host := bu.Hostname()
 		for _, addr := range hostToAddrs[host] {
 			buCopy := *bu
 			buCopy.Host = addr
 			busNew = append(busNew, &backendURL{
-				url: &buCopy,
+				url:    &buCopy,
+				weight: up.getWeight(i),
 			})
 		}
 	}
@@ -501,6 +803,7 @@ func (up *URLPrefix) UnmarshalYAML(f func(any) error) error {
 	up.vOriginal = v
 
 	var urls []string
+	var weights []int
 	switch x := v.(type) {
 	case string:
 		urls = []string{x}
@@ -509,14 +812,29 @@ func (up *URLPrefix) UnmarshalYAML(f func(any) error) error {
 			return fmt.Errorf("`url_prefix` must contain at least a single url")
 		}
 		us := make([]string, len(x))
+		ws := make([]int, len(x))
+		hasWeights := false
 		for i, xx := range x {
-			s, ok := xx.(string)
-			if !ok {
-				return fmt.Errorf("`url_prefix` must contain array of strings; got %T", xx)
+			switch t := xx.(type) {
+			case string:
+				us[i] = t
+				ws[i] = 1
+			case map[any]any:
+				u, weight, err := parseWeightedURL(t)
+				if err != nil {
+					return err
+				}
+				us[i] = u
+				ws[i] = weight
+				hasWeights = true
+			default:
+				return fmt.Errorf("`url_prefix` must contain array of strings or `{url: ..., weight: ...}` objects; got %T", xx)
 			}
-			us[i] = s
 		}
 		urls = us
+		if hasWeights {
+			weights = ws
+		}
 	default:
 		return fmt.Errorf("unexpected type for `url_prefix`: %T; want string or []string", v)
 	}
@@ -530,9 +848,49 @@ func (up *URLPrefix) UnmarshalYAML(f func(any) error) error {
 		bus[i] = pu
 	}
 	up.busOriginal = bus
+	up.weightsOriginal = weights
 	return nil
 }
 
+// parseWeightedURL parses `{url: ..., weight: ...}` item from `url_prefix` list.
+func parseWeightedURL(m map[any]any) (string, int, error) {
+	var u string
+	weight := 1
+	for k, v := range m {
+		switch k {
+		case "url":
+			s, ok := v.(string)
+			if !ok {
+				return "", 0, fmt.Errorf("`url` in `url_prefix` must be string; got %T", v)
+			}
+			u = s
+		case "weight":
+			n, ok := v.(int)
+			if !ok {
+				return "", 0, fmt.Errorf("`weight` in `url_prefix` must be integer; got %T", v)
+			}
+			if n <= 0 {
+				return "", 0, fmt.Errorf("`weight` in `url_prefix` must be positive; got %d", n)
+			}
+			weight = n
+		default:
+			return "", 0, fmt.Errorf("unexpected key %v in `url_prefix` item; want `url` or `weight`", k)
+		}
+	}
+	if u == "" {
+		return "", 0, fmt.Errorf("missing `url` in `url_prefix` item")
+	}
+	return u, weight, nil
+}
+
+// getWeight returns the weight for busOriginal[i].
+func (up *URLPrefix) getWeight(i int) int {
+	if up.weightsOriginal == nil {
+		return 1
+	}
+	return up.weightsOriginal[i]
+}
+
 // MarshalYAML marshals up to yaml.
 func (up *URLPrefix) MarshalYAML() (any, error) {
 	return up.vOriginal, nil
@@ -615,6 +973,7 @@ func initAuthConfig() {
 func stopAuthConfig() {
 	close(stopCh)
 	authConfigWG.Wait()
+	authConfig.Load().stopHealthChecks()
 }
 
 func authConfigReloader(sighupCh <-chan os.Signal) {
@@ -711,9 +1070,11 @@ func reloadAuthConfigData(data []byte) (bool, error) {
 
 	acPrev := authConfig.Load()
 	if acPrev != nil {
+		acPrev.stopHealthChecks()
 		metrics.UnregisterSet(acPrev.ms, true)
 	}
 	metrics.RegisterSet(ac.ms)
+	ac.startHealthChecks()
 
 	authConfig.Store(ac)
 	authConfigData.Store(&data)
@@ -751,6 +1112,9 @@ func parseAuthConfig(data []byte) (*AuthConfig, error) {
 		if ui.Name != "" {
 			return nil, fmt.Errorf("field name can't be specified for unauthorized_user section")
 		}
+		if ui.JWT != nil {
+			return nil, fmt.Errorf("field jwt can't be specified for unauthorized_user section")
+		}
 		if err := ui.initURLs(); err != nil {
 			return nil, err
 		}
@@ -770,6 +1134,10 @@ func parseAuthConfig(data []byte) (*AuthConfig, error) {
 		_ = ac.ms.NewGauge(`vmauth_unauthorized_user_concurrent_requests_current`+metricLabels, func() float64 {
 			return float64(len(ui.concurrencyLimitCh))
 		})
+		if err := ui.initRateLimiters(ac.ms, "vmauth_unauthorized_user", metricLabels); err != nil {
+			return nil, fmt.Errorf("cannot initialize rate limits for unauthorized_user: %w", err)
+		}
+		ui.initMirrorMetrics(ac.ms, "vmauth_unauthorized_user", metricLabels)
 
 		rt, err := newRoundTripper(ui.TLSCAFile, ui.TLSCertFile, ui.TLSKeyFile, ui.TLSServerName, ui.TLSInsecureSkipVerify)
 		if err != nil {
@@ -789,9 +1157,21 @@ func parseAuthConfigUsers(ac *AuthConfig) (map[string]*UserInfo, error) {
 	}
 	for i := range uis {
 		ui := &uis[i]
-		ats, err := getAuthTokens(ui.AuthToken, ui.BearerToken, ui.Username, ui.Password)
-		if err != nil {
-			return nil, err
+		var ats []string
+		if ui.JWT != nil {
+			if ui.AuthToken != "" || ui.BearerToken != "" || ui.Username != "" || ui.Password != "" {
+				return nil, fmt.Errorf("auth_token, bearer_token, username and password cannot be specified if jwt is set")
+			}
+			if err := ui.JWT.init(); err != nil {
+				return nil, fmt.Errorf("cannot initialize jwt config for user %q: %w", ui.name(), err)
+			}
+			ac.jwtUsers = append(ac.jwtUsers, ui)
+		} else {
+			var err error
+			ats, err = getAuthTokens(ui.AuthToken, ui.BearerToken, ui.Username, ui.Password)
+			if err != nil {
+				return nil, err
+			}
 		}
 		for _, at := range ats {
 			if uiOld := byAuthToken[at]; uiOld != nil {
@@ -819,6 +1199,10 @@ func parseAuthConfigUsers(ac *AuthConfig) (map[string]*UserInfo, error) {
 		_ = ac.ms.GetOrCreateGauge(`vmauth_user_concurrent_requests_current`+metricLabels, func() float64 {
 			return float64(len(ui.concurrencyLimitCh))
 		})
+		if err := ui.initRateLimiters(ac.ms, "vmauth_user", metricLabels); err != nil {
+			return nil, fmt.Errorf("cannot initialize rate limits for user %q: %w", ui.name(), err)
+		}
+		ui.initMirrorMetrics(ac.ms, "vmauth_user", metricLabels)
 
 		rt, err := newRoundTripper(ui.TLSCAFile, ui.TLSCertFile, ui.TLSKeyFile, ui.TLSServerName, ui.TLSInsecureSkipVerify)
 		if err != nil {
@@ -856,6 +1240,14 @@ func (ui *UserInfo) getMetricLabels() (string, error) {
 	return labelsStr, nil
 }
 
+// addMetricLabel adds the given label in the form `name="value"` to metricLabels obtained via getMetricLabels.
+func addMetricLabel(metricLabels, label string) string {
+	if metricLabels == "" {
+		return "{" + label + "}"
+	}
+	return "{" + label + "," + metricLabels[1:]
+}
+
 func (ui *UserInfo) initURLs() error {
 	retryStatusCodes := defaultRetryStatusCodes.Values()
 	loadBalancingPolicy := *defaultLoadBalancingPolicy
@@ -873,6 +1265,30 @@ func (ui *UserInfo) initURLs() error {
 	if ui.DiscoverBackendIPs != nil {
 		discoverBackendIPs = *ui.DiscoverBackendIPs
 	}
+	healthCheck := ui.HealthCheck
+	if healthCheck != nil {
+		if err := healthCheck.init(); err != nil {
+			return err
+		}
+	}
+	var mirror *mirrorConfig
+	if ui.MirrorURLPrefix != nil {
+		if err := ui.MirrorURLPrefix.sanitizeAndInitialize(); err != nil {
+			return fmt.Errorf("cannot initialize `mirror_url_prefix`: %w", err)
+		}
+		ui.MirrorURLPrefix.discoverBackendIPs = discoverBackendIPs
+		mc, err := newMirrorConfig(ui.MirrorURLPrefix, ui.MirrorSampleRatio)
+		if err != nil {
+			return err
+		}
+		mirror = mc
+	} else if ui.MirrorSampleRatio != nil {
+		return fmt.Errorf("`mirror_sample_ratio` cannot be set without `mirror_url_prefix`")
+	}
+	labelFilters, err := parseEnforcedLabelFilters(ui.EnforcedLabelFilters)
+	if err != nil {
+		return err
+	}
 
 	if ui.URLPrefix != nil {
 		if err := ui.URLPrefix.sanitizeAndInitialize(); err != nil {
@@ -881,7 +1297,10 @@ func (ui *UserInfo) initURLs() error {
 		ui.URLPrefix.retryStatusCodes = retryStatusCodes
 		ui.URLPrefix.dropSrcPathPrefixParts = dropSrcPathPrefixParts
 		ui.URLPrefix.discoverBackendIPs = discoverBackendIPs
-		if err := ui.URLPrefix.setLoadBalancingPolicy(loadBalancingPolicy); err != nil {
+		ui.URLPrefix.healthCheck = healthCheck
+		ui.URLPrefix.mirror = mirror
+		ui.URLPrefix.labelFilters = labelFilters
+		if err := ui.URLPrefix.setLoadBalancingPolicy(loadBalancingPolicy, ui.ConsistentHashKey); err != nil {
 			return err
 		}
 	}
@@ -889,8 +1308,9 @@ func (ui *UserInfo) initURLs() error {
 		if err := ui.DefaultURL.sanitizeAndInitialize(); err != nil {
 			return err
 		}
+		ui.DefaultURL.labelFilters = labelFilters
 	}
-	for _, e := range ui.URLMaps {
+	for i, e := range ui.URLMaps {
 		if len(e.SrcPaths) == 0 && len(e.SrcHosts) == 0 && len(e.SrcQueryArgs) == 0 && len(e.SrcHeaders) == 0 {
 			return fmt.Errorf("missing `src_paths`, `src_hosts`, `src_query_args` and `src_headers` in `url_map`")
 		}
@@ -902,6 +1322,7 @@ func (ui *UserInfo) initURLs() error {
 		}
 		rscs := retryStatusCodes
 		lbp := loadBalancingPolicy
+		chk := ui.ConsistentHashKey
 		dsp := dropSrcPathPrefixParts
 		dbd := discoverBackendIPs
 		if e.RetryStatusCodes != nil {
@@ -910,6 +1331,9 @@ func (ui *UserInfo) initURLs() error {
 		if e.LoadBalancingPolicy != "" {
 			lbp = e.LoadBalancingPolicy
 		}
+		if e.ConsistentHashKey != "" {
+			chk = e.ConsistentHashKey
+		}
 		if e.DropSrcPathPrefixParts != nil {
 			dsp = *e.DropSrcPathPrefixParts
 		}
@@ -917,11 +1341,53 @@ func (ui *UserInfo) initURLs() error {
 			dbd = *e.DiscoverBackendIPs
 		}
 		e.URLPrefix.retryStatusCodes = rscs
-		if err := e.URLPrefix.setLoadBalancingPolicy(lbp); err != nil {
+		e.URLPrefix.urlMapIdx = i
+		e.URLPrefix.srcHeaders = e.SrcHeaders
+		if err := e.URLPrefix.setLoadBalancingPolicy(lbp, chk); err != nil {
 			return err
 		}
 		e.URLPrefix.dropSrcPathPrefixParts = dsp
 		e.URLPrefix.discoverBackendIPs = dbd
+		e.URLPrefix.healthCheck = healthCheck
+		if e.HealthCheck != nil {
+			if err := e.HealthCheck.init(); err != nil {
+				return err
+			}
+			e.URLPrefix.healthCheck = e.HealthCheck
+		}
+		e.URLPrefix.mirror = mirror
+		if e.MirrorURLPrefix != nil {
+			if err := e.MirrorURLPrefix.sanitizeAndInitialize(); err != nil {
+				return fmt.Errorf("cannot initialize `mirror_url_prefix` in `url_map`: %w", err)
+			}
+			e.MirrorURLPrefix.discoverBackendIPs = dbd
+			sampleRatio := e.MirrorSampleRatio
+			if sampleRatio == nil {
+				sampleRatio = ui.MirrorSampleRatio
+			}
+			mc, err := newMirrorConfig(e.MirrorURLPrefix, sampleRatio)
+			if err != nil {
+				return err
+			}
+			e.URLPrefix.mirror = mc
+		} else if e.MirrorSampleRatio != nil {
+			return fmt.Errorf("`mirror_sample_ratio` cannot be set without `mirror_url_prefix` in `url_map`")
+		}
+		e.URLPrefix.labelFilters = labelFilters
+		if e.EnforcedLabelFilters != nil {
+			lfs, err := parseEnforcedLabelFilters(e.EnforcedLabelFilters)
+			if err != nil {
+				return fmt.Errorf("cannot initialize `enforced_label_filters` in `url_map`: %w", err)
+			}
+			e.URLPrefix.labelFilters = lfs
+		}
+		if e.ResponseCacheTTL != nil {
+			ttl := e.ResponseCacheTTL.Duration()
+			if ttl < 0 {
+				return fmt.Errorf("`response_cache_ttl` cannot be negative; got %s", ttl)
+			}
+			e.URLPrefix.responseCacheTTL = ttl
+		}
 	}
 	if len(ui.URLMaps) == 0 && ui.URLPrefix == nil {
 		return fmt.Errorf("missing `url_prefix` or `url_map`")
@@ -944,6 +1410,10 @@ func (ui *UserInfo) name() string {
 		h := xxhash.Sum64([]byte(ui.AuthToken))
 		return fmt.Sprintf("auth_token:hash:%016X", h)
 	}
+	if ui.JWT != nil {
+		h := xxhash.Sum64([]byte(ui.JWT.Issuer + "\n" + ui.JWT.Audience))
+		return fmt.Sprintf("jwt:hash:%016X", h)
+	}
 	return ""
 }
 
@@ -1034,7 +1504,8 @@ func (up *URLPrefix) sanitizeAndInitialize() error {
 	bus := make([]*backendURL, len(up.busOriginal))
 	for i, bu := range up.busOriginal {
 		bus[i] = &backendURL{
-			url: bu,
+			url:    bu,
+			weight: up.getWeight(i),
 		}
 	}
 	up.bus.Store(&bus)
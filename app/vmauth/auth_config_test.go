@@ -4,9 +4,12 @@ import (
 	"bytes"
 	"fmt"
 	"net"
+	"net/http"
 	"net/url"
+	"strings"
 	"testing"
 
+	"github.com/VictoriaMetrics/metrics"
 	"gopkg.in/yaml.v2"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
@@ -277,6 +280,186 @@ users:
   metric_labels:
     not-prometheus-compatible: value
 `)
+
+	// Missing keys in jwt
+	f(`
+users:
+- jwt:
+    issuer: foo
+  url_prefix: http://foo.bar
+`)
+
+	// jwt with bearer_token
+	f(`
+users:
+- jwt:
+    hmac_secret: foo
+  bearer_token: bar
+  url_prefix: http://foo.bar
+`)
+
+	// Invalid public key in jwt
+	f(`
+users:
+- jwt:
+    public_keys: [foobar]
+  url_prefix: http://foo.bar
+`)
+
+	// jwt in unauthorized_user
+	f(`
+unauthorized_user:
+  jwt:
+    hmac_secret: foo
+  url_prefix: http://foo.bar
+`)
+
+	// invalid weight in url_prefix
+	f(`
+users:
+- username: foo
+  url_prefix:
+  - url: http://foo.bar
+    weight: 0
+`)
+	f(`
+users:
+- username: foo
+  url_prefix:
+  - url: http://foo.bar
+    weight: foo
+`)
+
+	// missing url in url_prefix item
+	f(`
+users:
+- username: foo
+  url_prefix:
+  - weight: 10
+`)
+
+	// unknown key in url_prefix item
+	f(`
+users:
+- username: foo
+  url_prefix:
+  - url: http://foo.bar
+    foo: bar
+`)
+
+	// missing consistent_hash_key for consistent_hash policy
+	f(`
+users:
+- username: foo
+  url_prefix: [http://foo.bar, http://baz]
+  load_balancing_policy: consistent_hash
+`)
+
+	// invalid consistent_hash_key
+	f(`
+users:
+- username: foo
+  url_prefix: [http://foo.bar, http://baz]
+  load_balancing_policy: consistent_hash
+  consistent_hash_key: foobar
+`)
+	f(`
+users:
+- username: foo
+  url_prefix: [http://foo.bar, http://baz]
+  load_balancing_policy: consistent_hash
+  consistent_hash_key: "header:"
+`)
+
+	// missing path in health_check
+	f(`
+users:
+- username: foo
+  url_prefix: http://foo.bar
+  health_check:
+    interval: 5s
+`)
+
+	// health_check path with host
+	f(`
+users:
+- username: foo
+  url_prefix: http://foo.bar
+  health_check:
+    path: http://foo.bar/health
+`)
+
+	// invalid health_check thresholds
+	f(`
+users:
+- username: foo
+  url_map:
+  - src_paths: ["/foo"]
+    url_prefix: http://foo.bar
+    health_check:
+      path: /health
+      unhealthy_threshold: -1
+`)
+
+	// invalid mirror_sample_ratio
+	f(`
+users:
+- username: foo
+  url_prefix: http://foo.bar
+  mirror_url_prefix: http://baz
+  mirror_sample_ratio: 1.5
+`)
+	f(`
+users:
+- username: foo
+  url_map:
+  - src_paths: ["/foo"]
+    url_prefix: http://foo.bar
+    mirror_url_prefix: http://baz
+    mirror_sample_ratio: 0
+`)
+
+	// mirror_sample_ratio without mirror_url_prefix
+	f(`
+users:
+- username: foo
+  url_prefix: http://foo.bar
+  mirror_sample_ratio: 0.5
+`)
+
+	// invalid mirror_url_prefix
+	f(`
+users:
+- username: foo
+  url_prefix: http://foo.bar
+  mirror_url_prefix: ftp://baz
+`)
+
+	// negative response_cache_ttl
+	f(`
+users:
+- username: foo
+  url_map:
+  - src_paths: ["/api/v1/query_range"]
+    url_prefix: http://foo.bar
+    response_cache_ttl: -1m
+`)
+
+	// invalid enforced_label_filters
+	f(`
+users:
+- username: foo
+  url_prefix: http://foo.bar
+  enforced_label_filters: ['team="payments",env="prod"']
+`)
+	f(`
+users:
+- username: foo
+  url_map:
+  - src_paths: ["/api/v1/query"]
+    url_prefix: http://foo.bar
+    enforced_label_filters: ["foo"]
+`)
 }
 
 func TestParseAuthConfigSuccess(t *testing.T) {
@@ -715,6 +898,44 @@ func isSetBool(boolP *bool, expectedValue bool) bool {
 	return *boolP == expectedValue
 }
 
+func TestUserInfoCheckRateLimits(t *testing.T) {
+	ui := &UserInfo{
+		MaxRequestsPerSecond:     2,
+		MaxRequestBytesPerSecond: 10,
+	}
+	if err := ui.initRateLimiters(metrics.NewSet(), "vmauth_user", ""); err != nil {
+		t.Fatalf("cannot initialize rate limiters: %s", err)
+	}
+	if _, err := ui.checkRateLimits(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Requests rejected by bytes limit mustn't consume requests limit.
+	ui.requestBytesRateLimiter.Consume(100)
+	for i := 0; i < 3; i++ {
+		_, err := ui.checkRateLimits()
+		if err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+		if !strings.Contains(err.Error(), "max_request_bytes_per_second") {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	// Restore the request bytes budget. The remaining request must pass requests limit.
+	ui.requestBytesRateLimiter.Consume(-100)
+	if _, err := ui.checkRateLimits(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	_, err := ui.checkRateLimits()
+	if err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+	if !strings.Contains(err.Error(), "max_requests_per_second") {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
 func TestGetLeastLoadedBackendURL(t *testing.T) {
 	up := mustParseURLs([]string{
 		"http://node1:343",
@@ -779,6 +1000,118 @@ func TestGetLeastLoadedBackendURL(t *testing.T) {
 	fn(7, 7, 7)
 }
 
+func TestGetWeightedRoundRobinBackendURL(t *testing.T) {
+	f := func(weights []int, resultExpected string) {
+		t.Helper()
+		us := make([]string, len(weights))
+		for i := range weights {
+			us[i] = fmt.Sprintf("http://node%d:343", i+1)
+		}
+		up := mustParseURLs(us)
+		up.loadBalancingPolicy = "weighted_round_robin"
+		pbus := up.bus.Load()
+		for i, bu := range *pbus {
+			bu.weight = weights[i]
+		}
+
+		var a []string
+		for i := 0; i < 10; i++ {
+			bu := up.getBackendURL()
+			a = append(a, bu.url.Host[:len("node1")])
+			bu.put()
+		}
+		result := strings.Join(a, ",")
+		if result != resultExpected {
+			t.Fatalf("unexpected backends order;\ngot\n%s\nwant\n%s", result, resultExpected)
+		}
+	}
+
+	f([]int{1}, "node1,node1,node1,node1,node1,node1,node1,node1,node1,node1")
+	f([]int{1, 1}, "node1,node2,node1,node2,node1,node2,node1,node2,node1,node2")
+	f([]int{9, 1}, "node1,node1,node1,node1,node1,node2,node1,node1,node1,node1")
+	f([]int{3, 1, 1}, "node1,node2,node1,node3,node1,node1,node2,node1,node3,node1")
+}
+
+func TestGetWeightedRoundRobinBackendURLBroken(t *testing.T) {
+	up := mustParseURLs([]string{
+		"http://node1:343",
+		"http://node2:343",
+	})
+	up.loadBalancingPolicy = "weighted_round_robin"
+	pbus := up.bus.Load()
+	bus := *pbus
+	bus[0].weight = 9
+	bus[1].weight = 1
+
+	// broken backend should never return while there are healthy backends
+	bus[0].setBroken()
+	for i := 0; i < 100; i++ {
+		bu := up.getBackendURL()
+		if bu.isBroken() {
+			t.Fatalf("unexpected broken backend %q", bu.url)
+		}
+		bu.put()
+	}
+}
+
+func TestGetConsistentHashBackendURL(t *testing.T) {
+	up := mustParseURLs([]string{
+		"http://node1:343",
+		"http://node2:343",
+		"http://node3:343",
+	})
+	if err := up.setLoadBalancingPolicy("consistent_hash", "header:X-Scope-OrgID"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	getBackend := func(tenant string) *backendURL {
+		t.Helper()
+		r, err := http.NewRequest(http.MethodGet, "http://vmauth/api/v1/query", nil)
+		if err != nil {
+			t.Fatalf("cannot initialize http request: %s", err)
+		}
+		if tenant != "" {
+			r.Header.Set("X-Scope-OrgID", tenant)
+		}
+		bu := up.getBackendURLForRequest(r)
+		bu.put()
+		return bu
+	}
+
+	// The same tenant must stick to the same backend.
+	perBackendTenants := make(map[*backendURL]int)
+	for i := 0; i < 100; i++ {
+		tenant := fmt.Sprintf("tenant_%d", i)
+		bu := getBackend(tenant)
+		for j := 0; j < 5; j++ {
+			if buNext := getBackend(tenant); buNext != bu {
+				t.Fatalf("unexpected backend for %q; got %q; want %q", tenant, buNext.url, bu.url)
+			}
+		}
+		perBackendTenants[bu]++
+	}
+	if len(perBackendTenants) != 3 {
+		t.Fatalf("tenants must be spread among all the backends; got %d backends", len(perBackendTenants))
+	}
+
+	// The tenant is moved to another backend if its backend is broken.
+	bu := getBackend("foo")
+	bu.setBroken()
+	buNext := getBackend("foo")
+	if buNext == bu {
+		t.Fatalf("unexpected broken backend %q", bu.url)
+	}
+	bu.brokenDeadline.Store(0)
+	if buNext := getBackend("foo"); buNext != bu {
+		t.Fatalf("the tenant must return to the backend %q after it becomes healthy; got %q", bu.url, buNext.url)
+	}
+
+	// Requests without the hash key are spread among backends.
+	if bu := getBackend(""); bu == nil {
+		t.Fatalf("expecting non-nil backend for request without the hash key")
+	}
+}
+
 func TestBrokenBackend(t *testing.T) {
 	up := mustParseURLs([]string{
 		"http://node1:343",
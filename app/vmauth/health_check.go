@@ -0,0 +1,348 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutil"
+)
+
+// HealthCheckConfig is the config for active health checks of url_prefix backends.
+//
+// See https://docs.victoriametrics.com/victoriametrics/vmauth/#health-checks
+type HealthCheckConfig struct {
+	// Path is the HTTP path to request at every backend. For example, /health
+	Path string `yaml:"path"`
+
+	// Interval is the interval between health checks. By default 5s
+	Interval *promutil.Duration `yaml:"interval,omitempty"`
+
+	// Timeout is the timeout for health check requests. By default it is equal to Interval
+	Timeout *promutil.Duration `yaml:"timeout,omitempty"`
+
+	// HealthyThreshold is the number of consecutive successful checks before marking unhealthy backend as healthy. By default 2
+	HealthyThreshold int `yaml:"healthy_threshold,omitempty"`
+
+	// UnhealthyThreshold is the number of consecutive failed checks before marking healthy backend as unhealthy. By default 3
+	UnhealthyThreshold int `yaml:"unhealthy_threshold,omitempty"`
+
+	pathURL            *url.URL
+	interval           time.Duration
+	timeout            time.Duration
+	healthyThreshold   int
+	unhealthyThreshold int
+}
+
+func (hcc *HealthCheckConfig) init() error {
+	if hcc.Path == "" {
+		return fmt.Errorf("missing `path` in `health_check`")
+	}
+	pu, err := url.Parse(hcc.Path)
+	if err != nil {
+		return fmt.Errorf("cannot parse `path: %q` in `health_check`: %w", hcc.Path, err)
+	}
+	if pu.Scheme != "" || pu.Host != "" {
+		return fmt.Errorf("`path: %q` in `health_check` mustn't contain scheme and host", hcc.Path)
+	}
+	hcc.pathURL = pu
+
+	hcc.interval = 5 * time.Second
+	if hcc.Interval != nil {
+		hcc.interval = hcc.Interval.Duration()
+	}
+	if hcc.interval <= 0 {
+		return fmt.Errorf("`interval` in `health_check` must be positive; got %s", hcc.interval)
+	}
+	hcc.timeout = hcc.interval
+	if hcc.Timeout != nil {
+		hcc.timeout = hcc.Timeout.Duration()
+	}
+	if hcc.timeout <= 0 {
+		return fmt.Errorf("`timeout` in `health_check` must be positive; got %s", hcc.timeout)
+	}
+
+	hcc.healthyThreshold = 2
+	if hcc.HealthyThreshold != 0 {
+		hcc.healthyThreshold = hcc.HealthyThreshold
+	}
+	if hcc.healthyThreshold < 0 {
+		return fmt.Errorf("`healthy_threshold` in `health_check` cannot be negative; got %d", hcc.healthyThreshold)
+	}
+	hcc.unhealthyThreshold = 3
+	if hcc.UnhealthyThreshold != 0 {
+		hcc.unhealthyThreshold = hcc.UnhealthyThreshold
+	}
+	if hcc.unhealthyThreshold < 0 {
+		return fmt.Errorf("`unhealthy_threshold` in `health_check` cannot be negative; got %d", hcc.unhealthyThreshold)
+	}
+	return nil
+}
+
+// backendHealthStatus is the result of active health checks for the backend.
+type backendHealthStatus struct {
+	healthy   bool
+	lastCheck time.Time
+	lastError string
+
+	// the number of consecutive successful or failed checks depending on the result of the last check
+	consecutiveChecks int
+}
+
+// healthChecker performs active health checks for up backends.
+type healthChecker struct {
+	up  *URLPrefix
+	cfg *HealthCheckConfig
+	rt  http.RoundTripper
+
+	ms           *metrics.Set
+	metricLabels string
+}
+
+// startHealthChecks starts active health checks for backends of all the users with health_check option.
+//
+// stopHealthChecks must be called when the health checks are no longer needed.
+func (ac *AuthConfig) startHealthChecks() {
+	ac.healthChecksStopCh = make(chan struct{})
+
+	startForUser := func(ui *UserInfo) {
+		metricLabels, err := ui.getMetricLabels()
+		if err != nil {
+			logger.Panicf("BUG: metric labels must be already validated: %s", err)
+		}
+		startForURLPrefix := func(up *URLPrefix) {
+			if up == nil || up.healthCheck == nil {
+				return
+			}
+			hc := &healthChecker{
+				up:           up,
+				cfg:          up.healthCheck,
+				rt:           ui.rt,
+				ms:           ac.ms,
+				metricLabels: metricLabels,
+			}
+			ac.healthChecksWG.Add(1)
+			go func() {
+				defer ac.healthChecksWG.Done()
+				hc.run(ac.healthChecksStopCh)
+			}()
+		}
+		startForURLPrefix(ui.URLPrefix)
+		for _, e := range ui.URLMaps {
+			startForURLPrefix(e.URLPrefix)
+		}
+	}
+
+	if ac.UnauthorizedUser != nil {
+		startForUser(ac.UnauthorizedUser)
+	}
+	for i := range ac.Users {
+		startForUser(&ac.Users[i])
+	}
+}
+
+// stopHealthChecks stops health checks started via startHealthChecks.
+func (ac *AuthConfig) stopHealthChecks() {
+	if ac.healthChecksStopCh == nil {
+		return
+	}
+	close(ac.healthChecksStopCh)
+	ac.healthChecksWG.Wait()
+}
+
+func (hc *healthChecker) run(stopCh <-chan struct{}) {
+	ticker := time.NewTicker(hc.cfg.interval)
+	defer ticker.Stop()
+
+	for {
+		hc.checkBackends(stopCh)
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (hc *healthChecker) checkBackends(stopCh <-chan struct{}) {
+	hc.up.discoverBackendAddrsIfNeeded()
+
+	ctx, cancel := context.WithTimeout(context.Background(), hc.cfg.timeout)
+	defer cancel()
+	go func() {
+		select {
+		case <-stopCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	pbus := hc.up.bus.Load()
+	var wg sync.WaitGroup
+	for _, bu := range *pbus {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := hc.checkBackend(ctx, bu)
+			if ctx.Err() != nil && !hc.isTimeout(ctx) {
+				// The health check has been canceled because of stopCh.
+				return
+			}
+			hc.updateStatus(bu, err)
+		}()
+	}
+	wg.Wait()
+}
+
+func (hc *healthChecker) isTimeout(ctx context.Context) bool {
+	deadline, ok := ctx.Deadline()
+	return ok && !time.Now().Before(deadline)
+}
+
+func (hc *healthChecker) checkBackend(ctx context.Context, bu *backendURL) error {
+	u := *bu.url
+	u.Path = hc.cfg.pathURL.Path
+	u.RawPath = hc.cfg.pathURL.RawPath
+	u.RawQuery = hc.cfg.pathURL.RawQuery
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return fmt.Errorf("cannot create health check request to %s: %w", u.Redacted(), err)
+	}
+	req.Header.Set("User-Agent", "vmauth")
+	resp, err := hc.rt.RoundTrip(req)
+	if err != nil {
+		return fmt.Errorf("cannot perform health check request to %s: %w", u.Redacted(), err)
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response status code for health check request to %s: %d; want 2xx", u.Redacted(), resp.StatusCode)
+	}
+	return nil
+}
+
+func (hc *healthChecker) updateStatus(bu *backendURL, err error) {
+	hsPrev := bu.healthStatus.Load()
+	hs := &backendHealthStatus{
+		healthy:   true,
+		lastCheck: time.Now(),
+	}
+	if hsPrev != nil {
+		hs.healthy = hsPrev.healthy
+		hs.consecutiveChecks = hsPrev.consecutiveChecks
+	}
+	succeeded := err == nil
+	if err != nil {
+		hs.lastError = err.Error()
+	}
+	if hsPrev == nil || (hsPrev.lastError == "") != succeeded {
+		// The result of the check differs from the previous one. Reset the counter.
+		hs.consecutiveChecks = 0
+	}
+	hs.consecutiveChecks++
+
+	if succeeded && !hs.healthy && hs.consecutiveChecks >= hc.cfg.healthyThreshold {
+		hs.healthy = true
+		logger.Infof("backend %s is healthy again after %d successful health checks", bu.url.Redacted(), hs.consecutiveChecks)
+	}
+	if !succeeded && hs.healthy && hs.consecutiveChecks >= hc.cfg.unhealthyThreshold {
+		hs.healthy = false
+		logger.Warnf("backend %s is marked as unhealthy after %d failed health checks; last error: %s", bu.url.Redacted(), hs.consecutiveChecks, err)
+	}
+	bu.healthStatus.Store(hs)
+
+	labels := hc.getBackendMetricLabels(bu)
+	hc.ms.GetOrCreateCounter(`vmauth_backend_health_checks_total` + labels).Inc()
+	if !succeeded {
+		hc.ms.GetOrCreateCounter(`vmauth_backend_health_check_errors_total` + labels).Inc()
+	}
+	healthy := 0.0
+	if hs.healthy {
+		healthy = 1
+	}
+	hc.ms.GetOrCreateGauge(`vmauth_backend_healthy`+labels, nil).Set(healthy)
+}
+
+func (hc *healthChecker) getBackendMetricLabels(bu *backendURL) string {
+	return addMetricLabel(hc.metricLabels, fmt.Sprintf("backend=%q", bu.url.Redacted()))
+}
+
+// backendsStatus is the status of url_prefix backends returned at /-/backends page.
+type backendsStatus struct {
+	Username  string          `json:"username"`
+	URLPrefix []string        `json:"url_prefix"`
+	Backends  []backendStatus `json:"backends"`
+}
+
+type backendStatus struct {
+	URL                string `json:"url"`
+	Broken             bool   `json:"broken"`
+	ConcurrentRequests int32  `json:"concurrent_requests"`
+
+	// The following fields are set only if active health checks are enabled for the backend.
+	Healthy              *bool  `json:"healthy,omitempty"`
+	LastHealthCheck      string `json:"last_health_check,omitempty"`
+	LastHealthCheckError string `json:"last_health_check_error,omitempty"`
+}
+
+// writeBackendsStatus writes the status of all the backends from ac to w in JSON format.
+func writeBackendsStatus(w io.Writer, ac *AuthConfig) error {
+	var result []backendsStatus
+	appendForUser := func(ui *UserInfo, username string) {
+		appendForURLPrefix := func(up *URLPrefix) {
+			if up == nil {
+				return
+			}
+			bss := backendsStatus{
+				Username: username,
+				Backends: []backendStatus{},
+			}
+			for _, u := range up.busOriginal {
+				bss.URLPrefix = append(bss.URLPrefix, u.Redacted())
+			}
+			pbus := up.bus.Load()
+			for _, bu := range *pbus {
+				bs := backendStatus{
+					URL:                bu.url.Redacted(),
+					Broken:             bu.isBroken(),
+					ConcurrentRequests: bu.concurrentRequests.Load(),
+				}
+				if hs := bu.healthStatus.Load(); hs != nil {
+					healthy := hs.healthy
+					bs.Healthy = &healthy
+					bs.LastHealthCheck = hs.lastCheck.UTC().Format(time.RFC3339)
+					bs.LastHealthCheckError = hs.lastError
+				}
+				bss.Backends = append(bss.Backends, bs)
+			}
+			result = append(result, bss)
+		}
+		appendForURLPrefix(ui.URLPrefix)
+		for _, e := range ui.URLMaps {
+			appendForURLPrefix(e.URLPrefix)
+		}
+		appendForURLPrefix(ui.DefaultURL)
+	}
+
+	if ac.UnauthorizedUser != nil {
+		appendForUser(ac.UnauthorizedUser, "")
+	}
+	for i := range ac.Users {
+		ui := &ac.Users[i]
+		appendForUser(ui, ui.name())
+	}
+
+	return json.NewEncoder(w).Encode(map[string]any{
+		"status": "success",
+		"data":   result,
+	})
+}
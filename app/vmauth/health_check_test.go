@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/VictoriaMetrics/metrics"
+)
+
+func TestHealthCheckerUpdateStatus(t *testing.T) {
+	up := mustParseURL("http://foo:8428")
+	cfg := &HealthCheckConfig{
+		Path:               "/health",
+		HealthyThreshold:   2,
+		UnhealthyThreshold: 3,
+	}
+	if err := cfg.init(); err != nil {
+		t.Fatalf("cannot initialize health check config: %s", err)
+	}
+	hc := &healthChecker{
+		up:  up,
+		cfg: cfg,
+		ms:  metrics.NewSet(),
+	}
+	bu := (*up.bus.Load())[0]
+
+	f := func(checkErr error, brokenExpected bool) {
+		t.Helper()
+		hc.updateStatus(bu, checkErr)
+		if bu.isBroken() != brokenExpected {
+			t.Fatalf("unexpected isBroken result; got %v; want %v", bu.isBroken(), brokenExpected)
+		}
+	}
+
+	checkErr := fmt.Errorf("connection refused")
+
+	// The backend is marked as unhealthy after unhealthy_threshold consecutive failures.
+	f(nil, false)
+	f(checkErr, false)
+	f(checkErr, false)
+	f(nil, false)
+	f(checkErr, false)
+	f(checkErr, false)
+	f(checkErr, true)
+	f(checkErr, true)
+
+	// The backend is marked as healthy after healthy_threshold consecutive successes.
+	f(nil, true)
+	f(checkErr, true)
+	f(nil, true)
+	f(nil, false)
+	f(nil, false)
+
+	var bb bytes.Buffer
+	hc.ms.WritePrometheus(&bb)
+	result := bb.String()
+	for _, s := range []string{
+		`vmauth_backend_healthy{backend="http://foo:8428"} 1`,
+		`vmauth_backend_health_checks_total{backend="http://foo:8428"} 13`,
+		`vmauth_backend_health_check_errors_total{backend="http://foo:8428"} 7`,
+	} {
+		if !strings.Contains(result, s) {
+			t.Fatalf("missing %q in metrics:\n%s", s, result)
+		}
+	}
+}
+
+func TestHealthCheckerCheckBackends(t *testing.T) {
+	var healthy atomic.Bool
+	healthy.Store(true)
+	var requestedPath atomic.Pointer[string]
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.RequestURI()
+		requestedPath.Store(&path)
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+
+	up := mustParseURL(ts.URL + "/select/0/prometheus")
+	cfg := &HealthCheckConfig{
+		Path:               "/health?extended=1",
+		HealthyThreshold:   1,
+		UnhealthyThreshold: 1,
+	}
+	if err := cfg.init(); err != nil {
+		t.Fatalf("cannot initialize health check config: %s", err)
+	}
+	hc := &healthChecker{
+		up:           up,
+		cfg:          cfg,
+		rt:           http.DefaultTransport,
+		ms:           metrics.NewSet(),
+		metricLabels: `{username="foo"}`,
+	}
+	bu := (*up.bus.Load())[0]
+	stopCh := make(chan struct{})
+
+	hc.checkBackends(stopCh)
+	if bu.isBroken() {
+		t.Fatalf("backend mustn't be broken")
+	}
+	if path := *requestedPath.Load(); path != "/health?extended=1" {
+		t.Fatalf("unexpected health check path; got %q; want %q", path, "/health?extended=1")
+	}
+
+	healthy.Store(false)
+	hc.checkBackends(stopCh)
+	if !bu.isBroken() {
+		t.Fatalf("backend must be broken")
+	}
+	hs := bu.healthStatus.Load()
+	if !strings.Contains(hs.lastError, "unexpected response status code") {
+		t.Fatalf("unexpected last error: %q", hs.lastError)
+	}
+
+	// The status page must contain the backend state.
+	ac := &AuthConfig{
+		Users: []UserInfo{{
+			Username:  "foo",
+			URLPrefix: up,
+		}},
+	}
+	var bb bytes.Buffer
+	if err := writeBackendsStatus(&bb, ac); err != nil {
+		t.Fatalf("cannot write backends status: %s", err)
+	}
+	result := bb.String()
+	for _, s := range []string{`"username":"foo"`, `"broken":true`, `"healthy":false`, `"last_health_check_error":"unexpected response status code`} {
+		if !strings.Contains(result, s) {
+			t.Fatalf("missing %q in backends status:\n%s", s, result)
+		}
+	}
+
+	healthy.Store(true)
+	hc.checkBackends(stopCh)
+	if bu.isBroken() {
+		t.Fatalf("backend mustn't be broken after successful health check")
+	}
+}
@@ -0,0 +1,493 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs/fscore"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httpserver"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+)
+
+// JWTConfig is the config for authorizing users by JWT bearer tokens.
+//
+// See https://docs.victoriametrics.com/victoriametrics/vmauth/#jwt-authorization
+type JWTConfig struct {
+	// PublicKeys is an optional list of PEM-encoded RSA or ECDSA public keys for verifying token signatures.
+	PublicKeys []string `yaml:"public_keys,omitempty"`
+
+	// PublicKeyFiles is an optional list of paths to files with PEM-encoded RSA or ECDSA public keys.
+	PublicKeyFiles []string `yaml:"public_key_files,omitempty"`
+
+	// JWKSFiles is an optional list of paths or http urls to JSON Web Key Sets.
+	JWKSFiles []string `yaml:"jwks_files,omitempty"`
+
+	// HMACSecret is an optional secret for verifying HS256 token signatures.
+	HMACSecret string `yaml:"hmac_secret,omitempty"`
+
+	// Issuer is an optional value, which must match `iss` claim.
+	Issuer string `yaml:"issuer,omitempty"`
+
+	// Audience is an optional value, which must be contained in `aud` claim.
+	Audience string `yaml:"audience,omitempty"`
+
+	// keys contains keys for verifying token signatures.
+	keys atomic.Pointer[[]*jwtKey]
+
+	// lastKeysRefresh contains the last unix timestamp when the keys were refreshed.
+	lastKeysRefresh atomic.Uint64
+
+	parserOptions []jwt.ParserOption
+}
+
+// jwtKeysRefreshInterval is the minimum interval in seconds between keys' refreshes
+// when a token with unknown key id is received.
+const jwtKeysRefreshInterval = 30
+
+type jwtKey struct {
+	// kid is an optional key id from JWKS.
+	kid string
+
+	// key is either *rsa.PublicKey, *ecdsa.PublicKey or []byte
+	key any
+}
+
+var supportedJWTSigningMethods = []string{"RS256", "ES256", "HS256"}
+
+func (jc *JWTConfig) init() error {
+	if len(jc.PublicKeys) == 0 && len(jc.PublicKeyFiles) == 0 && len(jc.JWKSFiles) == 0 && jc.HMACSecret == "" {
+		return fmt.Errorf("missing `public_keys`, `public_key_files`, `jwks_files` and `hmac_secret` in `jwt` section")
+	}
+	keys, err := jc.loadKeys()
+	if err != nil {
+		return err
+	}
+	jc.keys.Store(&keys)
+	jc.lastKeysRefresh.Store(fasttime.UnixTimestamp())
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(supportedJWTSigningMethods),
+		jwt.WithExpirationRequired(),
+	}
+	if jc.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(jc.Issuer))
+	}
+	if jc.Audience != "" {
+		opts = append(opts, jwt.WithAudience(jc.Audience))
+	}
+	jc.parserOptions = opts
+	return nil
+}
+
+func (jc *JWTConfig) loadKeys() ([]*jwtKey, error) {
+	var keys []*jwtKey
+	for _, s := range jc.PublicKeys {
+		key, err := parsePEMPublicKey([]byte(s))
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse `public_keys` entry: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	for _, path := range jc.PublicKeyFiles {
+		data, err := fscore.ReadFileOrHTTP(path)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read `public_key_files` entry: %w", err)
+		}
+		key, err := parsePEMPublicKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse public key from %q: %w", path, err)
+		}
+		keys = append(keys, key)
+	}
+	for _, path := range jc.JWKSFiles {
+		data, err := fscore.ReadFileOrHTTP(path)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read `jwks_files` entry: %w", err)
+		}
+		jwksKeys, err := parseJWKS(data)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse JWKS from %q: %w", path, err)
+		}
+		keys = append(keys, jwksKeys...)
+	}
+	if jc.HMACSecret != "" {
+		keys = append(keys, &jwtKey{
+			key: []byte(jc.HMACSecret),
+		})
+	}
+	return keys, nil
+}
+
+func parsePEMPublicKey(data []byte) (*jwtKey, error) {
+	if key, err := jwt.ParseRSAPublicKeyFromPEM(data); err == nil {
+		return &jwtKey{
+			key: key,
+		}, nil
+	}
+	key, err := jwt.ParseECPublicKeyFromPEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse PEM-encoded RSA or ECDSA public key: %w", err)
+	}
+	return &jwtKey{
+		key: key,
+	}, nil
+}
+
+// jwk represents JSON Web Key.
+//
+// See https://datatracker.ietf.org/doc/html/rfc7517
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+
+	// RSA key params
+	N string `json:"n"`
+	E string `json:"e"`
+
+	// EC key params
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+
+	// Symmetric key param
+	K string `json:"k"`
+}
+
+func parseJWKS(data []byte) ([]*jwtKey, error) {
+	var jwks struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &jwks); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal JWKS: %w", err)
+	}
+	var keys []*jwtKey
+	for _, k := range jwks.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.getKey()
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse key with kid=%q: %w", k.Kid, err)
+		}
+		if key == nil {
+			// Skip unsupported key type
+			continue
+		}
+		keys = append(keys, &jwtKey{
+			kid: k.Kid,
+			key: key,
+		})
+	}
+	return keys, nil
+}
+
+func (k *jwk) getKey() (any, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeJWKBigInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("cannot decode `n`: %w", err)
+		}
+		e, err := decodeJWKBigInt(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("cannot decode `e`: %w", err)
+		}
+		if !e.IsInt64() || e.Int64() <= 1 || e.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("unsupported exponent `e`=%s", e)
+		}
+		return &rsa.PublicKey{
+			N: n,
+			E: int(e.Int64()),
+		}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve `crv`=%q", k.Crv)
+		}
+		x, err := decodeJWKBigInt(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("cannot decode `x`: %w", err)
+		}
+		y, err := decodeJWKBigInt(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("cannot decode `y`: %w", err)
+		}
+		return &ecdsa.PublicKey{
+			Curve: curve,
+			X:     x,
+			Y:     y,
+		}, nil
+	case "oct":
+		key, err := base64.RawURLEncoding.DecodeString(k.K)
+		if err != nil {
+			return nil, fmt.Errorf("cannot decode `k`: %w", err)
+		}
+		return key, nil
+	default:
+		return nil, nil
+	}
+}
+
+func decodeJWKBigInt(s string) (*big.Int, error) {
+	if s == "" {
+		return nil, fmt.Errorf("missing value")
+	}
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// parseToken verifies the given token and returns its claims.
+func (jc *JWTConfig) parseToken(token string) (jwtClaims, error) {
+	claims := make(jwt.MapClaims)
+	if _, err := jwt.ParseWithClaims(token, claims, jc.getVerificationKeys, jc.parserOptions...); err != nil {
+		return nil, err
+	}
+	return jwtClaims(claims), nil
+}
+
+func (jc *JWTConfig) getVerificationKeys(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	ks := jc.getKeys(kid)
+	if len(ks.Keys) == 0 && kid != "" && len(jc.JWKSFiles) > 0 {
+		// The key may be rotated at identity provider. Try refreshing the keys.
+		jc.refreshKeys()
+		ks = jc.getKeys(kid)
+	}
+	if len(ks.Keys) == 0 {
+		return nil, fmt.Errorf("cannot find verification key for kid=%q", kid)
+	}
+	return ks, nil
+}
+
+func (jc *JWTConfig) getKeys(kid string) jwt.VerificationKeySet {
+	var ks jwt.VerificationKeySet
+	for _, k := range *jc.keys.Load() {
+		// Keys without kid can verify tokens with any kid.
+		if kid == "" || k.kid == "" || k.kid == kid {
+			ks.Keys = append(ks.Keys, k.key)
+		}
+	}
+	return ks
+}
+
+func (jc *JWTConfig) refreshKeys() {
+	lastRefresh := jc.lastKeysRefresh.Load()
+	currentTime := fasttime.UnixTimestamp()
+	if currentTime-lastRefresh < jwtKeysRefreshInterval {
+		return
+	}
+	if !jc.lastKeysRefresh.CompareAndSwap(lastRefresh, currentTime) {
+		// Concurrent goroutine refreshes the keys.
+		return
+	}
+	keys, err := jc.loadKeys()
+	if err != nil {
+		logger.Errorf("cannot refresh JWT verification keys: %s", err)
+		return
+	}
+	jc.keys.Store(&keys)
+}
+
+// getJWTUserInfo returns user info for the JWT bearer token from ats.
+func getJWTUserInfo(ats []string) (*UserInfo, jwtClaims) {
+	jwtUsers := authConfig.Load().jwtUsers
+	if len(jwtUsers) == 0 {
+		return nil, nil
+	}
+	for _, at := range ats {
+		token, ok := strings.CutPrefix(at, "http_auth:Bearer ")
+		if !ok || strings.Count(token, ".") != 2 {
+			continue
+		}
+		for _, ui := range jwtUsers {
+			claims, err := ui.JWT.parseToken(token)
+			if err == nil {
+				return ui, claims
+			}
+			if *logInvalidAuthTokens {
+				logger.Infof("cannot verify JWT token for user %s: %s", ui.name(), err)
+			}
+		}
+	}
+	return nil, nil
+}
+
+// jwtClaims contains claims from verified JWT token.
+//
+// Claim values can be substituted into `{{.claim_name}}` placeholders at `url_prefix`, `headers` and `src_query_args`.
+type jwtClaims map[string]any
+
+var claimPlaceholderRegexp = regexp.MustCompile(`\{\{\s*\.([^{}\s]+)\s*\}\}`)
+
+func hasClaimPlaceholders(s string) bool {
+	return strings.Contains(s, "{{") && claimPlaceholderRegexp.MatchString(s)
+}
+
+// substitute substitutes `{{.claim_name}}` placeholders in s with the corresponding claim values.
+//
+// Nested claims can be referred via `{{.parent.child}}`.
+func (claims jwtClaims) substitute(s string) (string, error) {
+	return claims.substituteWithCheck(s, nil)
+}
+
+// substitutePath substitutes claim placeholders in the given url path.
+//
+// Claim values cannot contain `/` and cannot be equal to `.` or `..` in order to prevent from path traversal.
+func (claims jwtClaims) substitutePath(path string) (string, error) {
+	return claims.substituteWithCheck(path, func(name, v string) error {
+		if strings.Contains(v, "/") {
+			return fmt.Errorf("claim %q substituted into url path mustn't contain `/`; got %q", name, v)
+		}
+		if v == "." || v == ".." {
+			return fmt.Errorf("claim %q substituted into url path mustn't be equal to %q", name, v)
+		}
+		return nil
+	})
+}
+
+func (claims jwtClaims) substituteWithCheck(s string, checkValue func(name, v string) error) (string, error) {
+	if !strings.Contains(s, "{{") {
+		return s, nil
+	}
+	var firstErr error
+	result := claimPlaceholderRegexp.ReplaceAllStringFunc(s, func(placeholder string) string {
+		name := claimPlaceholderRegexp.FindStringSubmatch(placeholder)[1]
+		v, err := claims.getValue(name)
+		if err == nil && checkValue != nil {
+			err = checkValue(name, v)
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		return v
+	})
+	if firstErr != nil {
+		return "", firstErr
+	}
+	return result, nil
+}
+
+func (claims jwtClaims) getValue(name string) (string, error) {
+	var v any = map[string]any(claims)
+	for _, part := range strings.Split(name, ".") {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return "", fmt.Errorf("claim %q isn't an object", name)
+		}
+		v, ok = m[part]
+		if !ok {
+			return "", fmt.Errorf("missing claim %q", name)
+		}
+	}
+	switch t := v.(type) {
+	case string:
+		return t, nil
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(t), nil
+	default:
+		return "", fmt.Errorf("unsupported type %T for claim %q; supported types: string, number, bool", v, name)
+	}
+}
+
+// substituteURL substitutes claim placeholders in u path and query args.
+func (claims jwtClaims) substituteURL(u *url.URL) (*url.URL, error) {
+	if !strings.Contains(u.Path, "{{") && !strings.Contains(u.RawQuery, "{{") {
+		return u, nil
+	}
+	uCopy := *u
+	path, err := claims.substitutePath(u.Path)
+	if err != nil {
+		return nil, err
+	}
+	if path != u.Path {
+		uCopy.Path = path
+		uCopy.RawPath = ""
+	}
+	if strings.Contains(u.RawQuery, "{{") {
+		args := u.Query()
+		for _, vs := range args {
+			for i, v := range vs {
+				vNew, err := claims.substitute(v)
+				if err != nil {
+					return nil, err
+				}
+				vs[i] = vNew
+			}
+		}
+		uCopy.RawQuery = args.Encode()
+	}
+	return &uCopy, nil
+}
+
+// substituteHeaders substitutes claim placeholders in hc header values.
+func (claims jwtClaims) substituteHeaders(hc HeadersConf) (HeadersConf, error) {
+	var err error
+	hc.RequestHeaders, err = claims.substituteHeaderValues(hc.RequestHeaders)
+	if err != nil {
+		return hc, err
+	}
+	hc.ResponseHeaders, err = claims.substituteHeaderValues(hc.ResponseHeaders)
+	return hc, err
+}
+
+func (claims jwtClaims) substituteHeaderValues(headers []*Header) ([]*Header, error) {
+	hasPlaceholders := false
+	for _, h := range headers {
+		if strings.Contains(h.Value, "{{") {
+			hasPlaceholders = true
+			break
+		}
+	}
+	if !hasPlaceholders {
+		return headers, nil
+	}
+	result := make([]*Header, len(headers))
+	for i, h := range headers {
+		v, err := claims.substitute(h.Value)
+		if err != nil {
+			return nil, fmt.Errorf("cannot substitute claims into %q header: %w", h.Name, err)
+		}
+		result[i] = &Header{
+			Name:      h.Name,
+			Value:     v,
+			sOriginal: h.sOriginal,
+		}
+	}
+	return result, nil
+}
+
+func handleJWTClaimsError(w http.ResponseWriter, r *http.Request, err error) {
+	jwtClaimsErrors.Inc()
+	err = &httpserver.ErrorWithStatusCode{
+		Err:        fmt.Errorf("cannot apply JWT claims: %w", err),
+		StatusCode: http.StatusForbidden,
+	}
+	httpserver.Errorf(w, r, "%s", err)
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestJWTClaimsSubstitute(t *testing.T) {
+	claims := jwtClaims{
+		"tenant_id": "123:4",
+		"team":      "foo",
+		"num":       float64(42),
+		"admin":     true,
+		"vm_access": map[string]any{
+			"account_id": float64(5),
+		},
+		"groups": []any{"a", "b"},
+	}
+	f := func(s, resultExpected string) {
+		t.Helper()
+		result, err := claims.substitute(s)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if result != resultExpected {
+			t.Fatalf("unexpected result; got %q; want %q", result, resultExpected)
+		}
+	}
+	fFailure := func(s string) {
+		t.Helper()
+		if _, err := claims.substitute(s); err == nil {
+			t.Fatalf("expecting non-nil error for %q", s)
+		}
+	}
+
+	f("", "")
+	f("foo", "foo")
+	f("/insert/{{.tenant_id}}/prometheus", "/insert/123:4/prometheus")
+	f("{{ .team }}-{{.num}}-{{.admin}}", "foo-42-true")
+	f("{{.vm_access.account_id}}", "5")
+
+	// missing claim
+	fFailure("{{.missing}}")
+	fFailure("{{.team.foo}}")
+
+	// unsupported claim type
+	fFailure("{{.groups}}")
+	fFailure("{{.vm_access}}")
+}
+
+func TestJWTClaimsSubstituteURL(t *testing.T) {
+	claims := jwtClaims{
+		"tenant_id": "123",
+		"path":      "../foo",
+		"parent":    "..",
+		"subpath":   "foo/bar",
+		"team":      "a&b",
+		"query":     "a/b",
+	}
+	f := func(s, resultExpected string) {
+		t.Helper()
+		u, err := url.Parse(s)
+		if err != nil {
+			t.Fatalf("cannot parse %q: %s", s, err)
+		}
+		result, err := claims.substituteURL(u)
+		if err != nil {
+			if resultExpected != "error" {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			return
+		}
+		if resultExpected == "error" {
+			t.Fatalf("expecting non-nil error for %q", s)
+		}
+		if result.String() != resultExpected {
+			t.Fatalf("unexpected url; got %q; want %q", result, resultExpected)
+		}
+	}
+
+	f("http://foo/bar", "http://foo/bar")
+	f("http://foo/insert/{{.tenant_id}}/prometheus", "http://foo/insert/123/prometheus")
+	f("http://foo/select?extra_label=team={{.team}}", "http://foo/select?extra_label=team%3Da%26b")
+
+	// path traversal
+	f("http://foo/bar/{{.path}}", "error")
+	f("http://foo/bar/{{.parent}}", "error")
+
+	// slashes in claims substituted into path
+	f("http://foo/bar/{{.subpath}}", "error")
+
+	// slashes are allowed in claims substituted into query args
+	f("http://foo/bar?x={{.query}}", "http://foo/bar?x=a%2Fb")
+
+	// missing claim
+	f("http://foo/bar/{{.missing}}", "error")
+}
+
+func TestJWTConfigParseToken(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("cannot generate RSA key: %s", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("cannot generate ECDSA key: %s", err)
+	}
+	jwksData := fmt.Sprintf(`{"keys":[
+		{"kty":"RSA","kid":"rsa1","use":"sig","n":%q,"e":%q},
+		{"kty":"EC","kid":"ec1","crv":"P-256","x":%q,"y":%q},
+		{"kty":"RSA","kid":"enc1","use":"enc","n":%q,"e":%q},
+		{"kty":"OKP","kid":"unsupported"}
+	]}`, encodeBigInt(rsaKey.N), encodeBigInt(big.NewInt(int64(rsaKey.E))), encodeBigInt(ecKey.X), encodeBigInt(ecKey.Y),
+		encodeBigInt(rsaKey.N), encodeBigInt(big.NewInt(int64(rsaKey.E))))
+
+	var jc JWTConfig
+	jc.JWKSFiles = []string{writeTempFile(t, "jwks.json", jwksData)}
+	jc.PublicKeys = []string{mustMarshalPublicKeyPEM(t, &ecKey.PublicKey)}
+	jc.HMACSecret = "secret"
+	jc.Issuer = "https://issuer"
+	jc.Audience = "vmauth"
+	if err := jc.init(); err != nil {
+		t.Fatalf("cannot initialize JWT config: %s", err)
+	}
+
+	validClaims := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":       "https://issuer",
+			"aud":       []string{"foo", "vmauth"},
+			"exp":       time.Now().Add(time.Hour).Unix(),
+			"tenant_id": "42",
+		}
+	}
+	f := func(method jwt.SigningMethod, kid string, key any, claims jwt.MapClaims, okExpected bool) {
+		t.Helper()
+		token := jwt.NewWithClaims(method, claims)
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		s, err := token.SignedString(key)
+		if err != nil {
+			t.Fatalf("cannot sign token: %s", err)
+		}
+		result, err := jc.parseToken(s)
+		if !okExpected {
+			if err == nil {
+				t.Fatalf("expecting non-nil error")
+			}
+			return
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if result["tenant_id"] != "42" {
+			t.Fatalf("unexpected claims: %v", result)
+		}
+	}
+
+	// valid tokens
+	f(jwt.SigningMethodRS256, "rsa1", rsaKey, validClaims(), true)
+	f(jwt.SigningMethodES256, "ec1", ecKey, validClaims(), true)
+	f(jwt.SigningMethodES256, "", ecKey, validClaims(), true)
+	f(jwt.SigningMethodHS256, "", []byte("secret"), validClaims(), true)
+
+	// RSA key is set only in JWKS with kid
+	f(jwt.SigningMethodRS256, "", rsaKey, validClaims(), true)
+
+	// unknown kid
+	f(jwt.SigningMethodRS256, "missing", rsaKey, validClaims(), false)
+
+	// key with non-sig use
+	f(jwt.SigningMethodRS256, "enc1", rsaKey, validClaims(), false)
+
+	// invalid signature
+	otherRSAKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("cannot generate RSA key: %s", err)
+	}
+	f(jwt.SigningMethodRS256, "rsa1", otherRSAKey, validClaims(), false)
+	f(jwt.SigningMethodHS256, "", []byte("invalid-secret"), validClaims(), false)
+
+	// unsupported signing method
+	f(jwt.SigningMethodRS512, "rsa1", rsaKey, validClaims(), false)
+
+	// expired token
+	claims := validClaims()
+	claims["exp"] = time.Now().Add(-time.Minute).Unix()
+	f(jwt.SigningMethodRS256, "rsa1", rsaKey, claims, false)
+
+	// missing exp
+	claims = validClaims()
+	delete(claims, "exp")
+	f(jwt.SigningMethodRS256, "rsa1", rsaKey, claims, false)
+
+	// invalid issuer
+	claims = validClaims()
+	claims["iss"] = "https://other-issuer"
+	f(jwt.SigningMethodRS256, "rsa1", rsaKey, claims, false)
+
+	// invalid audience
+	claims = validClaims()
+	claims["aud"] = "other"
+	f(jwt.SigningMethodRS256, "rsa1", rsaKey, claims, false)
+}
+
+func TestRequestHandlerJWT(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "requested_url=%s\nX-Team=%s", r.URL, r.Header.Get("X-Team"))
+	}))
+	defer ts.Close()
+
+	cfgStr := strings.ReplaceAll(`
+users:
+- username: foo
+  password: bar
+  url_prefix: {BACKEND}/static
+- jwt:
+    hmac_secret: secret
+    issuer: https://issuer
+  url_map:
+  - src_paths: ["/select/.*"]
+    src_query_args: ["tenant={{.tenant_id}}"]
+    url_prefix: "{BACKEND}/select/{{.tenant_id}}/prometheus"
+    headers:
+    - "X-Team: {{.team}}"
+  - src_paths: ["/insert/.*"]
+    url_prefix: "{BACKEND}/insert/{{.missing}}"
+`, "{BACKEND}", ts.URL)
+
+	cfgOrigP := authConfigData.Load()
+	if _, err := reloadAuthConfigData([]byte(cfgStr)); err != nil {
+		t.Fatalf("cannot load config data: %s", err)
+	}
+	defer func() {
+		cfgOrig := []byte("unauthorized_user:\n  url_prefix: http://foo/bar")
+		if cfgOrigP != nil {
+			cfgOrig = *cfgOrigP
+		}
+		if _, err := reloadAuthConfigData(cfgOrig); err != nil {
+			t.Fatalf("cannot load the original config: %s", err)
+		}
+	}()
+
+	newToken := func(secret, tenantID string) string {
+		t.Helper()
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"iss":       "https://issuer",
+			"exp":       time.Now().Add(time.Hour).Unix(),
+			"tenant_id": tenantID,
+			"team":      "devs",
+		})
+		s, err := token.SignedString([]byte(secret))
+		if err != nil {
+			t.Fatalf("cannot sign token: %s", err)
+		}
+		return s
+	}
+	f := func(requestURL, token, responseExpected string) {
+		t.Helper()
+		r, err := http.NewRequest(http.MethodGet, requestURL, nil)
+		if err != nil {
+			t.Fatalf("cannot initialize http request: %s", err)
+		}
+		r.RequestURI = r.URL.RequestURI()
+		r.RemoteAddr = "42.2.3.84:6789"
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := &fakeResponseWriter{}
+		if !requestHandler(w, r) {
+			t.Fatalf("unexpected false is returned from requestHandler")
+		}
+		response := strings.TrimSpace(strings.ReplaceAll(w.getResponse(), "\r\n", "\n"))
+		if response != strings.TrimSpace(responseExpected) {
+			t.Fatalf("unexpected response\ngot\n%s\nwant\n%s", response, responseExpected)
+		}
+	}
+
+	// claims are substituted into url_prefix and headers
+	f("http://vmauth/select/api/v1/query?tenant=42&query=up", newToken("secret", "42"), `
+statusCode=200
+requested_url=/select/42/prometheus/select/api/v1/query?query=up&tenant=42
+X-Team=devs`)
+
+	// src_query_args doesn't match the claim
+	f("http://vmauth/select/api/v1/query?tenant=43", newToken("secret", "42"), `
+statusCode=400
+missing route for "http://vmauth/select/api/v1/query?tenant=43"`)
+
+	// missing claim
+	f("http://vmauth/insert/api/v1/write", newToken("secret", "42"), `
+statusCode=403
+cannot apply JWT claims: missing claim "missing"`)
+
+	// invalid token signature
+	f("http://vmauth/select/api/v1/query?tenant=42", newToken("invalid-secret", "42"), `
+statusCode=401
+Unauthorized`)
+}
+
+func encodeBigInt(n *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(n.Bytes())
+}
+
+func mustMarshalPublicKeyPEM(t *testing.T, key any) string {
+	t.Helper()
+	data, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatalf("cannot marshal public key: %s", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{
+		Type:  "PUBLIC KEY",
+		Bytes: data,
+	}))
+}
+
+func writeTempFile(t *testing.T, name, data string) string {
+	t.Helper()
+	path := t.TempDir() + "/" + name
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatalf("cannot write %q: %s", path, err)
+	}
+	return path
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/VictoriaMetrics/metricsql"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httpserver"
+)
+
+// parseEnforcedLabelFilters parses filters from `enforced_label_filters` option.
+//
+// Every filter must have the form `label<op>"value"`, where <op> is one of `=`, `!=`, `=~` or `!~`.
+// The value may contain JWT claim placeholders such as `{{.team}}`.
+func parseEnforcedLabelFilters(filters []string) ([]metricsql.LabelFilter, error) {
+	if len(filters) == 0 {
+		return nil, nil
+	}
+	lfs := make([]metricsql.LabelFilter, 0, len(filters))
+	for _, filter := range filters {
+		expr, err := metricsql.Parse("{" + filter + "}")
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse `enforced_label_filters` entry %q: %w", filter, err)
+		}
+		me, ok := expr.(*metricsql.MetricExpr)
+		if !ok || len(me.LabelFilterss) != 1 || len(me.LabelFilterss[0]) != 1 {
+			return nil, fmt.Errorf("`enforced_label_filters` entry %q must contain a single label filter such as `team=\"payments\"`", filter)
+		}
+		lf := me.LabelFilterss[0][0]
+		if lf.Label == "__name__" {
+			return nil, fmt.Errorf("`enforced_label_filters` entry %q cannot contain filter on metric name", filter)
+		}
+		lfs = append(lfs, lf)
+	}
+	return lfs, nil
+}
+
+// queryArgPathSuffixes contains path suffixes for APIs, which accept MetricsQL query in `query` arg.
+var queryArgPathSuffixes = []string{
+	"/api/v1/query",
+	"/api/v1/query_range",
+	"/api/v1/query_exemplars",
+}
+
+// matchArgPathSuffixes contains path suffixes for APIs, which accept series selectors in `match[]` arg.
+var matchArgPathSuffixes = []string{
+	"/api/v1/series",
+	"/api/v1/labels",
+	"/api/v1/export",
+	"/api/v1/export/csv",
+	"/api/v1/export/native",
+	"/federate",
+}
+
+func hasPathSuffix(path string, suffixes []string) bool {
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(path, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+func isLabelValuesPath(path string) bool {
+	// The path has the form /api/v1/label/<labelName>/values
+	path, ok := strings.CutSuffix(path, "/values")
+	if !ok {
+		return false
+	}
+	n := strings.LastIndexByte(path, '/')
+	return n >= 0 && strings.HasSuffix(path[:n], "/api/v1/label")
+}
+
+// enforceLabelFilters adds lfs to series selectors in the request to u.
+//
+// Filters are added to MetricsQL queries in `query` arg and to series selectors in `match[]` and `extra_filters[]` args.
+// `extra_filters[]` arg with the enforced filters is added to requests to other APIs.
+// Query args are updated both in the returned url and in the request body for `application/x-www-form-urlencoded` requests.
+//
+// An error is returned if the request cannot be safely rewritten.
+//
+// See https://docs.victoriametrics.com/victoriametrics/vmauth/#enforcing-label-filters
+func enforceLabelFilters(r *http.Request, u *url.URL, lfs []metricsql.LabelFilter) (*url.URL, error) {
+	isQueryPath := hasPathSuffix(u.Path, queryArgPathSuffixes)
+	isMatchPath := hasPathSuffix(u.Path, matchArgPathSuffixes) || isLabelValuesPath(u.Path)
+
+	mediaType, err := getMediaType(r)
+	if err != nil {
+		// Reject requests with unparsable Content-Type, since backends may still parse their body as form args.
+		return nil, err
+	}
+	if mediaType == "multipart/form-data" {
+		return nil, fmt.Errorf("requests with Content-Type=%q aren't supported when `enforced_label_filters` is set", mediaType)
+	}
+	postArgs, err := getPostFormArgs(r)
+	if err != nil {
+		return nil, err
+	}
+	hasExtraFilters := false
+	hasMatches := false
+	for _, args := range []url.Values{u.Query(), postArgs} {
+		hasExtraFilters = hasExtraFilters || len(args["extra_filters"]) > 0 || len(args["extra_filters[]"]) > 0
+		hasMatches = hasMatches || len(args["match"]) > 0 || len(args["match[]"]) > 0
+	}
+
+	rewriteArgs := func(args url.Values) error {
+		if isQueryPath {
+			if err := rewriteArgValues(args, "query", lfs, addLabelFiltersToQuery); err != nil {
+				return err
+			}
+		}
+		for _, argName := range []string{"match", "match[]", "extra_filters", "extra_filters[]"} {
+			if err := rewriteArgValues(args, argName, lfs, addLabelFiltersToSeriesSelector); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	args := u.Query()
+	if err := rewriteArgs(args); err != nil {
+		return nil, err
+	}
+	selector := string(appendSeriesSelector(nil, lfs))
+	if isMatchPath && !hasMatches {
+		// Restrict the returned series, since the API returns all the series if `match[]` arg is missing.
+		args.Set("match[]", selector)
+	}
+	if !isQueryPath && !isMatchPath && !hasExtraFilters {
+		args.Set("extra_filters[]", selector)
+	}
+	uCopy := *u
+	uCopy.RawQuery = args.Encode()
+
+	if postArgs != nil {
+		if err := rewriteArgs(postArgs); err != nil {
+			return nil, err
+		}
+		setRequestBody(r, postArgs.Encode())
+	}
+	return &uCopy, nil
+}
+
+// substituteClaimsInLabelFilters substitutes JWT claim placeholders in lfs values.
+func substituteClaimsInLabelFilters(lfs []metricsql.LabelFilter, claims jwtClaims) ([]metricsql.LabelFilter, error) {
+	hasPlaceholders := false
+	for _, lf := range lfs {
+		if strings.Contains(lf.Value, "{{") {
+			hasPlaceholders = true
+			break
+		}
+	}
+	if !hasPlaceholders {
+		return lfs, nil
+	}
+	result := make([]metricsql.LabelFilter, len(lfs))
+	for i, lf := range lfs {
+		v, err := claims.substitute(lf.Value)
+		if err != nil {
+			return nil, fmt.Errorf("cannot substitute claims into `enforced_label_filters`: %w", err)
+		}
+		lf.Value = v
+		result[i] = lf
+	}
+	return result, nil
+}
+
+// getPostFormArgs returns query args from the request body for `application/x-www-form-urlencoded` requests.
+//
+// nil is returned if the request body doesn't contain query args.
+// r.Body is replaced with the read body contents on both success and error, so the request could be proxied to backends as is.
+func getPostFormArgs(r *http.Request) (url.Values, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, nil
+	}
+	if r.Method != http.MethodPost && r.Method != http.MethodPut && r.Method != http.MethodPatch {
+		// http.Request.ParseForm reads the request body only for these methods.
+		return nil, nil
+	}
+	mediaType, err := getMediaType(r)
+	if err != nil {
+		return nil, err
+	}
+	if mediaType != "application/x-www-form-urlencoded" {
+		return nil, nil
+	}
+	if r.PostForm == nil {
+		// Do not use r.ParseForm(), since it returns an error for Content-Type with invalid media parameters,
+		// while backends may still parse the request body as form args in this case.
+		data, err := io.ReadAll(io.LimitReader(r.Body, maxPostFormSize+1))
+		if err != nil {
+			// Pass the read part of the body together with the error to the backend.
+			r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(data), &errorReader{err: err}))
+			return nil, fmt.Errorf("cannot read request body: %w", err)
+		}
+		if len(data) > maxPostFormSize {
+			r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(data), r.Body))
+			return nil, fmt.Errorf("request body size cannot exceed %d bytes", maxPostFormSize)
+		}
+		r.Body = io.NopCloser(bytes.NewReader(data))
+		args, err := url.ParseQuery(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse request body: %w", err)
+		}
+		r.PostForm = args
+	}
+	return r.PostForm, nil
+}
+
+// maxPostFormSize is the maximum size of request body with form args.
+//
+// It is the same as the limit used by net/http.Request.ParseForm.
+const maxPostFormSize = 10 << 20
+
+// getMediaType returns the media type from Content-Type header of r.
+//
+// An empty media type is returned if Content-Type header is missing.
+func getMediaType(r *http.Request) (string, error) {
+	ct := r.Header.Get("Content-Type")
+	if ct == "" {
+		return "", nil
+	}
+	mediaType, _, err := mime.ParseMediaType(ct)
+	if err != nil && !errors.Is(err, mime.ErrInvalidMediaParameter) {
+		return "", fmt.Errorf("cannot parse Content-Type=%q: %w", ct, err)
+	}
+	// mime.ParseMediaType returns the media type together with ErrInvalidMediaParameter error for invalid media parameters.
+	// net/http uses the returned media type for parsing the request body in this case, so it must be used here too.
+	return mediaType, nil
+}
+
+func setRequestBody(r *http.Request, body string) {
+	r.Body = io.NopCloser(strings.NewReader(body))
+	r.ContentLength = int64(len(body))
+	r.GetBody = nil
+}
+
+func rewriteArgValues(args url.Values, argName string, lfs []metricsql.LabelFilter, rewrite func(s string, lfs []metricsql.LabelFilter) (string, error)) error {
+	for i, v := range args[argName] {
+		vNew, err := rewrite(v, lfs)
+		if err != nil {
+			return fmt.Errorf("cannot enforce label filters in `%s` arg: %w", argName, err)
+		}
+		args[argName][i] = vNew
+	}
+	return nil
+}
+
+// addLabelFiltersToQuery adds lfs to all the series selectors in MetricsQL query q.
+func addLabelFiltersToQuery(q string, lfs []metricsql.LabelFilter) (string, error) {
+	expr, err := metricsql.Parse(q)
+	if err != nil {
+		return "", fmt.Errorf("cannot parse query %q: %w", q, err)
+	}
+	return addLabelFiltersToExpr(expr, lfs)
+}
+
+// addLabelFiltersToSeriesSelector adds lfs to series selector s.
+func addLabelFiltersToSeriesSelector(s string, lfs []metricsql.LabelFilter) (string, error) {
+	expr, err := metricsql.Parse(s)
+	if err != nil {
+		return "", fmt.Errorf("cannot parse series selector %q: %w", s, err)
+	}
+	if _, ok := expr.(*metricsql.MetricExpr); !ok {
+		return "", fmt.Errorf("expecting series selector; got %q", s)
+	}
+	return addLabelFiltersToExpr(expr, lfs)
+}
+
+func addLabelFiltersToExpr(expr metricsql.Expr, lfs []metricsql.LabelFilter) (string, error) {
+	metricsql.VisitAll(expr, func(e metricsql.Expr) {
+		me, ok := e.(*metricsql.MetricExpr)
+		if !ok {
+			return
+		}
+		if len(me.LabelFilterss) == 0 {
+			me.LabelFilterss = [][]metricsql.LabelFilter{nil}
+		}
+		for i, filters := range me.LabelFilterss {
+			filters = filters[:len(filters):len(filters)]
+			me.LabelFilterss[i] = append(filters, lfs...)
+		}
+	})
+	result := string(expr.AppendString(nil))
+
+	// Verify that every series selector in the resulting query contains the enforced filters.
+	// This protects from bypassing the enforced filters via tricky queries, which cannot be rewritten properly.
+	exprNew, err := metricsql.Parse(result)
+	if err != nil {
+		return "", fmt.Errorf("cannot parse the rewritten query %q: %w", result, err)
+	}
+	var verifyErr error
+	metricsql.VisitAll(exprNew, func(e metricsql.Expr) {
+		me, ok := e.(*metricsql.MetricExpr)
+		if !ok || verifyErr != nil {
+			return
+		}
+		if len(me.LabelFilterss) == 0 {
+			verifyErr = fmt.Errorf("the rewritten query %q contains series selector without the enforced label filters", result)
+			return
+		}
+		for _, filters := range me.LabelFilterss {
+			if !containsLabelFilters(filters, lfs) {
+				verifyErr = fmt.Errorf("the rewritten query %q contains series selector without the enforced label filters", result)
+				return
+			}
+		}
+	})
+	if verifyErr != nil {
+		return "", verifyErr
+	}
+	return result, nil
+}
+
+func containsLabelFilters(filters, lfs []metricsql.LabelFilter) bool {
+	for _, lf := range lfs {
+		found := false
+		for _, f := range filters {
+			if f == lf {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func appendSeriesSelector(dst []byte, lfs []metricsql.LabelFilter) []byte {
+	dst = append(dst, '{')
+	for i := range lfs {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		dst = lfs[i].AppendString(dst)
+	}
+	dst = append(dst, '}')
+	return dst
+}
+
+func handleLabelFiltersError(w http.ResponseWriter, r *http.Request, err error) {
+	labelFiltersErrors.Inc()
+	err = &httpserver.ErrorWithStatusCode{
+		Err:        err,
+		StatusCode: http.StatusBadRequest,
+	}
+	httpserver.Errorf(w, r, "%s", err)
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestParseEnforcedLabelFiltersFailure(t *testing.T) {
+	f := func(filters []string) {
+		t.Helper()
+		lfs, err := parseEnforcedLabelFilters(filters)
+		if err == nil {
+			t.Fatalf("expecting non-nil error; got %v", lfs)
+		}
+	}
+
+	// invalid filter
+	f([]string{`team=`})
+	f([]string{`team="payments`})
+
+	// multiple filters in a single entry
+	f([]string{`team="payments",env="prod"`})
+	f([]string{`team="payments" or env="prod"`})
+
+	// metric name filter
+	f([]string{`__name__="foo"`})
+	f([]string{`foo`})
+}
+
+func TestAddLabelFiltersToQuerySuccess(t *testing.T) {
+	lfs, err := parseEnforcedLabelFilters([]string{`team="payments"`, `env!~"dev|staging"`})
+	if err != nil {
+		t.Fatalf("cannot parse label filters: %s", err)
+	}
+
+	f := func(q, resultExpected string) {
+		t.Helper()
+		result, err := addLabelFiltersToQuery(q, lfs)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if result != resultExpected {
+			t.Fatalf("unexpected result\ngot\n%s\nwant\n%s", result, resultExpected)
+		}
+	}
+
+	f(`foo`, `foo{team="payments",env!~"dev|staging"}`)
+	f(`{}`, `{team="payments",env!~"dev|staging"}`)
+	f(`foo{team="other"}`, `foo{team="other",team="payments",env!~"dev|staging"}`)
+	f(`{a="b" or c="d"}`, `{a="b",team="payments",env!~"dev|staging" or c="d",team="payments",env!~"dev|staging"}`)
+	f(`sum(rate(foo{a="b"}[5m])) by (x) / bar`, `sum(rate(foo{a="b",team="payments",env!~"dev|staging"}[5m])) by(x) / bar{team="payments",env!~"dev|staging"}`)
+	f(`max_over_time(rate(foo[5m])[1h:1m]) offset 1h`, `max_over_time(rate(foo{team="payments",env!~"dev|staging"}[5m])[1h:1m]) offset 1h`)
+	f(`foo @ timestamp(bar)`, `foo{team="payments",env!~"dev|staging"} @ timestamp(bar{team="payments",env!~"dev|staging"})`)
+
+	// WITH templates are expanded
+	f(`with (x = foo{y="z"}) x + 1`, `foo{y="z",team="payments",env!~"dev|staging"} + 1`)
+
+	// queries without series selectors
+	f(`1 + 2`, `3`)
+	f(`time()`, `time()`)
+}
+
+func TestAddLabelFiltersToQueryFailure(t *testing.T) {
+	lfs, err := parseEnforcedLabelFilters([]string{`team="payments"`})
+	if err != nil {
+		t.Fatalf("cannot parse label filters: %s", err)
+	}
+
+	f := func(q string) {
+		t.Helper()
+		result, err := addLabelFiltersToQuery(q, lfs)
+		if err == nil {
+			t.Fatalf("expecting non-nil error; got %q", result)
+		}
+	}
+
+	f(``)
+	f(`foo{`)
+	f(`sum(`)
+	f(`foo[5m] +`)
+}
+
+func TestEnforceLabelFilters(t *testing.T) {
+	lfs, err := parseEnforcedLabelFilters([]string{`team="payments"`})
+	if err != nil {
+		t.Fatalf("cannot parse label filters: %s", err)
+	}
+
+	f := func(method, requestURI, contentType, body, urlExpected, bodyExpected string) {
+		t.Helper()
+		r, err := http.NewRequest(method, "http://vmauth"+requestURI, strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("cannot create request: %s", err)
+		}
+		if contentType != "" {
+			r.Header.Set("Content-Type", contentType)
+		}
+		u, err := enforceLabelFilters(r, normalizeURL(r.URL), lfs)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if s := u.RequestURI(); s != urlExpected {
+			t.Fatalf("unexpected url\ngot\n%s\nwant\n%s", s, urlExpected)
+		}
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Fatalf("cannot read request body: %s", err)
+		}
+		if string(data) != bodyExpected {
+			t.Fatalf("unexpected body\ngot\n%s\nwant\n%s", data, bodyExpected)
+		}
+	}
+
+	// query APIs
+	f(http.MethodGet, "/api/v1/query?query=foo&time=123", "", "",
+		"/api/v1/query?query=foo%7Bteam%3D%22payments%22%7D&time=123", "")
+	f(http.MethodGet, "/select/0/prometheus/api/v1/query_range?query=sum(foo)&step=1m", "", "",
+		"/select/0/prometheus/api/v1/query_range?query=sum%28foo%7Bteam%3D%22payments%22%7D%29&step=1m", "")
+	f(http.MethodPost, "/api/v1/query", "application/x-www-form-urlencoded", "query=foo",
+		"/api/v1/query", "query=foo%7Bteam%3D%22payments%22%7D")
+	f(http.MethodPost, "/api/v1/query", "application/x-www-form-urlencoded; x", "query=foo",
+		"/api/v1/query", "query=foo%7Bteam%3D%22payments%22%7D")
+	f(http.MethodPost, "/api/v1/query", "Application/X-WWW-Form-Urlencoded; charset=utf-8", "query=foo",
+		"/api/v1/query", "query=foo%7Bteam%3D%22payments%22%7D")
+
+	// match[] APIs
+	f(http.MethodGet, "/api/v1/series?match[]=foo&match[]=bar", "", "",
+		"/api/v1/series?match%5B%5D=foo%7Bteam%3D%22payments%22%7D&match%5B%5D=bar%7Bteam%3D%22payments%22%7D", "")
+	f(http.MethodGet, "/api/v1/labels", "", "",
+		"/api/v1/labels?match%5B%5D=%7Bteam%3D%22payments%22%7D", "")
+	f(http.MethodGet, "/api/v1/label/job/values", "", "",
+		"/api/v1/label/job/values?match%5B%5D=%7Bteam%3D%22payments%22%7D", "")
+	f(http.MethodPost, "/api/v1/export", "application/x-www-form-urlencoded", "match[]=foo",
+		"/api/v1/export", "match%5B%5D=foo%7Bteam%3D%22payments%22%7D")
+
+	// other APIs
+	f(http.MethodGet, "/api/v1/status/tsdb", "", "",
+		"/api/v1/status/tsdb?extra_filters%5B%5D=%7Bteam%3D%22payments%22%7D", "")
+	f(http.MethodGet, "/api/v1/status/tsdb?extra_filters[]={team=\"other\"}", "", "",
+		"/api/v1/status/tsdb?extra_filters%5B%5D=%7Bteam%3D%22other%22%2Cteam%3D%22payments%22%7D", "")
+
+	// non-form request body isn't modified
+	f(http.MethodPost, "/api/v1/write", "application/x-protobuf", "foobar",
+		"/api/v1/write?extra_filters%5B%5D=%7Bteam%3D%22payments%22%7D", "foobar")
+}
+
+func TestEnforceLabelFiltersFailure(t *testing.T) {
+	lfs, err := parseEnforcedLabelFilters([]string{`team="payments"`})
+	if err != nil {
+		t.Fatalf("cannot parse label filters: %s", err)
+	}
+
+	f := func(method, requestURI, contentType, body string) {
+		t.Helper()
+		r, err := http.NewRequest(method, "http://vmauth"+requestURI, strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("cannot create request: %s", err)
+		}
+		if contentType != "" {
+			r.Header.Set("Content-Type", contentType)
+		}
+		if _, err := enforceLabelFilters(r, normalizeURL(r.URL), lfs); err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+
+	// invalid query
+	f(http.MethodGet, "/api/v1/query?query=sum(", "", "")
+	f(http.MethodPost, "/api/v1/query", "application/x-www-form-urlencoded", "query=sum(")
+
+	// match[] isn't a series selector
+	f(http.MethodGet, "/api/v1/series?match[]=sum(foo)", "", "")
+
+	// extra_filters[] isn't a series selector
+	f(http.MethodGet, "/api/v1/status/tsdb?extra_filters[]=foo%2Bbar", "", "")
+
+	// multipart form
+	f(http.MethodPost, "/api/v1/query", "multipart/form-data; boundary=foo", "--foo--")
+	f(http.MethodPost, "/api/v1/query", "multipart/form-data; x", "--foo--")
+
+	// unparsable Content-Type
+	f(http.MethodPost, "/api/v1/query", "application/x-www-form-urlencoded/foo", "query=foo")
+	f(http.MethodPost, "/api/v1/query", "; charset=utf-8", "query=foo")
+}
+
+func TestRequestHandlerEnforcedLabelFilters(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		fmt.Fprintf(w, "requested_url=%s\nbody=%s", r.URL, body)
+	}))
+	defer ts.Close()
+
+	cfgStr := strings.ReplaceAll(`
+users:
+- username: foo
+  password: bar
+  enforced_label_filters: ['team="payments"']
+  url_map:
+  - src_paths: ["/api/v1/query"]
+    url_prefix: {BACKEND}/select
+  - src_paths: ["/admin/api/v1/query"]
+    url_prefix: {BACKEND}/select
+    enforced_label_filters: []
+- name: jwt
+  jwt:
+    hmac_secret: secret
+  url_prefix: {BACKEND}/select
+  enforced_label_filters: ['team="{{.team}}"']
+`, "{BACKEND}", ts.URL)
+
+	cfgOrigP := authConfigData.Load()
+	if _, err := reloadAuthConfigData([]byte(cfgStr)); err != nil {
+		t.Fatalf("cannot load config data: %s", err)
+	}
+	defer func() {
+		cfgOrig := []byte("unauthorized_user:\n  url_prefix: http://foo/bar")
+		if cfgOrigP != nil {
+			cfgOrig = *cfgOrigP
+		}
+		if _, err := reloadAuthConfigData(cfgOrig); err != nil {
+			t.Fatalf("cannot load the original config: %s", err)
+		}
+	}()
+
+	f := func(requestURL, authHeader, responseExpected string) {
+		t.Helper()
+		r, err := http.NewRequest(http.MethodGet, requestURL, nil)
+		if err != nil {
+			t.Fatalf("cannot initialize http request: %s", err)
+		}
+		r.RequestURI = r.URL.RequestURI()
+		r.RemoteAddr = "42.2.3.84:6789"
+		r.Header.Set("Authorization", authHeader)
+		w := &fakeResponseWriter{}
+		if !requestHandler(w, r) {
+			t.Fatalf("unexpected false is returned from requestHandler")
+		}
+		response := strings.TrimSpace(strings.ReplaceAll(w.getResponse(), "\r\n", "\n"))
+		if response != strings.TrimSpace(responseExpected) {
+			t.Fatalf("unexpected response\ngot\n%s\nwant\n%s", response, responseExpected)
+		}
+	}
+
+	basicAuth := "Basic Zm9vOmJhcg=="
+
+	// the enforced filters are added to the query
+	f("http://vmauth/api/v1/query?query=foo", basicAuth, `
+statusCode=200
+requested_url=/select/api/v1/query?query=foo%7Bteam%3D%22payments%22%7D
+body=`)
+
+	// url_map entry overrides the enforced filters
+	f("http://vmauth/admin/api/v1/query?query=foo", basicAuth, `
+statusCode=200
+requested_url=/select/admin/api/v1/query?query=foo
+body=`)
+
+	// invalid query is rejected
+	f("http://vmauth/api/v1/query?query=sum(", basicAuth, `
+statusCode=400
+cannot enforce label filters in `+"`query`"+` arg: cannot parse query "sum(": singleExpr: unexpected token ""; want "(", "{", "-", "+"; unparsed data: ""`)
+
+	// the enforced filters are populated from JWT claims
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"exp":  time.Now().Add(time.Hour).Unix(),
+		"team": "billing",
+	}).SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatalf("cannot sign token: %s", err)
+	}
+	f("http://vmauth/api/v1/series?match[]=foo", "Bearer "+token, `
+statusCode=200
+requested_url=/select/api/v1/series?match%5B%5D=foo%7Bteam%3D%22billing%22%7D
+body=`)
+}
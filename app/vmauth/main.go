@@ -6,14 +6,17 @@ import (
 	"flag"
 	"fmt"
 	"io"
+	"math"
 	"net"
 	"net/http"
 	"net/textproto"
 	"net/url"
 	"os"
 	"slices"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/VictoriaMetrics/metrics"
@@ -50,8 +53,9 @@ var (
 	maxConcurrentPerUserRequests = flag.Int("maxConcurrentPerUserRequests", 300, "The maximum number of concurrent requests vmauth can process per each configured user. "+
 		"Other requests are rejected with '429 Too Many Requests' http status code. See also -maxConcurrentRequests command-line option and max_concurrent_requests option "+
 		"in per-user config")
-	reloadAuthKey        = flagutil.NewPassword("reloadAuthKey", "Auth key for /-/reload http endpoint. It must be passed via authKey query arg. It overrides -httpAuth.*")
-	logInvalidAuthTokens = flag.Bool("logInvalidAuthTokens", false, "Whether to log requests with invalid auth tokens. "+
+	reloadAuthKey         = flagutil.NewPassword("reloadAuthKey", "Auth key for /-/reload http endpoint. It must be passed via authKey query arg. It overrides -httpAuth.*")
+	backendsStatusAuthKey = flagutil.NewPassword("backendsStatusAuthKey", "Auth key for /-/backends http endpoint. It must be passed via authKey query arg. It overrides -httpAuth.*")
+	logInvalidAuthTokens  = flag.Bool("logInvalidAuthTokens", false, "Whether to log requests with invalid auth tokens. "+
 		`Such requests are always counted at vmauth_http_request_errors_total{reason="invalid_auth_token"} metric, which is exposed at /metrics page`)
 	failTimeout               = flag.Duration("failTimeout", 3*time.Second, "Sets a delay period for load balancing to skip a malfunctioning backend")
 	maxRequestBodySizeToRetry = flagutil.NewBytes("maxRequestBodySizeToRetry", 16*1024, "The maximum request body size, which can be cached and re-tried at other backends. "+
@@ -126,6 +130,8 @@ func main() {
 	}
 	logger.Infof("successfully shut down the webservice in %.3f seconds", time.Since(startTime).Seconds())
 	stopAuthConfig()
+	stopResponseCache()
+	stopAuditLog()
 	logger.Infof("successfully stopped vmauth in %.3f seconds", time.Since(startTime).Seconds())
 }
 
@@ -139,6 +145,16 @@ func internalRequestHandler(w http.ResponseWriter, r *http.Request) bool {
 		procutil.SelfSIGHUP()
 		w.WriteHeader(http.StatusOK)
 		return true
+	case "/-/backends":
+		if !httpserver.CheckAuthFlag(w, r, backendsStatusAuthKey) {
+			return true
+		}
+		backendsStatusRequests.Inc()
+		w.Header().Set("Content-Type", "application/json")
+		if err := writeBackendsStatus(w, authConfig.Load()); err != nil {
+			httpserver.Errorf(w, r, "cannot write backends status: %s", err)
+		}
+		return true
 	}
 	return false
 }
@@ -157,7 +173,7 @@ func requestHandler(w http.ResponseWriter, r *http.Request) bool {
 		// Process requests for unauthorized users
 		ui := authConfig.Load().UnauthorizedUser
 		if ui != nil {
-			processUserRequest(w, r, ui)
+			processUserRequest(w, r, ui, nil)
 			return true
 		}
 
@@ -166,10 +182,14 @@ func requestHandler(w http.ResponseWriter, r *http.Request) bool {
 	}
 
 	ui := getUserInfoByAuthTokens(ats)
+	var claims jwtClaims
+	if ui == nil {
+		ui, claims = getJWTUserInfo(ats)
+	}
 	if ui == nil {
 		uu := authConfig.Load().UnauthorizedUser
 		if uu != nil {
-			processUserRequest(w, r, uu)
+			processUserRequest(w, r, uu, nil)
 			return true
 		}
 
@@ -187,7 +207,7 @@ func requestHandler(w http.ResponseWriter, r *http.Request) bool {
 		return true
 	}
 
-	processUserRequest(w, r, ui)
+	processUserRequest(w, r, ui, claims)
 	return true
 }
 
@@ -202,12 +222,29 @@ func getUserInfoByAuthTokens(ats []string) *UserInfo {
 	return nil
 }
 
-func processUserRequest(w http.ResponseWriter, r *http.Request, ui *UserInfo) {
+// processUserRequest processes r for the given ui.
+//
+// claims must contain verified JWT claims if ui is authorized via JWT token.
+func processUserRequest(w http.ResponseWriter, r *http.Request, ui *UserInfo, claims jwtClaims) {
 	startTime := time.Now()
 	defer ui.requestsDuration.UpdateDuration(startTime)
 
 	ui.requests.Inc()
 
+	if retryAfter, err := ui.checkRateLimits(); err != nil {
+		handleRateLimitError(w, r, retryAfter, err)
+		return
+	}
+	if ui.requestBytesRateLimiter != nil && r.Body != nil {
+		cb := &countingReadCloser{
+			rc: r.Body,
+		}
+		r.Body = cb
+		defer func() {
+			ui.requestBytesRateLimiter.Consume(int(cb.n.Load()))
+		}()
+	}
+
 	// Limit the concurrency of requests to backends
 	concurrencyLimitOnce.Do(concurrencyLimitInit)
 	select {
@@ -223,21 +260,25 @@ func processUserRequest(w http.ResponseWriter, r *http.Request, ui *UserInfo) {
 		handleConcurrencyLimitError(w, r, err)
 		return
 	}
-	processRequest(w, r, ui)
+	processRequest(w, r, ui, claims)
 	ui.endConcurrencyLimit()
 	<-concurrencyLimitCh
 }
 
-func processRequest(w http.ResponseWriter, r *http.Request, ui *UserInfo) {
+func processRequest(w http.ResponseWriter, r *http.Request, ui *UserInfo, claims jwtClaims) {
 	u := normalizeURL(r.URL)
-	up, hc := ui.getURLPrefixAndHeaders(u, r.Host, r.Header)
+	ar := newAuditRecord(r, u, ui, claims)
+	w = ar.wrapResponseWriter(w)
+	defer ar.write()
+
+	up, hc := ui.getURLPrefixAndHeaders(u, r.Host, r.Header, claims)
 	isDefault := false
 	if up == nil {
 		if ui.DefaultURL == nil {
 			// Authorization should be requested for http requests without credentials
 			// to a route that is not in the configuration for unauthorized user.
 			// See https://github.com/VictoriaMetrics/VictoriaMetrics/issues/5236
-			if ui.BearerToken == "" && ui.Username == "" && len(*authUsers.Load()) > 0 {
+			if ui.BearerToken == "" && ui.Username == "" && ui.JWT == nil && (len(*authUsers.Load()) > 0 || len(authConfig.Load().jwtUsers) > 0) {
 				handleMissingAuthorizationError(w)
 				return
 			}
@@ -252,17 +293,57 @@ func processRequest(w http.ResponseWriter, r *http.Request, ui *UserInfo) {
 		up, hc = ui.DefaultURL, ui.HeadersConf
 		isDefault = true
 	}
+	ar.setRoute(ui, up)
+	if claims != nil {
+		var err error
+		hc, err = claims.substituteHeaders(hc)
+		if err != nil {
+			handleJWTClaimsError(w, r, err)
+			return
+		}
+	}
+	if len(up.labelFilters) > 0 {
+		lfs, err := substituteClaimsInLabelFilters(up.labelFilters, claims)
+		if err != nil {
+			handleJWTClaimsError(w, r, err)
+			return
+		}
+		u, err = enforceLabelFilters(r, u, lfs)
+		if err != nil {
+			handleLabelFiltersError(w, r, err)
+			return
+		}
+	}
+
+	crw, ok := tryServingCachedResponse(w, r, u, up, hc, ui, claims)
+	if ok {
+		return
+	}
+	if crw != nil {
+		w = crw
+		defer crw.mustStore()
+	}
+	mirrorRequestIfNeeded(r, u, up, hc, ui, claims)
 
 	rtb := newReadTrackingBody(r.Body, maxRequestBodySizeToRetry.IntN())
 	r.Body = rtb
 
 	maxAttempts := up.getBackendsCount()
 	for i := 0; i < maxAttempts; i++ {
-		bu := up.getBackendURL()
+		bu := up.getBackendURLForRequest(r)
 		if bu == nil {
 			break
 		}
 		targetURL := bu.url
+		if claims != nil {
+			var err error
+			targetURL, err = claims.substituteURL(targetURL)
+			if err != nil {
+				bu.put()
+				handleJWTClaimsError(w, r, err)
+				return
+			}
+		}
 		// Don't change path and add request_path query param for default route.
 		if isDefault {
 			query := targetURL.Query()
@@ -272,6 +353,8 @@ func processRequest(w http.ResponseWriter, r *http.Request, ui *UserInfo) {
 			targetURL = mergeURLs(targetURL, u, up.dropSrcPathPrefixParts)
 		}
 
+		ar.setBackend(targetURL)
+
 		wasLocalRetry := false
 	again:
 		ok, needLocalRetry := tryProcessingRequest(w, r, targetURL, hc, up.retryStatusCodes, ui)
@@ -374,9 +457,16 @@ func tryProcessingRequest(w http.ResponseWriter, r *http.Request, targetURL *url
 
 	copyBuf := copyBufPool.Get()
 	copyBuf.B = bytesutil.ResizeNoCopyNoOverallocate(copyBuf.B, 16*1024)
-	_, err = io.CopyBuffer(w, res.Body, copyBuf.B)
+	n, err := io.CopyBuffer(w, res.Body, copyBuf.B)
 	copyBufPool.Put(copyBuf)
+	ui.responseBytesRateLimiter.Consume(int(n))
 	_ = res.Body.Close()
+	if err != nil {
+		if crw, ok := w.(*cachingResponseWriter); ok {
+			// The response may be truncated, so it mustn't be cached.
+			crw.copyErr = err
+		}
+	}
 	if err != nil && !netutil.IsTrivialNetworkError(err) {
 		remoteAddr := httpserver.GetQuotedRemoteAddr(r)
 		requestURI := httpserver.GetRequestURI(r)
@@ -470,8 +560,11 @@ var hopHeaders = []string{
 
 var (
 	configReloadRequests     = metrics.NewCounter(`vmauth_http_requests_total{path="/-/reload"}`)
+	backendsStatusRequests   = metrics.NewCounter(`vmauth_http_requests_total{path="/-/backends"}`)
 	invalidAuthTokenRequests = metrics.NewCounter(`vmauth_http_request_errors_total{reason="invalid_auth_token"}`)
 	missingRouteRequests     = metrics.NewCounter(`vmauth_http_request_errors_total{reason="missing_route"}`)
+	jwtClaimsErrors          = metrics.NewCounter(`vmauth_http_request_errors_total{reason="invalid_jwt_claims"}`)
+	labelFiltersErrors       = metrics.NewCounter(`vmauth_http_request_errors_total{reason="cannot_enforce_label_filters"}`)
 )
 
 func newRoundTripper(caFileOpt, certFileOpt, keyFileOpt, serverNameOpt string, insecureSkipVerifyP *bool) (http.RoundTripper, error) {
@@ -563,6 +656,34 @@ func handleConcurrencyLimitError(w http.ResponseWriter, r *http.Request, err err
 	httpserver.Errorf(w, r, "%s", err)
 }
 
+func handleRateLimitError(w http.ResponseWriter, r *http.Request, retryAfter time.Duration, err error) {
+	retryAfterSeconds := max(int(math.Ceil(retryAfter.Seconds())), 1)
+	w.Header().Add("Retry-After", strconv.Itoa(retryAfterSeconds))
+	err = &httpserver.ErrorWithStatusCode{
+		Err:        err,
+		StatusCode: http.StatusTooManyRequests,
+	}
+	httpserver.Errorf(w, r, "%s", err)
+}
+
+// countingReadCloser counts the number of bytes read from rc.
+type countingReadCloser struct {
+	rc io.ReadCloser
+
+	// n is updated atomically, since http.RoundTrip may read the request body after return.
+	n atomic.Int64
+}
+
+func (cr *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := cr.rc.Read(p)
+	cr.n.Add(int64(n))
+	return n, err
+}
+
+func (cr *countingReadCloser) Close() error {
+	return cr.rc.Close()
+}
+
 // readTrackingBody must be obtained via getReadTrackingBody()
 type readTrackingBody struct {
 	// maxBodySize is the maximum body size to cache in buf.
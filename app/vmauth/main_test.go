@@ -201,6 +201,26 @@ Expected to receive non-empty authKey when -reloadAuthKey is set`
 		t.Fatalf("unexpected error: %s", err)
 	}
 
+	// /-/backends handler failure
+	origBackendsStatusAuthKey := backendsStatusAuthKey.Get()
+	if err := backendsStatusAuthKey.Set("secret"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	cfgStr = `
+unauthorized_user:
+  url_prefix: "{BACKEND}/foo"`
+	requestURL = "http://some-host.com/-/backends"
+	backendHandler = func(_ http.ResponseWriter, _ *http.Request) {
+		panic(fmt.Errorf("backend handler shouldn't be called"))
+	}
+	responseExpected = `
+statusCode=401
+Expected to receive non-empty authKey when -backendsStatusAuthKey is set`
+	f(cfgStr, requestURL, backendHandler, responseExpected)
+	if err := backendsStatusAuthKey.Set(origBackendsStatusAuthKey); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
 	// missing authorization
 	cfgStr = `
 users:
@@ -504,6 +524,77 @@ requested_url={BACKEND}/path2/foo/?de=fg`
 	}
 }
 
+func TestRequestHandlerRateLimits(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		fmt.Fprintf(w, "%s", strings.Repeat("x", 100))
+	}))
+	defer ts.Close()
+
+	cfgStr := strings.ReplaceAll(`
+users:
+- bearer_token: requests
+  url_prefix: {BACKEND}
+  max_requests_per_second: 2
+- bearer_token: request_bytes
+  url_prefix: {BACKEND}
+  max_request_bytes_per_second: 10
+- bearer_token: response_bytes
+  url_prefix: {BACKEND}
+  max_response_bytes_per_second: 30
+`, "{BACKEND}", ts.URL)
+
+	cfgOrigP := authConfigData.Load()
+	if _, err := reloadAuthConfigData([]byte(cfgStr)); err != nil {
+		t.Fatalf("cannot load config data: %s", err)
+	}
+	defer func() {
+		cfgOrig := []byte("unauthorized_user:\n  url_prefix: http://foo/bar")
+		if cfgOrigP != nil {
+			cfgOrig = *cfgOrigP
+		}
+		if _, err := reloadAuthConfigData(cfgOrig); err != nil {
+			t.Fatalf("cannot load the original config: %s", err)
+		}
+	}()
+
+	f := func(token, body string, statusCodeExpected int, retryAfterExpected string) {
+		t.Helper()
+		r, err := http.NewRequest(http.MethodPost, "http://vmauth/api/v1/write", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("cannot initialize http request: %s", err)
+		}
+		r.RequestURI = r.URL.RequestURI()
+		r.RemoteAddr = "42.2.3.84:6789"
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := &fakeResponseWriter{}
+		if !requestHandler(w, r) {
+			t.Fatalf("unexpected false is returned from requestHandler")
+		}
+		response := w.getResponse()
+		statusCodeLine := fmt.Sprintf("statusCode=%d\n", statusCodeExpected)
+		if !strings.HasPrefix(response, statusCodeLine) {
+			t.Fatalf("unexpected response; got\n%s\nwant status code %d", response, statusCodeExpected)
+		}
+		if retryAfter := w.Header().Get("Retry-After"); retryAfter != retryAfterExpected {
+			t.Fatalf("unexpected Retry-After header; got %q; want %q", retryAfter, retryAfterExpected)
+		}
+	}
+
+	// requests per second limit
+	f("requests", "", http.StatusOK, "")
+	f("requests", "", http.StatusOK, "")
+	f("requests", "", http.StatusTooManyRequests, "1")
+
+	// request bytes per second limit; the budget is exhausted by the first request for 5 seconds
+	f("request_bytes", strings.Repeat("a", 60), http.StatusOK, "")
+	f("request_bytes", "a", http.StatusTooManyRequests, "6")
+
+	// response bytes per second limit; the budget is exhausted by the first response for 3 seconds
+	f("response_bytes", "", http.StatusOK, "")
+	f("response_bytes", "", http.StatusTooManyRequests, "3")
+}
+
 type fakeResponseWriter struct {
 	h http.Header
 
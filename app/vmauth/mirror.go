@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+)
+
+var (
+	mirrorMaxRequestBodySize = flagutil.NewBytes("mirror.maxRequestBodySize", 1024*1024, "The maximum request body size, which can be mirrored to mirror_url_prefix backends. "+
+		"Requests with bigger bodies aren't mirrored. See https://docs.victoriametrics.com/victoriametrics/vmauth/#request-mirroring")
+	mirrorMaxConcurrentRequests = flag.Int("mirror.maxConcurrentRequests", 100, "The maximum number of concurrent requests vmauth can send to mirror_url_prefix backends. "+
+		"Excess requests aren't mirrored. See https://docs.victoriametrics.com/victoriametrics/vmauth/#request-mirroring")
+)
+
+// mirrorConfig contains the config for mirroring requests to mirror_url_prefix backends.
+type mirrorConfig struct {
+	// up contains mirror backends
+	up *URLPrefix
+
+	// sampleRatio is the ratio of requests to mirror in the range (0..1]
+	sampleRatio float64
+}
+
+func newMirrorConfig(up *URLPrefix, sampleRatio *float64) (*mirrorConfig, error) {
+	ratio := 1.0
+	if sampleRatio != nil {
+		ratio = *sampleRatio
+	}
+	if ratio <= 0 || ratio > 1 {
+		return nil, fmt.Errorf("`mirror_sample_ratio` must be in the range (0..1]; got %v", ratio)
+	}
+	return &mirrorConfig{
+		up:          up,
+		sampleRatio: ratio,
+	}, nil
+}
+
+// mirrorMetrics contains per-user metrics for mirrored requests.
+type mirrorMetrics struct {
+	ms           *metrics.Set
+	metricPrefix string
+	metricLabels string
+
+	requestsDuration *metrics.Summary
+	requestErrors    *metrics.Counter
+	requestsSkipped  *metrics.Counter
+}
+
+func (ui *UserInfo) hasMirrors() bool {
+	if ui.MirrorURLPrefix != nil {
+		return true
+	}
+	for _, e := range ui.URLMaps {
+		if e.MirrorURLPrefix != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (ui *UserInfo) initMirrorMetrics(ms *metrics.Set, metricPrefix, metricLabels string) {
+	if !ui.hasMirrors() {
+		return
+	}
+	ui.mirrorMetrics = &mirrorMetrics{
+		ms:               ms,
+		metricPrefix:     metricPrefix,
+		metricLabels:     metricLabels,
+		requestsDuration: ms.GetOrCreateSummary(metricPrefix + `_mirror_request_duration_seconds` + metricLabels),
+		requestErrors:    ms.GetOrCreateCounter(metricPrefix + `_mirror_request_errors_total` + metricLabels),
+		requestsSkipped:  ms.GetOrCreateCounter(metricPrefix + `_mirror_requests_skipped_total` + metricLabels),
+	}
+}
+
+func (mm *mirrorMetrics) registerResponse(statusCode int) {
+	labels := addMetricLabel(mm.metricLabels, fmt.Sprintf("status_code=\"%d\"", statusCode))
+	mm.ms.GetOrCreateCounter(mm.metricPrefix + `_mirror_requests_total` + labels).Inc()
+}
+
+var (
+	mirrorConcurrencyLimitCh   chan struct{}
+	mirrorConcurrencyLimitOnce sync.Once
+)
+
+func mirrorConcurrencyLimitInit() {
+	mirrorConcurrencyLimitCh = make(chan struct{}, *mirrorMaxConcurrentRequests)
+}
+
+// mirrorRequestIfNeeded asynchronously sends a copy of r to mirror backends of up if needed.
+//
+// Responses from mirror backends are discarded. r.Body may be replaced with the buffered copy of the original body.
+func mirrorRequestIfNeeded(r *http.Request, u *url.URL, up *URLPrefix, hc HeadersConf, ui *UserInfo, claims jwtClaims) {
+	mc := up.mirror
+	if mc == nil {
+		return
+	}
+	if mc.sampleRatio < 1 && rand.Float64() >= mc.sampleRatio {
+		return
+	}
+	mm := ui.mirrorMetrics
+
+	body, ok := readBodyForMirroring(r)
+	if !ok {
+		mm.requestsSkipped.Inc()
+		return
+	}
+
+	bu := mc.up.getBackendURL()
+	if bu == nil {
+		mm.requestsSkipped.Inc()
+		return
+	}
+	targetURL := bu.url
+	if claims != nil {
+		var err error
+		targetURL, err = claims.substituteURL(targetURL)
+		if err != nil {
+			bu.put()
+			mm.requestsSkipped.Inc()
+			return
+		}
+	}
+	targetURL = mergeURLs(targetURL, u, up.dropSrcPathPrefixParts)
+
+	mirrorConcurrencyLimitOnce.Do(mirrorConcurrencyLimitInit)
+	select {
+	case mirrorConcurrencyLimitCh <- struct{}{}:
+	default:
+		bu.put()
+		mm.requestsSkipped.Inc()
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *responseTimeout)
+	req := sanitizeRequestHeaders(r).WithContext(ctx)
+	req.URL = targetURL
+	req.Header.Set("User-Agent", "vmauth")
+	updateHeadersByConfig(req.Header, hc.RequestHeaders)
+	if hc.KeepOriginalHost == nil || !*hc.KeepOriginalHost {
+		if host := getHostHeader(hc.RequestHeaders); host != "" {
+			req.Host = host
+		} else {
+			req.Host = targetURL.Host
+		}
+	}
+	req.Body = http.NoBody
+	if len(body) > 0 {
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	req.ContentLength = int64(len(body))
+	req.GetBody = nil
+
+	go func() {
+		defer func() {
+			cancel()
+			bu.put()
+			<-mirrorConcurrencyLimitCh
+		}()
+
+		startTime := time.Now()
+		res, err := ui.rt.RoundTrip(req)
+		if err != nil {
+			mm.requestErrors.Inc()
+			logger.Warnf("cannot mirror the request to %s: %s", targetURL.Redacted(), err)
+			return
+		}
+		_, _ = io.Copy(io.Discard, res.Body)
+		_ = res.Body.Close()
+		mm.requestsDuration.UpdateDuration(startTime)
+		mm.registerResponse(res.StatusCode)
+	}()
+}
+
+// readBodyForMirroring reads r.Body, so it could be sent to mirror backends.
+//
+// r.Body is replaced with the buffered body. False is returned if the body exceeds -mirror.maxRequestBodySize
+// or cannot be read.
+func readBodyForMirroring(r *http.Request) ([]byte, bool) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, true
+	}
+	maxSize := mirrorMaxRequestBodySize.N
+	if r.ContentLength > maxSize {
+		return nil, false
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxSize+1))
+	if err != nil {
+		// Pass the read part of the body together with the error to the backend.
+		r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), &errorReader{err: err}))
+		return nil, false
+	}
+	if int64(len(body)) > maxSize {
+		// The body is too big. Do not mirror it and pass it to the backend as is.
+		r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+		return nil, false
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return body, true
+}
+
+type errorReader struct {
+	err error
+}
+
+func (er *errorReader) Read(_ []byte) (int, error) {
+	return 0, er.err
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadBodyForMirroring(t *testing.T) {
+	origMaxSize := mirrorMaxRequestBodySize.N
+	mirrorMaxRequestBodySize.N = 10
+	defer func() {
+		mirrorMaxRequestBodySize.N = origMaxSize
+	}()
+
+	f := func(body string, contentLength int64, okExpected bool) {
+		t.Helper()
+		r, err := http.NewRequest(http.MethodPost, "http://vmauth/api/v1/write", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("cannot initialize http request: %s", err)
+		}
+		r.ContentLength = contentLength
+		mirrorBody, ok := readBodyForMirroring(r)
+		if ok != okExpected {
+			t.Fatalf("unexpected ok; got %v; want %v", ok, okExpected)
+		}
+		if ok && string(mirrorBody) != body {
+			t.Fatalf("unexpected mirror body; got %q; want %q", mirrorBody, body)
+		}
+
+		// The original body must be available for proxying to the backend.
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Fatalf("cannot read request body: %s", err)
+		}
+		if string(data) != body {
+			t.Fatalf("unexpected request body; got %q; want %q", data, body)
+		}
+	}
+
+	f("", 0, true)
+	f("foobar", -1, true)
+	f("0123456789", 10, true)
+
+	// too big body
+	f("0123456789a", 11, false)
+	f("0123456789abcdef", -1, false)
+}
+
+func TestRequestHandlerMirror(t *testing.T) {
+	mirrorCh := make(chan string, 10)
+	tsMirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mirrorCh <- fmt.Sprintf("%s %s body=%q X-Team=%s", r.Method, r.URL, body, r.Header.Get("X-Team"))
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer tsMirror.Close()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		fmt.Fprintf(w, "requested_url=%s\nbody=%s", r.URL, body)
+	}))
+	defer ts.Close()
+
+	cfgStr := strings.NewReplacer("{BACKEND}", ts.URL, "{MIRROR}", tsMirror.URL).Replace(`
+users:
+- username: foo
+  password: bar
+  url_map:
+  - src_paths: ["/api/v1/write"]
+    url_prefix: {BACKEND}/insert
+    mirror_url_prefix: {MIRROR}/mirror-insert
+    headers:
+    - "X-Team: dev"
+  - src_paths: ["/api/v1/query"]
+    url_prefix: {BACKEND}/select
+`)
+
+	cfgOrigP := authConfigData.Load()
+	if _, err := reloadAuthConfigData([]byte(cfgStr)); err != nil {
+		t.Fatalf("cannot load config data: %s", err)
+	}
+	defer func() {
+		cfgOrig := []byte("unauthorized_user:\n  url_prefix: http://foo/bar")
+		if cfgOrigP != nil {
+			cfgOrig = *cfgOrigP
+		}
+		if _, err := reloadAuthConfigData(cfgOrig); err != nil {
+			t.Fatalf("cannot load the original config: %s", err)
+		}
+	}()
+
+	f := func(requestURL, body, responseExpected, mirroredExpected string) {
+		t.Helper()
+		r, err := http.NewRequest(http.MethodPost, requestURL, strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("cannot initialize http request: %s", err)
+		}
+		r.RequestURI = r.URL.RequestURI()
+		r.RemoteAddr = "42.2.3.84:6789"
+		r.SetBasicAuth("foo", "bar")
+		w := &fakeResponseWriter{}
+		if !requestHandler(w, r) {
+			t.Fatalf("unexpected false is returned from requestHandler")
+		}
+		response := strings.TrimSpace(strings.ReplaceAll(w.getResponse(), "\r\n", "\n"))
+		if response != strings.TrimSpace(responseExpected) {
+			t.Fatalf("unexpected response\ngot\n%s\nwant\n%s", response, responseExpected)
+		}
+
+		if mirroredExpected == "" {
+			select {
+			case mirrored := <-mirrorCh:
+				t.Fatalf("unexpected mirrored request: %s", mirrored)
+			case <-time.After(100 * time.Millisecond):
+			}
+			return
+		}
+		select {
+		case mirrored := <-mirrorCh:
+			if mirrored != mirroredExpected {
+				t.Fatalf("unexpected mirrored request\ngot\n%s\nwant\n%s", mirrored, mirroredExpected)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout when waiting for mirrored request")
+		}
+	}
+
+	// The request is mirrored, while the error from mirror backend doesn't affect the response.
+	f("http://vmauth/api/v1/write?extra_label=foo=bar", "foobar", `
+statusCode=200
+requested_url=/insert/api/v1/write?extra_label=foo%3Dbar
+body=foobar`, `POST /mirror-insert/api/v1/write?extra_label=foo%3Dbar body="foobar" X-Team=dev`)
+
+	// The request to url_map entry without mirror_url_prefix isn't mirrored.
+	f("http://vmauth/api/v1/query", "query=up", `
+statusCode=200
+requested_url=/select/api/v1/query
+body=query=up`, "")
+}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/fastcache"
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/cespare/xxhash/v2"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/timeutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/workingsetcache"
+)
+
+var (
+	responseCacheMaxSize = flagutil.NewBytes("responseCache.maxSizeBytes", 256*1024*1024, "The maximum size of in-memory cache for responses from url_map entries "+
+		"with response_cache_ttl option. See https://docs.victoriametrics.com/victoriametrics/vmauth/#response-caching")
+	responseCacheMaxItemSize = flagutil.NewBytes("responseCache.maxItemSizeBytes", 4*1024*1024, "The maximum size of a single response, which can be stored in the response cache. "+
+		"Bigger responses aren't cached. See https://docs.victoriametrics.com/victoriametrics/vmauth/#response-caching")
+	responseCacheDataPath = flag.String("responseCache.dataPath", "", "Optional path to directory for persisting the response cache across vmauth restarts. "+
+		"By default the response cache is stored only in memory. See https://docs.victoriametrics.com/victoriametrics/vmauth/#response-caching")
+)
+
+// responseCacheImmutableOffset is the offset from the current time, after which the query results are considered immutable.
+//
+// Responses for queries with end timestamps older than this offset are cached for the whole response_cache_ttl,
+// since the data for the queried time range isn't expected to change.
+const responseCacheImmutableOffset = 5 * time.Minute
+
+var (
+	responseCache     *workingsetcache.Cache
+	responseCacheOnce sync.Once
+)
+
+func getResponseCache() *workingsetcache.Cache {
+	responseCacheOnce.Do(func() {
+		maxSize := responseCacheMaxSize.IntN()
+		if *responseCacheDataPath != "" {
+			responseCache = workingsetcache.Load(*responseCacheDataPath, maxSize)
+		} else {
+			responseCache = workingsetcache.New(maxSize)
+		}
+		_ = metrics.NewGauge(`vmauth_response_cache_size_bytes`, func() float64 {
+			var cs fastcache.Stats
+			responseCache.UpdateStats(&cs)
+			return float64(cs.BytesSize)
+		})
+		_ = metrics.NewGauge(`vmauth_response_cache_entries`, func() float64 {
+			var cs fastcache.Stats
+			responseCache.UpdateStats(&cs)
+			return float64(cs.EntriesCount)
+		})
+	})
+	return responseCache
+}
+
+// stopResponseCache stops the response cache and persists it to -responseCache.dataPath if needed.
+func stopResponseCache() {
+	c := responseCache
+	if c == nil {
+		return
+	}
+	if *responseCacheDataPath != "" {
+		startTime := time.Now()
+		if err := c.Save(*responseCacheDataPath); err != nil {
+			logger.Errorf("cannot save response cache to %q: %s", *responseCacheDataPath, err)
+		} else {
+			logger.Infof("saved response cache to %q in %.3f seconds", *responseCacheDataPath, time.Since(startTime).Seconds())
+		}
+	}
+	c.Stop()
+}
+
+var (
+	responseCacheRequests = metrics.NewCounter(`vmauth_response_cache_requests_total`)
+	responseCacheHits     = metrics.NewCounter(`vmauth_response_cache_hits_total`)
+	responseCacheStores   = metrics.NewCounter(`vmauth_response_cache_stores_total`)
+)
+
+// tryServingCachedResponse tries serving the response for r from the response cache.
+//
+// It returns true if the response has been served from the cache.
+// Otherwise it returns the writer, which must be used for proxying the response from backend,
+// so it could be stored in the cache after the mustStore call.
+// nil writer is returned if the response mustn't be cached.
+func tryServingCachedResponse(w http.ResponseWriter, r *http.Request, u *url.URL, up *URLPrefix, hc HeadersConf, ui *UserInfo, claims jwtClaims) (*cachingResponseWriter, bool) {
+	if up.responseCacheTTL <= 0 || r.Method != http.MethodGet {
+		return nil, false
+	}
+	responseCacheRequests.Inc()
+
+	key := getResponseCacheKey(ui.name(), up, r.Host, u, r.Header, claims)
+	if !hasCacheControlDirective(r.Header, "no-cache", "no-store") {
+		c := getResponseCache()
+		buf := c.GetBig(nil, key)
+		if cr, ok := unmarshalCachedResponse(buf, time.Now()); ok {
+			responseCacheHits.Inc()
+			cr.writeTo(w, hc)
+			ui.responseBytesRateLimiter.Consume(len(cr.body))
+			return nil, true
+		}
+	}
+	if hasCacheControlDirective(r.Header, "no-store") {
+		return nil, false
+	}
+	ttl := getResponseCacheTTL(u.Query(), up.responseCacheTTL, time.Now())
+	crw := &cachingResponseWriter{
+		ResponseWriter: w,
+		key:            key,
+		deadline:       time.Now().Add(ttl),
+		maxSize:        responseCacheMaxItemSize.IntN(),
+	}
+	return crw, false
+}
+
+// getResponseCacheKey returns the response cache key for the request to u from the user with the given userName.
+//
+// The key contains the matched url_map entry with its backends, the request host and the values of src_headers,
+// since they determine the backend and the tenant for the request. It also contains the request path,
+// sorted query args and the accepted encoding, since it affects the response body.
+// JWT claims are added to the key, since they may change the backend url and request headers.
+func getResponseCacheKey(userName string, up *URLPrefix, host string, u *url.URL, h http.Header, claims jwtClaims) []byte {
+	var b []byte
+	b = encoding.MarshalBytes(b, []byte(userName))
+	b = encoding.MarshalUint64(b, uint64(up.urlMapIdx))
+	b = encoding.MarshalUint64(b, uint64(len(up.busOriginal)))
+	for _, bu := range up.busOriginal {
+		b = encoding.MarshalBytes(b, []byte(bu.String()))
+	}
+	b = encoding.MarshalBytes(b, []byte(host))
+	for _, sh := range up.srcHeaders {
+		vs := h.Values(sh.Name)
+		b = encoding.MarshalUint64(b, uint64(len(vs)))
+		for _, v := range vs {
+			b = encoding.MarshalBytes(b, []byte(v))
+		}
+	}
+	b = encoding.MarshalBytes(b, []byte(u.Path))
+	// url.Values.Encode sorts query args by name, so the same args in different order result in the same key.
+	b = encoding.MarshalBytes(b, []byte(u.Query().Encode()))
+	b = encoding.MarshalBytes(b, []byte(h.Get("Accept-Encoding")))
+	if claims != nil {
+		data, err := json.Marshal(claims)
+		if err != nil {
+			logger.Panicf("BUG: cannot marshal JWT claims: %s", err)
+		}
+		b = encoding.MarshalUint64(b, xxhash.Sum64(data))
+	}
+	return b
+}
+
+// getResponseCacheTTL returns the cache TTL for the response to the query with the given args.
+//
+// Responses for queries over the recent data are cached until the next step boundary,
+// since a new data point for the query may appear after that. Responses for queries over the data
+// older than responseCacheImmutableOffset are cached for maxTTL.
+func getResponseCacheTTL(args url.Values, maxTTL time.Duration, now time.Time) time.Duration {
+	endStr := args.Get("end")
+	if endStr == "" {
+		endStr = args.Get("time")
+	}
+	end := now
+	if endStr != "" {
+		nsecs, err := timeutil.ParseTimeAt(endStr, now.UnixNano())
+		if err == nil {
+			end = time.Unix(0, nsecs)
+		}
+	}
+	if end.Before(now.Add(-responseCacheImmutableOffset)) {
+		return maxTTL
+	}
+
+	step := parseStep(args.Get("step"))
+	if step <= 0 {
+		return maxTTL
+	}
+	// Align the expiration to the next step boundary.
+	ttl := step - time.Duration(now.UnixNano()%int64(step))
+	return max(min(ttl, maxTTL), time.Second)
+}
+
+func parseStep(s string) time.Duration {
+	if s == "" {
+		return 0
+	}
+	if secs, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.Duration(secs * float64(time.Second))
+	}
+	d, err := timeutil.ParseDuration(s)
+	if err != nil {
+		return 0
+	}
+	return d
+}
+
+func hasCacheControlDirective(h http.Header, directives ...string) bool {
+	for _, v := range h.Values("Cache-Control") {
+		for _, directive := range strings.Split(v, ",") {
+			directive = strings.TrimSpace(directive)
+			if n := strings.IndexByte(directive, '='); n >= 0 {
+				directive = directive[:n]
+			}
+			for _, d := range directives {
+				if strings.EqualFold(directive, d) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// cachingResponseWriter collects the response proxied from backend, so it could be stored in the response cache.
+type cachingResponseWriter struct {
+	http.ResponseWriter
+
+	key      []byte
+	deadline time.Time
+	maxSize  int
+
+	statusCode int
+	body       []byte
+	overflow   bool
+
+	// copyErr is set to the error occurred when proxying the response body from backend
+	copyErr error
+}
+
+// WriteHeader implements http.ResponseWriter interface.
+func (crw *cachingResponseWriter) WriteHeader(statusCode int) {
+	if crw.statusCode == 0 {
+		crw.statusCode = statusCode
+	}
+	crw.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Write implements http.ResponseWriter interface.
+func (crw *cachingResponseWriter) Write(p []byte) (int, error) {
+	if crw.statusCode == 0 {
+		crw.statusCode = http.StatusOK
+	}
+	if !crw.overflow {
+		if len(crw.body)+len(p) > crw.maxSize {
+			crw.overflow = true
+			crw.body = nil
+		} else {
+			crw.body = append(crw.body, p...)
+		}
+	}
+	return crw.ResponseWriter.Write(p)
+}
+
+// mustStore stores the collected response in the response cache if it is cacheable.
+func (crw *cachingResponseWriter) mustStore() {
+	if crw.statusCode != http.StatusOK || crw.overflow || crw.copyErr != nil {
+		return
+	}
+	h := crw.ResponseWriter.Header()
+	if hasCacheControlDirective(h, "no-cache", "no-store", "private") {
+		return
+	}
+	cr := &cachedResponse{
+		deadline:        crw.deadline,
+		contentType:     h.Get("Content-Type"),
+		contentEncoding: h.Get("Content-Encoding"),
+		body:            crw.body,
+	}
+	c := getResponseCache()
+	c.SetBig(crw.key, cr.marshal(nil))
+	responseCacheStores.Inc()
+}
+
+type cachedResponse struct {
+	deadline        time.Time
+	contentType     string
+	contentEncoding string
+	body            []byte
+}
+
+func (cr *cachedResponse) marshal(dst []byte) []byte {
+	dst = encoding.MarshalUint64(dst, uint64(cr.deadline.UnixMilli()))
+	dst = encoding.MarshalBytes(dst, []byte(cr.contentType))
+	dst = encoding.MarshalBytes(dst, []byte(cr.contentEncoding))
+	dst = append(dst, cr.body...)
+	return dst
+}
+
+// unmarshalCachedResponse unmarshals the cached response from src.
+//
+// It returns false if src doesn't contain valid cached response or if the response is expired at now.
+func unmarshalCachedResponse(src []byte, now time.Time) (*cachedResponse, bool) {
+	if len(src) < 8 {
+		return nil, false
+	}
+	deadline := time.UnixMilli(int64(encoding.UnmarshalUint64(src)))
+	if !now.Before(deadline) {
+		return nil, false
+	}
+	src = src[8:]
+
+	contentType, n := encoding.UnmarshalBytes(src)
+	if n <= 0 {
+		return nil, false
+	}
+	src = src[n:]
+
+	contentEncoding, n := encoding.UnmarshalBytes(src)
+	if n <= 0 {
+		return nil, false
+	}
+	src = src[n:]
+
+	cr := &cachedResponse{
+		deadline:        deadline,
+		contentType:     string(contentType),
+		contentEncoding: string(contentEncoding),
+		body:            src,
+	}
+	return cr, true
+}
+
+func (cr *cachedResponse) writeTo(w http.ResponseWriter, hc HeadersConf) {
+	h := w.Header()
+	if cr.contentType != "" {
+		h.Set("Content-Type", cr.contentType)
+	}
+	if cr.contentEncoding != "" {
+		h.Set("Content-Encoding", cr.contentEncoding)
+	}
+	updateHeadersByConfig(h, hc.ResponseHeaders)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(cr.body)
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGetResponseCacheTTL(t *testing.T) {
+	now := time.Unix(1699999990, 0)
+	f := func(query string, ttlExpected time.Duration) {
+		t.Helper()
+		args, err := url.ParseQuery(query)
+		if err != nil {
+			t.Fatalf("cannot parse query %q: %s", query, err)
+		}
+		ttl := getResponseCacheTTL(args, 5*time.Minute, now)
+		if ttl != ttlExpected {
+			t.Fatalf("unexpected ttl for %q; got %s; want %s", query, ttl, ttlExpected)
+		}
+	}
+
+	// missing end and step
+	f("", 5*time.Minute)
+	f("query=up", 5*time.Minute)
+
+	// recent end; the ttl is aligned to the next step boundary
+	f("query=up&step=60", 50*time.Second)
+	f("query=up&step=1m&end=1700000000", 50*time.Second)
+	f("query=up&step=15s&end=now", 5*time.Second)
+	f("query=up&time=1700000005&step=30s", 20*time.Second)
+
+	// step exceeding the max ttl
+	f("query=up&step=1h", 5*time.Minute)
+
+	// the ttl cannot be smaller than a second
+	f("query=up&step=0.5", time.Second)
+
+	// end is older than the immutable offset
+	f("query=up&step=60&end=1699990000", 5*time.Minute)
+	f("query=up&time=2023-11-14T20:00:00Z", 5*time.Minute)
+
+	// invalid step
+	f("query=up&step=foo", 5*time.Minute)
+}
+
+func TestHasCacheControlDirective(t *testing.T) {
+	f := func(values []string, directive string, resultExpected bool) {
+		t.Helper()
+		h := http.Header{}
+		for _, v := range values {
+			h.Add("Cache-Control", v)
+		}
+		result := hasCacheControlDirective(h, directive)
+		if result != resultExpected {
+			t.Fatalf("unexpected result for %q; got %v; want %v", values, result, resultExpected)
+		}
+	}
+
+	f(nil, "no-cache", false)
+	f([]string{"no-cache"}, "no-cache", true)
+	f([]string{"No-Cache"}, "no-cache", true)
+	f([]string{"max-age=0, no-store"}, "no-store", true)
+	f([]string{"max-age=0", "no-store"}, "no-store", true)
+	f([]string{"no-cache=Set-Cookie"}, "no-cache", true)
+	f([]string{"max-age=60"}, "no-cache", false)
+}
+
+func TestCachedResponseMarshalUnmarshal(t *testing.T) {
+	now := time.Now()
+	cr := &cachedResponse{
+		deadline:        now.Add(time.Minute),
+		contentType:     "application/json",
+		contentEncoding: "gzip",
+		body:            []byte("foobar"),
+	}
+	data := cr.marshal(nil)
+
+	result, ok := unmarshalCachedResponse(data, now)
+	if !ok {
+		t.Fatalf("cannot unmarshal cached response")
+	}
+	if result.contentType != cr.contentType || result.contentEncoding != cr.contentEncoding || string(result.body) != string(cr.body) {
+		t.Fatalf("unexpected unmarshaled response; got %+v; want %+v", result, cr)
+	}
+
+	// expired response
+	if _, ok := unmarshalCachedResponse(data, now.Add(2*time.Minute)); ok {
+		t.Fatalf("expecting expired response")
+	}
+
+	// missing and invalid responses
+	if _, ok := unmarshalCachedResponse(nil, now); ok {
+		t.Fatalf("expecting failure for empty data")
+	}
+	if _, ok := unmarshalCachedResponse(data[:9], now); ok {
+		t.Fatalf("expecting failure for truncated data")
+	}
+}
+
+func TestRequestHandlerResponseCache(t *testing.T) {
+	var backendRequests atomic.Int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := backendRequests.Add(1)
+		if r.URL.Query().Get("nocache") == "1" {
+			w.Header().Set("Cache-Control", "no-store")
+		}
+		if r.URL.Query().Get("fail") == "1" {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+		fmt.Fprintf(w, "requested_url=%s\nbackend_request=%d", r.URL, n)
+	}))
+	defer ts.Close()
+
+	cfgStr := strings.ReplaceAll(`
+users:
+- username: foo
+  password: bar
+  url_map:
+  - src_paths: ["/api/v1/query_range"]
+    url_prefix: {BACKEND}
+    response_cache_ttl: 5m
+  - src_paths: ["/api/v1/query"]
+    url_prefix: {BACKEND}
+- username: baz
+  password: bar
+  url_map:
+  - src_paths: ["/api/v1/query_range"]
+    url_prefix: {BACKEND}
+    response_cache_ttl: 5m
+`, "{BACKEND}", ts.URL)
+
+	cfgOrigP := authConfigData.Load()
+	if _, err := reloadAuthConfigData([]byte(cfgStr)); err != nil {
+		t.Fatalf("cannot load config data: %s", err)
+	}
+	defer func() {
+		cfgOrig := []byte("unauthorized_user:\n  url_prefix: http://foo/bar")
+		if cfgOrigP != nil {
+			cfgOrig = *cfgOrigP
+		}
+		if _, err := reloadAuthConfigData(cfgOrig); err != nil {
+			t.Fatalf("cannot load the original config: %s", err)
+		}
+	}()
+
+	f := func(method, username, requestURL, cacheControl, responseExpected string) {
+		t.Helper()
+		r, err := http.NewRequest(method, requestURL, nil)
+		if err != nil {
+			t.Fatalf("cannot initialize http request: %s", err)
+		}
+		r.RequestURI = r.URL.RequestURI()
+		r.RemoteAddr = "42.2.3.84:6789"
+		r.SetBasicAuth(username, "bar")
+		if cacheControl != "" {
+			r.Header.Set("Cache-Control", cacheControl)
+		}
+		w := &fakeResponseWriter{}
+		if !requestHandler(w, r) {
+			t.Fatalf("unexpected false is returned from requestHandler")
+		}
+		response := w.getResponse()
+		response = strings.ReplaceAll(response, "\r\n", "\n")
+		response = strings.TrimSpace(response)
+		responseExpected = strings.TrimSpace(responseExpected)
+		if response != responseExpected {
+			t.Fatalf("unexpected response\ngot\n%s\nwant\n%s", response, responseExpected)
+		}
+	}
+
+	// The response is cached after the first request.
+	f(http.MethodGet, "foo", "http://vmauth/api/v1/query_range?query=up&start=1&end=2&step=1", "", `
+statusCode=200
+requested_url=/api/v1/query_range?end=2&query=up&start=1&step=1
+backend_request=1`)
+	f(http.MethodGet, "foo", "http://vmauth/api/v1/query_range?query=up&start=1&end=2&step=1", "", `
+statusCode=200
+requested_url=/api/v1/query_range?end=2&query=up&start=1&step=1
+backend_request=1`)
+
+	// Query args in different order hit the cache.
+	f(http.MethodGet, "foo", "http://vmauth/api/v1/query_range?step=1&end=2&start=1&query=up", "", `
+statusCode=200
+requested_url=/api/v1/query_range?end=2&query=up&start=1&step=1
+backend_request=1`)
+
+	// Cached responses aren't shared among users.
+	f(http.MethodGet, "baz", "http://vmauth/api/v1/query_range?query=up&start=1&end=2&step=1", "", `
+statusCode=200
+requested_url=/api/v1/query_range?end=2&query=up&start=1&step=1
+backend_request=2`)
+
+	// Cache-Control: no-cache bypasses the cache and updates the cached response.
+	f(http.MethodGet, "foo", "http://vmauth/api/v1/query_range?query=up&start=1&end=2&step=1", "no-cache", `
+statusCode=200
+requested_url=/api/v1/query_range?end=2&query=up&start=1&step=1
+backend_request=3`)
+	f(http.MethodGet, "foo", "http://vmauth/api/v1/query_range?query=up&start=1&end=2&step=1", "", `
+statusCode=200
+requested_url=/api/v1/query_range?end=2&query=up&start=1&step=1
+backend_request=3`)
+
+	// Non-GET requests aren't cached.
+	f(http.MethodPost, "foo", "http://vmauth/api/v1/query_range?query=up&start=1&end=2&step=1", "", `
+statusCode=200
+requested_url=/api/v1/query_range?end=2&query=up&start=1&step=1
+backend_request=4`)
+
+	// Routes without response_cache_ttl aren't cached.
+	f(http.MethodGet, "foo", "http://vmauth/api/v1/query?query=up&time=1", "", `
+statusCode=200
+requested_url=/api/v1/query?query=up&time=1
+backend_request=5`)
+	f(http.MethodGet, "foo", "http://vmauth/api/v1/query?query=up&time=1", "", `
+statusCode=200
+requested_url=/api/v1/query?query=up&time=1
+backend_request=6`)
+
+	// Responses with Cache-Control: no-store aren't cached.
+	f(http.MethodGet, "foo", "http://vmauth/api/v1/query_range?query=up&nocache=1", "", `
+statusCode=200
+Cache-Control: no-store
+requested_url=/api/v1/query_range?nocache=1&query=up
+backend_request=7`)
+	f(http.MethodGet, "foo", "http://vmauth/api/v1/query_range?query=up&nocache=1", "", `
+statusCode=200
+Cache-Control: no-store
+requested_url=/api/v1/query_range?nocache=1&query=up
+backend_request=8`)
+
+	// Non-200 responses aren't cached.
+	f(http.MethodGet, "foo", "http://vmauth/api/v1/query_range?query=up&fail=1", "", `
+statusCode=400
+requested_url=/api/v1/query_range?fail=1&query=up
+backend_request=9`)
+	f(http.MethodGet, "foo", "http://vmauth/api/v1/query_range?query=up&fail=1", "", `
+statusCode=400
+requested_url=/api/v1/query_range?fail=1&query=up
+backend_request=10`)
+}
+
+func TestRequestHandlerResponseCacheKey(t *testing.T) {
+	var backendRequests atomic.Int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := backendRequests.Add(1)
+		if strings.HasSuffix(r.URL.Path, "/truncated") {
+			// Send truncated response body.
+			w.Header().Set("Content-Length", "1000")
+		}
+		fmt.Fprintf(w, "requested_url=%s\nbackend_request=%d", r.URL, n)
+	}))
+	defer ts.Close()
+
+	cfgStr := strings.ReplaceAll(`
+users:
+- username: foo
+  password: bar
+  url_map:
+  - src_paths: ["/api/v1/query_range"]
+    src_headers: ["TenantID: 1", "TenantID: 2"]
+    url_prefix: {BACKEND}/tenant
+    response_cache_ttl: 5m
+  - src_paths: ["/api/v1/query_range", "/truncated"]
+    url_prefix: {BACKEND}/default
+    response_cache_ttl: 5m
+`, "{BACKEND}", ts.URL)
+
+	cfgOrigP := authConfigData.Load()
+	if _, err := reloadAuthConfigData([]byte(cfgStr)); err != nil {
+		t.Fatalf("cannot load config data: %s", err)
+	}
+	defer func() {
+		cfgOrig := []byte("unauthorized_user:\n  url_prefix: http://foo/bar")
+		if cfgOrigP != nil {
+			cfgOrig = *cfgOrigP
+		}
+		if _, err := reloadAuthConfigData(cfgOrig); err != nil {
+			t.Fatalf("cannot load the original config: %s", err)
+		}
+	}()
+
+	f := func(requestURL, tenantID, responseExpected string) {
+		t.Helper()
+		r, err := http.NewRequest(http.MethodGet, requestURL, nil)
+		if err != nil {
+			t.Fatalf("cannot initialize http request: %s", err)
+		}
+		r.RequestURI = r.URL.RequestURI()
+		r.RemoteAddr = "42.2.3.84:6789"
+		r.SetBasicAuth("foo", "bar")
+		if tenantID != "" {
+			r.Header.Set("TenantID", tenantID)
+		}
+		w := &fakeResponseWriter{}
+		if !requestHandler(w, r) {
+			t.Fatalf("unexpected false is returned from requestHandler")
+		}
+		response := w.getResponse()
+		response = strings.ReplaceAll(response, "\r\n", "\n")
+		response = strings.TrimSpace(response)
+		responseExpected = strings.TrimSpace(responseExpected)
+		if response != responseExpected {
+			t.Fatalf("unexpected response\ngot\n%s\nwant\n%s", response, responseExpected)
+		}
+	}
+
+	f("http://vmauth/api/v1/query_range?query=up&start=1&end=2", "1", `
+statusCode=200
+requested_url=/tenant/api/v1/query_range?end=2&query=up&start=1
+backend_request=1`)
+	f("http://vmauth/api/v1/query_range?query=up&start=1&end=2", "1", `
+statusCode=200
+requested_url=/tenant/api/v1/query_range?end=2&query=up&start=1
+backend_request=1`)
+
+	// Distinct src_headers values do not share the cached response.
+	f("http://vmauth/api/v1/query_range?query=up&start=1&end=2", "2", `
+statusCode=200
+requested_url=/tenant/api/v1/query_range?end=2&query=up&start=1
+backend_request=2`)
+
+	// Distinct url_map entries do not share the cached response.
+	f("http://vmauth/api/v1/query_range?query=up&start=1&end=2", "", `
+statusCode=200
+requested_url=/default/api/v1/query_range?end=2&query=up&start=1
+backend_request=3`)
+
+	// Distinct hosts do not share the cached response.
+	f("http://vmauth-other/api/v1/query_range?query=up&start=1&end=2", "1", `
+statusCode=200
+requested_url=/tenant/api/v1/query_range?end=2&query=up&start=1
+backend_request=4`)
+	f("http://vmauth-other/api/v1/query_range?query=up&start=1&end=2", "1", `
+statusCode=200
+requested_url=/tenant/api/v1/query_range?end=2&query=up&start=1
+backend_request=4`)
+
+	// Truncated responses aren't cached.
+	f("http://vmauth/truncated?query=up&start=1&end=2", "", `
+statusCode=200
+requested_url=/default/truncated?end=2&query=up&start=1
+backend_request=5`)
+	f("http://vmauth/truncated?query=up&start=1&end=2", "", `
+statusCode=200
+requested_url=/default/truncated?end=2&query=up&start=1
+backend_request=6`)
+}
@@ -51,7 +51,7 @@ func dropPrefixParts(path string, parts int) string {
 	return path
 }
 
-func (ui *UserInfo) getURLPrefixAndHeaders(u *url.URL, host string, h http.Header) (*URLPrefix, HeadersConf) {
+func (ui *UserInfo) getURLPrefixAndHeaders(u *url.URL, host string, h http.Header, claims jwtClaims) (*URLPrefix, HeadersConf) {
 	for _, e := range ui.URLMaps {
 		if !matchAnyRegex(e.SrcHosts, host) {
 			continue
@@ -59,7 +59,7 @@ func (ui *UserInfo) getURLPrefixAndHeaders(u *url.URL, host string, h http.Heade
 		if !matchAnyRegex(e.SrcPaths, u.Path) {
 			continue
 		}
-		if !matchAnyQueryArg(e.SrcQueryArgs, u.Query()) {
+		if !matchAnyQueryArg(e.SrcQueryArgs, u.Query(), claims) {
 			continue
 		}
 		if !matchAnyHeader(e.SrcHeaders, h) {
@@ -86,7 +86,7 @@ func matchAnyRegex(rs []*Regex, s string) bool {
 	return false
 }
 
-func matchAnyQueryArg(qas []*QueryArg, args url.Values) bool {
+func matchAnyQueryArg(qas []*QueryArg, args url.Values, claims jwtClaims) bool {
 	if len(qas) == 0 {
 		return true
 	}
@@ -95,6 +95,17 @@ func matchAnyQueryArg(qas []*QueryArg, args url.Values) bool {
 		if !ok {
 			continue
 		}
+		if qa.valueTemplate != "" && claims != nil {
+			// The query arg value must be equal to the value with substituted JWT claims.
+			expected, err := claims.substitute(qa.valueTemplate)
+			if err != nil {
+				continue
+			}
+			if slices.Contains(vs, expected) {
+				return true
+			}
+			continue
+		}
 		for _, v := range vs {
 			if qa.Value.match(v) {
 				return true
@@ -424,6 +424,22 @@ var (
 	}
 )
 
+const (
+	vmagentFilePath = "vmagent-file-path"
+)
+
+var (
+	vmagentFileFlags = []cli.Flag{
+		&cli.StringFlag{
+			Name: vmagentFilePath,
+			Usage: "Path to a file or to a directory with files written by vmagent via -remoteWrite.url=file:///path/to/dir . " +
+				"Files are imported in the order they were created by vmagent. " +
+				"See https://docs.victoriametrics.com/victoriametrics/vmagent/#writing-data-to-local-files",
+			Required: true,
+		},
+	}
+)
+
 const (
 	vmNativeFilterMatch       = "vm-native-filter-match"
 	vmNativeFilterTimeStart   = "vm-native-filter-time-start"
@@ -274,6 +274,22 @@ func main() {
 					return pp.run()
 				},
 			},
+			{
+				Name:   "vmagent-file",
+				Usage:  "Import files written by vmagent via -remoteWrite.url=file:///path/to/dir",
+				Flags:  mergeFlags(globalFlags, vmagentFileFlags, vmFlags),
+				Before: beforeFn,
+				Action: func(c *cli.Context) error {
+					fmt.Println("vmagent file import mode")
+
+					vmCfg, err := initConfigVM(c)
+					if err != nil {
+						return fmt.Errorf("failed to init VM configuration: %s", err)
+					}
+					fp := newVMAgentFileProcessor(c.String(vmagentFilePath), vmCfg, c.Bool(globalVerbose))
+					return fp.run(ctx)
+				},
+			},
 			{
 				Name:   "vm-native",
 				Usage:  "Migrate time series between VictoriaMetrics installations",
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/backoff"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/barpool"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/limiter"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmctl/vm"
+)
+
+// vmagentFileProcessor imports files written by vmagent
+// via -remoteWrite.url=file:///path/to/dir into VictoriaMetrics.
+type vmagentFileProcessor struct {
+	// path is a path to a single file or to a directory with files
+	path string
+
+	// cfg contains the destination VictoriaMetrics config
+	cfg    vm.Config
+	client *http.Client
+	rl     *limiter.Limiter
+
+	// cc stands for concurrency
+	// and defines number of concurrently
+	// imported files
+	cc int
+
+	// isVerbose enables verbose output
+	isVerbose bool
+
+	filesImported atomic.Uint64
+	bytesImported atomic.Uint64
+}
+
+func newVMAgentFileProcessor(path string, cfg vm.Config, isVerbose bool) *vmagentFileProcessor {
+	client := &http.Client{}
+	if cfg.Transport != nil {
+		client.Transport = cfg.Transport
+	}
+	cc := int(cfg.Concurrency)
+	if cc < 1 {
+		cc = 1
+	}
+	return &vmagentFileProcessor{
+		path:      path,
+		cfg:       cfg,
+		client:    client,
+		rl:        limiter.NewLimiter(cfg.RateLimit),
+		cc:        cc,
+		isVerbose: isVerbose,
+	}
+}
+
+func (fp *vmagentFileProcessor) run(ctx context.Context) error {
+	files, err := listVMAgentFiles(fp.path)
+	if err != nil {
+		return fmt.Errorf("cannot list files at %q: %w", fp.path, err)
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("found no files to import at %q", fp.path)
+	}
+	question := fmt.Sprintf("Found %d files to import. Continue?", len(files))
+	if !prompt(question) {
+		return nil
+	}
+
+	startTime := time.Now()
+	if err := fp.importFiles(ctx, files); err != nil {
+		return fmt.Errorf("import failed: %w", err)
+	}
+
+	log.Println("Import finished!")
+	log.Printf("VictoriaMetrics importer stats:\n"+
+		"  time spent while importing: %v;\n"+
+		"  files imported: %d;\n"+
+		"  bytes imported: %d;", time.Since(startTime), fp.filesImported.Load(), fp.bytesImported.Load())
+	return nil
+}
+
+func (fp *vmagentFileProcessor) importFiles(ctx context.Context, files []string) error {
+	bar := barpool.AddWithTemplate(fmt.Sprintf(barTpl, "Processing files"), len(files))
+	if err := barpool.Start(); err != nil {
+		return err
+	}
+	defer barpool.Stop()
+
+	filesCh := make(chan string)
+	errCh := make(chan error, fp.cc)
+
+	var wg sync.WaitGroup
+	wg.Add(fp.cc)
+	for i := 0; i < fp.cc; i++ {
+		go func() {
+			defer wg.Done()
+			for path := range filesCh {
+				if err := fp.importFile(ctx, path); err != nil {
+					errCh <- fmt.Errorf("cannot import %q: %w", path, err)
+					return
+				}
+				bar.Increment()
+			}
+		}()
+	}
+	// any error breaks the import
+	for _, path := range files {
+		select {
+		case err := <-errCh:
+			close(filesCh)
+			wg.Wait()
+			return err
+		case <-ctx.Done():
+			close(filesCh)
+			wg.Wait()
+			return ctx.Err()
+		case filesCh <- path:
+		}
+	}
+
+	close(filesCh)
+	wg.Wait()
+	close(errCh)
+	for err := range errCh {
+		return err
+	}
+	return nil
+}
+
+func (fp *vmagentFileProcessor) importFile(ctx context.Context, path string) error {
+	importPath, contentEncoding, err := getVMAgentFileImportPath(path)
+	if err != nil {
+		return err
+	}
+	addr := strings.TrimRight(fp.cfg.Addr, "/")
+	importURL := fmt.Sprintf("%s/%s", addr, importPath)
+	if fp.cfg.AccountID != "" {
+		importURL = fmt.Sprintf("%s/insert/%s/prometheus/%s", addr, fp.cfg.AccountID, importPath)
+	}
+	importURL, err = vm.AddExtraLabelsToImportPath(importURL, fp.cfg.ExtraLabels)
+	if err != nil {
+		return err
+	}
+
+	attempts, err := fp.cfg.Backoff.Retry(ctx, func() error {
+		return fp.sendFile(ctx, importURL, contentEncoding, path)
+	})
+	if err != nil {
+		return fmt.Errorf("import failed with %d retries: %w", attempts, err)
+	}
+	if fp.isVerbose {
+		log.Printf("imported %q to %q", path, importURL)
+	}
+	return nil
+}
+
+func (fp *vmagentFileProcessor) sendFile(ctx context.Context, importURL, contentEncoding, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	r := &limitedReader{
+		r:  f,
+		rl: fp.rl,
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, importURL, r)
+	if err != nil {
+		return fmt.Errorf("cannot create request to %q: %w", fp.cfg.Addr, err)
+	}
+	if fp.cfg.User != "" {
+		req.SetBasicAuth(fp.cfg.User, fp.cfg.Password)
+	}
+	if contentEncoding != "" {
+		req.Header.Set("Content-Encoding", contentEncoding)
+	}
+	resp, err := fp.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("unexpected error when performing request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusNoContent {
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return fmt.Errorf("failed to read response body for status code %d: %w", resp.StatusCode, err)
+		}
+		if resp.StatusCode == http.StatusBadRequest {
+			return fmt.Errorf("%w: %s", backoff.ErrBadRequest, body)
+		}
+		return fmt.Errorf("unexpected response code %d: %s", resp.StatusCode, body)
+	}
+	fp.filesImported.Add(1)
+	fp.bytesImported.Add(r.n)
+	return nil
+}
+
+// listVMAgentFiles returns files written by vmagent at the given path in the order they were created.
+//
+// The path may point either to a single file or to a directory with files.
+func listVMAgentFiles(path string) ([]string, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return []string{path}, nil
+	}
+	des, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, de := range des {
+		if !de.Type().IsRegular() {
+			continue
+		}
+		if _, _, err := getVMAgentFileImportPath(de.Name()); err != nil {
+			// Skip unsupported files and files, which are still written by vmagent.
+			continue
+		}
+		files = append(files, filepath.Join(path, de.Name()))
+	}
+	// vmagent names files by their creation time, so the lexicographical order matches the creation order.
+	slices.Sort(files)
+	return files, nil
+}
+
+// getVMAgentFileImportPath returns the import path and the content encoding for the file written by vmagent.
+func getVMAgentFileImportPath(path string) (string, string, error) {
+	name := filepath.Base(path)
+	contentEncoding := ""
+	if n, ok := strings.CutSuffix(name, ".zst"); ok {
+		name = n
+		contentEncoding = "zstd"
+	}
+	switch filepath.Ext(name) {
+	case ".native":
+		return "api/v1/import/native", contentEncoding, nil
+	case ".jsonline":
+		return "api/v1/import", contentEncoding, nil
+	default:
+		return "", "", fmt.Errorf("unsupported file %q; vmagent writes files with .native, .jsonline, .native.zst or .jsonline.zst extensions", path)
+	}
+}
+
+type limitedReader struct {
+	r  io.Reader
+	rl *limiter.Limiter
+	n  uint64
+}
+
+func (lr *limitedReader) Read(p []byte) (int, error) {
+	n, err := lr.r.Read(p)
+	lr.rl.Register(n)
+	lr.n += uint64(n)
+	return n, err
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestGetVMAgentFileImportPath_Failure(t *testing.T) {
+	f := func(path string) {
+		t.Helper()
+
+		_, _, err := getVMAgentFileImportPath(path)
+		if err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+
+	// file which is still written by vmagent
+	f("/data/20251019T120000_000000001.native.tmp")
+
+	// unsupported extension
+	f("/data/foo.json")
+	f("/data/foo.zst")
+}
+
+func TestGetVMAgentFileImportPath_Success(t *testing.T) {
+	f := func(path, importPathExpected, contentEncodingExpected string) {
+		t.Helper()
+
+		importPath, contentEncoding, err := getVMAgentFileImportPath(path)
+		if err != nil {
+			t.Fatalf("getVMAgentFileImportPath() error: %s", err)
+		}
+		if importPath != importPathExpected {
+			t.Fatalf("unexpected import path; got %q; want %q", importPath, importPathExpected)
+		}
+		if contentEncoding != contentEncodingExpected {
+			t.Fatalf("unexpected content encoding; got %q; want %q", contentEncoding, contentEncodingExpected)
+		}
+	}
+
+	f("/data/20251019T120000_000000001.native", "api/v1/import/native", "")
+	f("/data/20251019T120000_000000001.native.zst", "api/v1/import/native", "zstd")
+	f("20251019T120000_000000001.jsonline", "api/v1/import", "")
+	f("20251019T120000_000000001.jsonline.zst", "api/v1/import", "zstd")
+}
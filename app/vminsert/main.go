@@ -5,6 +5,7 @@ import (
 	"flag"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -323,7 +324,14 @@ func RequestHandler(w http.ResponseWriter, r *http.Request) bool {
 		// https://prometheus.io/docs/prometheus/latest/querying/api/#targets
 		state := r.FormValue("state")
 		scrapePool := r.FormValue("scrapePool")
-		promscrape.WriteAPIV1Targets(w, state, scrapePool)
+		showHistory, _ := strconv.ParseBool(r.FormValue("history"))
+		promscrape.WriteAPIV1Targets(w, state, scrapePool, showHistory)
+		return true
+	case "/prometheus/api/v1/targets/cardinality", "/api/v1/targets/cardinality":
+		promscrapeAPIV1TargetsCardinalityRequests.Inc()
+		w.Header().Set("Content-Type", "application/json")
+		scrapePool := r.FormValue("scrapePool")
+		promscrape.WriteAPIV1TargetsCardinality(w, scrapePool)
 		return true
 	case "/prometheus/target_response", "/target_response":
 		promscrapeTargetResponseRequests.Inc()
@@ -432,7 +440,8 @@ var (
 	promscrapeTargetsRequests          = metrics.NewCounter(`vm_http_requests_total{path="/targets"}`)
 	promscrapeServiceDiscoveryRequests = metrics.NewCounter(`vm_http_requests_total{path="/service-discovery"}`)
 
-	promscrapeAPIV1TargetsRequests = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/targets"}`)
+	promscrapeAPIV1TargetsRequests            = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/targets"}`)
+	promscrapeAPIV1TargetsCardinalityRequests = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/targets/cardinality"}`)
 
 	promscrapeTargetResponseRequests = metrics.NewCounter(`vm_http_requests_total{path="/target_response"}`)
 	promscrapeTargetResponseErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/target_response"}`)
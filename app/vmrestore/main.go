@@ -30,6 +30,10 @@ var (
 	concurrency             = flag.Int("concurrency", 10, "The number of concurrent workers. Higher concurrency may reduce restore duration")
 	maxBytesPerSecond       = flagutil.NewBytes("maxBytesPerSecond", 0, "The maximum download speed. There is no limit if it is set to 0")
 	skipBackupCompleteCheck = flag.Bool("skipBackupCompleteCheck", false, "Whether to skip checking for 'backup complete' file in -src. This may be useful for restoring from old backups, which were created without 'backup complete' file")
+	verifyOnly              = flag.Bool("verifyOnly", false, "Whether to verify the integrity of the backup at -src without restoring it. All the backed up data is downloaded and checked against the stored checksums. "+
+		"See https://docs.victoriametrics.com/victoriametrics/vmrestore/#backup-verification")
+	verifyOnlyCheckPartHeaders = flag.Bool("verifyOnly.checkPartHeaders", false, "Whether to decode metadata.json and metaindex.bin files for every data part when -verifyOnly is set. "+
+		"See https://docs.victoriametrics.com/victoriametrics/vmrestore/#backup-verification")
 )
 
 func main() {
@@ -54,6 +58,10 @@ func main() {
 	if err != nil {
 		logger.Fatalf("%s", err)
 	}
+	if *verifyOnly {
+		verifyBackup(ctx, srcFS, listenAddrs)
+		return
+	}
 	dstFS, err := newDstFS()
 	if err != nil {
 		logger.Fatalf("%s", err)
@@ -72,6 +80,27 @@ func main() {
 	srcFS.MustStop()
 	dstFS.MustStop()
 
+	stopHTTPServer(listenAddrs)
+}
+
+func verifyBackup(ctx context.Context, srcFS common.RemoteFS, listenAddrs []string) {
+	a := &actions.Verify{
+		Concurrency:             *concurrency,
+		Src:                     srcFS,
+		SkipBackupCompleteCheck: *skipBackupCompleteCheck,
+		CheckPartHeaders:        *verifyOnlyCheckPartHeaders,
+	}
+	pushmetrics.Init()
+	if err := a.Run(ctx); err != nil {
+		logger.Fatalf("backup verification failed: %s", err)
+	}
+	pushmetrics.Stop()
+	srcFS.MustStop()
+
+	stopHTTPServer(listenAddrs)
+}
+
+func stopHTTPServer(listenAddrs []string) {
 	startTime := time.Now()
 	logger.Infof("gracefully shutting down http server for metrics at %q", listenAddrs)
 	if err := httpserver.Stop(listenAddrs); err != nil {
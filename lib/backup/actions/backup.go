@@ -9,9 +9,11 @@ import (
 	"time"
 
 	"github.com/VictoriaMetrics/metrics"
+	"github.com/cespare/xxhash/v2"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/backupnames"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/common"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/fsencrypted"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/fslocal"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/fsnil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
@@ -65,6 +67,14 @@ func (b *Backup) Run() error {
 		origin = &fsnil.FS{}
 	}
 
+	if rfs, ok := origin.(common.RemoteFS); ok {
+		if err := fsencrypted.CheckBackup(rfs); err != nil {
+			return fmt.Errorf("cannot use origin: %w", err)
+		}
+	}
+	if err := fsencrypted.PrepareBackup(dst); err != nil {
+		return err
+	}
 	if err := dst.DeleteFile(backupnames.BackupCompleteFilename); err != nil {
 		return fmt.Errorf("cannot delete `backup complete` file at %s: %w", dst, err)
 	}
@@ -128,6 +138,24 @@ func runBackup(src *fslocal.FS, dst common.RemoteFS, origin common.OriginFS, con
 	}
 	logger.Infof("obtained %d parts from origin %s", len(originParts), origin)
 
+	// Part checksums aren't stored for encrypted backups, since every chunk of encrypted parts is authenticated during decryption.
+	_, isEncrypted := dst.(*fsencrypted.FS)
+	checksums := newPartChecksums()
+	originChecksums := newPartChecksums()
+	if !isEncrypted {
+		dstChecksums, err := readPartChecksums(dst)
+		if err != nil {
+			return err
+		}
+		if rfs, ok := origin.(common.RemoteFS); ok {
+			originChecksums, err = readPartChecksums(rfs)
+			if err != nil {
+				return err
+			}
+		}
+		checksums.copyFrom(dstChecksums, common.PartsIntersect(dstParts, srcParts))
+	}
+
 	backupSize := getPartsSize(srcParts)
 	partsToDelete := common.PartsDifference(dstParts, srcParts)
 	deleteSize := getPartsSize(partsToDelete)
@@ -141,6 +169,7 @@ func runBackup(src *fslocal.FS, dst common.RemoteFS, origin common.OriginFS, con
 	if err := copySrcParts(origin, dst, originPartsToCopy, concurrency); err != nil {
 		return fmt.Errorf("cannot server-side copy origin parts to dst: %w", err)
 	}
+	checksums.copyFrom(originChecksums, originPartsToCopy)
 
 	srcCopyParts := common.PartsDifference(partsToCopy, originParts)
 	uploadSize := getPartsSize(srcCopyParts)
@@ -153,13 +182,17 @@ func runBackup(src *fslocal.FS, dst common.RemoteFS, origin common.OriginFS, con
 			if err != nil {
 				return fmt.Errorf("cannot create reader for %s from %s: %w", &p, src, err)
 			}
+			h := xxhash.New()
 			sr := &statReader{
-				r:         rc,
+				r:         io.TeeReader(rc, h),
 				bytesRead: &bytesUploaded,
 			}
 			if err := dst.UploadPart(p, sr); err != nil {
 				return fmt.Errorf("cannot upload %s to %s: %w", &p, dst, err)
 			}
+			if !isEncrypted {
+				checksums.set(p, h.Sum64())
+			}
 			if err = rc.Close(); err != nil {
 				return fmt.Errorf("cannot close reader for %s from %s: %w", &p, src, err)
 			}
@@ -174,6 +207,21 @@ func runBackup(src *fslocal.FS, dst common.RemoteFS, origin common.OriginFS, con
 		}
 	}
 
+	if isEncrypted {
+		if err := dst.DeleteFile(backupnames.BackupChecksumsFilename); err != nil {
+			return fmt.Errorf("cannot delete %s file at %s: %w", backupnames.BackupChecksumsFilename, dst, err)
+		}
+	} else {
+		// Parts uploaded by the previous unsuccessful backup and parts copied from origin without checksums
+		// have no checksums yet, so calculate them from src.
+		if err := calculateMissingChecksums(src, srcParts, checksums, concurrency); err != nil {
+			return err
+		}
+		if err := checksums.store(dst, srcParts); err != nil {
+			return err
+		}
+	}
+
 	logger.Infof("backup from %s to %s with origin %s is complete; backed up %d bytes in %.3f seconds; server-side deleted %d bytes; "+
 		"server-side copied %d bytes; uploaded %d bytes",
 		src, dst, origin, backupSize, time.Since(startTime).Seconds(), deleteSize, copySize, uploadSize)
@@ -181,6 +229,45 @@ func runBackup(src *fslocal.FS, dst common.RemoteFS, origin common.OriginFS, con
 	return nil
 }
 
+func calculateMissingChecksums(src *fslocal.FS, parts []common.Part, checksums *partChecksums, concurrency int) error {
+	var partsWithoutChecksums []common.Part
+	for _, p := range parts {
+		if _, ok := checksums.get(p); !ok {
+			partsWithoutChecksums = append(partsWithoutChecksums, p)
+		}
+	}
+	if len(partsWithoutChecksums) == 0 {
+		return nil
+	}
+	logger.Infof("calculating checksums for %d parts without checksums from %s", len(partsWithoutChecksums), src)
+	calculateSize := getPartsSize(partsWithoutChecksums)
+	var bytesRead atomic.Uint64
+	return runParallel(concurrency, partsWithoutChecksums, func(p common.Part) error {
+		rc, err := src.NewReadCloser(p)
+		if err != nil {
+			return fmt.Errorf("cannot create reader for %s from %s: %w", &p, src, err)
+		}
+		h := xxhash.New()
+		n, err := io.Copy(h, rc)
+		if err1 := rc.Close(); err1 != nil && err == nil {
+			err = err1
+		}
+		if err != nil {
+			return fmt.Errorf("cannot read %s from %s: %w", &p, src, err)
+		}
+		if uint64(n) != p.Size {
+			return fmt.Errorf("unexpected number of bytes read for %s from %s; got %d; want %d", &p, src, n, p.Size)
+		}
+		bytesRead.Add(uint64(n))
+		checksums.set(p, h.Sum64())
+		return nil
+	}, func(elapsed time.Duration) {
+		n := bytesRead.Load()
+		prc := 100 * float64(n) / float64(calculateSize)
+		logger.Infof("calculated checksums for %d out of %d bytes (%.2f%%) from %s in %s", n, calculateSize, prc, src, elapsed)
+	})
+}
+
 type statReader struct {
 	r         io.Reader
 	bytesRead *atomic.Uint64
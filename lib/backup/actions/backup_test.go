@@ -0,0 +1,168 @@
+package actions
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cespare/xxhash/v2"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/backupnames"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/common"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/fsencrypted"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/fslocal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/fsremote"
+)
+
+var testSnapshotFiles = map[string]string{
+	"data/small/foo": "foo contents",
+	"data/big/bar":   "bar contents",
+	"indexdb/baz":    "baz contents",
+}
+
+func newTestSnapshot(t *testing.T) *fslocal.FS {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "20250101000000-0000000000000001")
+	for name, data := range testSnapshotFiles {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatalf("cannot create directory for %q: %s", path, err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatalf("cannot create %q: %s", path, err)
+		}
+	}
+	return &fslocal.FS{
+		Dir: dir,
+	}
+}
+
+func checkTestBackupChecksums(t *testing.T, dst common.RemoteFS, srcParts []common.Part) {
+	t.Helper()
+	checksums, err := readPartChecksums(dst)
+	if err != nil {
+		t.Fatalf("cannot read checksums: %s", err)
+	}
+	if len(checksums.m) != len(srcParts) {
+		t.Fatalf("unexpected number of checksums; got %d; want %d", len(checksums.m), len(srcParts))
+	}
+	for _, p := range srcParts {
+		h, ok := checksums.get(p)
+		if !ok {
+			t.Fatalf("missing checksum for %s", &p)
+		}
+		hExpected := xxhash.Sum64String(testSnapshotFiles[p.Path])
+		if h != hExpected {
+			t.Fatalf("unexpected checksum for %s; got %016X; want %016X", &p, h, hExpected)
+		}
+	}
+}
+
+func TestBackupChecksums(t *testing.T) {
+	src := newTestSnapshot(t)
+	srcParts, err := src.ListParts()
+	if err != nil {
+		t.Fatalf("cannot list src parts: %s", err)
+	}
+	dst := &fsremote.FS{
+		Dir: t.TempDir(),
+	}
+	b := &Backup{
+		Concurrency: 2,
+		Src:         src,
+		Dst:         dst,
+	}
+	if err := b.Run(); err != nil {
+		t.Fatalf("cannot make backup: %s", err)
+	}
+	checkTestBackupChecksums(t, dst, srcParts)
+
+	v := &Verify{
+		Concurrency: 2,
+		Src:         dst,
+	}
+	if err := v.Run(context.Background()); err != nil {
+		t.Fatalf("cannot verify backup: %s", err)
+	}
+}
+
+func TestBackupChecksumsAfterFailedBackup(t *testing.T) {
+	src := newTestSnapshot(t)
+	srcParts, err := src.ListParts()
+	if err != nil {
+		t.Fatalf("cannot list src parts: %s", err)
+	}
+
+	// Simulate the previous unsuccessful backup, which uploaded some parts without storing their checksums.
+	dst := &fsremote.FS{
+		Dir: t.TempDir(),
+	}
+	for _, p := range srcParts[:2] {
+		data := testSnapshotFiles[p.Path]
+		if err := dst.UploadPart(p, bytes.NewBufferString(data)); err != nil {
+			t.Fatalf("cannot upload %s: %s", &p, err)
+		}
+	}
+
+	b := &Backup{
+		Concurrency: 2,
+		Src:         src,
+		Dst:         dst,
+	}
+	if err := b.Run(); err != nil {
+		t.Fatalf("cannot make backup: %s", err)
+	}
+	checkTestBackupChecksums(t, dst, srcParts)
+
+	v := &Verify{
+		Concurrency: 2,
+		Src:         dst,
+	}
+	if err := v.Run(context.Background()); err != nil {
+		t.Fatalf("cannot verify backup: %s", err)
+	}
+}
+
+func TestBackupEncryptedWithoutChecksums(t *testing.T) {
+	src := newTestSnapshot(t)
+	dst := &fsencrypted.FS{
+		Remote: &fsremote.FS{
+			Dir: t.TempDir(),
+		},
+		Key: bytes.Repeat([]byte{1}, fsencrypted.KeySize),
+	}
+	if err := dst.Init(); err != nil {
+		t.Fatalf("cannot initialize dst: %s", err)
+	}
+
+	// Plaintext checksums left by the previous backup must be removed.
+	if err := dst.CreateFile(backupnames.BackupChecksumsFilename, []byte("{}")); err != nil {
+		t.Fatalf("cannot create checksums file: %s", err)
+	}
+
+	b := &Backup{
+		Concurrency: 2,
+		Src:         src,
+		Dst:         dst,
+	}
+	if err := b.Run(); err != nil {
+		t.Fatalf("cannot make backup: %s", err)
+	}
+	ok, err := dst.HasFile(backupnames.BackupChecksumsFilename)
+	if err != nil {
+		t.Fatalf("cannot check for checksums file: %s", err)
+	}
+	if ok {
+		t.Fatalf("encrypted backup mustn't contain %s file", backupnames.BackupChecksumsFilename)
+	}
+
+	v := &Verify{
+		Concurrency: 2,
+		Src:         dst,
+	}
+	if err := v.Run(context.Background()); err != nil {
+		t.Fatalf("cannot verify backup: %s", err)
+	}
+}
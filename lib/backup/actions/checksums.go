@@ -0,0 +1,91 @@
+package actions
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/backupnames"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/common"
+)
+
+// partChecksums contains xxhash64 checksums for the original contents of backed up parts.
+//
+// The checksums are stored in backupnames.BackupChecksumsFilename file at the backup
+// and they are used for verifying the backup integrity.
+type partChecksums struct {
+	mu sync.Mutex
+	m  map[string]uint64
+}
+
+func newPartChecksums() *partChecksums {
+	return &partChecksums{
+		m: make(map[string]uint64),
+	}
+}
+
+// readPartChecksums reads part checksums from fs.
+//
+// Empty checksums are returned if fs doesn't contain checksums. This is the case for backups made by older vmbackup versions.
+func readPartChecksums(fs common.RemoteFS) (*partChecksums, error) {
+	pcs := newPartChecksums()
+	ok, err := fs.HasFile(backupnames.BackupChecksumsFilename)
+	if err != nil {
+		return nil, fmt.Errorf("cannot check for %s file at %s: %w", backupnames.BackupChecksumsFilename, fs, err)
+	}
+	if !ok {
+		return pcs, nil
+	}
+	data, err := fs.ReadFile(backupnames.BackupChecksumsFilename)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %s file at %s: %w", backupnames.BackupChecksumsFilename, fs, err)
+	}
+	if err := json.Unmarshal(data, &pcs.m); err != nil {
+		return nil, fmt.Errorf("cannot parse %s file at %s: %w", backupnames.BackupChecksumsFilename, fs, err)
+	}
+	return pcs, nil
+}
+
+// store stores checksums for the given parts to fs.
+func (pcs *partChecksums) store(fs common.RemoteFS, parts []common.Part) error {
+	m := make(map[string]uint64, len(parts))
+	for _, p := range parts {
+		if h, ok := pcs.get(p); ok {
+			m[partChecksumKey(p)] = h
+		}
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("cannot marshal part checksums: %w", err)
+	}
+	if err := fs.CreateFile(backupnames.BackupChecksumsFilename, data); err != nil {
+		return fmt.Errorf("cannot create %s file at %s: %w", backupnames.BackupChecksumsFilename, fs, err)
+	}
+	return nil
+}
+
+func (pcs *partChecksums) get(p common.Part) (uint64, bool) {
+	pcs.mu.Lock()
+	h, ok := pcs.m[partChecksumKey(p)]
+	pcs.mu.Unlock()
+	return h, ok
+}
+
+func (pcs *partChecksums) set(p common.Part, h uint64) {
+	pcs.mu.Lock()
+	pcs.m[partChecksumKey(p)] = h
+	pcs.mu.Unlock()
+}
+
+// copyFrom copies checksums for the given parts from src to pcs.
+func (pcs *partChecksums) copyFrom(src *partChecksums, parts []common.Part) {
+	for _, p := range parts {
+		if h, ok := src.get(p); ok {
+			pcs.set(p, h)
+		}
+	}
+}
+
+func partChecksumKey(p common.Part) string {
+	return fmt.Sprintf("%s/%016X_%016X_%016X", p.Path, p.FileSize, p.Offset, p.Size)
+}
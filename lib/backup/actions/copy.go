@@ -6,6 +6,7 @@ import (
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/backupnames"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/common"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/fsencrypted"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
 )
 
@@ -32,6 +33,12 @@ func (b *RemoteBackupCopy) Run() error {
 	src := b.Src
 	dst := b.Dst
 
+	if err := fsencrypted.CheckBackup(src); err != nil {
+		return err
+	}
+	if err := fsencrypted.PrepareBackup(dst); err != nil {
+		return err
+	}
 	if err := dst.DeleteFile(backupnames.BackupCompleteFilename); err != nil {
 		return fmt.Errorf("cannot delete `backup complete` file at %s: %w", dst, err)
 	}
@@ -41,6 +48,9 @@ func (b *RemoteBackupCopy) Run() error {
 	if err := copyMetadata(src, dst); err != nil {
 		return fmt.Errorf("cannot store backup metadata: %w", err)
 	}
+	if err := copyChecksums(src, dst); err != nil {
+		return fmt.Errorf("cannot store backup checksums: %w", err)
+	}
 	if err := dst.CreateFile(backupnames.BackupCompleteFilename, nil); err != nil {
 		return fmt.Errorf("cannot create `backup complete` file at %s: %w", dst, err)
 	}
@@ -59,6 +69,25 @@ func copyMetadata(src common.RemoteFS, dst common.RemoteFS) error {
 	return nil
 }
 
+func copyChecksums(src common.RemoteFS, dst common.RemoteFS) error {
+	ok, err := src.HasFile(backupnames.BackupChecksumsFilename)
+	if err != nil {
+		return fmt.Errorf("cannot check for checksums at %s: %w", src, err)
+	}
+	if !ok {
+		// The backup has been made by older vmbackup version without checksums.
+		return dst.DeleteFile(backupnames.BackupChecksumsFilename)
+	}
+	data, err := src.ReadFile(backupnames.BackupChecksumsFilename)
+	if err != nil {
+		return fmt.Errorf("cannot read checksums from %s: %w", src, err)
+	}
+	if err := dst.CreateFile(backupnames.BackupChecksumsFilename, data); err != nil {
+		return fmt.Errorf("cannot create checksums at %s: %w", dst, err)
+	}
+	return nil
+}
+
 func runCopy(src common.OriginFS, dst common.RemoteFS, concurrency int) error {
 	startTime := time.Now()
 
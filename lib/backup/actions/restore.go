@@ -13,6 +13,7 @@ import (
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/backupnames"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/common"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/fsencrypted"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/fslocal"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
@@ -58,14 +59,13 @@ func (r *Restore) Run(ctx context.Context) error {
 	dst := r.Dst
 
 	if !r.SkipBackupCompleteCheck {
-		ok, err := src.HasFile(backupnames.BackupCompleteFilename)
-		if err != nil {
+		if err := checkBackupComplete(src); err != nil {
 			return err
 		}
-		if !ok {
-			return fmt.Errorf("cannot find %s file in %s; this means either incomplete backup or old backup; "+
-				"pass -skipBackupCompleteCheck command-line flag if you still need restoring from this backup", backupnames.BackupCompleteFilename, src)
-		}
+	}
+
+	if err := fsencrypted.CheckBackup(src); err != nil {
+		return err
 	}
 
 	logger.Infof("starting restore from %s to %s", src, dst)
@@ -83,33 +83,8 @@ func (r *Restore) Run(ctx context.Context) error {
 
 	backupSize := getPartsSize(srcParts)
 
-	// Validate srcParts. They must cover the whole files.
-	common.SortParts(srcParts)
-	offset := uint64(0)
-	var pOld common.Part
-	var path string
-	for _, p := range srcParts {
-		if p.Path != path {
-			if offset != pOld.FileSize {
-				return fmt.Errorf("invalid size for %q; got %d; want %d", path, offset, pOld.FileSize)
-			}
-			pOld = p
-			path = p.Path
-			offset = 0
-		}
-		if p.Offset < offset {
-			return fmt.Errorf("there is an overlap in %d bytes between %s and %s", offset-p.Offset, &pOld, &p)
-		}
-		if p.Offset > offset {
-			if offset == 0 {
-				return fmt.Errorf("there is a gap in %d bytes from file start to %s", p.Offset, &p)
-			}
-			return fmt.Errorf("there is a gap in %d bytes between %s and %s", p.Offset-offset, &pOld, &p)
-		}
-		if p.Size != p.ActualSize {
-			return fmt.Errorf("invalid size for %s; got %d; want %d", &p, p.ActualSize, p.Size)
-		}
-		offset += p.Size
+	if err := validateParts(srcParts); err != nil {
+		return err
 	}
 
 	partsToDelete := common.PartsDifference(dstParts, srcParts)
@@ -196,6 +171,56 @@ func (r *Restore) Run(ctx context.Context) error {
 	return nil
 }
 
+// checkBackupComplete returns an error if src doesn't contain `backup complete` file.
+func checkBackupComplete(src common.RemoteFS) error {
+	ok, err := src.HasFile(backupnames.BackupCompleteFilename)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("cannot find %s file in %s; this means either incomplete backup or old backup; "+
+			"pass -skipBackupCompleteCheck command-line flag if you still need restoring from this backup", backupnames.BackupCompleteFilename, src)
+	}
+	return nil
+}
+
+// validateParts verifies that parts cover the whole files and have the expected sizes.
+//
+// parts are sorted by the function.
+func validateParts(parts []common.Part) error {
+	common.SortParts(parts)
+	offset := uint64(0)
+	var pOld common.Part
+	var path string
+	for _, p := range parts {
+		if p.Path != path {
+			if offset != pOld.FileSize {
+				return fmt.Errorf("invalid size for %q; got %d; want %d", path, offset, pOld.FileSize)
+			}
+			pOld = p
+			path = p.Path
+			offset = 0
+		}
+		if p.Offset < offset {
+			return fmt.Errorf("there is an overlap in %d bytes between %s and %s", offset-p.Offset, &pOld, &p)
+		}
+		if p.Offset > offset {
+			if offset == 0 {
+				return fmt.Errorf("there is a gap in %d bytes from file start to %s", p.Offset, &p)
+			}
+			return fmt.Errorf("there is a gap in %d bytes between %s and %s", p.Offset-offset, &pOld, &p)
+		}
+		if p.Size != p.ActualSize {
+			return fmt.Errorf("invalid size for %s; got %d; want %d", &p, p.ActualSize, p.Size)
+		}
+		offset += p.Size
+	}
+	if offset != pOld.FileSize {
+		return fmt.Errorf("invalid size for %q; got %d; want %d", path, offset, pOld.FileSize)
+	}
+	return nil
+}
+
 type statWriter struct {
 	w            io.Writer
 	bytesWritten *atomic.Uint64
@@ -0,0 +1,109 @@
+package actions
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/common"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/fslocal"
+)
+
+func TestValidatePartsSuccess(t *testing.T) {
+	f := func(parts []common.Part) {
+		t.Helper()
+
+		if err := validateParts(parts); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	f(nil)
+	f([]common.Part{
+		{Path: "foo", FileSize: 0, Offset: 0, Size: 0, ActualSize: 0},
+	})
+	f([]common.Part{
+		{Path: "foo", FileSize: 20, Offset: 10, Size: 10, ActualSize: 10},
+		{Path: "bar", FileSize: 5, Offset: 0, Size: 5, ActualSize: 5},
+		{Path: "foo", FileSize: 20, Offset: 0, Size: 10, ActualSize: 10},
+	})
+}
+
+func TestValidatePartsFailure(t *testing.T) {
+	f := func(parts []common.Part, errExpected string) {
+		t.Helper()
+
+		err := validateParts(parts)
+		if err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+		if !strings.Contains(err.Error(), errExpected) {
+			t.Fatalf("unexpected error; got %q; want it to contain %q", err, errExpected)
+		}
+	}
+
+	// overlap
+	f([]common.Part{
+		{Path: "foo", FileSize: 20, Offset: 0, Size: 10, ActualSize: 10},
+		{Path: "foo", FileSize: 20, Offset: 5, Size: 15, ActualSize: 15},
+	}, "there is an overlap in 5 bytes")
+
+	// gap at the file start
+	f([]common.Part{
+		{Path: "foo", FileSize: 20, Offset: 10, Size: 10, ActualSize: 10},
+	}, "there is a gap in 10 bytes from file start")
+
+	// gap between parts
+	f([]common.Part{
+		{Path: "foo", FileSize: 30, Offset: 0, Size: 10, ActualSize: 10},
+		{Path: "foo", FileSize: 30, Offset: 20, Size: 10, ActualSize: 10},
+	}, "there is a gap in 10 bytes between")
+
+	// unexpected actual size
+	f([]common.Part{
+		{Path: "foo", FileSize: 10, Offset: 0, Size: 10, ActualSize: 7},
+	}, "invalid size for part")
+
+	// missing trailing part for the file followed by another file
+	f([]common.Part{
+		{Path: "foo", FileSize: 20, Offset: 0, Size: 10, ActualSize: 10},
+		{Path: "goo", FileSize: 5, Offset: 0, Size: 5, ActualSize: 5},
+	}, `invalid size for "foo"; got 10; want 20`)
+
+	// missing trailing part for the last file
+	f([]common.Part{
+		{Path: "bar", FileSize: 5, Offset: 0, Size: 5, ActualSize: 5},
+		{Path: "foo", FileSize: 20, Offset: 0, Size: 10, ActualSize: 10},
+	}, `invalid size for "foo"; got 10; want 20`)
+}
+
+func TestRestoreMissingTrailingPart(t *testing.T) {
+	dir := t.TempDir()
+	src, parts := newTestBackup(t, dir)
+	path := getTestRemotePath(dir, parts[2])
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("cannot remove %q: %s", path, err)
+	}
+
+	dstDir := filepath.Join(t.TempDir(), "storage")
+	r := &Restore{
+		Concurrency: 2,
+		Src:         src,
+		Dst: &fslocal.FS{
+			Dir: dstDir,
+		},
+	}
+	err := r.Run(context.Background())
+	if err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+	errExpected := `invalid size for "data/foo"; got 20; want 30`
+	if !strings.Contains(err.Error(), errExpected) {
+		t.Fatalf("unexpected error; got %q; want it to contain %q", err, errExpected)
+	}
+	if _, err := os.Stat(filepath.Join(dstDir, "data", "foo")); !os.IsNotExist(err) {
+		t.Fatalf("data/foo mustn't be restored from incomplete backup; stat error: %v", err)
+	}
+}
@@ -2,8 +2,11 @@ package actions
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/hex"
 	"flag"
 	"fmt"
+	"os"
 	"path/filepath"
 	"strings"
 	"sync"
@@ -11,6 +14,7 @@ import (
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/azremote"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/common"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/fsencrypted"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/fsremote"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/gcsremote"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/s3remote"
@@ -33,6 +37,11 @@ var (
 		"See https://docs.aws.amazon.com/AmazonS3/latest/userguide/storage-class-intro.html")
 	s3TLSInsecureSkipVerify = flag.Bool("s3TLSInsecureSkipVerify", false, "Whether to skip TLS verification when connecting to the S3 endpoint.")
 	s3Tags                  = flag.String("s3ObjectTags", "", `S3 tags to be set for uploaded objects. Must be set in JSON format: {"param1":"value1",...,"paramN":"valueN"}.`)
+
+	encryptionKeyFile = flag.String("encryptionKeyFile", "", "Optional path to file with 32-byte key for client-side encryption of backup data. "+
+		"The key must be hex- or base64-encoded. See https://docs.victoriametrics.com/victoriametrics/vmbackup/#encryption")
+	encryptionKeyEnv = flag.String("encryptionKeyEnv", "", "Optional name of environment variable with 32-byte key for client-side encryption of backup data. "+
+		"The key must be hex- or base64-encoded. See https://docs.victoriametrics.com/victoriametrics/vmbackup/#encryption")
 )
 
 func runParallel(concurrency int, parts []common.Part, f func(p common.Part) error, progress func(elapsed time.Duration)) error {
@@ -189,7 +198,79 @@ func getPartsSize(parts []common.Part) uint64 {
 }
 
 // NewRemoteFS returns new remote fs from the given path.
+//
+// The returned fs encrypts and decrypts backup data if -encryptionKeyFile or -encryptionKeyEnv is set.
 func NewRemoteFS(ctx context.Context, path string) (common.RemoteFS, error) {
+	key, err := getEncryptionKey()
+	if err != nil {
+		return nil, err
+	}
+	fs, err := newRemoteFS(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return fs, nil
+	}
+	efs := &fsencrypted.FS{
+		Remote: fs,
+		Key:    key,
+	}
+	if err := efs.Init(); err != nil {
+		fs.MustStop()
+		return nil, fmt.Errorf("cannot initialize encryption: %w", err)
+	}
+	return efs, nil
+}
+
+// getEncryptionKey returns the encryption key from -encryptionKeyFile or -encryptionKeyEnv.
+//
+// nil is returned if encryption is disabled.
+func getEncryptionKey() ([]byte, error) {
+	var data string
+	switch {
+	case *encryptionKeyFile != "" && *encryptionKeyEnv != "":
+		return nil, fmt.Errorf("-encryptionKeyFile and -encryptionKeyEnv cannot be set simultaneously")
+	case *encryptionKeyFile != "":
+		b, err := os.ReadFile(*encryptionKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read -encryptionKeyFile: %w", err)
+		}
+		data = string(b)
+	case *encryptionKeyEnv != "":
+		v, ok := os.LookupEnv(*encryptionKeyEnv)
+		if !ok {
+			return nil, fmt.Errorf("missing %q environment variable set via -encryptionKeyEnv", *encryptionKeyEnv)
+		}
+		data = v
+	default:
+		return nil, nil
+	}
+	key, err := parseEncryptionKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption key: %w", err)
+	}
+	return key, nil
+}
+
+func parseEncryptionKey(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	if len(s) == 2*fsencrypted.KeySize {
+		if key, err := hex.DecodeString(s); err == nil {
+			return key, nil
+		}
+	}
+	key, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("the key must be hex- or base64-encoded")
+	}
+	if len(key) != fsencrypted.KeySize {
+		return nil, fmt.Errorf("unexpected key size; got %d bytes; want %d bytes", len(key), fsencrypted.KeySize)
+	}
+	return key, nil
+}
+
+func newRemoteFS(ctx context.Context, path string) (common.RemoteFS, error) {
 	m, err := flagutil.ParseJSONMap(*objectMetadata)
 	if err != nil {
 		return nil, fmt.Errorf("cannot parse s3 objectMetadata %q: %w", *objectMetadata, err)
@@ -0,0 +1,186 @@
+package actions
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/common"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/fsencrypted"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/mergeset"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+)
+
+// Verify verifies the integrity of the backup without restoring it.
+type Verify struct {
+	// Concurrency is the number of concurrent workers to run during verification.
+	Concurrency int
+
+	// Src is the backup to verify.
+	Src common.RemoteFS
+
+	// SkipBackupCompleteCheck may be set in order to skip for `backup complete` file in Src.
+	SkipBackupCompleteCheck bool
+
+	// CheckPartHeaders enables decoding of metadata.json and metaindex.bin files for every part in the backup.
+	CheckPartHeaders bool
+}
+
+// Run runs v with the provided settings.
+//
+// It downloads all the parts from v.Src and verifies their sizes and checksums.
+// An error is returned if the backup is incomplete or corrupted.
+func (v *Verify) Run(ctx context.Context) error {
+	startTime := time.Now()
+	src := v.Src
+
+	if err := fsencrypted.CheckBackup(src); err != nil {
+		return err
+	}
+	if !v.SkipBackupCompleteCheck {
+		if err := checkBackupComplete(src); err != nil {
+			return err
+		}
+	}
+
+	logger.Infof("starting verification of the backup at %s", src)
+
+	srcParts, err := src.ListParts()
+	if err != nil {
+		return fmt.Errorf("cannot list src parts: %w", err)
+	}
+	if err := validateParts(srcParts); err != nil {
+		return err
+	}
+	logger.Infof("obtained %d valid parts from %s", len(srcParts), src)
+
+	checksums := newPartChecksums()
+	if _, isEncrypted := src.(*fsencrypted.FS); isEncrypted {
+		// Part checksums aren't stored for encrypted backups, since every chunk of encrypted parts is authenticated during decryption.
+		logger.Infof("the backup at %s is encrypted; verifying the parts via authenticated decryption", src)
+	} else {
+		checksums, err = readPartChecksums(src)
+		if err != nil {
+			return err
+		}
+		if len(checksums.m) == 0 {
+			logger.Warnf("the backup at %s has no part checksums, since it has been made by older vmbackup version; "+
+				"verifying only that the parts can be read", src)
+		}
+	}
+	hasChecksums := len(checksums.m) > 0
+
+	var problemsLock sync.Mutex
+	var problems []string
+	addProblem := func(format string, args ...any) {
+		s := fmt.Sprintf(format, args...)
+		logger.Errorf("%s", s)
+		problemsLock.Lock()
+		problems = append(problems, s)
+		problemsLock.Unlock()
+	}
+
+	perPath := make(map[string][]common.Part)
+	for _, p := range srcParts {
+		perPath[p.Path] = append(perPath[p.Path], p)
+	}
+	verifySize := getPartsSize(srcParts)
+	var bytesVerified atomic.Uint64
+	err = runParallelPerPath(ctx, v.Concurrency, perPath, func(parts []common.Part) error {
+		common.SortParts(parts)
+		filePath := parts[0].Path
+		var fileData *bytes.Buffer
+		if v.CheckPartHeaders && isPartHeaderFile(filePath) {
+			fileData = &bytes.Buffer{}
+		}
+		for _, p := range parts {
+			h := xxhash.New()
+			w := io.Writer(h)
+			if fileData != nil {
+				w = io.MultiWriter(h, fileData)
+			}
+			sw := &statWriter{
+				w:            w,
+				bytesWritten: &bytesVerified,
+			}
+			if err := src.DownloadPart(p, sw); err != nil {
+				addProblem("cannot download %s from %s: %s", &p, src, err)
+				return nil
+			}
+			if !hasChecksums {
+				continue
+			}
+			checksum, ok := checksums.get(p)
+			if !ok {
+				addProblem("missing checksum for %s at %s", &p, src)
+				return nil
+			}
+			if checksumGot := h.Sum64(); checksumGot != checksum {
+				addProblem("checksum mismatch for %s at %s; got %016X; want %016X", &p, src, checksumGot, checksum)
+				return nil
+			}
+		}
+		if fileData != nil {
+			if err := verifyPartHeaderFile(filePath, fileData.Bytes()); err != nil {
+				addProblem("cannot decode %q at %s: %s", filePath, src, err)
+			}
+		}
+		return nil
+	}, func(elapsed time.Duration) {
+		n := bytesVerified.Load()
+		prc := 100 * float64(n) / float64(verifySize)
+		logger.Infof("verified %d out of %d bytes (%.2f%%) at %s in %s", n, verifySize, prc, src, elapsed)
+	})
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("verification has been interrupted: %w", err)
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("found %d problems in the backup at %s; see the log above for details", len(problems), src)
+	}
+
+	logger.Infof("successfully verified %d bytes in %d parts at %s in %.3f seconds",
+		verifySize, len(srcParts), src, time.Since(startTime).Seconds())
+	return nil
+}
+
+// isPartHeaderFile returns true if filePath points to metadata.json or metaindex.bin file for lib/storage or lib/mergeset part.
+func isPartHeaderFile(filePath string) bool {
+	if !strings.HasPrefix(filePath, "data/") && !strings.HasPrefix(filePath, "indexdb/") {
+		return false
+	}
+	name := path.Base(filePath)
+	return name == "metadata.json" || name == "metaindex.bin"
+}
+
+// verifyPartHeaderFile verifies that data for the file at filePath can be decoded.
+func verifyPartHeaderFile(filePath string, data []byte) error {
+	isIndexDB := strings.HasPrefix(filePath, "indexdb/")
+	switch path.Base(filePath) {
+	case "metadata.json":
+		if isIndexDB {
+			return mergeset.ValidatePartMetadata(data)
+		}
+		return storage.ValidatePartMetadata(data)
+	case "metaindex.bin":
+		if isIndexDB {
+			return mergeset.ValidateMetaindex(data)
+		}
+		return storage.ValidateMetaindex(data)
+	default:
+		logger.Panicf("BUG: unexpected file %q", filePath)
+		return nil
+	}
+}
@@ -0,0 +1,142 @@
+package actions
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cespare/xxhash/v2"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/backupnames"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/common"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/fsremote"
+)
+
+// newTestBackup creates a complete backup with part checksums at the given dir.
+//
+// The backup contains data/foo file split into 3 parts and data/bar file with a single part.
+func newTestBackup(t *testing.T, dir string) (*fsremote.FS, []common.Part) {
+	t.Helper()
+	fs := &fsremote.FS{
+		Dir: dir,
+	}
+	parts := []common.Part{
+		{Path: "data/foo", FileSize: 30, Offset: 0, Size: 10},
+		{Path: "data/foo", FileSize: 30, Offset: 10, Size: 10},
+		{Path: "data/foo", FileSize: 30, Offset: 20, Size: 10},
+		{Path: "data/bar", FileSize: 5, Offset: 0, Size: 5},
+	}
+	checksums := newPartChecksums()
+	for i, p := range parts {
+		data := bytes.Repeat([]byte{byte('a' + i)}, int(p.Size))
+		if err := fs.UploadPart(p, bytes.NewReader(data)); err != nil {
+			t.Fatalf("cannot upload %s: %s", &p, err)
+		}
+		checksums.set(p, xxhash.Sum64(data))
+	}
+	if err := checksums.store(fs, parts); err != nil {
+		t.Fatalf("cannot store checksums: %s", err)
+	}
+	if err := fs.CreateFile(backupnames.BackupCompleteFilename, nil); err != nil {
+		t.Fatalf("cannot create `backup complete` file: %s", err)
+	}
+	return fs, parts
+}
+
+func getTestRemotePath(dir string, p common.Part) string {
+	return filepath.Join(dir, filepath.FromSlash(p.RemotePath("")))
+}
+
+func TestVerifySuccess(t *testing.T) {
+	fs, _ := newTestBackup(t, t.TempDir())
+	v := &Verify{
+		Concurrency:      2,
+		Src:              fs,
+		CheckPartHeaders: true,
+	}
+	if err := v.Run(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestVerifyFailure(t *testing.T) {
+	f := func(corrupt func(dir string, parts []common.Part), errExpected string) {
+		t.Helper()
+
+		dir := t.TempDir()
+		fs, parts := newTestBackup(t, dir)
+		corrupt(dir, parts)
+		v := &Verify{
+			Concurrency: 2,
+			Src:         fs,
+		}
+		err := v.Run(context.Background())
+		if err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+		if !strings.Contains(err.Error(), errExpected) {
+			t.Fatalf("unexpected error; got %q; want it to contain %q", err, errExpected)
+		}
+	}
+
+	// checksum mismatch
+	f(func(dir string, parts []common.Part) {
+		path := getTestRemotePath(dir, parts[1])
+		if err := os.WriteFile(path, bytes.Repeat([]byte{'x'}, int(parts[1].Size)), 0600); err != nil {
+			t.Fatalf("cannot corrupt %q: %s", path, err)
+		}
+	}, "found 1 problems")
+
+	// missing checksum
+	f(func(dir string, parts []common.Part) {
+		checksums, err := readPartChecksums(&fsremote.FS{Dir: dir})
+		if err != nil {
+			t.Fatalf("cannot read checksums: %s", err)
+		}
+		if err := checksums.store(&fsremote.FS{Dir: dir}, parts[:2]); err != nil {
+			t.Fatalf("cannot store checksums: %s", err)
+		}
+	}, "found 2 problems")
+
+	// missing part in the middle of the file
+	f(func(dir string, parts []common.Part) {
+		path := getTestRemotePath(dir, parts[1])
+		if err := os.Remove(path); err != nil {
+			t.Fatalf("cannot remove %q: %s", path, err)
+		}
+	}, "there is a gap in 10 bytes")
+
+	// missing part at the start of the file
+	f(func(dir string, parts []common.Part) {
+		path := getTestRemotePath(dir, parts[0])
+		if err := os.Remove(path); err != nil {
+			t.Fatalf("cannot remove %q: %s", path, err)
+		}
+	}, "there is a gap in 10 bytes from file start")
+
+	// missing part at the end of the file
+	f(func(dir string, parts []common.Part) {
+		path := getTestRemotePath(dir, parts[2])
+		if err := os.Remove(path); err != nil {
+			t.Fatalf("cannot remove %q: %s", path, err)
+		}
+	}, `invalid size for "data/foo"; got 20; want 30`)
+
+	// truncated part
+	f(func(dir string, parts []common.Part) {
+		path := getTestRemotePath(dir, parts[3])
+		if err := os.Truncate(path, 3); err != nil {
+			t.Fatalf("cannot truncate %q: %s", path, err)
+		}
+	}, "invalid size for part{path: \"data/bar\"")
+
+	// incomplete backup
+	f(func(dir string, _ []common.Part) {
+		if err := os.Remove(filepath.Join(dir, backupnames.BackupCompleteFilename)); err != nil {
+			t.Fatalf("cannot remove `backup complete` file: %s", err)
+		}
+	}, "cannot find "+backupnames.BackupCompleteFilename)
+}
@@ -21,4 +21,12 @@ const (
 
 	// BackupMetadataFilename is a filename, which contains metadata for the backup.
 	BackupMetadataFilename = "backup_metadata.ignore"
+
+	// BackupEncryptionFilename is a filename, which is created in the destination fs for encrypted backups.
+	// It contains encryption parameters and the id of the encryption key used for the backup.
+	BackupEncryptionFilename = "backup_encryption.ignore"
+
+	// BackupChecksumsFilename is a filename, which contains checksums for the backed up parts.
+	// It is used for verifying the backup integrity.
+	BackupChecksumsFilename = "backup_checksums.ignore"
 )
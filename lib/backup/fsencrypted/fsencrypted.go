@@ -0,0 +1,477 @@
+package fsencrypted
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/backupnames"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/common"
+)
+
+// KeySize is the size of the encryption key in bytes.
+const KeySize = 32
+
+// chunkSize is the size of plaintext chunks, which are encrypted independently.
+const chunkSize = 64 * 1024
+
+const (
+	headerMagic   = "VMBE"
+	formatVersion = 1
+	saltSize      = 32
+	headerSize    = len(headerMagic) + 1 + saltSize
+	tagSize       = 16
+)
+
+// FS is a RemoteFS, which encrypts parts uploaded to Remote and decrypts parts downloaded from Remote.
+//
+// Every part is encrypted with AES-256-GCM in chunks of 64KiB. Every part is encrypted with its own key,
+// which is derived from Key and random per-part salt stored in the part header.
+// Chunks are authenticated together with the part path and offset, so they cannot be reordered, truncated
+// or moved to another part without detection.
+//
+// Files such as `backup_complete.ignore` and `backup_metadata.ignore` aren't encrypted.
+type FS struct {
+	// Remote is the underlying fs where encrypted parts are stored.
+	Remote common.RemoteFS
+
+	// Key is the encryption key. It must contain KeySize bytes.
+	Key []byte
+
+	keyID string
+}
+
+// Init initializes fs.
+//
+// Init must be called before using fs.
+func (fs *FS) Init() error {
+	if len(fs.Key) != KeySize {
+		return fmt.Errorf("unexpected encryption key size; got %d bytes; want %d bytes", len(fs.Key), KeySize)
+	}
+	keyID, err := hkdf.Key(sha256.New, fs.Key, nil, "vmbackup key id", 16)
+	if err != nil {
+		return fmt.Errorf("cannot derive encryption key id: %w", err)
+	}
+	fs.keyID = hex.EncodeToString(keyID)
+	return nil
+}
+
+// MustStop stops fs.
+func (fs *FS) MustStop() {
+	fs.Remote.MustStop()
+}
+
+// String returns human-readable representation of fs.
+func (fs *FS) String() string {
+	return fs.Remote.String()
+}
+
+// ListParts returns all the parts from fs.
+//
+// Part sizes are converted from the sizes of encrypted parts at fs.Remote to the sizes of the original parts.
+func (fs *FS) ListParts() ([]common.Part, error) {
+	parts, err := fs.Remote.ListParts()
+	if err != nil {
+		return nil, err
+	}
+	for i := range parts {
+		p := &parts[i]
+		if n, ok := decryptedSize(p.Size); ok {
+			p.Size = n
+		}
+		if n, ok := decryptedSize(p.ActualSize); ok {
+			p.ActualSize = n
+		}
+	}
+	return parts, nil
+}
+
+// DeletePart deletes part p from fs.
+func (fs *FS) DeletePart(p common.Part) error {
+	return fs.Remote.DeletePart(encryptedPart(p))
+}
+
+// RemoveEmptyDirs recursively removes empty dirs in fs.
+func (fs *FS) RemoveEmptyDirs() error {
+	return fs.Remote.RemoveEmptyDirs()
+}
+
+// CopyPart copies part p from srcFS to fs.
+//
+// srcFS must be *FS with the same encryption key, since encrypted data is copied as is.
+func (fs *FS) CopyPart(srcFS common.OriginFS, p common.Part) error {
+	src, ok := srcFS.(*FS)
+	if !ok {
+		return fmt.Errorf("cannot perform server-side copying from %s to %s: both of them must be encrypted", srcFS, fs)
+	}
+	if src.keyID != fs.keyID {
+		return fmt.Errorf("cannot perform server-side copying from %s to %s: they are encrypted with distinct keys", srcFS, fs)
+	}
+	return fs.Remote.CopyPart(src.Remote, encryptedPart(p))
+}
+
+// DownloadPart downloads part p from fs to w.
+func (fs *FS) DownloadPart(p common.Part, w io.Writer) error {
+	dw := &decryptWriter{
+		fs: fs,
+		p:  p,
+		w:  w,
+	}
+	if err := fs.Remote.DownloadPart(encryptedPart(p), dw); err != nil {
+		return err
+	}
+	return dw.finish()
+}
+
+// UploadPart uploads part p from r to fs.
+func (fs *FS) UploadPart(p common.Part, r io.Reader) error {
+	er, err := fs.newEncryptReader(p, r)
+	if err != nil {
+		return err
+	}
+	return fs.Remote.UploadPart(encryptedPart(p), er)
+}
+
+// DeleteFile deletes filePath at fs.
+func (fs *FS) DeleteFile(filePath string) error {
+	return fs.Remote.DeleteFile(filePath)
+}
+
+// CreateFile creates filePath at fs and puts data into it.
+//
+// data isn't encrypted.
+func (fs *FS) CreateFile(filePath string, data []byte) error {
+	return fs.Remote.CreateFile(filePath, data)
+}
+
+// HasFile returns true if filePath exists at fs.
+func (fs *FS) HasFile(filePath string) (bool, error) {
+	return fs.Remote.HasFile(filePath)
+}
+
+// ReadFile returns the contents of filePath at fs.
+func (fs *FS) ReadFile(filePath string) ([]byte, error) {
+	return fs.Remote.ReadFile(filePath)
+}
+
+// encryptionInfo is stored in backupnames.BackupEncryptionFilename file for encrypted backups.
+type encryptionInfo struct {
+	Version   int    `json:"version"`
+	Algorithm string `json:"algorithm"`
+	ChunkSize int    `json:"chunk_size"`
+	KeyID     string `json:"key_id"`
+}
+
+const algorithm = "AES-256-GCM"
+
+// CheckBackup verifies that the backup at fs can be read and updated via fs.
+//
+// An error is returned if fs isn't *FS while the backup is encrypted,
+// if fs is *FS while the backup contains unencrypted parts or if the backup is encrypted with another key.
+func CheckBackup(fs common.RemoteFS) error {
+	efs, isEncrypted := fs.(*FS)
+	ok, err := fs.HasFile(backupnames.BackupEncryptionFilename)
+	if err != nil {
+		return fmt.Errorf("cannot check for %s file at %s: %w", backupnames.BackupEncryptionFilename, fs, err)
+	}
+	if !isEncrypted {
+		if ok {
+			return fmt.Errorf("the backup at %s is encrypted; pass the encryption key via -encryptionKeyFile or -encryptionKeyEnv command-line flag", fs)
+		}
+		return nil
+	}
+	if !ok {
+		parts, err := efs.Remote.ListParts()
+		if err != nil {
+			return fmt.Errorf("cannot list parts at %s: %w", fs, err)
+		}
+		if len(parts) > 0 {
+			return fmt.Errorf("the backup at %s contains %d unencrypted parts; refusing to mix encrypted and unencrypted data in a single backup", fs, len(parts))
+		}
+		return nil
+	}
+	data, err := fs.ReadFile(backupnames.BackupEncryptionFilename)
+	if err != nil {
+		return fmt.Errorf("cannot read %s file at %s: %w", backupnames.BackupEncryptionFilename, fs, err)
+	}
+	var ei encryptionInfo
+	if err := json.Unmarshal(data, &ei); err != nil {
+		return fmt.Errorf("cannot parse %s file at %s: %w", backupnames.BackupEncryptionFilename, fs, err)
+	}
+	if ei.Version != formatVersion || ei.Algorithm != algorithm || ei.ChunkSize != chunkSize {
+		return fmt.Errorf("unsupported encryption at %s: version=%d, algorithm=%q, chunk_size=%d; want version=%d, algorithm=%q, chunk_size=%d",
+			fs, ei.Version, ei.Algorithm, ei.ChunkSize, formatVersion, algorithm, chunkSize)
+	}
+	if ei.KeyID != efs.keyID {
+		return fmt.Errorf("the backup at %s is encrypted with another key; key_id=%s; want key_id=%s", fs, ei.KeyID, efs.keyID)
+	}
+	return nil
+}
+
+// PrepareBackup verifies the backup at fs via CheckBackup and marks it as encrypted if fs is *FS.
+//
+// PrepareBackup must be called before writing parts to fs.
+func PrepareBackup(fs common.RemoteFS) error {
+	if err := CheckBackup(fs); err != nil {
+		return err
+	}
+	efs, ok := fs.(*FS)
+	if !ok {
+		return nil
+	}
+	ei := &encryptionInfo{
+		Version:   formatVersion,
+		Algorithm: algorithm,
+		ChunkSize: chunkSize,
+		KeyID:     efs.keyID,
+	}
+	data, err := json.Marshal(ei)
+	if err != nil {
+		return fmt.Errorf("cannot marshal encryption info: %w", err)
+	}
+	if err := fs.CreateFile(backupnames.BackupEncryptionFilename, data); err != nil {
+		return fmt.Errorf("cannot create %s file at %s: %w", backupnames.BackupEncryptionFilename, fs, err)
+	}
+	return nil
+}
+
+// encryptedPart returns the part stored at the underlying fs for the given part p.
+//
+// The remote path for the encrypted part contains the original FileSize and Offset, while Size is set to the encrypted size.
+func encryptedPart(p common.Part) common.Part {
+	p.Size = encryptedSize(p.Size)
+	p.ActualSize = encryptedSize(p.ActualSize)
+	return p
+}
+
+// encryptedSize returns the size of the encrypted part for the part with the given size.
+func encryptedSize(size uint64) uint64 {
+	chunks := getChunksCount(size)
+	return uint64(headerSize) + size + chunks*tagSize
+}
+
+// decryptedSize returns the size of the original part for the encrypted part with the given size.
+//
+// false is returned if there is no original part with the given encrypted size.
+func decryptedSize(size uint64) (uint64, bool) {
+	if size < uint64(headerSize)+tagSize {
+		return 0, false
+	}
+	n := size - uint64(headerSize)
+	fullChunks := n / (chunkSize + tagSize)
+	tail := n % (chunkSize + tagSize)
+	result := fullChunks * chunkSize
+	if tail > 0 {
+		if tail < tagSize {
+			return 0, false
+		}
+		result += tail - tagSize
+	}
+	if encryptedSize(result) != size {
+		return 0, false
+	}
+	return result, true
+}
+
+// getChunksCount returns the number of chunks for the part with the given size.
+//
+// Empty parts contain a single empty chunk.
+func getChunksCount(size uint64) uint64 {
+	if size == 0 {
+		return 1
+	}
+	return (size + chunkSize - 1) / chunkSize
+}
+
+func (fs *FS) newAEAD(salt []byte) (cipher.AEAD, error) {
+	key, err := hkdf.Key(sha256.New, fs.Key, salt, "vmbackup part key", KeySize)
+	if err != nil {
+		return nil, fmt.Errorf("cannot derive part key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create AES cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// getNonce returns nonce for the chunk with the given index.
+//
+// The last byte of the nonce distinguishes the last chunk from other chunks in order to detect truncated parts.
+func getNonce(dst []byte, chunkIdx uint64, isLast bool) []byte {
+	dst = binary.BigEndian.AppendUint64(dst[:0], chunkIdx)
+	dst = append(dst, 0, 0, 0, 0)
+	if isLast {
+		dst[len(dst)-1] = 1
+	}
+	return dst
+}
+
+// getAdditionalData returns additional authenticated data for the chunks of part p.
+func getAdditionalData(p common.Part) []byte {
+	return fmt.Appendf(nil, "%s/%016X_%016X", p.Path, p.FileSize, p.Offset)
+}
+
+// encryptReader returns encrypted data for the part read from r.
+type encryptReader struct {
+	r      io.Reader
+	p      common.Part
+	aead   cipher.AEAD
+	ad     []byte
+	chunks uint64
+
+	chunkIdx  uint64
+	remaining uint64
+	plaintext []byte
+	nonce     []byte
+
+	buf    []byte
+	bufPos int
+}
+
+func (fs *FS) newEncryptReader(p common.Part, r io.Reader) (*encryptReader, error) {
+	var header [headerSize]byte
+	copy(header[:], headerMagic)
+	header[len(headerMagic)] = formatVersion
+	salt := header[len(headerMagic)+1:]
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("cannot generate salt: %w", err)
+	}
+	aead, err := fs.newAEAD(salt)
+	if err != nil {
+		return nil, err
+	}
+	er := &encryptReader{
+		r:         r,
+		p:         p,
+		aead:      aead,
+		ad:        getAdditionalData(p),
+		chunks:    getChunksCount(p.Size),
+		remaining: p.Size,
+		buf:       append([]byte{}, header[:]...),
+	}
+	return er, nil
+}
+
+// Read implements io.Reader.
+func (er *encryptReader) Read(p []byte) (int, error) {
+	if er.bufPos >= len(er.buf) {
+		if er.chunkIdx >= er.chunks {
+			return 0, io.EOF
+		}
+		if err := er.encryptNextChunk(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, er.buf[er.bufPos:])
+	er.bufPos += n
+	return n, nil
+}
+
+func (er *encryptReader) encryptNextChunk() error {
+	n := min(er.remaining, chunkSize)
+	er.plaintext = append(er.plaintext[:0], make([]byte, n)...)
+	if _, err := io.ReadFull(er.r, er.plaintext); err != nil {
+		return fmt.Errorf("cannot read chunk #%d for %s: %w", er.chunkIdx, &er.p, err)
+	}
+	er.remaining -= n
+	isLast := er.chunkIdx+1 == er.chunks
+	er.nonce = getNonce(er.nonce, er.chunkIdx, isLast)
+	er.buf = er.aead.Seal(er.buf[:0], er.nonce, er.plaintext, er.ad)
+	er.bufPos = 0
+	er.chunkIdx++
+	return nil
+}
+
+// decryptWriter decrypts the encrypted part written to it and writes the decrypted data to w.
+type decryptWriter struct {
+	fs *FS
+	p  common.Part
+	w  io.Writer
+
+	aead     cipher.AEAD
+	ad       []byte
+	chunkIdx uint64
+	nonce    []byte
+
+	buf       []byte
+	plaintext []byte
+}
+
+// Write implements io.Writer.
+func (dw *decryptWriter) Write(p []byte) (int, error) {
+	dw.buf = append(dw.buf, p...)
+	if dw.aead == nil {
+		if len(dw.buf) < headerSize {
+			return len(p), nil
+		}
+		if err := dw.readHeader(); err != nil {
+			return 0, err
+		}
+	}
+	chunks := getChunksCount(dw.p.Size)
+	for dw.chunkIdx < chunks {
+		n := chunkSize + tagSize
+		if dw.chunkIdx+1 == chunks {
+			n = int(dw.p.Size-dw.chunkIdx*chunkSize) + tagSize
+		}
+		if len(dw.buf) < n {
+			break
+		}
+		if err := dw.decryptChunk(dw.buf[:n], dw.chunkIdx+1 == chunks); err != nil {
+			return 0, err
+		}
+		dw.buf = append(dw.buf[:0], dw.buf[n:]...)
+	}
+	if dw.chunkIdx >= chunks && len(dw.buf) > 0 {
+		return 0, fmt.Errorf("unexpected %d bytes after the last chunk for %s", len(dw.buf), &dw.p)
+	}
+	return len(p), nil
+}
+
+func (dw *decryptWriter) readHeader() error {
+	header := dw.buf[:headerSize]
+	if string(header[:len(headerMagic)]) != headerMagic {
+		return fmt.Errorf("missing encryption header for %s; the part isn't encrypted", &dw.p)
+	}
+	if v := header[len(headerMagic)]; v != formatVersion {
+		return fmt.Errorf("unsupported encryption format version for %s; got %d; want %d", &dw.p, v, formatVersion)
+	}
+	aead, err := dw.fs.newAEAD(header[len(headerMagic)+1:])
+	if err != nil {
+		return err
+	}
+	dw.aead = aead
+	dw.ad = getAdditionalData(dw.p)
+	dw.buf = append(dw.buf[:0], dw.buf[headerSize:]...)
+	return nil
+}
+
+func (dw *decryptWriter) decryptChunk(chunk []byte, isLast bool) error {
+	dw.nonce = getNonce(dw.nonce, dw.chunkIdx, isLast)
+	plaintext, err := dw.aead.Open(dw.plaintext[:0], dw.nonce, chunk, dw.ad)
+	if err != nil {
+		return fmt.Errorf("cannot decrypt chunk #%d for %s; the part is corrupted or it is encrypted with another key: %w", dw.chunkIdx, &dw.p, err)
+	}
+	dw.plaintext = plaintext
+	if _, err := dw.w.Write(plaintext); err != nil {
+		return err
+	}
+	dw.chunkIdx++
+	return nil
+}
+
+// finish verifies that the whole part has been decrypted.
+func (dw *decryptWriter) finish() error {
+	if dw.aead == nil || dw.chunkIdx < getChunksCount(dw.p.Size) {
+		return fmt.Errorf("incomplete encrypted data for %s", &dw.p)
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package fsencrypted
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/common"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/fsremote"
+)
+
+func newTestFS(t *testing.T, dir string, key byte) *FS {
+	t.Helper()
+	fs := &FS{
+		Remote: &fsremote.FS{
+			Dir: dir,
+		},
+		Key: bytes.Repeat([]byte{key}, KeySize),
+	}
+	if err := fs.Init(); err != nil {
+		t.Fatalf("cannot initialize fs: %s", err)
+	}
+	return fs
+}
+
+func getRemotePath(dir string, p common.Part) string {
+	pe := encryptedPart(p)
+	return filepath.Join(dir, filepath.FromSlash(pe.RemotePath("")))
+}
+
+func TestEncryptedSize(t *testing.T) {
+	f := func(size uint64) {
+		t.Helper()
+		n := encryptedSize(size)
+		result, ok := decryptedSize(n)
+		if !ok {
+			t.Fatalf("cannot obtain decrypted size for encrypted size %d", n)
+		}
+		if result != size {
+			t.Fatalf("unexpected decrypted size; got %d; want %d", result, size)
+		}
+	}
+
+	f(0)
+	f(1)
+	f(chunkSize - 1)
+	f(chunkSize)
+	f(chunkSize + 1)
+	f(10*chunkSize + 123)
+	f(common.MaxPartSize)
+
+	// invalid encrypted sizes
+	for _, n := range []int{0, headerSize, headerSize + tagSize - 1, headerSize + chunkSize + tagSize + 1} {
+		if _, ok := decryptedSize(uint64(n)); ok {
+			t.Fatalf("expecting invalid encrypted size %d", n)
+		}
+	}
+}
+
+func TestFSUploadDownload(t *testing.T) {
+	dir := t.TempDir()
+	fs := newTestFS(t, dir, 1)
+
+	f := func(size int) {
+		t.Helper()
+		data := make([]byte, size)
+		for i := range data {
+			data[i] = byte(i)
+		}
+		p := common.Part{
+			Path:     "data/small/part",
+			FileSize: uint64(size) + 100,
+			Offset:   100,
+			Size:     uint64(size),
+		}
+		if err := fs.UploadPart(p, bytes.NewReader(data)); err != nil {
+			t.Fatalf("cannot upload part: %s", err)
+		}
+
+		// Verify the stored data is encrypted
+		remotePath := getRemotePath(dir, p)
+		encrypted, err := os.ReadFile(remotePath)
+		if err != nil {
+			t.Fatalf("cannot read encrypted part: %s", err)
+		}
+		if uint64(len(encrypted)) != encryptedSize(p.Size) {
+			t.Fatalf("unexpected encrypted part size; got %d; want %d", len(encrypted), encryptedSize(p.Size))
+		}
+		if size > 16 && bytes.Contains(encrypted, data[:16]) {
+			t.Fatalf("encrypted part contains plaintext data")
+		}
+
+		// Verify ListParts returns the original sizes
+		parts, err := fs.ListParts()
+		if err != nil {
+			t.Fatalf("cannot list parts: %s", err)
+		}
+		if len(parts) != 1 {
+			t.Fatalf("unexpected number of parts; got %d; want 1", len(parts))
+		}
+		pExpected := p
+		pExpected.ActualSize = p.Size
+		if parts[0] != pExpected {
+			t.Fatalf("unexpected part\ngot\n%#v\nwant\n%#v", parts[0], pExpected)
+		}
+
+		var bb bytes.Buffer
+		if err := fs.DownloadPart(p, &bb); err != nil {
+			t.Fatalf("cannot download part: %s", err)
+		}
+		if !bytes.Equal(bb.Bytes(), data) {
+			t.Fatalf("unexpected downloaded data")
+		}
+
+		// Verify the part cannot be decrypted with another key
+		fsOther := newTestFS(t, dir, 2)
+		if err := fsOther.DownloadPart(p, &bb); err == nil {
+			t.Fatalf("expecting non-nil error when decrypting part with another key")
+		}
+
+		// Verify the corrupted part cannot be decrypted
+		encrypted[len(encrypted)-1] ^= 1
+		if err := os.WriteFile(remotePath, encrypted, 0600); err != nil {
+			t.Fatalf("cannot write corrupted part: %s", err)
+		}
+		if err := fs.DownloadPart(p, &bb); err == nil {
+			t.Fatalf("expecting non-nil error when decrypting corrupted part")
+		}
+
+		if err := fs.DeletePart(p); err != nil {
+			t.Fatalf("cannot delete part: %s", err)
+		}
+	}
+
+	f(0)
+	f(1)
+	f(chunkSize - 1)
+	f(chunkSize)
+	f(3*chunkSize + 17)
+}
+
+func TestFSPartMovedToAnotherOffset(t *testing.T) {
+	dir := t.TempDir()
+	fs := newTestFS(t, dir, 1)
+
+	p := common.Part{
+		Path:     "data/part",
+		FileSize: 20,
+		Offset:   0,
+		Size:     10,
+	}
+	if err := fs.UploadPart(p, strings.NewReader("0123456789")); err != nil {
+		t.Fatalf("cannot upload part: %s", err)
+	}
+	pMoved := p
+	pMoved.Offset = 10
+	srcPath := getRemotePath(dir, p)
+	dstPath := getRemotePath(dir, pMoved)
+	if err := os.Rename(srcPath, dstPath); err != nil {
+		t.Fatalf("cannot move part: %s", err)
+	}
+	var bb bytes.Buffer
+	if err := fs.DownloadPart(pMoved, &bb); err == nil {
+		t.Fatalf("expecting non-nil error when decrypting moved part")
+	}
+}
+
+func TestCheckBackup(t *testing.T) {
+	// empty backup
+	dir := t.TempDir()
+	fs := newTestFS(t, dir, 1)
+	if err := CheckBackup(fs); err != nil {
+		t.Fatalf("unexpected error for empty backup: %s", err)
+	}
+	if err := CheckBackup(fs.Remote); err != nil {
+		t.Fatalf("unexpected error for empty backup: %s", err)
+	}
+
+	// encrypted backup
+	if err := PrepareBackup(fs); err != nil {
+		t.Fatalf("cannot prepare backup: %s", err)
+	}
+	if err := CheckBackup(fs); err != nil {
+		t.Fatalf("unexpected error for encrypted backup: %s", err)
+	}
+	if err := CheckBackup(fs.Remote); err == nil {
+		t.Fatalf("expecting non-nil error when accessing encrypted backup without key")
+	}
+	if err := CheckBackup(newTestFS(t, dir, 2)); err == nil {
+		t.Fatalf("expecting non-nil error when accessing encrypted backup with another key")
+	}
+
+	// unencrypted backup
+	dir = t.TempDir()
+	fs = newTestFS(t, dir, 1)
+	if err := fs.Remote.UploadPart(common.Part{Path: "foo", FileSize: 3, Size: 3}, strings.NewReader("foo")); err != nil {
+		t.Fatalf("cannot upload part: %s", err)
+	}
+	if err := PrepareBackup(fs.Remote); err != nil {
+		t.Fatalf("unexpected error for unencrypted backup: %s", err)
+	}
+	if err := PrepareBackup(fs); err == nil {
+		t.Fatalf("expecting non-nil error when adding encrypted data to unencrypted backup")
+	}
+}
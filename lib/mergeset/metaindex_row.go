@@ -1,6 +1,7 @@
 package mergeset
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"sort"
@@ -82,6 +83,14 @@ func (mr *metaindexRow) Unmarshal(src []byte) ([]byte, error) {
 	return src, nil
 }
 
+// ValidateMetaindex verifies that data contains valid contents of metaindex.bin file for the part.
+//
+// This function is used for verifying backups without opening the part.
+func ValidateMetaindex(data []byte) error {
+	_, err := unmarshalMetaindexRows(nil, bytes.NewReader(data))
+	return err
+}
+
 func unmarshalMetaindexRows(dst []metaindexRow, r io.Reader) ([]metaindexRow, error) {
 	// It is ok to read all the metaindex in memory,
 	// since it is quite small.
@@ -92,23 +92,38 @@ func (ph *partHeader) MustReadMetadata(partPath string) {
 	if err := json.Unmarshal(metadata, &phj); err != nil {
 		logger.Panicf("FATAL: cannot parse %q: %s", metadataPath, err)
 	}
-
-	if phj.ItemsCount <= 0 {
-		logger.Panicf("FATAL: part %q cannot contain zero items", partPath)
+	if err := phj.validate(); err != nil {
+		logger.Panicf("FATAL: invalid metadata for part %q: %s", partPath, err)
 	}
 	ph.itemsCount = phj.ItemsCount
+	ph.blocksCount = phj.BlocksCount
+
+	ph.firstItem = append(ph.firstItem[:0], phj.FirstItem...)
+	ph.lastItem = append(ph.lastItem[:0], phj.LastItem...)
+}
+
+// ValidatePartMetadata verifies that data contains valid contents of metadata.json file for the part.
+//
+// This function is used for verifying backups without opening the part.
+func ValidatePartMetadata(data []byte) error {
+	var phj partHeaderJSON
+	if err := json.Unmarshal(data, &phj); err != nil {
+		return fmt.Errorf("cannot parse part metadata: %w", err)
+	}
+	return phj.validate()
+}
 
+func (phj *partHeaderJSON) validate() error {
+	if phj.ItemsCount <= 0 {
+		return fmt.Errorf("part cannot contain zero items")
+	}
 	if phj.BlocksCount <= 0 {
-		logger.Panicf("FATAL: part %q cannot contain zero blocks", partPath)
+		return fmt.Errorf("part cannot contain zero blocks")
 	}
 	if phj.BlocksCount > phj.ItemsCount {
-		logger.Panicf("FATAL: the number of blocks cannot exceed the number of items in the part %q; got blocksCount=%d, itemsCount=%d",
-			partPath, phj.BlocksCount, phj.ItemsCount)
+		return fmt.Errorf("the number of blocks cannot exceed the number of items in the part; got blocksCount=%d, itemsCount=%d", phj.BlocksCount, phj.ItemsCount)
 	}
-	ph.blocksCount = phj.BlocksCount
-
-	ph.firstItem = append(ph.firstItem[:0], phj.FirstItem...)
-	ph.lastItem = append(ph.lastItem[:0], phj.LastItem...)
+	return nil
 }
 
 func (ph *partHeader) MustWriteMetadata(partPath string) {
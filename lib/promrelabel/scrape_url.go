@@ -33,6 +33,12 @@ func GetScrapeURL(labels *promutil.Labels, extraParams map[string][]string) (str
 		scheme = "https"
 		address = address[len("https://"):]
 	}
+	// Scrape over unix socket only if it is explicitly requested via `scheme: unix` or `__scheme__="unix"`,
+	// since `unix:` prefix at __address__ may be a valid host named `unix`.
+	if scheme == "unix" {
+		// __address__ contains the path to unix socket, so it cannot contain metricsPath.
+		return buildUnixScrapeURL(labels, extraParams, address, metricsPath), address
+	}
 	if n := strings.IndexByte(address, '/'); n >= 0 {
 		metricsPath = address[n:]
 		address = address[:n]
@@ -61,6 +67,39 @@ func GetScrapeURL(labels *promutil.Labels, extraParams map[string][]string) (str
 	return scrapeURL, addressMustWithPort
 }
 
+// buildUnixScrapeURL returns scrape url for the target listening on the given unix socketPath.
+//
+// The url has nginx-like format: unix:<socketPath>:<metricsPath>
+func buildUnixScrapeURL(labels *promutil.Labels, extraParams map[string][]string, socketPath, metricsPath string) string {
+	if !strings.HasPrefix(metricsPath, "/") {
+		metricsPath = "/" + metricsPath
+	}
+	s := "unix:" + socketPath + ":" + metricsPath
+	if params := getParamsFromLabels(labels, extraParams); len(params) > 0 {
+		optionalQuestion := "?"
+		if strings.Contains(metricsPath, "?") {
+			optionalQuestion = "&"
+		}
+		s += optionalQuestion + url.Values(params).Encode()
+	}
+	return bytesutil.InternString(s)
+}
+
+// ParseUnixScrapeURL parses scrapeURL returned by GetScrapeURL for unix socket targets.
+//
+// It returns the path to unix socket and the request uri. The ok is false if scrapeURL doesn't point to unix socket.
+func ParseUnixScrapeURL(scrapeURL string) (socketPath, requestURI string, ok bool) {
+	s, ok := strings.CutPrefix(scrapeURL, "unix:")
+	if !ok {
+		return "", "", false
+	}
+	n := strings.IndexByte(s, ':')
+	if n < 0 {
+		return "", "", false
+	}
+	return s[:n], s[n+1:], true
+}
+
 func getParamsFromLabels(labels *promutil.Labels, extraParams map[string][]string) map[string][]string {
 	// See https://www.robustperception.io/life-of-a-label
 	var m map[string][]string
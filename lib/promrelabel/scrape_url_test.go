@@ -52,6 +52,13 @@ func TestGetScrapeURL(t *testing.T) {
 	f(`{__address__="http://foo/bar/baz?abc=de",__param_xx="yy"}`, "http://foo/bar/baz?abc=de&xx=yy", "foo:80")
 	f(`{__address__="https://foo/bar/baz?abc=de",__param_xx="yy"}`, "https://foo/bar/baz?abc=de&xx=yy", "foo:443")
 
+	// unix socket
+	f(`{__address__="/var/run/exporter.sock",__scheme__="unix"}`, "unix:/var/run/exporter.sock:/metrics", "/var/run/exporter.sock")
+	f(`{__address__="/var/run/exporter.sock",__scheme__="unix",__metrics_path__="abc",__param_x="y"}`, "unix:/var/run/exporter.sock:/abc?x=y", "/var/run/exporter.sock")
+
+	// host named unix mustn't be scraped over unix socket
+	f(`{__address__="unix:9100"}`, "http://unix:9100/metrics", "unix:9100")
+
 	// __address__ already carry 80/443 port
 	f(`{__address__="foo:80"}`, "http://foo:80/metrics", "foo:80")
 	f(`{__address__="foo:443"}`, "http://foo:443/metrics", "foo:443")
@@ -60,3 +67,25 @@ func TestGetScrapeURL(t *testing.T) {
 	f(`{__address__="http://foo:80"}`, "http://foo:80/metrics", "foo:80")
 	f(`{__address__="https://foo:443"}`, "https://foo:443/metrics", "foo:443")
 }
+
+func TestParseUnixScrapeURL(t *testing.T) {
+	f := func(scrapeURL, socketPathExpected, requestURIExpected string, okExpected bool) {
+		t.Helper()
+
+		socketPath, requestURI, ok := ParseUnixScrapeURL(scrapeURL)
+		if ok != okExpected {
+			t.Fatalf("unexpected ok for %q; got %v; want %v", scrapeURL, ok, okExpected)
+		}
+		if socketPath != socketPathExpected {
+			t.Fatalf("unexpected socketPath for %q; got %q; want %q", scrapeURL, socketPath, socketPathExpected)
+		}
+		if requestURI != requestURIExpected {
+			t.Fatalf("unexpected requestURI for %q; got %q; want %q", scrapeURL, requestURI, requestURIExpected)
+		}
+	}
+
+	f("http://foo/metrics", "", "", false)
+	f("unix:/var/run/exporter.sock", "", "", false)
+	f("unix:/var/run/exporter.sock:/metrics", "/var/run/exporter.sock", "/metrics", true)
+	f("unix:/var/run/exporter.sock:/abc?x=y", "/var/run/exporter.sock", "/abc?x=y", true)
+}
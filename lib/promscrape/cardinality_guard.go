@@ -0,0 +1,437 @@
+package promscrape
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/cespare/xxhash/v2"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/stringsutil"
+)
+
+// CardinalityGuardConfig represents `cardinality_guard` section at scrape_configs.
+//
+// See https://docs.victoriametrics.com/victoriametrics/vmagent/#cardinality-guard
+type CardinalityGuardConfig struct {
+	// SeriesThreshold is the number of series per scrape after relabeling, which triggers the guard.
+	SeriesThreshold int `yaml:"series_threshold"`
+
+	// TopN is the number of top offending metric names to report. By default, defaultCardinalityGuardTopN is used.
+	TopN int `yaml:"top_n,omitempty"`
+
+	// AutoLabelDrop enables dropping the offending label from the offending metrics.
+	AutoLabelDrop bool `yaml:"auto_labeldrop,omitempty"`
+}
+
+const defaultCardinalityGuardTopN = 5
+
+func (cgc *CardinalityGuardConfig) validate() error {
+	if cgc.SeriesThreshold <= 0 {
+		return fmt.Errorf("`series_threshold` must be positive; got %d", cgc.SeriesThreshold)
+	}
+	if cgc.TopN < 0 {
+		return fmt.Errorf("`top_n` cannot be negative; got %d", cgc.TopN)
+	}
+	return nil
+}
+
+func (cgc *CardinalityGuardConfig) getTopN() int {
+	if cgc.TopN <= 0 {
+		return defaultCardinalityGuardTopN
+	}
+	return cgc.TopN
+}
+
+// String returns string representation for cgc.
+func (cgc *CardinalityGuardConfig) String() string {
+	if cgc == nil {
+		return ""
+	}
+	data, err := json.Marshal(cgc)
+	if err != nil {
+		logger.Panicf("BUG: cannot marshal CardinalityGuardConfig: %s", err)
+	}
+	return string(data)
+}
+
+// metricCardinality contains cardinality stats for a single metric name in a single scrape.
+type metricCardinality struct {
+	// series is the number of series with the given metric name.
+	series int
+
+	// labelValues contains the number of unique values per each label name.
+	labelValues map[string]int
+}
+
+// getCardinalityStats returns per-metric cardinality stats for tss.
+func getCardinalityStats(tss []prompb.TimeSeries) map[string]*metricCardinality {
+	type metricValues struct {
+		series int
+		values map[string]map[uint64]struct{}
+	}
+	m := make(map[string]*metricValues)
+	for i := range tss {
+		labels := tss[i].Labels
+		metricName := getMetricName(labels)
+		mv := m[metricName]
+		if mv == nil {
+			mv = &metricValues{
+				values: make(map[string]map[uint64]struct{}),
+			}
+			m[metricName] = mv
+		}
+		mv.series++
+		for _, label := range labels {
+			if label.Name == "__name__" {
+				continue
+			}
+			values := mv.values[label.Name]
+			if values == nil {
+				values = make(map[uint64]struct{})
+				mv.values[label.Name] = values
+			}
+			values[xxhash.Sum64String(label.Value)] = struct{}{}
+		}
+	}
+
+	stats := make(map[string]*metricCardinality, len(m))
+	for metricName, mv := range m {
+		labelValues := make(map[string]int, len(mv.values))
+		for labelName, values := range mv.values {
+			labelValues[labelName] = len(values)
+		}
+		stats[metricName] = &metricCardinality{
+			series:      mv.series,
+			labelValues: labelValues,
+		}
+	}
+	return stats
+}
+
+func getMetricName(labels []prompb.Label) string {
+	for _, label := range labels {
+		if label.Name == "__name__" {
+			return label.Value
+		}
+	}
+	return ""
+}
+
+// cardinalityOffender describes a metric name responsible for cardinality growth.
+type cardinalityOffender struct {
+	// metric is the metric name.
+	metric string
+
+	// series is the number of series for the metric in the last scrape.
+	series int
+
+	// seriesGrowth is the growth of the number of series for the metric comparing to the baseline.
+	seriesGrowth int
+
+	// label is the label name with the biggest growth of unique values for the metric.
+	//
+	// It is empty if the metric has no labels with multiple values except of structural labels
+	// such as histogram buckets and summary quantiles. See isCardinalityGuardStructuralLabel.
+	label string
+
+	// labelValues is the number of unique values for the label in the last scrape.
+	labelValues int
+
+	// labelValuesGrowth is the growth of unique values for the label comparing to the baseline.
+	labelValuesGrowth int
+
+	// dropped is set to true if the label has been dropped from the metric.
+	dropped bool
+}
+
+// getCardinalityOffenders returns up to topN metric names with the biggest growth of series in curr comparing to baseline.
+func getCardinalityOffenders(curr, baseline map[string]*metricCardinality, topN int) []cardinalityOffender {
+	var offenders []cardinalityOffender
+	for metricName, mc := range curr {
+		prev := baseline[metricName]
+		if prev == nil {
+			prev = &metricCardinality{}
+		}
+		seriesGrowth := mc.series - prev.series
+		if seriesGrowth <= 0 {
+			continue
+		}
+		co := cardinalityOffender{
+			metric:       metricName,
+			series:       mc.series,
+			seriesGrowth: seriesGrowth,
+		}
+		for labelName, n := range mc.labelValues {
+			if n <= 1 || isCardinalityGuardStructuralLabel(labelName) {
+				continue
+			}
+			growth := n - prev.labelValues[labelName]
+			if co.label == "" || growth > co.labelValuesGrowth || growth == co.labelValuesGrowth && (n > co.labelValues || n == co.labelValues && labelName < co.label) {
+				co.label = labelName
+				co.labelValues = n
+				co.labelValuesGrowth = growth
+			}
+		}
+		offenders = append(offenders, co)
+	}
+	sort.Slice(offenders, func(i, j int) bool {
+		a, b := &offenders[i], &offenders[j]
+		if a.seriesGrowth != b.seriesGrowth {
+			return a.seriesGrowth > b.seriesGrowth
+		}
+		if a.series != b.series {
+			return a.series > b.series
+		}
+		return a.metric < b.metric
+	})
+	if len(offenders) > topN {
+		offenders = offenders[:topN]
+	}
+	return offenders
+}
+
+// isCardinalityGuardStructuralLabel returns true if labelName cannot be reported as the offending label.
+//
+// Histogram buckets and summary quantiles naturally have many values, while job and instance labels identify the target,
+// so dropping them would break the scraped metrics.
+func isCardinalityGuardStructuralLabel(labelName string) bool {
+	switch labelName {
+	case "le", "quantile", "job", "instance":
+		return true
+	default:
+		return false
+	}
+}
+
+// getLabelsToDrop returns metric name -> label name map for the offending labels, which must be dropped.
+//
+// It marks the corresponding offenders as dropped.
+func getLabelsToDrop(offenders []cardinalityOffender) map[string]string {
+	var labelsToDrop map[string]string
+	for i := range offenders {
+		co := &offenders[i]
+		if co.label == "" {
+			continue
+		}
+		if labelsToDrop == nil {
+			labelsToDrop = make(map[string]string, len(offenders))
+		}
+		labelsToDrop[co.metric] = co.label
+		co.dropped = true
+	}
+	return labelsToDrop
+}
+
+// dropLabels removes labels from tss according to labelsToDrop map (metric name -> label name).
+//
+// Series, which become identical after dropping the labels, are deduplicated by keeping the first series,
+// since they may contain conflicting samples for the same timestamp.
+//
+// It returns tss without duplicates and the number of series with dropped labels.
+func dropLabels(tss []prompb.TimeSeries, labelsToDrop map[string]string) ([]prompb.TimeSeries, int) {
+	if len(labelsToDrop) == 0 {
+		return tss, 0
+	}
+	droppedSeries := 0
+	var seen map[string]struct{}
+	var keyBuf []byte
+	dst := tss[:0]
+	for i := range tss {
+		ts := &tss[i]
+		labelName, ok := labelsToDrop[getMetricName(ts.Labels)]
+		if ok {
+			labels := ts.Labels[:0]
+			for _, label := range ts.Labels {
+				if label.Name != labelName {
+					labels = append(labels, label)
+				}
+			}
+			if len(labels) < len(ts.Labels) {
+				droppedSeries++
+			}
+			ts.Labels = labels
+
+			keyBuf = keyBuf[:0]
+			for _, label := range labels {
+				keyBuf = encoding.MarshalBytes(keyBuf, bytesutil.ToUnsafeBytes(label.Name))
+				keyBuf = encoding.MarshalBytes(keyBuf, bytesutil.ToUnsafeBytes(label.Value))
+			}
+			if _, ok := seen[string(keyBuf)]; ok {
+				continue
+			}
+			if seen == nil {
+				seen = make(map[string]struct{})
+			}
+			seen[string(keyBuf)] = struct{}{}
+		}
+		dst = append(dst, *ts)
+	}
+	clear(tss[len(dst):])
+	return dst, droppedSeries
+}
+
+// cardinalityReport contains the result of the cardinality guard for a single scrape exceeding series_threshold.
+type cardinalityReport struct {
+	timestamp int64
+	series    int
+	offenders []cardinalityOffender
+}
+
+// cardinalityGuardStatsInterval is the interval for re-calculating cardinality stats for the target.
+//
+// Cardinality stats are expensive to calculate for big number of series, so they aren't calculated on every scrape.
+const cardinalityGuardStatsInterval = time.Minute
+
+// applyCardinalityGuard checks whether tss obtained from the scrape at the given timestamp exceeds series_threshold
+// and reports the offending metrics and labels if this is the case.
+//
+// The offending labels are dropped from tss if auto_labeldrop is enabled. The resulting tss is returned.
+func (sw *scrapeWork) applyCardinalityGuard(tss []prompb.TimeSeries, timestamp int64) []prompb.TimeSeries {
+	cgc := sw.Config.CardinalityGuard
+	if len(tss) <= cgc.SeriesThreshold {
+		// Remember stats for the last scrape below the threshold,
+		// so the growth could be detected when the threshold is exceeded.
+		if sw.cardinalityBaseline == nil || timestamp-sw.cardinalityBaselineTimestamp >= cardinalityGuardStatsInterval.Milliseconds() {
+			sw.cardinalityBaseline = getCardinalityStats(tss)
+			sw.cardinalityBaselineTimestamp = timestamp
+		}
+		sw.cardinalityOffenders = nil
+		sw.cardinalityOffendersTimestamp = 0
+		sw.setCardinalityDroppedLabels(nil)
+		tsmGlobal.UpdateCardinalityReport(sw, nil)
+		return tss
+	}
+	cardinalityGuardExceeded.Inc()
+	labelsToDrop := sw.cardinalityDroppedLabels
+	if sw.cardinalityOffendersTimestamp == 0 || timestamp-sw.cardinalityOffendersTimestamp >= cardinalityGuardStatsInterval.Milliseconds() {
+		// Re-calculate offenders only once per cardinalityGuardStatsInterval while the threshold is exceeded.
+		// The previously detected offenders are used in between.
+		stats := getCardinalityStats(tss)
+		sw.cardinalityOffenders = getCardinalityOffenders(stats, sw.cardinalityBaseline, cgc.getTopN())
+		sw.cardinalityOffendersTimestamp = timestamp
+		labelsToDrop = nil
+		if cgc.AutoLabelDrop && sw.cardinalityBaseline != nil {
+			// Do not drop labels until the baseline is obtained from a scrape below the threshold,
+			// since the growth of label values cannot be detected without the baseline.
+			labelsToDrop = getLabelsToDrop(sw.cardinalityOffenders)
+		}
+	}
+	if len(labelsToDrop) > 0 {
+		var droppedSeries int
+		tss, droppedSeries = dropLabels(tss, labelsToDrop)
+		cardinalityGuardLabelDroppedSeries.Add(droppedSeries)
+	}
+	sw.setCardinalityDroppedLabels(labelsToDrop)
+	tsmGlobal.UpdateCardinalityReport(sw, &cardinalityReport{
+		timestamp: timestamp,
+		series:    len(tss),
+		offenders: sw.cardinalityOffenders,
+	})
+	return tss
+}
+
+// setCardinalityDroppedLabels sets labels dropped by the cardinality guard at the current scrape.
+func (sw *scrapeWork) setCardinalityDroppedLabels(labelsToDrop map[string]string) {
+	prevLabelsToDrop := sw.cardinalityDroppedLabels
+	sw.cardinalityDroppedLabels = labelsToDrop
+	if len(prevLabelsToDrop) == 0 && len(labelsToDrop) == 0 {
+		sw.cardinalityStaleSkipMetrics = nil
+		return
+	}
+	m := make(map[string]struct{}, len(prevLabelsToDrop)+len(labelsToDrop))
+	for metricName := range prevLabelsToDrop {
+		m[metricName] = struct{}{}
+	}
+	for metricName := range labelsToDrop {
+		m[metricName] = struct{}{}
+	}
+	sw.cardinalityStaleSkipMetrics = m
+}
+
+// adjustStaleSeriesForCardinalityGuard adjusts tss with stale markers according to the labels dropped by the cardinality guard.
+//
+// Series for metrics with dropped labels at the current or the previous scrape are removed from tss if isTargetAlive is set,
+// since these series may be still written without the dropped label. Otherwise the labels dropped at the last scrape
+// are removed from tss, so stale markers are sent for the series in the same form as they were written.
+func (sw *scrapeWork) adjustStaleSeriesForCardinalityGuard(tss []prompb.TimeSeries, isTargetAlive bool) []prompb.TimeSeries {
+	if !isTargetAlive {
+		tss, _ = dropLabels(tss, sw.cardinalityDroppedLabels)
+		return tss
+	}
+	if len(sw.cardinalityStaleSkipMetrics) == 0 {
+		return tss
+	}
+	dst := tss[:0]
+	for _, ts := range tss {
+		if _, ok := sw.cardinalityStaleSkipMetrics[getMetricName(ts.Labels)]; !ok {
+			dst = append(dst, ts)
+		}
+	}
+	clear(tss[len(dst):])
+	return dst
+}
+
+var (
+	cardinalityGuardExceeded           = metrics.NewCounter("vm_promscrape_cardinality_guard_exceeded_total")
+	cardinalityGuardLabelDroppedSeries = metrics.NewCounter("vm_promscrape_cardinality_guard_label_dropped_series_total")
+)
+
+// WriteAPIV1TargetsCardinality writes /api/v1/targets/cardinality response to w.
+//
+// The response contains the cardinality guard reports for targets exceeding `series_threshold` at the last scrape.
+// Only targets from the given scrapePool are returned if scrapePool isn't empty.
+func WriteAPIV1TargetsCardinality(w io.Writer, scrapePool string) {
+	tss := tsmGlobal.getActiveTargetStatuses()
+	fmt.Fprintf(w, `{"status":"success","data":[`)
+	needComma := false
+	for _, ts := range tss {
+		cr := ts.cardinality
+		if cr == nil {
+			continue
+		}
+		if scrapePool != "" && ts.sw.Config.jobNameOriginal != scrapePool {
+			continue
+		}
+		if needComma {
+			fmt.Fprintf(w, `,`)
+		}
+		fmt.Fprintf(w, `{"labels":`)
+		writeLabelsJSON(w, ts.sw.Config.Labels)
+		fmt.Fprintf(w, `,"scrapePool":%s`, stringsutil.JSONString(ts.sw.Config.jobNameOriginal))
+		fmt.Fprintf(w, `,"scrapeUrl":%s`, stringsutil.JSONString(ts.sw.Config.ScrapeURL))
+		fmt.Fprintf(w, `,"lastScrape":"%s"`, time.UnixMilli(cr.timestamp).Format(time.RFC3339Nano))
+		fmt.Fprintf(w, `,"series":%d`, cr.series)
+		fmt.Fprintf(w, `,"seriesThreshold":%d`, ts.sw.Config.CardinalityGuard.SeriesThreshold)
+		fmt.Fprintf(w, `,"offenders":`)
+		writeCardinalityOffendersJSON(w, cr.offenders)
+		fmt.Fprintf(w, `}`)
+		needComma = true
+	}
+	fmt.Fprintf(w, `]}`)
+}
+
+func writeCardinalityOffendersJSON(w io.Writer, offenders []cardinalityOffender) {
+	fmt.Fprintf(w, `[`)
+	for i := range offenders {
+		co := &offenders[i]
+		fmt.Fprintf(w, `{"metric":%s`, stringsutil.JSONString(co.metric))
+		fmt.Fprintf(w, `,"series":%d`, co.series)
+		fmt.Fprintf(w, `,"seriesGrowth":%d`, co.seriesGrowth)
+		fmt.Fprintf(w, `,"label":%s`, stringsutil.JSONString(co.label))
+		fmt.Fprintf(w, `,"labelValues":%d`, co.labelValues)
+		fmt.Fprintf(w, `,"labelValuesGrowth":%d`, co.labelValuesGrowth)
+		fmt.Fprintf(w, `,"dropped":%v}`, co.dropped)
+		if i+1 < len(offenders) {
+			fmt.Fprintf(w, `,`)
+		}
+	}
+	fmt.Fprintf(w, `]`)
+}
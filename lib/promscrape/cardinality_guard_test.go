@@ -0,0 +1,385 @@
+package promscrape
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/auth"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/chunkedbuffer"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/protoparserutil"
+)
+
+func TestCardinalityGuardConfigValidateFailure(t *testing.T) {
+	f := func(cgc *CardinalityGuardConfig) {
+		t.Helper()
+		if err := cgc.validate(); err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+
+	// missing series_threshold
+	f(&CardinalityGuardConfig{})
+
+	// negative series_threshold
+	f(&CardinalityGuardConfig{
+		SeriesThreshold: -1,
+	})
+
+	// negative top_n
+	f(&CardinalityGuardConfig{
+		SeriesThreshold: 10,
+		TopN:            -1,
+	})
+}
+
+func TestGetCardinalityOffenders(t *testing.T) {
+	f := func(baselineData, currData string, topN int, resultExpected string) {
+		t.Helper()
+		baseline := getCardinalityStats(parseData(baselineData))
+		curr := getCardinalityStats(parseData(currData))
+		offenders := getCardinalityOffenders(curr, baseline, topN)
+		var a []string
+		for _, co := range offenders {
+			a = append(a, fmt.Sprintf("%s:%d:%d:%s:%d:%d", co.metric, co.series, co.seriesGrowth, co.label, co.labelValues, co.labelValuesGrowth))
+		}
+		result := strings.Join(a, ",")
+		if result != resultExpected {
+			t.Fatalf("unexpected offenders; got %s; want %s", result, resultExpected)
+		}
+	}
+
+	// no growth
+	f(`
+		foo{a="1"} 1 123
+		foo{a="2"} 1 123
+`, `
+		foo{a="1"} 1 123
+		foo{a="2"} 1 123
+`, 5, "")
+
+	// empty baseline
+	f(``, `
+		foo{a="1",b="x"} 1 123
+		foo{a="2",b="x"} 1 123
+		bar 1 123
+`, 5, "foo:2:2:a:2:2,bar:1:1::0:0")
+
+	// the label with the biggest growth of values is reported
+	f(`
+		foo{a="1",b="1"} 1 123
+		foo{a="2",b="2"} 1 123
+		foo{a="3",b="3"} 1 123
+		bar{c="1"} 1 123
+`, `
+		foo{a="1",b="1"} 1 123
+		foo{a="1",b="2"} 1 123
+		foo{a="1",b="3"} 1 123
+		foo{a="1",b="4"} 1 123
+		foo{a="2",b="5"} 1 123
+		bar{c="1"} 1 123
+		bar{c="2"} 1 123
+		baz 1 123
+`, 5, "foo:5:2:b:5:2,bar:2:1:c:2:1,baz:1:1::0:0")
+
+	// the number of offenders is limited by topN
+	f(``, `
+		foo{a="1"} 1 123
+		foo{a="2"} 1 123
+		foo{a="3"} 1 123
+		bar{a="1"} 1 123
+		bar{a="2"} 1 123
+		baz 1 123
+`, 2, "foo:3:3:a:3:3,bar:2:2:a:2:2")
+
+	// histogram buckets, summary quantiles, job and instance labels aren't reported
+	f(`
+		req_duration_bucket{job="app",instance="a:80",path="/",le="+Inf"} 1 123
+`, `
+		req_duration_bucket{job="app",instance="a:80",path="/",le="0.1"} 1 123
+		req_duration_bucket{job="app",instance="a:80",path="/",le="1"} 1 123
+		req_duration_bucket{job="app",instance="a:80",path="/",le="+Inf"} 1 123
+		req_duration_bucket{job="app",instance="a:80",path="/foo",le="0.1"} 1 123
+		req_duration_bucket{job="app",instance="a:80",path="/foo",le="1"} 1 123
+		req_duration_bucket{job="app",instance="a:80",path="/foo",le="+Inf"} 1 123
+		rpc_duration{job="app",instance="b:80",quantile="0.5"} 1 123
+		rpc_duration{job="app",instance="c:80",quantile="0.9"} 1 123
+		rpc_duration{job="other",instance="d:80",quantile="0.99"} 1 123
+`, 5, "req_duration_bucket:6:5:path:2:1,rpc_duration:3:3::0:0")
+}
+
+func TestDropLabels(t *testing.T) {
+	tss := parseData(`
+		foo{a="1",b="1"} 1 123
+		foo{a="2",b="1"} 1 123
+		bar{a="1"} 1 123
+		baz{a="1"} 1 123
+`)
+	offenders := []cardinalityOffender{
+		{
+			metric: "foo",
+			label:  "a",
+		},
+		{
+			metric: "baz",
+		},
+	}
+	tss, droppedSeries := dropLabels(tss, getLabelsToDrop(offenders))
+	if droppedSeries != 2 {
+		t.Fatalf("unexpected number of series with dropped labels; got %d; want 2", droppedSeries)
+	}
+	if !offenders[0].dropped {
+		t.Fatalf("expecting dropped label for foo metric")
+	}
+	if offenders[1].dropped {
+		t.Fatalf("unexpected dropped label for baz metric")
+	}
+	tssExpected := parseData(`
+		foo{b="1"} 1 123
+		bar{a="1"} 1 123
+		baz{a="1"} 1 123
+`)
+	if len(tss) != len(tssExpected) {
+		t.Fatalf("unexpected number of series; got %d; want %d", len(tss), len(tssExpected))
+	}
+	for i := range tssExpected {
+		result := timeseriesToString(&tss[i])
+		resultExpected := timeseriesToString(&tssExpected[i])
+		if result != resultExpected {
+			t.Fatalf("unexpected timeseries #%d; got %s; want %s", i, result, resultExpected)
+		}
+	}
+}
+
+func TestScrapeWorkCardinalityGuard(t *testing.T) {
+	var sw scrapeWork
+	sw.Config = &ScrapeWork{
+		ScrapeURL:     "http://foo.bar/metrics",
+		ScrapeTimeout: time.Second * 42,
+		Labels: promutil.NewLabelsFromMap(map[string]string{
+			"job": "foo",
+		}),
+		CardinalityGuard: &CardinalityGuardConfig{
+			SeriesThreshold: 3,
+			AutoLabelDrop:   true,
+		},
+		jobNameOriginal: "foo",
+	}
+
+	var body string
+	sw.ReadData = func(dst *chunkedbuffer.Buffer) (bool, error) {
+		dst.MustWrite([]byte(body))
+		return false, nil
+	}
+	var pushed []string
+	sw.PushData = func(_ *auth.Token, wr *prompb.WriteRequest) {
+		for i := range wr.Timeseries {
+			ts := &wr.Timeseries[i]
+			if isAutoMetric(getMetricName(ts.Labels)) {
+				continue
+			}
+			pushed = append(pushed, timeseriesToString(ts))
+		}
+	}
+
+	protoparserutil.StartUnmarshalWorkers()
+	defer protoparserutil.StopUnmarshalWorkers()
+	tsmGlobal.Register(&sw)
+	defer tsmGlobal.Unregister(&sw)
+
+	// The first scrape doesn't exceed the threshold.
+	body = `
+		http_requests_total{path="/"} 1
+		http_requests_total{path="/foo"} 2
+		up_since 123
+`
+	if err := sw.scrapeInternal(123000, 123000); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if n := len(pushed); n != 3 {
+		t.Fatalf("unexpected number of pushed series; got %d; want 3", n)
+	}
+	var bb bytes.Buffer
+	WriteAPIV1TargetsCardinality(&bb, "")
+	if s := bb.String(); s != `{"status":"success","data":[]}` {
+		t.Fatalf("unexpected response for the scrape below threshold: %s", s)
+	}
+
+	// The second scrape exceeds the threshold because of the new label.
+	pushed = pushed[:0]
+	body = `
+		http_requests_total{path="/",user_id="1"} 1
+		http_requests_total{path="/",user_id="2"} 5
+		http_requests_total{path="/foo",user_id="3"} 2
+		up_since 123
+`
+	if err := sw.scrapeInternal(124000, 124000); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	tssExpected := parseData(`
+		http_requests_total{path="/",job="foo"} 1 124
+		http_requests_total{path="/foo",job="foo"} 2 124
+		up_since{job="foo"} 123 124
+`)
+	var pushedExpected []string
+	for i := range tssExpected {
+		pushedExpected = append(pushedExpected, timeseriesToString(&tssExpected[i]))
+	}
+	sort.Strings(pushed)
+	sort.Strings(pushedExpected)
+	if !reflect.DeepEqual(pushed, pushedExpected) {
+		t.Fatalf("unexpected pushed series;\ngot\n%s\nwant\n%s", strings.Join(pushed, "\n"), strings.Join(pushedExpected, "\n"))
+	}
+	bb.Reset()
+	WriteAPIV1TargetsCardinality(&bb, "foo")
+	if !strings.Contains(bb.String(), `"offenders":[{"metric":"http_requests_total","series":3,"seriesGrowth":1,"label":"user_id","labelValues":3,"labelValuesGrowth":3,"dropped":true}]`) {
+		t.Fatalf("unexpected response for the scrape exceeding threshold: %s", bb.String())
+	}
+	bb.Reset()
+	WriteAPIV1TargetsCardinality(&bb, "bar")
+	if s := bb.String(); s != `{"status":"success","data":[]}` {
+		t.Fatalf("unexpected response for missing scrape pool: %s", s)
+	}
+
+	// The third scrape returns below the threshold. Stale markers mustn't be sent for series,
+	// which were written without the dropped label, since they continue to exist.
+	pushed = pushed[:0]
+	body = `
+		http_requests_total{path="/"} 1
+		http_requests_total{path="/foo"} 2
+		up_since 123
+`
+	if err := sw.scrapeInternal(125000, 125000); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for _, s := range pushed {
+		if strings.Contains(s, "NaN") {
+			t.Fatalf("unexpected stale marker: %s", s)
+		}
+	}
+	if n := len(pushed); n != 3 {
+		t.Fatalf("unexpected number of pushed series; got %d; want 3", n)
+	}
+	bb.Reset()
+	WriteAPIV1TargetsCardinality(&bb, "")
+	if s := bb.String(); s != `{"status":"success","data":[]}` {
+		t.Fatalf("unexpected response for the scrape below threshold: %s", s)
+	}
+}
+
+func TestApplyCardinalityGuardWithoutBaseline(t *testing.T) {
+	var sw scrapeWork
+	sw.Config = &ScrapeWork{
+		CardinalityGuard: &CardinalityGuardConfig{
+			SeriesThreshold: 2,
+			AutoLabelDrop:   true,
+		},
+		jobNameOriginal: "foo",
+	}
+	tsmGlobal.Register(&sw)
+	defer tsmGlobal.Unregister(&sw)
+
+	// The first scrape exceeds the threshold, so there is no baseline for detecting the growth of label values.
+	// Labels mustn't be dropped in this case, while offenders must be reported.
+	tss := sw.applyCardinalityGuard(parseData(`
+		foo_bucket{a="1",le="0.1"} 1 123
+		foo_bucket{a="1",le="1"} 1 123
+		foo_bucket{a="2",le="0.1"} 1 123
+		foo_bucket{a="2",le="1"} 1 123
+`), 123000)
+	if len(tss) != 4 {
+		t.Fatalf("unexpected number of series; got %d; want 4", len(tss))
+	}
+	for i := range tss {
+		if len(tss[i].Labels) != 3 {
+			t.Fatalf("unexpected labels dropped from %s", timeseriesToString(&tss[i]))
+		}
+	}
+	if len(sw.cardinalityDroppedLabels) > 0 {
+		t.Fatalf("unexpected dropped labels: %v", sw.cardinalityDroppedLabels)
+	}
+	if len(sw.cardinalityOffenders) != 1 {
+		t.Fatalf("unexpected number of offenders; got %d; want 1", len(sw.cardinalityOffenders))
+	}
+	if co := &sw.cardinalityOffenders[0]; co.metric != "foo_bucket" || co.label != "a" || co.dropped {
+		t.Fatalf("unexpected offender: %+v", *co)
+	}
+}
+
+func TestApplyCardinalityGuardStatsInterval(t *testing.T) {
+	var sw scrapeWork
+	sw.Config = &ScrapeWork{
+		CardinalityGuard: &CardinalityGuardConfig{
+			SeriesThreshold: 2,
+			AutoLabelDrop:   true,
+		},
+		jobNameOriginal: "foo",
+	}
+	tsmGlobal.Register(&sw)
+	defer tsmGlobal.Unregister(&sw)
+
+	f := func(data string, timestamp int64, resultExpected string) {
+		t.Helper()
+		tss := sw.applyCardinalityGuard(parseData(data), timestamp)
+		var result []string
+		for i := range tss {
+			result = append(result, timeseriesToString(&tss[i]))
+		}
+		tssExpected := parseData(resultExpected)
+		var expected []string
+		for i := range tssExpected {
+			expected = append(expected, timeseriesToString(&tssExpected[i]))
+		}
+		if !reflect.DeepEqual(result, expected) {
+			t.Fatalf("unexpected series;\ngot\n%s\nwant\n%s", strings.Join(result, "\n"), strings.Join(expected, "\n"))
+		}
+	}
+
+	// The baseline is calculated at the first scrape below the threshold.
+	f(`
+		foo{a="1"} 1 123
+		bar{b="1"} 1 123
+`, 123000, `
+		foo{a="1"} 1 123
+		bar{b="1"} 1 123
+`)
+
+	// The threshold is exceeded because of foo metric.
+	f(`
+		foo{a="1"} 1 124
+		foo{a="2"} 2 124
+		bar{b="1"} 1 124
+`, 124000, `
+		foo 1 124
+		bar{b="1"} 1 124
+`)
+
+	// Offenders aren't re-calculated until cardinalityGuardStatsInterval passes,
+	// so the label is dropped from foo metric only.
+	f(`
+		foo{a="1"} 1 125
+		bar{b="1"} 1 125
+		bar{b="2"} 2 125
+`, 125000, `
+		foo 1 125
+		bar{b="1"} 1 125
+		bar{b="2"} 2 125
+`)
+
+	// Offenders are re-calculated after cardinalityGuardStatsInterval.
+	timestamp := 124000 + cardinalityGuardStatsInterval.Milliseconds()
+	f(`
+		foo{a="1"} 1 126
+		bar{b="1"} 1 126
+		bar{b="2"} 2 126
+`, timestamp, `
+		foo{a="1"} 1 126
+		bar 1 126
+`)
+}
@@ -5,6 +5,7 @@ import (
 	"flag"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"strings"
@@ -16,6 +17,7 @@ import (
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httputil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/netutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promrelabel"
 )
 
 var (
@@ -36,6 +38,7 @@ type client struct {
 	c                       *http.Client
 	ctx                     context.Context
 	scrapeURL               string
+	requestURL              string
 	scrapeTimeoutSecondsStr string
 	setHeaders              func(req *http.Request) error
 	setProxyHeaders         func(req *http.Request) error
@@ -55,7 +58,18 @@ func newClient(ctx context.Context, sw *ScrapeWork) (*client, error) {
 	proxyURL := sw.ProxyURL
 	var proxyURLFunc func(*http.Request) (*url.URL, error)
 
-	if proxyURL != nil {
+	requestURL := sw.ScrapeURL
+	if socketPath, requestURI, ok := promrelabel.ParseUnixScrapeURL(sw.ScrapeURL); ok {
+		if proxyURL != nil {
+			return nil, fmt.Errorf("proxy_url cannot be used for scraping unix socket target %q", sw.ScrapeURL)
+		}
+		// The host in the request url is ignored, since connections are always established to socketPath.
+		requestURL = "http://localhost" + requestURI
+		dialFunc = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socketPath)
+		}
+	} else if proxyURL != nil {
 		// case for direct http proxy connection.
 		// must be used for http based scrape targets
 		// since standard golang http.transport has special case for it
@@ -106,6 +120,7 @@ func newClient(ctx context.Context, sw *ScrapeWork) (*client, error) {
 		c:                       hc,
 		ctx:                     ctx,
 		scrapeURL:               sw.ScrapeURL,
+		requestURL:              requestURL,
 		scrapeTimeoutSecondsStr: fmt.Sprintf("%.3f", sw.ScrapeTimeout.Seconds()),
 		setHeaders:              setHeaders,
 		setProxyHeaders:         setProxyHeaders,
@@ -120,7 +135,7 @@ func (c *client) ReadData(dst *chunkedbuffer.Buffer) (bool, error) {
 	ctx, cancel := context.WithDeadline(c.ctx, deadline)
 	defer cancel()
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.scrapeURL, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.requestURL, nil)
 	if err != nil {
 		return false, fmt.Errorf("cannot create request for %q: %w", c.scrapeURL, err)
 	}
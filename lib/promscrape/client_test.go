@@ -8,6 +8,7 @@ import (
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -207,3 +208,58 @@ func TestClientProxyReadOk(t *testing.T) {
 	// backend tls and proxy auth
 	f(true, false, nil, &promauth.BasicAuthConfig{Username: "proxy-test", Password: promauth.NewSecret("1234")})
 }
+
+func TestClientUnixSocketReadOk(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "exporter.sock")
+	ln, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatalf("cannot listen unix socket: %s", err)
+	}
+	expectedResponse := `metric_name{key="value"} 123` + "\n"
+	s := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.RequestURI() != "/metrics?foo=bar" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = w.Write([]byte(expectedResponse))
+		}),
+	}
+	go func() {
+		_ = s.Serve(ln)
+	}()
+	defer s.Close()
+
+	c, err := newClient(context.Background(), &ScrapeWork{
+		ScrapeURL:          "unix:" + socketPath + ":/metrics?foo=bar",
+		ScrapeTimeout:      5 * time.Second,
+		AuthConfig:         newTestAuthConfig(t, false, nil),
+		MaxScrapeSize:      16000,
+		DisableCompression: true,
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %s", err)
+	}
+	var cb chunkedbuffer.Buffer
+	if _, err := c.ReadData(&cb); err != nil {
+		t.Fatalf("unexpected error at ReadData: %s", err)
+	}
+	got, err := io.ReadAll(cb.NewReader())
+	if err != nil {
+		t.Fatalf("err read: %s", err)
+	}
+	if string(got) != expectedResponse {
+		t.Fatalf("unexpected response; got %q; want %q", got, expectedResponse)
+	}
+
+	// proxy_url cannot be used for unix socket targets
+	_, err = newClient(context.Background(), &ScrapeWork{
+		ScrapeURL:     "unix:" + socketPath + ":/metrics",
+		ScrapeTimeout: 5 * time.Second,
+		ProxyURL:      proxy.MustNewURL("http://proxy:3128"),
+		AuthConfig:    newTestAuthConfig(t, false, nil),
+	})
+	if err == nil {
+		t.Fatalf("expecting non-nil error for unix socket target with proxy_url")
+	}
+}
@@ -0,0 +1,410 @@
+package promscrape
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/cespare/xxhash/v2"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/kubernetes"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutil"
+)
+
+var (
+	clusterPeers = flagutil.NewArrayString("promscrape.cluster.peers", "Optional list of vmagent instances in the cluster of scrapers in the form host:port. "+
+		"If set, then targets are distributed among healthy peers with consistent hashing instead of -promscrape.cluster.membersCount and -promscrape.cluster.memberNum. "+
+		"Peers are checked via /health endpoint every -promscrape.cluster.peersCheckInterval. The list must include -promscrape.cluster.memberAddr. "+
+		"See https://docs.victoriametrics.com/victoriametrics/vmagent/#dynamic-cluster-membership")
+	clusterPeersKubernetesService = flag.String("promscrape.cluster.peersKubernetesService", "", "Optional Kubernetes service in the form namespace/name, "+
+		"which endpoints must be used as vmagent instances in the cluster of scrapers. Only ready endpoints are used. "+
+		"See also -promscrape.cluster.peersKubernetesPortName and https://docs.victoriametrics.com/victoriametrics/vmagent/#dynamic-cluster-membership")
+	clusterPeersKubernetesPortName = flag.String("promscrape.cluster.peersKubernetesPortName", "", "The name of the port at -promscrape.cluster.peersKubernetesService endpoints, "+
+		"which must be used for vmagent instances. It must be set when -promscrape.cluster.peersKubernetesService is set")
+	clusterMemberAddr = flag.String("promscrape.cluster.memberAddr", "", "The address of the current vmagent instance in the form host:port. "+
+		"It must match the address of the current instance in -promscrape.cluster.peers or -promscrape.cluster.peersKubernetesService endpoints. "+
+		"See https://docs.victoriametrics.com/victoriametrics/vmagent/#dynamic-cluster-membership")
+	clusterPeersCheckInterval = flag.Duration("promscrape.cluster.peersCheckInterval", 10*time.Second, "Interval for checking the list of healthy peers "+
+		"in the cluster of scrapers. See https://docs.victoriametrics.com/victoriametrics/vmagent/#dynamic-cluster-membership")
+)
+
+// clusterMembershipGlobal is non-nil if dynamic cluster membership is enabled.
+var clusterMembershipGlobal *clusterMembership
+
+func mustInitClusterMembership() {
+	if len(*clusterPeers) == 0 && *clusterPeersKubernetesService == "" {
+		return
+	}
+	if len(*clusterPeers) > 0 && *clusterPeersKubernetesService != "" {
+		logger.Fatalf("-promscrape.cluster.peers and -promscrape.cluster.peersKubernetesService cannot be set simultaneously")
+	}
+	if *clusterMembersCount > 1 {
+		logger.Fatalf("-promscrape.cluster.membersCount cannot be set together with -promscrape.cluster.peers or -promscrape.cluster.peersKubernetesService")
+	}
+	if *clusterMemberAddr == "" {
+		logger.Fatalf("-promscrape.cluster.memberAddr must be set when -promscrape.cluster.peers or -promscrape.cluster.peersKubernetesService is set")
+	}
+	if *clusterPeersCheckInterval <= 0 {
+		logger.Fatalf("-promscrape.cluster.peersCheckInterval must be positive; got %s", *clusterPeersCheckInterval)
+	}
+
+	var pd peersDiscoverer
+	if len(*clusterPeers) > 0 {
+		if !slices.Contains(*clusterPeers, *clusterMemberAddr) {
+			logger.Fatalf("-promscrape.cluster.peers=%q must contain -promscrape.cluster.memberAddr=%q", *clusterPeers, *clusterMemberAddr)
+		}
+		pd = newStaticPeersDiscoverer(*clusterPeers, *clusterMemberAddr, *clusterPeersCheckInterval)
+	} else {
+		kpd, err := newKubernetesPeersDiscoverer(*clusterPeersKubernetesService, *clusterPeersKubernetesPortName)
+		if err != nil {
+			logger.Fatalf("cannot initialize -promscrape.cluster.peersKubernetesService=%q: %s", *clusterPeersKubernetesService, err)
+		}
+		pd = kpd
+	}
+	cm := newClusterMembership(*clusterMemberAddr, pd)
+	cm.mustStart(*clusterPeersCheckInterval)
+	clusterMembershipGlobal = cm
+}
+
+func mustStopClusterMembership() {
+	if clusterMembershipGlobal == nil {
+		return
+	}
+	clusterMembershipGlobal.mustStop()
+	clusterMembershipGlobal = nil
+}
+
+// getClusterMembershipChangedCh returns a channel, which is notified on cluster membership changes.
+//
+// nil is returned if dynamic cluster membership is disabled.
+func getClusterMembershipChangedCh() <-chan struct{} {
+	if clusterMembershipGlobal == nil {
+		return nil
+	}
+	return clusterMembershipGlobal.changedCh
+}
+
+// peersDiscoverer returns the list of healthy peers.
+type peersDiscoverer interface {
+	getPeers() ([]string, error)
+	mustStop()
+}
+
+// clusterMembership tracks healthy peers in the cluster of scrapers
+// and distributes scrape targets among them with consistent hashing.
+type clusterMembership struct {
+	selfAddr string
+	pd       peersDiscoverer
+
+	mu    sync.Mutex
+	peers []string
+
+	// changedCh is notified when peers change
+	changedCh chan struct{}
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+
+	changesTotal  *metrics.Counter
+	checkErrors   *metrics.Counter
+	lastCheckTime *metrics.Gauge
+}
+
+func newClusterMembership(selfAddr string, pd peersDiscoverer) *clusterMembership {
+	cm := &clusterMembership{
+		selfAddr:  selfAddr,
+		pd:        pd,
+		peers:     []string{selfAddr},
+		changedCh: make(chan struct{}, 1),
+		stopCh:    make(chan struct{}),
+
+		changesTotal:  metrics.GetOrCreateCounter(`vm_promscrape_cluster_membership_changes_total`),
+		checkErrors:   metrics.GetOrCreateCounter(`vm_promscrape_cluster_peers_check_errors_total`),
+		lastCheckTime: metrics.GetOrCreateGauge(`vm_promscrape_cluster_peers_last_check_timestamp_seconds`, nil),
+	}
+	_ = metrics.GetOrCreateGauge(`vm_promscrape_cluster_peers`, func() float64 {
+		return float64(len(cm.getPeers()))
+	})
+	return cm
+}
+
+func (cm *clusterMembership) mustStart(checkInterval time.Duration) {
+	// Obtain the initial list of peers before starting scrapers in order to avoid scraping all the targets on startup.
+	cm.updatePeers()
+
+	cm.wg.Add(1)
+	go func() {
+		defer cm.wg.Done()
+		t := time.NewTicker(checkInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-cm.stopCh:
+				return
+			case <-t.C:
+				cm.updatePeers()
+			}
+		}
+	}()
+}
+
+func (cm *clusterMembership) mustStop() {
+	close(cm.stopCh)
+	cm.wg.Wait()
+	cm.pd.mustStop()
+}
+
+func (cm *clusterMembership) updatePeers() {
+	peers, err := cm.pd.getPeers()
+	if err != nil {
+		cm.checkErrors.Inc()
+		logger.Errorf("cannot obtain the list of peers in the cluster of scrapers: %s; continuing with the previous list of peers: %s", err, cm.getPeers())
+		return
+	}
+	cm.lastCheckTime.Set(float64(time.Now().Unix()))
+	if !cm.setPeers(peers) {
+		return
+	}
+	cm.changesTotal.Inc()
+	logger.Infof("cluster membership has been changed; current peers: %s", cm.getPeers())
+	select {
+	case cm.changedCh <- struct{}{}:
+	default:
+	}
+}
+
+// setPeers sets peers for cm and returns true if they were changed.
+//
+// The current member is always included in peers, since it is alive.
+func (cm *clusterMembership) setPeers(peers []string) bool {
+	peers = append([]string{}, peers...)
+	if !slices.Contains(peers, cm.selfAddr) {
+		peers = append(peers, cm.selfAddr)
+	}
+	sort.Strings(peers)
+	peers = slices.Compact(peers)
+
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	if slices.Equal(cm.peers, peers) {
+		return false
+	}
+	cm.peers = peers
+	return true
+}
+
+func (cm *clusterMembership) getPeers() []string {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	return cm.peers
+}
+
+// getPeersForScrapeWork returns peers responsible for scraping the target with the given key.
+//
+// The returned peers are stable when cluster membership changes, e.g. only 1/N of targets are moved
+// between peers when a peer is added or removed from the cluster of N peers.
+func (cm *clusterMembership) getPeersForScrapeWork(key string, replicasCount int) []string {
+	return getRendezvousPeers(key, cm.getPeers(), replicasCount)
+}
+
+// filterScrapeWorkByClusterMembership returns ScrapeWork items from sws, which must be scraped by the current cluster member.
+//
+// sws is returned as is if dynamic cluster membership is disabled.
+func filterScrapeWorkByClusterMembership(sws []*ScrapeWork) []*ScrapeWork {
+	cm := clusterMembershipGlobal
+	if cm == nil {
+		return sws
+	}
+	dst := make([]*ScrapeWork, 0, len(sws))
+	for _, sw := range sws {
+		peers := cm.getPeersForScrapeWork(sw.clusterShardKey, *clusterReplicationFactor)
+		if !slices.Contains(peers, cm.selfAddr) {
+			droppedTargetsMap.Register(sw.OriginalLabels, sw.RelabelConfigs, targetDropReasonSharding, nil)
+			continue
+		}
+		dst = append(dst, sw)
+	}
+	return dst
+}
+
+// getRendezvousPeers returns replicasCount peers with the highest score for the given key.
+//
+// See https://en.wikipedia.org/wiki/Rendezvous_hashing
+func getRendezvousPeers(key string, peers []string, replicasCount int) []string {
+	if replicasCount < 1 {
+		replicasCount = 1
+	}
+	if replicasCount >= len(peers) {
+		return peers
+	}
+	type peerScore struct {
+		peer  string
+		score uint64
+	}
+	h := xxhash.Sum64String(key)
+	scores := make([]peerScore, len(peers))
+	for i, peer := range peers {
+		scores[i] = peerScore{
+			peer:  peer,
+			score: mixHash(h ^ xxhash.Sum64String(peer)),
+		}
+	}
+	sort.Slice(scores, func(i, j int) bool {
+		return scores[i].score > scores[j].score
+	})
+	result := make([]string, replicasCount)
+	for i := range result {
+		result[i] = scores[i].peer
+	}
+	return result
+}
+
+// mixHash is a finalizer from splitmix64, which improves the distribution of the combined hashes.
+func mixHash(h uint64) uint64 {
+	h ^= h >> 30
+	h *= 0xbf58476d1ce4e5b9
+	h ^= h >> 27
+	h *= 0x94d049bb133111eb
+	h ^= h >> 31
+	return h
+}
+
+// staticPeersDiscoverer returns peers from the static list, which respond to /health requests.
+type staticPeersDiscoverer struct {
+	peers    []string
+	selfAddr string
+	client   *http.Client
+}
+
+func newStaticPeersDiscoverer(peers []string, selfAddr string, checkInterval time.Duration) *staticPeersDiscoverer {
+	timeout := checkInterval
+	if timeout > 5*time.Second {
+		timeout = 5 * time.Second
+	}
+	return &staticPeersDiscoverer{
+		peers:    peers,
+		selfAddr: selfAddr,
+		client: &http.Client{
+			Timeout: timeout,
+		},
+	}
+}
+
+func (spd *staticPeersDiscoverer) getPeers() ([]string, error) {
+	healthy := make([]bool, len(spd.peers))
+	var wg sync.WaitGroup
+	for i, peer := range spd.peers {
+		if peer == spd.selfAddr {
+			healthy[i] = true
+			continue
+		}
+		wg.Add(1)
+		go func(i int, peer string) {
+			defer wg.Done()
+			if err := spd.checkPeer(peer); err != nil {
+				logger.Warnf("excluding peer %q from the cluster of scrapers: %s", peer, err)
+				return
+			}
+			healthy[i] = true
+		}(i, peer)
+	}
+	wg.Wait()
+
+	var peers []string
+	for i, peer := range spd.peers {
+		if healthy[i] {
+			peers = append(peers, peer)
+		}
+	}
+	return peers, nil
+}
+
+func (spd *staticPeersDiscoverer) checkPeer(peer string) error {
+	healthURL := peer
+	if !strings.Contains(healthURL, "://") {
+		healthURL = "http://" + healthURL
+	}
+	healthURL = strings.TrimSuffix(healthURL, "/") + "/health"
+	resp, err := spd.client.Get(healthURL)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code returned from %q: %d; want %d", healthURL, resp.StatusCode, http.StatusOK)
+	}
+	return nil
+}
+
+func (spd *staticPeersDiscoverer) mustStop() {
+	spd.client.CloseIdleConnections()
+}
+
+// kubernetesPeersDiscoverer returns ready endpoints for the given Kubernetes service.
+type kubernetesPeersDiscoverer struct {
+	sdc kubernetes.SDConfig
+}
+
+func newKubernetesPeersDiscoverer(service, portName string) (*kubernetesPeersDiscoverer, error) {
+	namespace, name, ok := strings.Cut(service, "/")
+	if !ok || namespace == "" || name == "" {
+		return nil, fmt.Errorf("service must be in the form namespace/name")
+	}
+	if portName == "" {
+		// Every endpoint port is exposed as a separate target by Kubernetes service discovery,
+		// so the port name is required in order to obtain a single address per vmagent instance.
+		return nil, fmt.Errorf("-promscrape.cluster.peersKubernetesPortName must be set")
+	}
+	kpd := &kubernetesPeersDiscoverer{
+		sdc: kubernetes.SDConfig{
+			Role: "endpoints",
+			Namespaces: kubernetes.Namespaces{
+				Names: []string{namespace},
+			},
+			Selectors: []kubernetes.Selector{{
+				Role:  "endpoints",
+				Field: "metadata.name=" + name,
+			}},
+		},
+	}
+	kpd.sdc.MustStart("", func(metaLabels *promutil.Labels) any {
+		if metaLabels.Get("__meta_kubernetes_endpoint_ready") != "true" {
+			return nil
+		}
+		if metaLabels.Get("__meta_kubernetes_endpoint_port_name") != portName {
+			return nil
+		}
+		addr := metaLabels.Get("__address__")
+		if addr == "" {
+			return nil
+		}
+		return &addr
+	})
+	return kpd, nil
+}
+
+func (kpd *kubernetesPeersDiscoverer) getPeers() ([]string, error) {
+	swos, err := kpd.sdc.GetScrapeWorkObjects()
+	if err != nil {
+		return nil, err
+	}
+	peers := make([]string, 0, len(swos))
+	for _, swo := range swos {
+		peers = append(peers, *swo.(*string))
+	}
+	return peers, nil
+}
+
+func (kpd *kubernetesPeersDiscoverer) mustStop() {
+	kpd.sdc.MustStop()
+}
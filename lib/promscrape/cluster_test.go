@@ -0,0 +1,233 @@
+package promscrape
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type testPeersDiscoverer struct {
+	peers []string
+}
+
+func (tpd *testPeersDiscoverer) getPeers() ([]string, error) {
+	return tpd.peers, nil
+}
+
+func (tpd *testPeersDiscoverer) mustStop() {}
+
+func TestGetRendezvousPeers(t *testing.T) {
+	f := func(key string, peers []string, replicasCount int, resultExpected []string) {
+		t.Helper()
+
+		result := getRendezvousPeers(key, peers, replicasCount)
+		if !reflect.DeepEqual(result, resultExpected) {
+			t.Fatalf("unexpected result for key=%q, peers=%q, replicasCount=%d; got %q; want %q", key, peers, replicasCount, result, resultExpected)
+		}
+	}
+
+	// single peer
+	f("foo", []string{"a:8429"}, 1, []string{"a:8429"})
+
+	// replicasCount exceeds the number of peers
+	f("foo", []string{"a:8429", "b:8429"}, 3, []string{"a:8429", "b:8429"})
+
+	// the result doesn't depend on the order of peers
+	peers := []string{"a:8429", "b:8429", "c:8429"}
+	result := getRendezvousPeers("foo", peers, 2)
+	f("foo", []string{"c:8429", "a:8429", "b:8429"}, 2, result)
+	f("foo", peers, 1, result[:1])
+}
+
+func TestGetRendezvousPeersRedistribution(t *testing.T) {
+	const targetsCount = 10000
+	peersOld := []string{"vmagent-0:8429", "vmagent-1:8429", "vmagent-2:8429", "vmagent-3:8429"}
+	peersNew := append(peersOld, "vmagent-4:8429", "vmagent-5:8429")
+
+	targetsPerPeer := make(map[string]int)
+	moved := 0
+	for i := 0; i < targetsCount; i++ {
+		key := fmt.Sprintf("instance=host-%d:9100,job=node_exporter,", i)
+		peerOld := getRendezvousPeers(key, peersOld, 1)[0]
+		peerNew := getRendezvousPeers(key, peersNew, 1)[0]
+		targetsPerPeer[peerNew]++
+		if peerOld != peerNew {
+			moved++
+			if !strings.HasPrefix(peerNew, "vmagent-4") && !strings.HasPrefix(peerNew, "vmagent-5") {
+				t.Fatalf("target %q must be moved only to the new peers; moved from %q to %q", key, peerOld, peerNew)
+			}
+		}
+	}
+
+	// Approximately 2/6 of targets must be moved to the new peers.
+	if moved < targetsCount*2/6*9/10 || moved > targetsCount*2/6*11/10 {
+		t.Fatalf("unexpected number of moved targets; got %d; want approximately %d", moved, targetsCount*2/6)
+	}
+
+	// Targets must be evenly distributed among peers.
+	for _, peer := range peersNew {
+		n := targetsPerPeer[peer]
+		if n < targetsCount/6*8/10 || n > targetsCount/6*12/10 {
+			t.Fatalf("unexpected number of targets for peer %q; got %d; want approximately %d", peer, n, targetsCount/6)
+		}
+	}
+}
+
+func TestClusterMembershipStaticPeers(t *testing.T) {
+	var healthy atomic.Bool
+	healthy.Store(true)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			t.Errorf("unexpected path requested: %q", r.URL.Path)
+		}
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("OK"))
+	}))
+	defer s.Close()
+
+	selfAddr := "127.0.0.1:1"
+	peerAddr := strings.TrimPrefix(s.URL, "http://")
+	pd := newStaticPeersDiscoverer([]string{selfAddr, peerAddr}, selfAddr, time.Second)
+	cm := newClusterMembership(selfAddr, pd)
+	defer cm.pd.mustStop()
+
+	f := func(peersExpected []string, changedExpected bool) {
+		t.Helper()
+
+		cm.updatePeers()
+		if peers := cm.getPeers(); !reflect.DeepEqual(peers, peersExpected) {
+			t.Fatalf("unexpected peers; got %q; want %q", peers, peersExpected)
+		}
+		changed := false
+		select {
+		case <-cm.changedCh:
+			changed = true
+		default:
+		}
+		if changed != changedExpected {
+			t.Fatalf("unexpected membership change notification; got %v; want %v", changed, changedExpected)
+		}
+	}
+
+	f([]string{selfAddr, peerAddr}, true)
+	f([]string{selfAddr, peerAddr}, false)
+
+	// the peer becomes unhealthy
+	healthy.Store(false)
+	f([]string{selfAddr}, true)
+
+	// the peer returns back
+	healthy.Store(true)
+	f([]string{selfAddr, peerAddr}, true)
+}
+
+func TestNewKubernetesPeersDiscovererFailure(t *testing.T) {
+	f := func(service, portName string) {
+		t.Helper()
+
+		kpd, err := newKubernetesPeersDiscoverer(service, portName)
+		if err == nil {
+			kpd.mustStop()
+			t.Fatalf("expecting non-nil error for service=%q, portName=%q", service, portName)
+		}
+	}
+
+	// invalid service
+	f("", "http")
+	f("vmagent", "http")
+	f("monitoring/", "http")
+	f("/vmagent", "http")
+
+	// missing port name
+	f("monitoring/vmagent", "")
+}
+
+func TestFilterScrapeWorkByClusterMembershipKubernetesSD(t *testing.T) {
+	const podsCount = 20
+	var items []string
+	for i := 0; i < podsCount; i++ {
+		items = append(items, fmt.Sprintf(`{
+  "apiVersion": "v1",
+  "kind": "Pod",
+  "metadata": {"name": "pod-%d", "namespace": "default"},
+  "spec": {"containers": [{"name": "app"}]},
+  "status": {"podIP": "10.0.0.%d", "phase": "Running"}
+}`, i, i+1))
+	}
+	podList := fmt.Sprintf(`{"kind": "PodList", "apiVersion": "v1", "metadata": {"resourceVersion": "1"}, "items": [%s]}`, strings.Join(items, ","))
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/pods" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.URL.Query().Get("watch") != "" {
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+			return
+		}
+		_, _ = w.Write([]byte(podList))
+	}))
+	defer s.Close()
+
+	selfAddr := "vmagent-0:8429"
+	pd := &testPeersDiscoverer{
+		peers: []string{selfAddr, "vmagent-1:8429"},
+	}
+	cm := newClusterMembership(selfAddr, pd)
+	cm.updatePeers()
+	clusterMembershipGlobal = cm
+	defer func() {
+		clusterMembershipGlobal = nil
+	}()
+
+	var cfg Config
+	data := fmt.Sprintf(`
+scrape_configs:
+- job_name: k8s
+  kubernetes_sd_configs:
+  - role: pod
+    api_server: %q
+`, s.URL)
+	if err := cfg.parseData([]byte(data), "sss"); err != nil {
+		t.Fatalf("cannot parse data: %s", err)
+	}
+	cfg.mustStart()
+	defer cfg.mustStop()
+
+	// Wait until the targets are discovered.
+	var sws []*ScrapeWork
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		sws = cfg.getKubernetesSDScrapeWork(nil)
+		if len(sws) == podsCount || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(sws) != podsCount {
+		t.Fatalf("unexpected number of discovered targets; got %d; want %d", len(sws), podsCount)
+	}
+
+	// Targets must be distributed among two peers.
+	n := len(filterScrapeWorkByClusterMembership(sws))
+	if n == 0 || n == podsCount {
+		t.Fatalf("unexpected number of targets for the current member; got %d; want a part of %d targets", n, podsCount)
+	}
+
+	// The cached kubernetes_sd targets must be re-distributed after the second peer leaves the cluster.
+	pd.peers = []string{selfAddr}
+	cm.updatePeers()
+	sws = cfg.getKubernetesSDScrapeWork(sws)
+	if n := len(filterScrapeWorkByClusterMembership(sws)); n != podsCount {
+		t.Fatalf("unexpected number of targets for the current member after membership change; got %d; want %d", n, podsCount)
+	}
+}
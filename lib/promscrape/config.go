@@ -37,15 +37,20 @@ import (
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/gce"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/hetzner"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/http"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/ionos"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/kubernetes"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/kuma"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/linode"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/marathon"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/nomad"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/openstack"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/ovhcloud"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/puppetdb"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/scaleway"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/vultr"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/yandexcloud"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/zookeeper"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discoveryutil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/proxy"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/timeutil"
@@ -62,6 +67,9 @@ var (
 	dropOriginalLabels = flag.Bool("promscrape.dropOriginalLabels", false, "Whether to drop original labels for scrape targets at /targets and /api/v1/targets pages. "+
 		"This may be needed for reducing memory usage when original labels for big number of scrape targets occupy big amounts of memory. "+
 		"Note that this reduces debuggability for improper per-target relabeling configs")
+	createdTimestampZeroIngestion = flag.Bool("promscrape.createdTimestampZeroIngestion", false, "Whether to add a sample with zero value at the creation timestamp "+
+		"for new counters, histograms and summaries with OpenMetrics _created series. This allows calculating the increase of such metrics "+
+		"for the first interval after their creation. See https://docs.victoriametrics.com/victoriametrics/vmagent/#created-timestamps")
 	clusterMembersCount = flag.Int("promscrape.cluster.membersCount", 1, "The number of members in a cluster of scrapers. "+
 		"Each member must have a unique -promscrape.cluster.memberNum in the range 0 ... promscrape.cluster.membersCount-1 . "+
 		"Each member then scrapes roughly 1/N of all the targets. By default, cluster scraping is disabled, i.e. a single scraper scrapes all the targets. "+
@@ -116,6 +124,16 @@ type Config struct {
 	ScrapeConfigs     []*ScrapeConfig `yaml:"scrape_configs,omitempty"`
 	ScrapeConfigFiles []string        `yaml:"scrape_config_files,omitempty"`
 
+	// ProbeConfigs contains scrape configs, which probe the discovered targets instead of scraping metrics from them.
+	//
+	// See https://docs.victoriametrics.com/victoriametrics/sd_configs/#probe_configs
+	ProbeConfigs []*ScrapeConfig `yaml:"probe_configs,omitempty"`
+
+	// ExecConfigs contains scrape configs, which read metrics from the output of the configured command instead of scraping the discovered targets.
+	//
+	// See https://docs.victoriametrics.com/victoriametrics/sd_configs/#exec_configs
+	ExecConfigs []*ScrapeConfig `yaml:"exec_configs,omitempty"`
+
 	// This is set to the directory from where the config has been loaded.
 	baseDir string
 }
@@ -304,29 +322,34 @@ type ScrapeConfig struct {
 	// That's why it needs to be supported too :(
 	EnableCompression *bool `yaml:"enable_compression,omitempty"`
 
-	AzureSDConfigs        []azure.SDConfig        `yaml:"azure_sd_configs,omitempty"`
-	ConsulSDConfigs       []consul.SDConfig       `yaml:"consul_sd_configs,omitempty"`
-	ConsulAgentSDConfigs  []consulagent.SDConfig  `yaml:"consulagent_sd_configs,omitempty"`
-	DigitaloceanSDConfigs []digitalocean.SDConfig `yaml:"digitalocean_sd_configs,omitempty"`
-	DNSSDConfigs          []dns.SDConfig          `yaml:"dns_sd_configs,omitempty"`
-	DockerSDConfigs       []docker.SDConfig       `yaml:"docker_sd_configs,omitempty"`
-	DockerSwarmSDConfigs  []dockerswarm.SDConfig  `yaml:"dockerswarm_sd_configs,omitempty"`
-	EC2SDConfigs          []ec2.SDConfig          `yaml:"ec2_sd_configs,omitempty"`
-	EurekaSDConfigs       []eureka.SDConfig       `yaml:"eureka_sd_configs,omitempty"`
-	FileSDConfigs         []FileSDConfig          `yaml:"file_sd_configs,omitempty"`
-	GCESDConfigs          []gce.SDConfig          `yaml:"gce_sd_configs,omitempty"`
-	HetznerSDConfigs      []hetzner.SDConfig      `yaml:"hetzner_sd_configs,omitempty"`
-	HTTPSDConfigs         []http.SDConfig         `yaml:"http_sd_configs,omitempty"`
-	KubernetesSDConfigs   []kubernetes.SDConfig   `yaml:"kubernetes_sd_configs,omitempty"`
-	KumaSDConfigs         []kuma.SDConfig         `yaml:"kuma_sd_configs,omitempty"`
-	MarathonSDConfigs     []marathon.SDConfig     `yaml:"marathon_sd_configs,omitempty"`
-	NomadSDConfigs        []nomad.SDConfig        `yaml:"nomad_sd_configs,omitempty"`
-	OpenStackSDConfigs    []openstack.SDConfig    `yaml:"openstack_sd_configs,omitempty"`
-	OVHCloudSDConfigs     []ovhcloud.SDConfig     `yaml:"ovhcloud_sd_configs,omitempty"`
-	PuppetDBSDConfigs     []puppetdb.SDConfig     `yaml:"puppetdb_sd_configs,omitempty"`
-	StaticConfigs         []StaticConfig          `yaml:"static_configs,omitempty"`
-	VultrSDConfigs        []vultr.SDConfig        `yaml:"vultr_configs,omitempty"`
-	YandexCloudSDConfigs  []yandexcloud.SDConfig  `yaml:"yandexcloud_sd_configs,omitempty"`
+	AzureSDConfigs        []azure.SDConfig              `yaml:"azure_sd_configs,omitempty"`
+	ConsulSDConfigs       []consul.SDConfig             `yaml:"consul_sd_configs,omitempty"`
+	ConsulAgentSDConfigs  []consulagent.SDConfig        `yaml:"consulagent_sd_configs,omitempty"`
+	DigitaloceanSDConfigs []digitalocean.SDConfig       `yaml:"digitalocean_sd_configs,omitempty"`
+	DNSSDConfigs          []dns.SDConfig                `yaml:"dns_sd_configs,omitempty"`
+	DockerSDConfigs       []docker.SDConfig             `yaml:"docker_sd_configs,omitempty"`
+	DockerSwarmSDConfigs  []dockerswarm.SDConfig        `yaml:"dockerswarm_sd_configs,omitempty"`
+	EC2SDConfigs          []ec2.SDConfig                `yaml:"ec2_sd_configs,omitempty"`
+	EurekaSDConfigs       []eureka.SDConfig             `yaml:"eureka_sd_configs,omitempty"`
+	FileSDConfigs         []FileSDConfig                `yaml:"file_sd_configs,omitempty"`
+	GCESDConfigs          []gce.SDConfig                `yaml:"gce_sd_configs,omitempty"`
+	HetznerSDConfigs      []hetzner.SDConfig            `yaml:"hetzner_sd_configs,omitempty"`
+	HTTPSDConfigs         []http.SDConfig               `yaml:"http_sd_configs,omitempty"`
+	IONOSSDConfigs        []ionos.SDConfig              `yaml:"ionos_sd_configs,omitempty"`
+	KubernetesSDConfigs   []kubernetes.SDConfig         `yaml:"kubernetes_sd_configs,omitempty"`
+	KumaSDConfigs         []kuma.SDConfig               `yaml:"kuma_sd_configs,omitempty"`
+	LinodeSDConfigs       []linode.SDConfig             `yaml:"linode_sd_configs,omitempty"`
+	MarathonSDConfigs     []marathon.SDConfig           `yaml:"marathon_sd_configs,omitempty"`
+	NerveSDConfigs        []zookeeper.NerveSDConfig     `yaml:"nerve_sd_configs,omitempty"`
+	NomadSDConfigs        []nomad.SDConfig              `yaml:"nomad_sd_configs,omitempty"`
+	OpenStackSDConfigs    []openstack.SDConfig          `yaml:"openstack_sd_configs,omitempty"`
+	OVHCloudSDConfigs     []ovhcloud.SDConfig           `yaml:"ovhcloud_sd_configs,omitempty"`
+	PuppetDBSDConfigs     []puppetdb.SDConfig           `yaml:"puppetdb_sd_configs,omitempty"`
+	ScalewaySDConfigs     []scaleway.SDConfig           `yaml:"scaleway_sd_configs,omitempty"`
+	ServersetSDConfigs    []zookeeper.ServersetSDConfig `yaml:"serverset_sd_configs,omitempty"`
+	StaticConfigs         []StaticConfig                `yaml:"static_configs,omitempty"`
+	VultrSDConfigs        []vultr.SDConfig              `yaml:"vultr_configs,omitempty"`
+	YandexCloudSDConfigs  []yandexcloud.SDConfig        `yaml:"yandexcloud_sd_configs,omitempty"`
 
 	// These options are supported only by lib/promscrape.
 	DisableCompression  bool                       `yaml:"disable_compression,omitempty"`
@@ -334,10 +357,21 @@ type ScrapeConfig struct {
 	StreamParse         bool                       `yaml:"stream_parse,omitempty"`
 	ScrapeAlignInterval *promutil.Duration         `yaml:"scrape_align_interval,omitempty"`
 	ScrapeOffset        *promutil.Duration         `yaml:"scrape_offset,omitempty"`
+	ScrapeBackoffMax    *promutil.Duration         `yaml:"scrape_backoff_max_interval,omitempty"`
 	SeriesLimit         *int                       `yaml:"series_limit,omitempty"`
 	NoStaleMarkers      *bool                      `yaml:"no_stale_markers,omitempty"`
+	CardinalityGuard    *CardinalityGuardConfig    `yaml:"cardinality_guard,omitempty"`
 	ProxyClientConfig   promauth.ProxyClientConfig `yaml:",inline"`
 
+	// CreatedTimestampZeroIngestion overrides -promscrape.createdTimestampZeroIngestion command-line flag.
+	CreatedTimestampZeroIngestion *bool `yaml:"created_timestamp_zero_ingestion,omitempty"`
+
+	// Probe can be set only at `probe_configs` entries.
+	Probe *ProbeConfig `yaml:"probe,omitempty"`
+
+	// Exec can be set only at `exec_configs` entries.
+	Exec *ExecConfig `yaml:"exec,omitempty"`
+
 	// This is set in loadConfig
 	swc *scrapeWorkConfig
 }
@@ -353,7 +387,17 @@ func (sc *ScrapeConfig) mustStart(baseDir string) {
 		return sw
 	}
 	for i := range sc.KubernetesSDConfigs {
-		sc.KubernetesSDConfigs[i].MustStart(baseDir, swosFunc)
+		sdc := &sc.KubernetesSDConfigs[i]
+		if discoveryutil.IsCacheEnabled() {
+			// Use the persisted targets while the initial list of Kubernetes objects is obtained in background.
+			// See https://docs.victoriametrics.com/victoriametrics/vmagent/#service-discovery-cache
+			key := getDiscoveryCacheKey("kubernetes_sd_config", sc.swc.jobName, baseDir, sdc)
+			if labels, ok := discoveryutil.GetPersistedLabels(key); ok {
+				sdc.MustStartWithPersistedLabels(baseDir, swosFunc, labels)
+				continue
+			}
+		}
+		sdc.MustStart(baseDir, swosFunc)
 	}
 }
 
@@ -394,12 +438,21 @@ func (sc *ScrapeConfig) mustStop() {
 	for i := range sc.HTTPSDConfigs {
 		sc.HTTPSDConfigs[i].MustStop()
 	}
+	for i := range sc.IONOSSDConfigs {
+		sc.IONOSSDConfigs[i].MustStop()
+	}
 	for i := range sc.KubernetesSDConfigs {
 		sc.KubernetesSDConfigs[i].MustStop()
 	}
 	for i := range sc.KumaSDConfigs {
 		sc.KumaSDConfigs[i].MustStop()
 	}
+	for i := range sc.LinodeSDConfigs {
+		sc.LinodeSDConfigs[i].MustStop()
+	}
+	for i := range sc.NerveSDConfigs {
+		sc.NerveSDConfigs[i].MustStop()
+	}
 	for i := range sc.NomadSDConfigs {
 		sc.NomadSDConfigs[i].MustStop()
 	}
@@ -412,6 +465,12 @@ func (sc *ScrapeConfig) mustStop() {
 	for i := range sc.PuppetDBSDConfigs {
 		sc.PuppetDBSDConfigs[i].MustStop()
 	}
+	for i := range sc.ScalewaySDConfigs {
+		sc.ScalewaySDConfigs[i].MustStop()
+	}
+	for i := range sc.ServersetSDConfigs {
+		sc.ServersetSDConfigs[i].MustStop()
+	}
 	for i := range sc.VultrSDConfigs {
 		sc.VultrSDConfigs[i].MustStop()
 	}
@@ -533,6 +592,47 @@ func (cfg *Config) parseData(data []byte, path string) error {
 	cfg.ScrapeConfigFiles = nil
 	cfg.ScrapeConfigs = append(cfg.ScrapeConfigs, scs...)
 
+	// Move cfg.ProbeConfigs and cfg.ExecConfigs into cfg.ScrapeConfigs
+	for _, sc := range cfg.ScrapeConfigs {
+		if sc.Probe != nil {
+			cfg.ScrapeConfigs = nil
+			return fmt.Errorf("`probe` section is allowed only in `probe_configs`; found it in `scrape_configs` for job_name=%q", sc.JobName)
+		}
+		if sc.Exec != nil {
+			cfg.ScrapeConfigs = nil
+			return fmt.Errorf("`exec` section is allowed only in `exec_configs`; found it in `scrape_configs` for job_name=%q", sc.JobName)
+		}
+	}
+	for _, sc := range cfg.ProbeConfigs {
+		if sc.Exec != nil {
+			cfg.ScrapeConfigs = nil
+			return fmt.Errorf("`exec` section is allowed only in `exec_configs`; found it in `probe_configs` for job_name=%q", sc.JobName)
+		}
+		if sc.Probe == nil {
+			sc.Probe = &ProbeConfig{
+				Prober: "http",
+			}
+		}
+	}
+	for _, sc := range cfg.ExecConfigs {
+		if sc.Probe != nil {
+			cfg.ScrapeConfigs = nil
+			return fmt.Errorf("`probe` section is allowed only in `probe_configs`; found it in `exec_configs` for job_name=%q", sc.JobName)
+		}
+		if sc.Exec == nil {
+			cfg.ScrapeConfigs = nil
+			return fmt.Errorf("missing `exec` section in `exec_configs` for job_name=%q", sc.JobName)
+		}
+	}
+	if len(cfg.ExecConfigs) > 0 && !*execEnable {
+		cfg.ScrapeConfigs = nil
+		return fmt.Errorf("`exec_configs` are disabled; pass -promscrape.exec.enable command-line flag in order to enable them")
+	}
+	cfg.ScrapeConfigs = append(cfg.ScrapeConfigs, cfg.ProbeConfigs...)
+	cfg.ProbeConfigs = nil
+	cfg.ScrapeConfigs = append(cfg.ScrapeConfigs, cfg.ExecConfigs...)
+	cfg.ExecConfigs = nil
+
 	// Check that all the scrape configs have unique JobName
 	m := make(map[string]struct{}, len(cfg.ScrapeConfigs))
 	for _, sc := range cfg.ScrapeConfigs {
@@ -717,6 +817,16 @@ func (cfg *Config) getHTTPDScrapeWork(prev []*ScrapeWork) []*ScrapeWork {
 	return cfg.getScrapeWorkGeneric(visitConfigs, "http_sd_config", prev)
 }
 
+// getIONOSSDScrapeWork returns `ionos_sd_configs` ScrapeWork from cfg.
+func (cfg *Config) getIONOSSDScrapeWork(prev []*ScrapeWork) []*ScrapeWork {
+	visitConfigs := func(sc *ScrapeConfig, visitor func(sdc targetLabelsGetter)) {
+		for i := range sc.IONOSSDConfigs {
+			visitor(&sc.IONOSSDConfigs[i])
+		}
+	}
+	return cfg.getScrapeWorkGeneric(visitConfigs, "ionos_sd_config", prev)
+}
+
 // getKubernetesSDScrapeWork returns `kubernetes_sd_configs` ScrapeWork from cfg.
 func (cfg *Config) getKubernetesSDScrapeWork(prev []*ScrapeWork) []*ScrapeWork {
 	const discoveryType = "kubernetes_sd_config"
@@ -733,6 +843,10 @@ func (cfg *Config) getKubernetesSDScrapeWork(prev []*ScrapeWork) []*ScrapeWork {
 				ok = false
 				break
 			}
+			if discoveryutil.IsCacheEnabled() {
+				key := getDiscoveryCacheKey(discoveryType, sc.swc.jobName, cfg.baseDir, sdc)
+				discoveryutil.PersistLabels(key, sdc.GetTargetLabels)
+			}
 			for _, swo := range swos {
 				sw := swo.(*ScrapeWork)
 				dst = append(dst, sw)
@@ -755,6 +869,16 @@ func (cfg *Config) getKumaSDScrapeWork(prev []*ScrapeWork) []*ScrapeWork {
 	return cfg.getScrapeWorkGeneric(visitConfigs, "kuma_sd_config", prev)
 }
 
+// getLinodeSDScrapeWork returns `linode_sd_configs` ScrapeWork from cfg.
+func (cfg *Config) getLinodeSDScrapeWork(prev []*ScrapeWork) []*ScrapeWork {
+	visitConfigs := func(sc *ScrapeConfig, visitor func(sdc targetLabelsGetter)) {
+		for i := range sc.LinodeSDConfigs {
+			visitor(&sc.LinodeSDConfigs[i])
+		}
+	}
+	return cfg.getScrapeWorkGeneric(visitConfigs, "linode_sd_config", prev)
+}
+
 // getMarathonSDScrapeWork returns `marathon_sd_configs` ScrapeWork from cfg.
 func (cfg *Config) getMarathonSDScrapeWork(prev []*ScrapeWork) []*ScrapeWork {
 	visitConfigs := func(sc *ScrapeConfig, visitor func(sdc targetLabelsGetter)) {
@@ -765,6 +889,16 @@ func (cfg *Config) getMarathonSDScrapeWork(prev []*ScrapeWork) []*ScrapeWork {
 	return cfg.getScrapeWorkGeneric(visitConfigs, "marathon_sd_config", prev)
 }
 
+// getNerveSDScrapeWork returns `nerve_sd_configs` ScrapeWork from cfg.
+func (cfg *Config) getNerveSDScrapeWork(prev []*ScrapeWork) []*ScrapeWork {
+	visitConfigs := func(sc *ScrapeConfig, visitor func(sdc targetLabelsGetter)) {
+		for i := range sc.NerveSDConfigs {
+			visitor(&sc.NerveSDConfigs[i])
+		}
+	}
+	return cfg.getScrapeWorkGeneric(visitConfigs, "nerve_sd_config", prev)
+}
+
 // getNomadSDScrapeWork returns `nomad_sd_configs` ScrapeWork from cfg.
 func (cfg *Config) getNomadSDScrapeWork(prev []*ScrapeWork) []*ScrapeWork {
 	visitConfigs := func(sc *ScrapeConfig, visitor func(sdc targetLabelsGetter)) {
@@ -805,6 +939,26 @@ func (cfg *Config) getPuppetDBSDScrapeWork(prev []*ScrapeWork) []*ScrapeWork {
 	return cfg.getScrapeWorkGeneric(visitConfigs, "puppetdb_sd_config", prev)
 }
 
+// getScalewaySDScrapeWork returns `scaleway_sd_configs` ScrapeWork from cfg.
+func (cfg *Config) getScalewaySDScrapeWork(prev []*ScrapeWork) []*ScrapeWork {
+	visitConfigs := func(sc *ScrapeConfig, visitor func(sdc targetLabelsGetter)) {
+		for i := range sc.ScalewaySDConfigs {
+			visitor(&sc.ScalewaySDConfigs[i])
+		}
+	}
+	return cfg.getScrapeWorkGeneric(visitConfigs, "scaleway_sd_config", prev)
+}
+
+// getServersetSDScrapeWork returns `serverset_sd_configs` ScrapeWork from cfg.
+func (cfg *Config) getServersetSDScrapeWork(prev []*ScrapeWork) []*ScrapeWork {
+	visitConfigs := func(sc *ScrapeConfig, visitor func(sdc targetLabelsGetter)) {
+		for i := range sc.ServersetSDConfigs {
+			visitor(&sc.ServersetSDConfigs[i])
+		}
+	}
+	return cfg.getScrapeWorkGeneric(visitConfigs, "serverset_sd_config", prev)
+}
+
 // getVultrSDScrapeWork returns `vultr_sd_configs` ScrapeWork from cfg.
 func (cfg *Config) getVultrSDScrapeWork(prev []*ScrapeWork) []*ScrapeWork {
 	visitConfigs := func(sc *ScrapeConfig, visitor func(sdc targetLabelsGetter)) {
@@ -839,7 +993,10 @@ func (cfg *Config) getScrapeWorkGeneric(visitConfigs func(sc *ScrapeConfig, visi
 			if !ok {
 				return
 			}
-			targetLabels, err := sdc.GetLabels(cfg.baseDir)
+			key := getDiscoveryCacheKey(discoveryType, sc.swc.jobName, cfg.baseDir, sdc)
+			targetLabels, err := discoveryutil.GetLabelsWithCache(key, func() ([]*promutil.Labels, error) {
+				return sdc.GetLabels(cfg.baseDir)
+			})
 			if err != nil {
 				logger.Errorf("skipping %s targets for job_name=%s because of error: %s", discoveryType, sc.swc.jobName, err)
 				ok = false
@@ -854,6 +1011,17 @@ func (cfg *Config) getScrapeWorkGeneric(visitConfigs func(sc *ScrapeConfig, visi
 	return dst
 }
 
+// getDiscoveryCacheKey returns the key for persisting discovery results for sdc.
+//
+// See https://docs.victoriametrics.com/victoriametrics/vmagent/#service-discovery-cache
+func getDiscoveryCacheKey(discoveryType, jobName, baseDir string, sdc any) string {
+	data, err := yaml.Marshal(sdc)
+	if err != nil {
+		logger.Panicf("BUG: cannot marshal %s for job_name=%s: %s", discoveryType, jobName, err)
+	}
+	return fmt.Sprintf("%s\n%s\n%s\n%s", discoveryType, jobName, baseDir, data)
+}
+
 func (sc *ScrapeConfig) appendPrevTargets(dst []*ScrapeWork, swsPrevByJob map[string][]*ScrapeWork, discoveryType string) []*ScrapeWork {
 	swsPrev := swsPrevByJob[sc.swc.jobName]
 	if len(swsPrev) == 0 {
@@ -926,13 +1094,31 @@ func getScrapeWorkConfig(sc *ScrapeConfig, baseDir string, globalCfg *GlobalConf
 	metricsPath := sc.MetricsPath
 	if metricsPath == "" {
 		metricsPath = "/metrics"
+		if sc.Probe != nil {
+			metricsPath = "/"
+		}
+	}
+	if sc.Probe != nil {
+		if err := sc.Probe.validate(); err != nil {
+			return nil, fmt.Errorf("invalid `probe` section for `job_name` %q: %w", jobName, err)
+		}
+	}
+	if sc.Exec != nil {
+		if err := sc.Exec.validate(); err != nil {
+			return nil, fmt.Errorf("invalid `exec` section for `job_name` %q: %w", jobName, err)
+		}
+	}
+	if sc.CardinalityGuard != nil {
+		if err := sc.CardinalityGuard.validate(); err != nil {
+			return nil, fmt.Errorf("invalid `cardinality_guard` section for `job_name` %q: %w", jobName, err)
+		}
 	}
 	scheme := strings.ToLower(sc.Scheme)
 	if scheme == "" {
 		scheme = "http"
 	}
-	if scheme != "http" && scheme != "https" {
-		return nil, fmt.Errorf("unexpected `scheme` for `job_name` %q: %q; supported values: http or https", jobName, scheme)
+	if scheme != "http" && scheme != "https" && scheme != "unix" {
+		return nil, fmt.Errorf("unexpected `scheme` for `job_name` %q: %q; supported values: http, https or unix", jobName, scheme)
 	}
 	params := sc.Params
 	ac, err := sc.HTTPClientConfig.NewConfig(baseDir)
@@ -968,6 +1154,10 @@ func getScrapeWorkConfig(sc *ScrapeConfig, baseDir string, globalCfg *GlobalConf
 	if sc.NoStaleMarkers != nil {
 		noStaleTracking = *sc.NoStaleMarkers
 	}
+	ctZeroIngestion := *createdTimestampZeroIngestion
+	if sc.CreatedTimestampZeroIngestion != nil {
+		ctZeroIngestion = *sc.CreatedTimestampZeroIngestion
+	}
 	seriesLimit := *seriesLimitPerTarget
 	if sc.SeriesLimit != nil {
 		seriesLimit = *sc.SeriesLimit
@@ -1002,8 +1192,13 @@ func getScrapeWorkConfig(sc *ScrapeConfig, baseDir string, globalCfg *GlobalConf
 		streamParse:          sc.StreamParse,
 		scrapeAlignInterval:  sc.ScrapeAlignInterval.Duration(),
 		scrapeOffset:         sc.ScrapeOffset.Duration(),
+		scrapeBackoffMax:     sc.ScrapeBackoffMax.Duration(),
 		seriesLimit:          seriesLimit,
 		noStaleMarkers:       noStaleTracking,
+		cardinalityGuard:     sc.CardinalityGuard,
+		ctZeroIngestion:      ctZeroIngestion,
+		probe:                sc.Probe,
+		exec:                 sc.Exec,
 	}
 	return swc, nil
 }
@@ -1034,8 +1229,13 @@ type scrapeWorkConfig struct {
 	streamParse          bool
 	scrapeAlignInterval  time.Duration
 	scrapeOffset         time.Duration
+	scrapeBackoffMax     time.Duration
 	seriesLimit          int
 	noStaleMarkers       bool
+	cardinalityGuard     *CardinalityGuardConfig
+	ctZeroIngestion      bool
+	probe                *ProbeConfig
+	exec                 *ExecConfig
 }
 
 func appendScrapeWorkForTargetLabels(dst []*ScrapeWork, swc *scrapeWorkConfig, targetLabels []*promutil.Labels, discoveryType string) []*ScrapeWork {
@@ -1197,7 +1397,17 @@ func (swc *scrapeWorkConfig) getScrapeWork(target string, extraLabels, metaLabel
 	// Perform the verification on labels after the relabeling in order to guarantee that targets with the same set of labels
 	// go to the same vmagent shard.
 	// See https://github.com/VictoriaMetrics/VictoriaMetrics/issues/1687#issuecomment-940629495
-	if *clusterMembersCount > 1 {
+	//
+	// Targets aren't dropped here when dynamic cluster membership is enabled, since the returned ScrapeWork may be cached
+	// by service discovery (for example, kubernetes_sd_configs) across cluster membership changes.
+	// Such targets are filtered by filterScrapeWorkByClusterMembership instead.
+	clusterShardKey := ""
+	if clusterMembershipGlobal != nil {
+		bb := scrapeWorkKeyBufPool.Get()
+		bb.B = appendScrapeWorkKey(bb.B[:0], labels)
+		clusterShardKey = string(bb.B)
+		scrapeWorkKeyBufPool.Put(bb)
+	} else if *clusterMembersCount > 1 {
 		bb := scrapeWorkKeyBufPool.Get()
 		bb.B = appendScrapeWorkKey(bb.B[:0], labels)
 		memberNums := getClusterMemberNumsForScrapeWork(bytesutil.ToUnsafeString(bb.B), *clusterMembersCount, *clusterReplicationFactor)
@@ -1215,9 +1425,14 @@ func (swc *scrapeWorkConfig) getScrapeWork(target string, extraLabels, metaLabel
 		droppedTargetsMap.Register(originalLabels, swc.relabelConfigs, targetDropReasonMissingScrapeURL, nil)
 		return nil, nil
 	}
-	if _, err := url.Parse(scrapeURL); err != nil {
+	u, err := url.Parse(scrapeURL)
+	if err != nil {
 		return nil, fmt.Errorf("invalid target url=%q for job=%q: %w", scrapeURL, swc.jobName, err)
 	}
+	if swc.probe != nil && swc.probe.needsTargetPort() && u.Port() == "" {
+		// Do not fall back to the default port for the url scheme, since it has nothing in common with the probed service.
+		return nil, fmt.Errorf("missing port in the target %q for `prober: %s` at job=%q", u.Host, swc.probe.Prober, swc.jobName)
+	}
 
 	var at *auth.Token
 	tenantID := labels.Get("__tenant_id__")
@@ -1292,8 +1507,12 @@ func (swc *scrapeWorkConfig) getScrapeWork(target string, extraLabels, metaLabel
 	if labels.Get("instance") == "" {
 		labels.Add("instance", address)
 	}
-	if *clusterMemberLabel != "" && *clusterMemberNum != "" {
-		labels.Add(*clusterMemberLabel, *clusterMemberNum)
+	if *clusterMemberLabel != "" {
+		if clusterMembershipGlobal != nil {
+			labels.Add(*clusterMemberLabel, *clusterMemberAddr)
+		} else if *clusterMemberNum != "" {
+			labels.Add(*clusterMemberLabel, *clusterMemberNum)
+		}
 	}
 	// Remove references to deleted labels, so GC could clean strings for label name and label value past len(labels.Labels).
 	// This should reduce memory usage when relabeling creates big number of temporary labels with long names and/or values.
@@ -1326,13 +1545,20 @@ func (swc *scrapeWorkConfig) getScrapeWork(target string, extraLabels, metaLabel
 		StreamParse:          streamParse,
 		ScrapeAlignInterval:  swc.scrapeAlignInterval,
 		ScrapeOffset:         swc.scrapeOffset,
+		ScrapeBackoffMax:     swc.scrapeBackoffMax,
 		SampleLimit:          sampleLimit,
 		SeriesLimit:          seriesLimit,
 		LabelLimit:           labelLimit,
 		NoStaleMarkers:       swc.noStaleMarkers,
+		CardinalityGuard:     swc.cardinalityGuard,
 		AuthToken:            at,
+		Probe:                swc.probe,
+		Exec:                 swc.exec,
+
+		CreatedTimestampZeroIngestion: swc.ctZeroIngestion,
 
 		jobNameOriginal: swc.jobName,
+		clusterShardKey: clusterShardKey,
 	}
 	return sw, nil
 }
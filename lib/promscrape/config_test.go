@@ -440,6 +440,73 @@ scrape_configs:
   static_configs:
     targets: ["bar"]
 `)
+
+	// Duplicate job_name across scrape_configs and probe_configs
+	f(`
+scrape_configs:
+- job_name: foo
+  static_configs:
+  - targets: ["foo"]
+probe_configs:
+- job_name: foo
+  static_configs:
+  - targets: ["bar"]
+`)
+
+	// probe section in scrape_configs
+	f(`
+scrape_configs:
+- job_name: foo
+  probe:
+    prober: tcp
+  static_configs:
+  - targets: ["foo"]
+`)
+
+	// exec section in scrape_configs
+	f(`
+scrape_configs:
+- job_name: foo
+  exec:
+    command: ["foo"]
+  static_configs:
+  - targets: ["foo"]
+`)
+
+	// exec_configs without -promscrape.exec.enable
+	f(`
+exec_configs:
+- job_name: foo
+  exec:
+    command: ["foo"]
+  static_configs:
+  - targets: ["foo"]
+`)
+
+	*execEnable = true
+	defer func() {
+		*execEnable = false
+	}()
+
+	// missing exec section in exec_configs
+	f(`
+exec_configs:
+- job_name: foo
+  static_configs:
+  - targets: ["foo"]
+`)
+
+	// probe section in exec_configs
+	f(`
+exec_configs:
+- job_name: foo
+  exec:
+    command: ["foo"]
+  probe:
+    prober: tcp
+  static_configs:
+  - targets: ["foo"]
+`)
 }
 
 // String returns human-readable representation for sw.
@@ -1220,6 +1287,7 @@ scrape_configs:
   scrape_interval: 1w
   scrape_align_interval: 1d
   scrape_offset: 2d
+  scrape_backoff_max_interval: 4w
   no_stale_markers: true
   static_configs:
   - targets: ["foo.bar:1234"]
@@ -1230,6 +1298,7 @@ scrape_configs:
 			ScrapeTimeout:       time.Hour * 24,
 			ScrapeAlignInterval: time.Hour * 24,
 			ScrapeOffset:        time.Hour * 24 * 2,
+			ScrapeBackoffMax:    time.Hour * 24 * 7 * 4,
 			MaxScrapeSize:       maxScrapeSize.N,
 			NoStaleMarkers:      true,
 			Labels: promutil.NewLabelsFromMap(map[string]string{
@@ -1263,6 +1332,167 @@ scrape_configs:
 		},
 	})
 	*seriesLimitPerTarget = defaultSeriesLimitPerTarget
+
+	// probe_configs
+	f(`
+probe_configs:
+- job_name: http
+  static_configs:
+  - targets: ["https://example.com"]
+- job_name: dns
+  probe:
+    prober: dns
+    dns:
+      query_name: example.com
+      query_type: MX
+  static_configs:
+  - targets: ["8.8.8.8"]
+`, []*ScrapeWork{
+		{
+			ScrapeURL:       "https://example.com/",
+			ScrapeInterval:  defaultScrapeInterval,
+			ScrapeTimeout:   defaultScrapeTimeout,
+			MaxScrapeSize:   maxScrapeSize.N,
+			jobNameOriginal: "http",
+			Labels: promutil.NewLabelsFromMap(map[string]string{
+				"instance": "example.com:443",
+				"job":      "http",
+			}),
+			Probe: &ProbeConfig{
+				Prober: "http",
+			},
+		},
+		{
+			ScrapeURL:       "http://8.8.8.8/",
+			ScrapeInterval:  defaultScrapeInterval,
+			ScrapeTimeout:   defaultScrapeTimeout,
+			MaxScrapeSize:   maxScrapeSize.N,
+			jobNameOriginal: "dns",
+			Labels: promutil.NewLabelsFromMap(map[string]string{
+				"instance": "8.8.8.8:80",
+				"job":      "dns",
+			}),
+			Probe: &ProbeConfig{
+				Prober: "dns",
+				DNS: &DNSProbeConfig{
+					QueryName: "example.com",
+					QueryType: "MX",
+				},
+			},
+		},
+	})
+
+	// probe_configs with invalid probe section must be skipped
+	f(`
+probe_configs:
+- job_name: foo
+  probe:
+    prober: icmp
+  static_configs:
+  - targets: ["foo"]
+`, []*ScrapeWork{})
+
+	// exec_configs
+	*execEnable = true
+	defer func() {
+		*execEnable = false
+	}()
+	f(`
+exec_configs:
+- job_name: exec
+  exec:
+    command: ["/usr/local/bin/backup-stats", "--format=prometheus"]
+    env:
+      FOO: bar
+  static_configs:
+  - targets: ["localhost"]
+`, []*ScrapeWork{
+		{
+			ScrapeURL:       "http://localhost/metrics",
+			ScrapeInterval:  defaultScrapeInterval,
+			ScrapeTimeout:   defaultScrapeTimeout,
+			MaxScrapeSize:   maxScrapeSize.N,
+			jobNameOriginal: "exec",
+			Labels: promutil.NewLabelsFromMap(map[string]string{
+				"instance": "localhost:80",
+				"job":      "exec",
+			}),
+			Exec: &ExecConfig{
+				Command: []string{"/usr/local/bin/backup-stats", "--format=prometheus"},
+				Env: map[string]*promauth.Secret{
+					"FOO": promauth.NewSecret("bar"),
+				},
+			},
+		},
+	})
+
+	// exec_configs with invalid exec section must be skipped
+	f(`
+exec_configs:
+- job_name: foo
+  exec:
+    command: []
+  static_configs:
+  - targets: ["foo"]
+`, []*ScrapeWork{})
+
+	// cardinality_guard
+	f(`
+scrape_configs:
+- job_name: foo
+  cardinality_guard:
+    series_threshold: 1000
+    auto_labeldrop: true
+  static_configs:
+  - targets: ["foo.bar:1234"]
+`, []*ScrapeWork{
+		{
+			ScrapeURL:       "http://foo.bar:1234/metrics",
+			ScrapeInterval:  defaultScrapeInterval,
+			ScrapeTimeout:   defaultScrapeTimeout,
+			MaxScrapeSize:   maxScrapeSize.N,
+			jobNameOriginal: "foo",
+			Labels: promutil.NewLabelsFromMap(map[string]string{
+				"instance": "foo.bar:1234",
+				"job":      "foo",
+			}),
+			CardinalityGuard: &CardinalityGuardConfig{
+				SeriesThreshold: 1000,
+				AutoLabelDrop:   true,
+			},
+		},
+	})
+
+	// scrape_configs with invalid cardinality_guard section must be skipped
+	f(`
+scrape_configs:
+- job_name: foo
+  cardinality_guard:
+    top_n: 10
+  static_configs:
+  - targets: ["foo"]
+`, []*ScrapeWork{})
+
+	// unix socket targets
+	f(`
+scrape_configs:
+- job_name: unix
+  scheme: unix
+  static_configs:
+  - targets: ["/var/run/exporter.sock"]
+`, []*ScrapeWork{
+		{
+			ScrapeURL:       "unix:/var/run/exporter.sock:/metrics",
+			ScrapeInterval:  defaultScrapeInterval,
+			ScrapeTimeout:   defaultScrapeTimeout,
+			MaxScrapeSize:   maxScrapeSize.N,
+			jobNameOriginal: "unix",
+			Labels: promutil.NewLabelsFromMap(map[string]string{
+				"instance": "/var/run/exporter.sock",
+				"job":      "unix",
+			}),
+		},
+	})
 }
 
 func equalStaticConfigForScrapeWorks(a, b []*ScrapeWork) bool {
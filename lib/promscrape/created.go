@@ -0,0 +1,84 @@
+package promscrape
+
+import (
+	"github.com/VictoriaMetrics/metrics"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+	parser "github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/prometheus"
+)
+
+// addCreatedTimestampZeroSamples adds zero samples at creation timestamps to wc for new series from rows
+// with the corresponding OpenMetrics `_created` series.
+//
+// A series is considered new if it wasn't seen at the previous scrape or if its creation timestamp has been changed,
+// e.g. after the target restart. This allows calculating the increase for the first interval after the series creation.
+//
+// See https://github.com/prometheus/OpenMetrics/blob/main/specification/OpenMetrics.md#counter-1
+func (sw *scrapeWork) addCreatedTimestampZeroSamples(wc *writeRequestCtx, rows []parser.Row, scrapeTimestamp int64) {
+	ct := &sw.createdTimestamps
+	ct.Init(rows)
+
+	prev := sw.seriesCreatedTimestamps
+	curr := sw.prevSeriesCreatedTimestamps
+	clear(curr)
+
+	cfg := sw.Config
+	tssLen := len(wc.writeRequest.Timeseries)
+	bb := bbPool.Get()
+	for i := range rows {
+		r := &rows[i]
+		createdTimestamp := ct.Get(r)
+		if createdTimestamp <= 0 {
+			continue
+		}
+		sampleTimestamp := r.Timestamp
+		if !cfg.HonorTimestamps || sampleTimestamp == 0 {
+			sampleTimestamp = scrapeTimestamp
+		}
+		if createdTimestamp >= sampleTimestamp {
+			continue
+		}
+		bb.B = marshalRowKey(bb.B[:0], r)
+		if curr == nil {
+			curr = make(map[string]int64)
+		}
+		key := bytesutil.ToUnsafeString(bb.B)
+		prevCreatedTimestamp, ok := prev[key]
+		key = string(bb.B)
+		curr[key] = createdTimestamp
+		if ok && prevCreatedTimestamp == createdTimestamp {
+			continue
+		}
+		zeroRow := parser.Row{
+			Metric:    r.Metric,
+			Tags:      r.Tags,
+			Timestamp: createdTimestamp,
+		}
+		// Errors are impossible here, since label_limit has been already checked for the same series.
+		_ = wc.addRow(cfg, &zeroRow, createdTimestamp, true)
+	}
+	bbPool.Put(bb)
+
+	sw.seriesCreatedTimestamps = curr
+	sw.prevSeriesCreatedTimestamps = prev
+
+	tss := wc.writeRequest.Timeseries[tssLen:]
+	if cfg.CardinalityGuard != nil {
+		// Zero samples must have the same labels as the original series.
+		dropLabels(tss, sw.cardinalityDroppedLabels)
+	}
+	createdTimestampZeroSamples.Add(len(tss))
+}
+
+var createdTimestampZeroSamples = metrics.NewCounter("vm_promscrape_created_timestamp_zero_samples_total")
+
+func marshalRowKey(dst []byte, r *parser.Row) []byte {
+	dst = append(dst, r.Metric...)
+	for _, tag := range r.Tags {
+		dst = append(dst, 0)
+		dst = append(dst, tag.Key...)
+		dst = append(dst, '=')
+		dst = append(dst, tag.Value...)
+	}
+	return dst
+}
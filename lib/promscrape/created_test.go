@@ -0,0 +1,112 @@
+package promscrape
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/auth"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/chunkedbuffer"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/protoparserutil"
+)
+
+func TestScrapeWorkCreatedTimestampZeroIngestion(t *testing.T) {
+	var sw scrapeWork
+	sw.Config = &ScrapeWork{
+		ScrapeURL:     "http://foo.bar/metrics",
+		ScrapeTimeout: time.Second * 42,
+		Labels: promutil.NewLabelsFromMap(map[string]string{
+			"job": "foo",
+		}),
+		CreatedTimestampZeroIngestion: true,
+		jobNameOriginal:               "foo",
+	}
+
+	var body string
+	sw.ReadData = func(dst *chunkedbuffer.Buffer) (bool, error) {
+		dst.MustWrite([]byte(body))
+		return false, nil
+	}
+	var pushed []string
+	sw.PushData = func(_ *auth.Token, wr *prompb.WriteRequest) {
+		for i := range wr.Timeseries {
+			ts := &wr.Timeseries[i]
+			if isAutoMetric(getMetricName(ts.Labels)) || ts.Samples[0].Value != 0 {
+				continue
+			}
+			pushed = append(pushed, timeseriesToString(ts))
+		}
+	}
+
+	protoparserutil.StartUnmarshalWorkers()
+	defer protoparserutil.StopUnmarshalWorkers()
+	tsmGlobal.Register(&sw)
+	defer tsmGlobal.Unregister(&sw)
+
+	f := func(scrapeTimestamp int64, data, zeroSamplesExpected string) {
+		t.Helper()
+		pushed = pushed[:0]
+		body = data
+		if err := sw.scrapeInternal(scrapeTimestamp, scrapeTimestamp); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		var resultExpected []string
+		tssExpected := parseData(zeroSamplesExpected)
+		for i := range tssExpected {
+			resultExpected = append(resultExpected, timeseriesToString(&tssExpected[i]))
+		}
+		sort.Strings(pushed)
+		sort.Strings(resultExpected)
+		result := strings.Join(pushed, "\n")
+		if s := strings.Join(resultExpected, "\n"); result != s {
+			t.Fatalf("unexpected zero samples;\ngot\n%s\nwant\n%s", result, s)
+		}
+	}
+
+	// zero samples are added for new series with _created series
+	f(123000, `
+		http_requests_total{path="/"} 5
+		http_requests_created{path="/"} 100
+		http_requests_total{path="/foo"} 3
+		latency_bucket{le="1"} 2
+		latency_bucket{le="+Inf"} 2
+		latency_count 2
+		latency_sum 1.5
+		latency_created 110.5
+`, `
+		http_requests_total{path="/",job="foo"} 0 100
+		latency_bucket{le="1",job="foo"} 0 110.5
+		latency_bucket{le="+Inf",job="foo"} 0 110.5
+		latency_count{job="foo"} 0 110.5
+		latency_sum{job="foo"} 0 110.5
+`)
+
+	// zero samples aren't added for already seen series
+	f(124000, `
+		http_requests_total{path="/"} 6
+		http_requests_created{path="/"} 100
+		http_requests_total{path="/bar"} 1
+		http_requests_created{path="/bar"} 123.5
+		latency_count 3
+		latency_sum 1.5
+		latency_created 110.5
+`, `
+		http_requests_total{path="/bar",job="foo"} 0 123.5
+`)
+
+	// zero samples are added for series with updated _created series, e.g. after target restart
+	f(125000, `
+		http_requests_total{path="/"} 1
+		http_requests_created{path="/"} 124.2
+		http_requests_total{path="/bar"} 2
+		http_requests_created{path="/bar"} 123.5
+		latency_count 3
+		latency_sum 1.5
+		latency_created 200
+`, `
+		http_requests_total{path="/",job="foo"} 0 124.2
+`)
+}
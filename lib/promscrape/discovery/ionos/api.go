@@ -0,0 +1,159 @@
+package ionos
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discoveryutil"
+)
+
+var configMap = discoveryutil.NewConfigMap()
+
+type apiConfig struct {
+	client       *discoveryutil.Client
+	port         int
+	datacenterID string
+}
+
+func getAPIConfig(sdc *SDConfig, baseDir string) (*apiConfig, error) {
+	v, err := configMap.Get(sdc, func() (any, error) { return newAPIConfig(sdc, baseDir) })
+	if err != nil {
+		return nil, err
+	}
+	return v.(*apiConfig), nil
+}
+
+func newAPIConfig(sdc *SDConfig, baseDir string) (*apiConfig, error) {
+	if sdc.DatacenterID == "" {
+		return nil, fmt.Errorf("missing `datacenter_id` option")
+	}
+	hcc := &sdc.HTTPClientConfig
+	if hcc.BasicAuth == nil && hcc.Authorization == nil && hcc.BearerToken == nil && hcc.BearerTokenFile == "" && hcc.OAuth2 == nil {
+		return nil, fmt.Errorf("missing `basic_auth` or `authorization` option with IONOS Cloud credentials")
+	}
+	ac, err := hcc.NewConfig(baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse auth config: %w", err)
+	}
+	proxyAC, err := sdc.ProxyClientConfig.NewConfig(baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse proxy auth config: %w", err)
+	}
+
+	// See https://api.ionos.com/docs/cloud/v6/
+	apiServer := "https://api.ionos.com/cloudapi/v6"
+	client, err := discoveryutil.NewClient(apiServer, ac, sdc.ProxyURL, proxyAC, hcc)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create HTTP client for %q: %w", apiServer, err)
+	}
+
+	port := sdc.Port
+	if port == 0 {
+		port = 80
+	}
+	cfg := &apiConfig{
+		client:       client,
+		port:         port,
+		datacenterID: sdc.DatacenterID,
+	}
+	return cfg, nil
+}
+
+// serversLimit is the maximum number of servers to request from IONOS Cloud API per page.
+const serversLimit = 1000
+
+// getServers returns all the servers in cfg.datacenterID.
+//
+// See https://api.ionos.com/docs/cloud/v6/#tag/Servers/operation/datacentersServersGet
+func getServers(cfg *apiConfig) (*servers, error) {
+	var result *servers
+	offset := 0
+	for {
+		// depth=3 is needed for obtaining nics with their ips and volumes with their images.
+		path := fmt.Sprintf("/datacenters/%s/servers?depth=3&offset=%d&limit=%d", url.PathEscape(cfg.datacenterID), offset, serversLimit)
+		data, err := cfg.client.GetAPIResponse(path)
+		if err != nil {
+			return nil, fmt.Errorf("cannot fetch data from IONOS Cloud API at %q: %w", path, err)
+		}
+		var ss servers
+		if err := json.Unmarshal(data, &ss); err != nil {
+			return nil, fmt.Errorf("cannot parse IONOS Cloud API response from %q: %w", path, err)
+		}
+		if result == nil {
+			result = &ss
+		} else {
+			result.Items = append(result.Items, ss.Items...)
+		}
+		if ss.Links.Next == "" || len(ss.Items) == 0 {
+			return result, nil
+		}
+		offset += len(ss.Items)
+	}
+}
+
+// servers represents IONOS Cloud servers collection.
+//
+// See https://api.ionos.com/docs/cloud/v6/#tag/Servers/operation/datacentersServersGet
+type servers struct {
+	ID    string   `json:"id"`
+	Items []server `json:"items"`
+	Links struct {
+		Next string `json:"next"`
+	} `json:"_links"`
+}
+
+type server struct {
+	ID         string           `json:"id"`
+	Metadata   serverMetadata   `json:"metadata"`
+	Properties serverProperties `json:"properties"`
+	Entities   serverEntities   `json:"entities"`
+}
+
+type serverMetadata struct {
+	State string `json:"state"`
+}
+
+type serverProperties struct {
+	Name             string       `json:"name"`
+	Type             string       `json:"type"`
+	AvailabilityZone string       `json:"availabilityZone"`
+	CPUFamily        string       `json:"cpuFamily"`
+	VMState          string       `json:"vmState"`
+	BootCdrom        *resourceRef `json:"bootCdrom"`
+	BootVolume       *resourceRef `json:"bootVolume"`
+}
+
+type resourceRef struct {
+	ID string `json:"id"`
+}
+
+type serverEntities struct {
+	NICs    nics    `json:"nics"`
+	Volumes volumes `json:"volumes"`
+}
+
+type nics struct {
+	Items []nic `json:"items"`
+}
+
+type nic struct {
+	Properties nicProperties `json:"properties"`
+}
+
+type nicProperties struct {
+	Name string   `json:"name"`
+	IPs  []string `json:"ips"`
+}
+
+type volumes struct {
+	Items []volume `json:"items"`
+}
+
+type volume struct {
+	Properties volumeProperties `json:"properties"`
+}
+
+type volumeProperties struct {
+	Image string `json:"image"`
+}
@@ -0,0 +1,63 @@
+package ionos
+
+import (
+	"strings"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discoveryutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutil"
+)
+
+func getServerLabels(cfg *apiConfig) ([]*promutil.Labels, error) {
+	ss, err := getServers(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return appendServerLabels(nil, ss, cfg.port), nil
+}
+
+func appendServerLabels(ms []*promutil.Labels, ss *servers, port int) []*promutil.Labels {
+	for i := range ss.Items {
+		s := &ss.Items[i]
+		var ips []string
+		var nicNames []string
+		ipsByNICName := make(map[string][]string)
+		for _, n := range s.Entities.NICs.Items {
+			nicName := discoveryutil.SanitizeLabelName(n.Properties.Name)
+			if _, ok := ipsByNICName[nicName]; !ok {
+				nicNames = append(nicNames, nicName)
+			}
+			ips = append(ips, n.Properties.IPs...)
+			ipsByNICName[nicName] = append(ipsByNICName[nicName], n.Properties.IPs...)
+		}
+		if len(ips) == 0 {
+			// Servers without IP addresses cannot be scraped.
+			continue
+		}
+
+		m := promutil.NewLabels(16)
+		m.Add("__address__", discoveryutil.JoinHostPort(ips[0], port))
+		m.Add("__meta_ionos_server_availability_zone", s.Properties.AvailabilityZone)
+		m.Add("__meta_ionos_server_cpu_family", s.Properties.CPUFamily)
+		m.Add("__meta_ionos_server_id", s.ID)
+		m.Add("__meta_ionos_server_ip", ","+strings.Join(ips, ",")+",")
+		m.Add("__meta_ionos_server_lifecycle", s.Metadata.State)
+		m.Add("__meta_ionos_server_name", s.Properties.Name)
+		m.Add("__meta_ionos_server_servers_id", ss.ID)
+		m.Add("__meta_ionos_server_state", s.Properties.VMState)
+		m.Add("__meta_ionos_server_type", s.Properties.Type)
+		if s.Properties.BootCdrom != nil {
+			m.Add("__meta_ionos_server_boot_cdrom_id", s.Properties.BootCdrom.ID)
+		}
+		if s.Properties.BootVolume != nil {
+			m.Add("__meta_ionos_server_boot_volume_id", s.Properties.BootVolume.ID)
+		}
+		if vs := s.Entities.Volumes.Items; len(vs) > 0 && vs[0].Properties.Image != "" {
+			m.Add("__meta_ionos_server_boot_image_id", vs[0].Properties.Image)
+		}
+		for _, nicName := range nicNames {
+			m.Add("__meta_ionos_server_nic_ip_"+nicName, strings.Join(ipsByNICName[nicName], ","))
+		}
+		ms = append(ms, m)
+	}
+	return ms
+}
@@ -0,0 +1,188 @@
+package ionos
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promauth"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discoveryutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutil"
+)
+
+func TestNewAPIConfigFailure(t *testing.T) {
+	f := func(sdc *SDConfig) {
+		t.Helper()
+
+		if _, err := newAPIConfig(sdc, ""); err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+
+	// missing datacenter_id
+	f(&SDConfig{
+		HTTPClientConfig: promauth.HTTPClientConfig{
+			BasicAuth: &promauth.BasicAuthConfig{
+				Username: "user",
+				Password: promauth.NewSecret("pass"),
+			},
+		},
+	})
+
+	// missing credentials
+	f(&SDConfig{
+		DatacenterID: "8feda53f-15f0-447f-badf-ebe32dad2fc0",
+	})
+}
+
+func TestGetServerLabels(t *testing.T) {
+	responses := map[string]string{
+		"/datacenters/8feda53f-15f0-447f-badf-ebe32dad2fc0/servers?depth=3&offset=0&limit=1000": `{
+  "id": "8feda53f-15f0-447f-badf-ebe32dad2fc0/servers",
+  "type": "collection",
+  "items": [
+    {
+      "id": "d6bf44ee-f7e8-4e19-8716-96fdd18cc697",
+      "type": "server",
+      "metadata": {"state": "AVAILABLE"},
+      "properties": {
+        "name": "prometheus-2",
+        "cores": 2,
+        "ram": 4096,
+        "availabilityZone": "ZONE_1",
+        "vmState": "RUNNING",
+        "bootCdrom": null,
+        "bootVolume": {"id": "8e0a9c16-bdc9-4b0b-b5a3-a53e6a3cc3e5", "type": "volume"},
+        "cpuFamily": "INTEL_SKYLAKE",
+        "type": "ENTERPRISE"
+      },
+      "entities": {
+        "volumes": {
+          "items": [
+            {"id": "8e0a9c16-bdc9-4b0b-b5a3-a53e6a3cc3e5", "properties": {"name": "Ubuntu-20.04-LTS", "image": "ca9f39d1-7ecd-11ec-a4df-a2cc0d7c0ff8"}}
+          ]
+        },
+        "nics": {
+          "items": [
+            {"id": "3dc70fd4-1d6c-4dc3-ad27-a30c88e6b0e7", "properties": {"name": "metrics", "ips": ["85.215.243.177"]}},
+            {"id": "4e5ccbaf-1a29-49bc-b0b4-4b1b8b9b7b4a", "properties": {"name": "unnamed-nic", "ips": ["185.56.150.9", "185.56.150.10"]}}
+          ]
+        }
+      }
+    }
+  ],
+  "offset": 0,
+  "limit": 1000,
+  "_links": {
+    "next": "https://api.ionos.com/cloudapi/v6/datacenters/8feda53f-15f0-447f-badf-ebe32dad2fc0/servers?depth=3&offset=1&limit=1000"
+  }
+}`,
+		"/datacenters/8feda53f-15f0-447f-badf-ebe32dad2fc0/servers?depth=3&offset=1&limit=1000": `{
+  "id": "8feda53f-15f0-447f-badf-ebe32dad2fc0/servers",
+  "type": "collection",
+  "items": [
+    {
+      "id": "b501942c-4e08-43e6-8ec1-00e59c64e0e4",
+      "type": "server",
+      "metadata": {"state": "BUSY"},
+      "properties": {
+        "name": "prometheus-3",
+        "availabilityZone": "AUTO",
+        "vmState": "SHUTOFF",
+        "bootCdrom": {"id": "0e4d57f9-cd78-11e9-b88c-525400f64d8d", "type": "image"},
+        "bootVolume": null,
+        "cpuFamily": "AMD_OPTERON",
+        "type": "CUBE"
+      },
+      "entities": {
+        "volumes": {"items": []},
+        "nics": {
+          "items": [
+            {"id": "0f5a7b1c-2d3e-4f5a-8b9c-0d1e2f3a4b5c", "properties": {"name": "metrics", "ips": ["85.215.238.118"]}}
+          ]
+        }
+      }
+    },
+    {
+      "id": "523415e6-ff8c-4dc0-86d3-09c256039b30",
+      "type": "server",
+      "metadata": {"state": "AVAILABLE"},
+      "properties": {
+        "name": "prometheus-1",
+        "availabilityZone": "ZONE_1",
+        "vmState": "RUNNING",
+        "cpuFamily": "AMD_OPTERON",
+        "type": "ENTERPRISE"
+      },
+      "entities": {
+        "volumes": {"items": []},
+        "nics": {"items": []}
+      }
+    }
+  ],
+  "offset": 1,
+  "limit": 1000,
+  "_links": {}
+}`,
+	}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp, ok := responses[r.RequestURI]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, "unexpected request: %s", r.RequestURI)
+			return
+		}
+		_, _ = w.Write([]byte(resp))
+	}))
+	defer s.Close()
+
+	c, err := discoveryutil.NewClient(s.URL, nil, nil, nil, &promauth.HTTPClientConfig{})
+	if err != nil {
+		t.Fatalf("cannot create client: %s", err)
+	}
+	defer c.Stop()
+	cfg := &apiConfig{
+		client:       c,
+		port:         9100,
+		datacenterID: "8feda53f-15f0-447f-badf-ebe32dad2fc0",
+	}
+
+	labelss, err := getServerLabels(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expectedLabelss := []*promutil.Labels{
+		promutil.NewLabelsFromMap(map[string]string{
+			"__address__":                            "85.215.243.177:9100",
+			"__meta_ionos_server_availability_zone":  "ZONE_1",
+			"__meta_ionos_server_boot_image_id":      "ca9f39d1-7ecd-11ec-a4df-a2cc0d7c0ff8",
+			"__meta_ionos_server_boot_volume_id":     "8e0a9c16-bdc9-4b0b-b5a3-a53e6a3cc3e5",
+			"__meta_ionos_server_cpu_family":         "INTEL_SKYLAKE",
+			"__meta_ionos_server_id":                 "d6bf44ee-f7e8-4e19-8716-96fdd18cc697",
+			"__meta_ionos_server_ip":                 ",85.215.243.177,185.56.150.9,185.56.150.10,",
+			"__meta_ionos_server_lifecycle":          "AVAILABLE",
+			"__meta_ionos_server_name":               "prometheus-2",
+			"__meta_ionos_server_nic_ip_metrics":     "85.215.243.177",
+			"__meta_ionos_server_nic_ip_unnamed_nic": "185.56.150.9,185.56.150.10",
+			"__meta_ionos_server_servers_id":         "8feda53f-15f0-447f-badf-ebe32dad2fc0/servers",
+			"__meta_ionos_server_state":              "RUNNING",
+			"__meta_ionos_server_type":               "ENTERPRISE",
+		}),
+		promutil.NewLabelsFromMap(map[string]string{
+			"__address__":                           "85.215.238.118:9100",
+			"__meta_ionos_server_availability_zone": "AUTO",
+			"__meta_ionos_server_boot_cdrom_id":     "0e4d57f9-cd78-11e9-b88c-525400f64d8d",
+			"__meta_ionos_server_cpu_family":        "AMD_OPTERON",
+			"__meta_ionos_server_id":                "b501942c-4e08-43e6-8ec1-00e59c64e0e4",
+			"__meta_ionos_server_ip":                ",85.215.238.118,",
+			"__meta_ionos_server_lifecycle":         "BUSY",
+			"__meta_ionos_server_name":              "prometheus-3",
+			"__meta_ionos_server_nic_ip_metrics":    "85.215.238.118",
+			"__meta_ionos_server_servers_id":        "8feda53f-15f0-447f-badf-ebe32dad2fc0/servers",
+			"__meta_ionos_server_state":             "SHUTOFF",
+			"__meta_ionos_server_type":              "CUBE",
+		}),
+	}
+	discoveryutil.TestEqualLabelss(t, labelss, expectedLabelss)
+}
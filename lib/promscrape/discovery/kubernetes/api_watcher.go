@@ -11,6 +11,7 @@ import (
 	"net/url"
 	"os"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -210,6 +211,38 @@ func (aw *apiWatcher) getScrapeWorkObjects() []any {
 	return swos
 }
 
+// getTargetLabels returns target labels for all the objects watched by aw.
+func (aw *apiWatcher) getTargetLabels() []*promutil.Labels {
+	gw := aw.gw
+	gw.mu.Lock()
+	defer gw.mu.Unlock()
+
+	// Return the labels in a stable order, so they could be compared with the previously returned labels.
+	var uws []*urlWatcher
+	for _, uw := range gw.m {
+		_, ok := uw.aws[aw]
+		_, okPending := uw.awsPending[aw]
+		if ok || okPending {
+			uws = append(uws, uw)
+		}
+	}
+	sort.Slice(uws, func(i, j int) bool {
+		return uws[i].apiURL < uws[j].apiURL
+	})
+	var labelss []*promutil.Labels
+	for _, uw := range uws {
+		keys := make([]string, 0, len(uw.objectsByKey))
+		for key := range uw.objectsByKey {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			labelss = append(labelss, uw.objectsByKey[key].getTargetLabels(gw)...)
+		}
+	}
+	return labelss
+}
+
 // groupWatcher watches for Kubernetes objects on the given apiServer with the given namespaces,
 // selectors and attachNodeMetadata using the given client.
 type groupWatcher struct {
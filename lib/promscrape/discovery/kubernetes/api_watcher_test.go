@@ -964,6 +964,120 @@ func TestGetScrapeWorkObjects(t *testing.T) {
 	}
 }
 
+func TestSDConfigMustStartWithPersistedLabels(t *testing.T) {
+	podList := []byte(`{
+  "kind": "PodList",
+  "apiVersion": "v1",
+  "metadata": {
+    "resourceVersion": "72425"
+  },
+  "items": [
+{
+    "apiVersion": "v1",
+    "kind": "Pod",
+    "metadata": {
+        "name": "stack-name-1",
+        "namespace": "default"
+    },
+    "spec": {
+        "containers": [
+            {
+               "name": "generic-pod"
+            }
+        ]
+    },
+    "status": {
+        "podIP": "10.10.2.2",
+        "phase": "Running"
+    }
+}]}`)
+	listCh := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc(getAPIPath(getObjectTypeByRole("pod"), "", ""), func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("watch") != "" {
+			w.WriteHeader(200)
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+			return
+		}
+		// Block the initial list until the persisted labels are verified.
+		select {
+		case <-listCh:
+		case <-r.Context().Done():
+			return
+		}
+		w.WriteHeader(200)
+		_, _ = w.Write(podList)
+	})
+	testAPIServer := httptest.NewServer(mux)
+	defer func() {
+		// Close the watch connection, so the server could be closed.
+		testAPIServer.CloseClientConnections()
+		testAPIServer.Close()
+	}()
+
+	sdc := &SDConfig{
+		APIServer: testAPIServer.URL,
+		Role:      "pod",
+	}
+	swcFunc := func(metaLabels *promutil.Labels) any {
+		addr := metaLabels.Get("__address__")
+		return &addr
+	}
+	getAddrs := func() []string {
+		t.Helper()
+		swos, err := sdc.GetScrapeWorkObjects()
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		var addrs []string
+		for _, swo := range swos {
+			addrs = append(addrs, *swo.(*string))
+		}
+		return addrs
+	}
+
+	persistedLabels := []*promutil.Labels{
+		promutil.NewLabelsFromMap(map[string]string{
+			"__address__": "10.10.2.1",
+		}),
+	}
+	sdc.MustStartWithPersistedLabels("", swcFunc, persistedLabels)
+	defer sdc.MustStop()
+
+	// The persisted targets are returned until the initial list of objects is obtained.
+	if addrs := getAddrs(); !reflect.DeepEqual(addrs, []string{"10.10.2.1"}) {
+		t.Fatalf("unexpected addresses for persisted labels; got %q; want %q", addrs, []string{"10.10.2.1"})
+	}
+	if _, ok := sdc.GetTargetLabels(); ok {
+		t.Fatalf("GetTargetLabels must return false until the initial list of objects is obtained")
+	}
+
+	close(listCh)
+	deadline := time.Now().Add(5 * time.Second)
+	for !sdc.isStarted() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout when waiting for the initial list of objects")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The discovered targets are returned after the initial list of objects is obtained.
+	if addrs := getAddrs(); !reflect.DeepEqual(addrs, []string{"10.10.2.2"}) {
+		t.Fatalf("unexpected addresses for discovered objects; got %q; want %q", addrs, []string{"10.10.2.2"})
+	}
+	labelss, ok := sdc.GetTargetLabels()
+	if !ok {
+		t.Fatalf("GetTargetLabels must return true after the initial list of objects is obtained")
+	}
+	if len(labelss) != 1 {
+		t.Fatalf("unexpected number of target labels; got %d; want 1", len(labelss))
+	}
+	if addr := labelss[0].Get("__address__"); addr != "10.10.2.2" {
+		t.Fatalf("unexpected __address__ label; got %q; want %q", addr, "10.10.2.2")
+	}
+}
+
 type watchObjectBroadcast struct {
 	mu          sync.Mutex
 	subscribers []chan []byte
@@ -35,6 +35,13 @@ type SDConfig struct {
 
 	cfg      *apiConfig
 	startErr error
+
+	// startedCh is closed when the initial list of objects is obtained after MustStartWithPersistedLabels call.
+	startedCh chan struct{}
+
+	// persistedSWOs contains ScrapeWork objects for the labels passed to MustStartWithPersistedLabels.
+	// They are returned from GetScrapeWorkObjects until startedCh is closed.
+	persistedSWOs []any
 }
 
 func (sdc *SDConfig) role() string {
@@ -79,9 +86,23 @@ func (sdc *SDConfig) GetScrapeWorkObjects() ([]any, error) {
 	if sdc.cfg == nil {
 		return nil, sdc.startErr
 	}
+	if !sdc.isStarted() {
+		return sdc.persistedSWOs, nil
+	}
 	return sdc.cfg.aw.getScrapeWorkObjects(), nil
 }
 
+// GetTargetLabels returns target labels for all the objects discovered by sdc.
+//
+// false is returned if the initial list of objects isn't obtained yet.
+// This function must be called after MustStart call.
+func (sdc *SDConfig) GetTargetLabels() ([]*promutil.Labels, bool) {
+	if sdc.cfg == nil || !sdc.isStarted() {
+		return nil, false
+	}
+	return sdc.cfg.aw.getTargetLabels(), true
+}
+
 // MustStart initializes sdc before its usage.
 //
 // swcFunc is used for constructing ScrapeWork objects from the given metadata.
@@ -95,10 +116,45 @@ func (sdc *SDConfig) MustStart(baseDir string, swcFunc ScrapeWorkConstructorFunc
 	sdc.cfg = cfg
 }
 
+// MustStartWithPersistedLabels initializes sdc before its usage.
+//
+// Unlike MustStart, it obtains the initial list of objects in background, while GetScrapeWorkObjects
+// returns ScrapeWork objects constructed via swcFunc from persistedLabels until then.
+func (sdc *SDConfig) MustStartWithPersistedLabels(baseDir string, swcFunc ScrapeWorkConstructorFunc, persistedLabels []*promutil.Labels) {
+	cfg, err := newAPIConfig(sdc, baseDir, swcFunc)
+	if err != nil {
+		sdc.startErr = fmt.Errorf("cannot create API config for kubernetes: %w", err)
+		return
+	}
+	sdc.persistedSWOs = getScrapeWorkObjectsForLabels(swcFunc, persistedLabels)
+	sdc.startedCh = make(chan struct{})
+	sdc.cfg = cfg
+	go func() {
+		cfg.aw.mustStart()
+		close(sdc.startedCh)
+	}()
+}
+
+func (sdc *SDConfig) isStarted() bool {
+	if sdc.startedCh == nil {
+		return true
+	}
+	select {
+	case <-sdc.startedCh:
+		return true
+	default:
+		return false
+	}
+}
+
 // MustStop stops further usage for sdc.
 func (sdc *SDConfig) MustStop() {
 	if sdc.cfg != nil {
 		// sdc.cfg can be nil on MustStart error.
+		if sdc.startedCh != nil {
+			// Wait until the initial list of objects is obtained, since the apiWatcher cannot be stopped before that.
+			<-sdc.startedCh
+		}
 		sdc.cfg.aw.mustStop()
 	}
 }
@@ -0,0 +1,184 @@
+package linode
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discoveryutil"
+)
+
+var configMap = discoveryutil.NewConfigMap()
+
+type apiConfig struct {
+	client       *discoveryutil.Client
+	port         int
+	region       string
+	tagSeparator string
+}
+
+func getAPIConfig(sdc *SDConfig, baseDir string) (*apiConfig, error) {
+	v, err := configMap.Get(sdc, func() (any, error) { return newAPIConfig(sdc, baseDir) })
+	if err != nil {
+		return nil, err
+	}
+	return v.(*apiConfig), nil
+}
+
+func newAPIConfig(sdc *SDConfig, baseDir string) (*apiConfig, error) {
+	hcc := &sdc.HTTPClientConfig
+	if hcc.Authorization == nil && hcc.BearerToken == nil && hcc.BearerTokenFile == "" && hcc.OAuth2 == nil {
+		return nil, fmt.Errorf("missing `authorization` option with Linode API token")
+	}
+	ac, err := hcc.NewConfig(baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse auth config: %w", err)
+	}
+	proxyAC, err := sdc.ProxyClientConfig.NewConfig(baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse proxy auth config: %w", err)
+	}
+
+	// See https://techdocs.akamai.com/linode-api/reference/api
+	apiServer := "https://api.linode.com"
+	client, err := discoveryutil.NewClient(apiServer, ac, sdc.ProxyURL, proxyAC, hcc)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create HTTP client for %q: %w", apiServer, err)
+	}
+
+	port := sdc.Port
+	if port == 0 {
+		port = 80
+	}
+	tagSeparator := ","
+	if sdc.TagSeparator != nil {
+		tagSeparator = *sdc.TagSeparator
+	}
+	cfg := &apiConfig{
+		client:       client,
+		port:         port,
+		region:       sdc.Region,
+		tagSeparator: tagSeparator,
+	}
+	return cfg, nil
+}
+
+// pagedResponse is a generic response for Linode list APIs.
+//
+// See https://techdocs.akamai.com/linode-api/reference/pagination
+type pagedResponse[T any] struct {
+	Data  []T `json:"data"`
+	Page  int `json:"page"`
+	Pages int `json:"pages"`
+}
+
+// getAllPages returns all the items from the paginated Linode API at the given path.
+//
+// The optional filter is passed to the API via X-Filter header.
+// See https://techdocs.akamai.com/linode-api/reference/filtering-and-sorting
+func getAllPages[T any](cfg *apiConfig, path, filter string) ([]T, error) {
+	var modifyRequest discoveryutil.RequestCallback
+	if filter != "" {
+		modifyRequest = func(req *http.Request) {
+			req.Header.Set("X-Filter", filter)
+		}
+	}
+	var items []T
+	for page := 1; ; page++ {
+		pagePath := fmt.Sprintf("%s?page=%d&page_size=500", path, page)
+		data, err := cfg.client.GetAPIResponseWithReqParams(pagePath, modifyRequest)
+		if err != nil {
+			return nil, fmt.Errorf("cannot fetch data from Linode API at %q: %w", pagePath, err)
+		}
+		var resp pagedResponse[T]
+		if err := json.Unmarshal(data, &resp); err != nil {
+			return nil, fmt.Errorf("cannot parse Linode API response from %q: %w", pagePath, err)
+		}
+		items = append(items, resp.Data...)
+		if resp.Page >= resp.Pages {
+			return items, nil
+		}
+	}
+}
+
+// instance represents Linode instance.
+//
+// See https://techdocs.akamai.com/linode-api/reference/get-linode-instances
+type instance struct {
+	ID         int            `json:"id"`
+	Label      string         `json:"label"`
+	Group      string         `json:"group"`
+	Status     string         `json:"status"`
+	Type       string         `json:"type"`
+	Region     string         `json:"region"`
+	Image      string         `json:"image"`
+	Hypervisor string         `json:"hypervisor"`
+	IPv4       []string       `json:"ipv4"`
+	IPv6       string         `json:"ipv6"`
+	Specs      instanceSpecs  `json:"specs"`
+	Backups    instanceBackup `json:"backups"`
+	Tags       []string       `json:"tags"`
+}
+
+type instanceSpecs struct {
+	Disk     int64 `json:"disk"`
+	Memory   int64 `json:"memory"`
+	VCPUs    int   `json:"vcpus"`
+	GPUs     int   `json:"gpus"`
+	Transfer int64 `json:"transfer"`
+}
+
+type instanceBackup struct {
+	Enabled bool `json:"enabled"`
+}
+
+// ipAddress represents Linode IP address.
+//
+// See https://techdocs.akamai.com/linode-api/reference/get-ips
+type ipAddress struct {
+	Address  string `json:"address"`
+	Type     string `json:"type"`
+	Public   bool   `json:"public"`
+	RDNS     string `json:"rdns"`
+	LinodeID int    `json:"linode_id"`
+}
+
+// ipv6Range represents Linode IPv6 range.
+//
+// See https://techdocs.akamai.com/linode-api/reference/get-ipv6-ranges
+type ipv6Range struct {
+	Range       string `json:"range"`
+	Prefix      int    `json:"prefix"`
+	RouteTarget string `json:"route_target"`
+}
+
+func getInstances(cfg *apiConfig) ([]instance, error) {
+	filter := ""
+	if cfg.region != "" {
+		data, err := json.Marshal(map[string]string{
+			"region": cfg.region,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("cannot marshal region filter: %w", err)
+		}
+		filter = string(data)
+	}
+	return getAllPages[instance](cfg, "/v4/linode/instances", filter)
+}
+
+func getIPAddresses(cfg *apiConfig) ([]ipAddress, error) {
+	return getAllPages[ipAddress](cfg, "/v4/networking/ips", "")
+}
+
+func getIPv6Ranges(cfg *apiConfig) ([]ipv6Range, error) {
+	return getAllPages[ipv6Range](cfg, "/v4/networking/ipv6/ranges", "")
+}
+
+// getRouteTarget returns the address from the Linode instance ipv6 field, which is used as route_target in IPv6 ranges.
+func getRouteTarget(ipv6 string) string {
+	if n := strings.IndexByte(ipv6, '/'); n >= 0 {
+		return ipv6[:n]
+	}
+	return ipv6
+}
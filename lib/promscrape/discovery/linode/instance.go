@@ -0,0 +1,121 @@
+package linode
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discoveryutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutil"
+)
+
+func getInstanceLabels(cfg *apiConfig) ([]*promutil.Labels, error) {
+	instances, err := getInstances(cfg)
+	if err != nil {
+		return nil, err
+	}
+	ips, err := getIPAddresses(cfg)
+	if err != nil {
+		return nil, err
+	}
+	ranges, err := getIPv6Ranges(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return appendInstanceLabels(nil, instances, ips, ranges, cfg.port, cfg.tagSeparator), nil
+}
+
+func appendInstanceLabels(ms []*promutil.Labels, instances []instance, ips []ipAddress, ranges []ipv6Range, port int, tagSeparator string) []*promutil.Labels {
+	ipsByInstance := make(map[int][]ipAddress)
+	for _, ip := range ips {
+		ipsByInstance[ip.LinodeID] = append(ipsByInstance[ip.LinodeID], ip)
+	}
+	for _, inst := range instances {
+		if len(inst.IPv4) == 0 {
+			continue
+		}
+
+		var privateIPv4, publicIPv4, publicIPv6 string
+		var privateIPv4RDNS, publicIPv4RDNS, publicIPv6RDNS string
+		var extraIPs []string
+		for _, ip := range ipsByInstance[inst.ID] {
+			switch ip.Type {
+			case "ipv4":
+				if ip.Public {
+					if publicIPv4 == "" {
+						publicIPv4 = ip.Address
+						publicIPv4RDNS = ip.RDNS
+					} else {
+						extraIPs = append(extraIPs, ip.Address)
+					}
+				} else {
+					if privateIPv4 == "" {
+						privateIPv4 = ip.Address
+						privateIPv4RDNS = ip.RDNS
+					} else {
+						extraIPs = append(extraIPs, ip.Address)
+					}
+				}
+			case "ipv6":
+				if ip.Public && publicIPv6 == "" {
+					publicIPv6 = ip.Address
+					publicIPv6RDNS = ip.RDNS
+				}
+			}
+		}
+
+		var ipv6Ranges []string
+		routeTarget := getRouteTarget(inst.IPv6)
+		for _, r := range ranges {
+			if r.RouteTarget == routeTarget {
+				ipv6Ranges = append(ipv6Ranges, fmt.Sprintf("%s/%d", r.Range, r.Prefix))
+			}
+		}
+
+		backups := "disabled"
+		if inst.Backups.Enabled {
+			backups = "enabled"
+		}
+
+		m := promutil.NewLabels(28)
+		m.Add("__address__", discoveryutil.JoinHostPort(inst.IPv4[0], port))
+		m.Add("__meta_linode_instance_id", strconv.Itoa(inst.ID))
+		m.Add("__meta_linode_instance_label", inst.Label)
+		m.Add("__meta_linode_image", inst.Image)
+		m.Add("__meta_linode_private_ipv4", privateIPv4)
+		m.Add("__meta_linode_public_ipv4", publicIPv4)
+		m.Add("__meta_linode_public_ipv6", publicIPv6)
+		m.Add("__meta_linode_private_ipv4_rdns", privateIPv4RDNS)
+		m.Add("__meta_linode_public_ipv4_rdns", publicIPv4RDNS)
+		m.Add("__meta_linode_public_ipv6_rdns", publicIPv6RDNS)
+		m.Add("__meta_linode_region", inst.Region)
+		m.Add("__meta_linode_type", inst.Type)
+		m.Add("__meta_linode_status", inst.Status)
+		m.Add("__meta_linode_group", inst.Group)
+		m.Add("__meta_linode_gpus", strconv.Itoa(inst.Specs.GPUs))
+		m.Add("__meta_linode_hypervisor", inst.Hypervisor)
+		m.Add("__meta_linode_backups", backups)
+		// Linode API returns disk, memory and transfer sizes in MiB.
+		m.Add("__meta_linode_specs_disk_bytes", strconv.FormatInt(inst.Specs.Disk<<20, 10))
+		m.Add("__meta_linode_specs_memory_bytes", strconv.FormatInt(inst.Specs.Memory<<20, 10))
+		m.Add("__meta_linode_specs_vcpus", strconv.Itoa(inst.Specs.VCPUs))
+		m.Add("__meta_linode_specs_transfer_bytes", strconv.FormatInt(inst.Specs.Transfer<<20, 10))
+		if len(inst.Tags) > 0 {
+			m.Add("__meta_linode_tags", joinWithSeparator(inst.Tags, tagSeparator))
+		}
+		if len(extraIPs) > 0 {
+			m.Add("__meta_linode_extra_ips", joinWithSeparator(extraIPs, tagSeparator))
+		}
+		if len(ipv6Ranges) > 0 {
+			m.Add("__meta_linode_ipv6_ranges", joinWithSeparator(ipv6Ranges, tagSeparator))
+		}
+		ms = append(ms, m)
+	}
+	return ms
+}
+
+// joinWithSeparator joins a with the given separator and surrounds the result with the separator,
+// so relabeling rules don't need to take into account the position of the item in the list.
+func joinWithSeparator(a []string, separator string) string {
+	return separator + strings.Join(a, separator) + separator
+}
@@ -0,0 +1,190 @@
+package linode
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promauth"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discoveryutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutil"
+)
+
+func TestNewAPIConfigFailure(t *testing.T) {
+	// missing authorization
+	sdc := &SDConfig{}
+	if _, err := newAPIConfig(sdc, ""); err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+}
+
+func TestGetInstanceLabels(t *testing.T) {
+	responses := map[string]string{
+		"/v4/linode/instances?page=1&page_size=500": `{
+  "data": [
+    {
+      "id": 26838044,
+      "label": "prometheus-linode-sd-exporter-1",
+      "group": "",
+      "status": "running",
+      "created": "2021-05-12T04:23:44",
+      "updated": "2021-05-12T04:23:44",
+      "type": "g6-standard-2",
+      "ipv4": ["45.33.82.151", "96.126.108.376", "192.168.170.51", "192.168.201.25"],
+      "ipv6": "2600:3c03::f03c:92ff:fe1a:1382/128",
+      "image": "linode/arch",
+      "region": "us-east",
+      "specs": {"disk": 81920, "memory": 4096, "vcpus": 2, "gpus": 0, "transfer": 4000},
+      "alerts": {"cpu": 180, "network_in": 10, "network_out": 10, "transfer_quota": 80, "io": 10000},
+      "backups": {"enabled": false, "schedule": {"day": null, "window": null}, "last_successful": null},
+      "hypervisor": "kvm",
+      "watchdog_enabled": true,
+      "tags": ["monitoring"]
+    }
+  ],
+  "page": 1,
+  "pages": 2,
+  "results": 2
+}`,
+		"/v4/linode/instances?page=2&page_size=500": `{
+  "data": [
+    {
+      "id": 26837992,
+      "label": "prometheus-linode-sd-exporter-4",
+      "group": "",
+      "status": "running",
+      "type": "g6-standard-2",
+      "ipv4": ["66.228.47.103", "172.104.18.104", "192.168.148.94"],
+      "ipv6": "2600:3c03::f03c:92ff:fe1a:fb4c/128",
+      "image": "linode/ubuntu20.04",
+      "region": "us-east",
+      "specs": {"disk": 81920, "memory": 4096, "vcpus": 2, "gpus": 1, "transfer": 4000},
+      "backups": {"enabled": true},
+      "hypervisor": "kvm",
+      "tags": []
+    },
+    {
+      "id": 42,
+      "label": "without-ipv4",
+      "status": "provisioning",
+      "ipv4": [],
+      "ipv6": null
+    }
+  ],
+  "page": 2,
+  "pages": 2,
+  "results": 3
+}`,
+		"/v4/networking/ips?page=1&page_size=500": `{
+  "data": [
+    {"address": "45.33.82.151", "gateway": "45.33.82.1", "subnet_mask": "255.255.255.0", "prefix": 24, "type": "ipv4", "public": true, "rdns": "li1028-151.members.linode.com", "linode_id": 26838044, "region": "us-east"},
+    {"address": "96.126.108.376", "gateway": "96.126.108.1", "subnet_mask": "255.255.255.0", "prefix": 24, "type": "ipv4", "public": true, "rdns": "li567-376.members.linode.com", "linode_id": 26838044, "region": "us-east"},
+    {"address": "192.168.170.51", "gateway": null, "subnet_mask": "255.255.128.0", "prefix": 17, "type": "ipv4", "public": false, "rdns": null, "linode_id": 26838044, "region": "us-east"},
+    {"address": "192.168.201.25", "gateway": null, "subnet_mask": "255.255.128.0", "prefix": 17, "type": "ipv4", "public": false, "rdns": null, "linode_id": 26838044, "region": "us-east"},
+    {"address": "2600:3c03::f03c:92ff:fe1a:1382", "gateway": "fe80::1", "subnet_mask": "ffff:ffff:ffff:ffff::", "prefix": 64, "type": "ipv6", "public": true, "rdns": null, "linode_id": 26838044, "region": "us-east"},
+    {"address": "66.228.47.103", "gateway": "66.228.47.1", "subnet_mask": "255.255.255.0", "prefix": 24, "type": "ipv4", "public": true, "rdns": "li328-103.members.linode.com", "linode_id": 26837992, "region": "us-east"},
+    {"address": "192.168.148.94", "gateway": null, "subnet_mask": "255.255.128.0", "prefix": 17, "type": "ipv4", "public": false, "rdns": null, "linode_id": 26837992, "region": "us-east"},
+    {"address": "2600:3c03::f03c:92ff:fe1a:fb4c", "gateway": "fe80::1", "subnet_mask": "ffff:ffff:ffff:ffff::", "prefix": 64, "type": "ipv6", "public": true, "rdns": "example.com", "linode_id": 26837992, "region": "us-east"}
+  ],
+  "page": 1,
+  "pages": 1,
+  "results": 8
+}`,
+		"/v4/networking/ipv6/ranges?page=1&page_size=500": `{
+  "data": [
+    {"range": "2600:3c03:e000:123::", "prefix": 64, "region": "us-east", "route_target": "2600:3c03::f03c:92ff:fe1a:1382"}
+  ],
+  "page": 1,
+  "pages": 1,
+  "results": 1
+}`,
+	}
+	var filterHeader string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v4/linode/instances" {
+			filterHeader = r.Header.Get("X-Filter")
+		}
+		resp, ok := responses[r.RequestURI]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, "unexpected request: %s", r.RequestURI)
+			return
+		}
+		_, _ = w.Write([]byte(resp))
+	}))
+	defer s.Close()
+
+	c, err := discoveryutil.NewClient(s.URL, nil, nil, nil, &promauth.HTTPClientConfig{})
+	if err != nil {
+		t.Fatalf("cannot create client: %s", err)
+	}
+	defer c.Stop()
+	cfg := &apiConfig{
+		client:       c,
+		port:         9100,
+		region:       "us-east",
+		tagSeparator: ",",
+	}
+
+	labelss, err := getInstanceLabels(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if filterHeader != `{"region":"us-east"}` {
+		t.Fatalf("unexpected X-Filter header; got %q; want %q", filterHeader, `{"region":"us-east"}`)
+	}
+
+	expectedLabelss := []*promutil.Labels{
+		promutil.NewLabelsFromMap(map[string]string{
+			"__address__":                        "45.33.82.151:9100",
+			"__meta_linode_instance_id":          "26838044",
+			"__meta_linode_instance_label":       "prometheus-linode-sd-exporter-1",
+			"__meta_linode_image":                "linode/arch",
+			"__meta_linode_private_ipv4":         "192.168.170.51",
+			"__meta_linode_public_ipv4":          "45.33.82.151",
+			"__meta_linode_public_ipv6":          "2600:3c03::f03c:92ff:fe1a:1382",
+			"__meta_linode_private_ipv4_rdns":    "",
+			"__meta_linode_public_ipv4_rdns":     "li1028-151.members.linode.com",
+			"__meta_linode_public_ipv6_rdns":     "",
+			"__meta_linode_region":               "us-east",
+			"__meta_linode_type":                 "g6-standard-2",
+			"__meta_linode_status":               "running",
+			"__meta_linode_group":                "",
+			"__meta_linode_gpus":                 "0",
+			"__meta_linode_hypervisor":           "kvm",
+			"__meta_linode_backups":              "disabled",
+			"__meta_linode_specs_disk_bytes":     "85899345920",
+			"__meta_linode_specs_memory_bytes":   "4294967296",
+			"__meta_linode_specs_vcpus":          "2",
+			"__meta_linode_specs_transfer_bytes": "4194304000",
+			"__meta_linode_tags":                 ",monitoring,",
+			"__meta_linode_extra_ips":            ",96.126.108.376,192.168.201.25,",
+			"__meta_linode_ipv6_ranges":          ",2600:3c03:e000:123::/64,",
+		}),
+		promutil.NewLabelsFromMap(map[string]string{
+			"__address__":                        "66.228.47.103:9100",
+			"__meta_linode_instance_id":          "26837992",
+			"__meta_linode_instance_label":       "prometheus-linode-sd-exporter-4",
+			"__meta_linode_image":                "linode/ubuntu20.04",
+			"__meta_linode_private_ipv4":         "192.168.148.94",
+			"__meta_linode_public_ipv4":          "66.228.47.103",
+			"__meta_linode_public_ipv6":          "2600:3c03::f03c:92ff:fe1a:fb4c",
+			"__meta_linode_private_ipv4_rdns":    "",
+			"__meta_linode_public_ipv4_rdns":     "li328-103.members.linode.com",
+			"__meta_linode_public_ipv6_rdns":     "example.com",
+			"__meta_linode_region":               "us-east",
+			"__meta_linode_type":                 "g6-standard-2",
+			"__meta_linode_status":               "running",
+			"__meta_linode_group":                "",
+			"__meta_linode_gpus":                 "1",
+			"__meta_linode_hypervisor":           "kvm",
+			"__meta_linode_backups":              "enabled",
+			"__meta_linode_specs_disk_bytes":     "85899345920",
+			"__meta_linode_specs_memory_bytes":   "4294967296",
+			"__meta_linode_specs_vcpus":          "2",
+			"__meta_linode_specs_transfer_bytes": "4194304000",
+		}),
+	}
+	discoveryutil.TestEqualLabelss(t, labelss, expectedLabelss)
+}
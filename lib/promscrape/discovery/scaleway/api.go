@@ -0,0 +1,160 @@
+package scaleway
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs/fscore"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discoveryutil"
+)
+
+var configMap = discoveryutil.NewConfigMap()
+
+// perPage is the number of items to request per page from Scaleway list APIs.
+const perPage = 100
+
+type apiConfig struct {
+	client *discoveryutil.Client
+	port   int
+	zone   string
+
+	// listQueryParams contains query args for filtering servers in list APIs apart of project id.
+	listQueryParams url.Values
+	projectID       string
+
+	// getSecretKey returns the secret key for Scaleway API.
+	getSecretKey func() (string, error)
+}
+
+func getAPIConfig(sdc *SDConfig, baseDir string) (*apiConfig, error) {
+	v, err := configMap.Get(sdc, func() (any, error) { return newAPIConfig(sdc, baseDir) })
+	if err != nil {
+		return nil, err
+	}
+	return v.(*apiConfig), nil
+}
+
+func newAPIConfig(sdc *SDConfig, baseDir string) (*apiConfig, error) {
+	switch sdc.Role {
+	case "instance", "baremetal":
+	default:
+		return nil, fmt.Errorf("unexpected `role`: %q; must be one of `instance` or `baremetal`", sdc.Role)
+	}
+	if sdc.ProjectID == "" {
+		return nil, fmt.Errorf("missing `project_id` option")
+	}
+	if sdc.AccessKey == "" {
+		return nil, fmt.Errorf("missing `access_key` option")
+	}
+	getSecretKey, err := newSecretKeyGetter(sdc, baseDir)
+	if err != nil {
+		return nil, err
+	}
+
+	ac, err := sdc.HTTPClientConfig.NewConfig(baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse auth config: %w", err)
+	}
+	proxyAC, err := sdc.ProxyClientConfig.NewConfig(baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse proxy auth config: %w", err)
+	}
+	apiServer := sdc.APIURL
+	if apiServer == "" {
+		apiServer = "https://api.scaleway.com"
+	}
+	apiServer = strings.TrimSuffix(apiServer, "/")
+	client, err := discoveryutil.NewClient(apiServer, ac, sdc.ProxyURL, proxyAC, &sdc.HTTPClientConfig)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create HTTP client for %q: %w", apiServer, err)
+	}
+
+	zone := sdc.Zone
+	if zone == "" {
+		zone = "fr-par-1"
+	}
+	port := sdc.Port
+	if port == 0 {
+		port = 80
+	}
+	qp := url.Values{}
+	if sdc.NameFilter != "" {
+		qp.Set("name", sdc.NameFilter)
+	}
+	if len(sdc.TagsFilter) > 0 {
+		qp.Set("tags", strings.Join(sdc.TagsFilter, ","))
+	}
+	cfg := &apiConfig{
+		client:          client,
+		port:            port,
+		zone:            zone,
+		listQueryParams: qp,
+		projectID:       sdc.ProjectID,
+		getSecretKey:    getSecretKey,
+	}
+	return cfg, nil
+}
+
+func newSecretKeyGetter(sdc *SDConfig, baseDir string) (func() (string, error), error) {
+	if sdc.SecretKey != nil && sdc.SecretKeyFile != "" {
+		return nil, fmt.Errorf("both `secret_key` and `secret_key_file` are set; please specify only one")
+	}
+	if sdc.SecretKey != nil {
+		secretKey := sdc.SecretKey.S
+		return func() (string, error) {
+			return secretKey, nil
+		}, nil
+	}
+	if sdc.SecretKeyFile == "" {
+		return nil, fmt.Errorf("missing `secret_key` or `secret_key_file` option")
+	}
+	path := fscore.GetFilepath(baseDir, sdc.SecretKeyFile)
+	return func() (string, error) {
+		secretKey, err := fscore.ReadPasswordFromFileOrHTTP(path)
+		if err != nil {
+			return "", fmt.Errorf("cannot read `secret_key_file`=%q: %w", path, err)
+		}
+		return secretKey, nil
+	}, nil
+}
+
+// getAPIResponse returns response from Scaleway API at the given path.
+func (cfg *apiConfig) getAPIResponse(path string) ([]byte, error) {
+	secretKey, err := cfg.getSecretKey()
+	if err != nil {
+		return nil, err
+	}
+	return cfg.client.GetAPIResponseWithReqParams(path, func(req *http.Request) {
+		// See https://www.scaleway.com/en/developers/api/#authentication
+		req.Header.Set("X-Auth-Token", secretKey)
+	})
+}
+
+// getListPath returns path for the given page at Scaleway list API with the given apiPath.
+//
+// projectArg is the name of query arg for project id, since it differs among Scaleway APIs.
+func (cfg *apiConfig) getListPath(apiPath, projectArg string, page int) string {
+	qp := url.Values{}
+	for k, vs := range cfg.listQueryParams {
+		qp[k] = vs
+	}
+	qp.Set(projectArg, cfg.projectID)
+	qp.Set("page", fmt.Sprintf("%d", page))
+	qp.Set("per_page", fmt.Sprintf("%d", perPage))
+	return apiPath + "?" + qp.Encode()
+}
+
+// getRegion returns region for the given Scaleway zone, e.g. fr-par for fr-par-1.
+func getRegion(zone string) string {
+	n := strings.LastIndexByte(zone, '-')
+	if n < 0 {
+		return zone
+	}
+	return zone[:n]
+}
+
+func joinStrings(a []string) string {
+	return "," + strings.Join(a, ",") + ","
+}
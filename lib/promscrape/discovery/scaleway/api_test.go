@@ -0,0 +1,110 @@
+package scaleway
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promauth"
+)
+
+// newMockAPIConfig returns apiConfig for the mock Scaleway API server, which returns the given responses for the given request URIs.
+func newMockAPIConfig(t *testing.T, responses map[string]string) *apiConfig {
+	t.Helper()
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token := r.Header.Get("X-Auth-Token"); token != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprintf(w, "unexpected X-Auth-Token: %q", token)
+			return
+		}
+		resp, ok := responses[r.RequestURI]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, "unexpected request: %s", r.RequestURI)
+			return
+		}
+		_, _ = w.Write([]byte(resp))
+	}))
+	t.Cleanup(s.Close)
+
+	sdc := &SDConfig{
+		Role:       "instance",
+		ProjectID:  "project-id",
+		AccessKey:  "access",
+		SecretKey:  promauth.NewSecret("secret"),
+		APIURL:     s.URL,
+		TagsFilter: []string{"foo", "bar"},
+		Port:       9100,
+	}
+	cfg, err := newAPIConfig(sdc, "")
+	if err != nil {
+		t.Fatalf("cannot create api config: %s", err)
+	}
+	t.Cleanup(cfg.client.Stop)
+	return cfg
+}
+
+func TestNewAPIConfigFailure(t *testing.T) {
+	f := func(sdc *SDConfig) {
+		t.Helper()
+
+		if _, err := newAPIConfig(sdc, ""); err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+
+	// unsupported role
+	f(&SDConfig{
+		Role:      "foo",
+		ProjectID: "project-id",
+		AccessKey: "access",
+		SecretKey: promauth.NewSecret("secret"),
+	})
+
+	// missing project_id
+	f(&SDConfig{
+		Role:      "instance",
+		AccessKey: "access",
+		SecretKey: promauth.NewSecret("secret"),
+	})
+
+	// missing access_key
+	f(&SDConfig{
+		Role:      "instance",
+		ProjectID: "project-id",
+		SecretKey: promauth.NewSecret("secret"),
+	})
+
+	// missing secret_key
+	f(&SDConfig{
+		Role:      "baremetal",
+		ProjectID: "project-id",
+		AccessKey: "access",
+	})
+
+	// both secret_key and secret_key_file are set
+	f(&SDConfig{
+		Role:          "baremetal",
+		ProjectID:     "project-id",
+		AccessKey:     "access",
+		SecretKey:     promauth.NewSecret("secret"),
+		SecretKeyFile: "/path/to/secret",
+	})
+}
+
+func TestGetRegion(t *testing.T) {
+	f := func(zone, regionExpected string) {
+		t.Helper()
+
+		region := getRegion(zone)
+		if region != regionExpected {
+			t.Fatalf("unexpected region for zone %q; got %q; want %q", zone, region, regionExpected)
+		}
+	}
+
+	f("fr-par-1", "fr-par")
+	f("nl-ams-3", "nl-ams")
+	f("foo", "foo")
+}
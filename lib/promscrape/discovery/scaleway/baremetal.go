@@ -0,0 +1,156 @@
+package scaleway
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discoveryutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutil"
+)
+
+// baremetalServersResponse is the response from Scaleway Elastic Metal API for listing servers.
+//
+// See https://www.scaleway.com/en/developers/api/elastic-metal/#path-elastic-metal-servers-list-elastic-metal-servers-for-an-organization
+type baremetalServersResponse struct {
+	Servers []baremetalServer `json:"servers"`
+}
+
+type baremetalServer struct {
+	ID        string            `json:"id"`
+	Name      string            `json:"name"`
+	ProjectID string            `json:"project_id"`
+	Status    string            `json:"status"`
+	OfferName string            `json:"offer_name"`
+	Zone      string            `json:"zone"`
+	Tags      []string          `json:"tags"`
+	IPs       []baremetalIP     `json:"ips"`
+	Install   *baremetalInstall `json:"install"`
+}
+
+type baremetalIP struct {
+	Address string `json:"address"`
+
+	// Version is either IPv4 or IPv6.
+	Version string `json:"version"`
+}
+
+type baremetalInstall struct {
+	OSID string `json:"os_id"`
+}
+
+// baremetalOS is the response from Scaleway Elastic Metal API for the given OS.
+//
+// See https://www.scaleway.com/en/developers/api/elastic-metal/#path-os-get-an-os-with-an-id
+type baremetalOS struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+func getBaremetalServers(cfg *apiConfig) ([]baremetalServer, error) {
+	apiPath := fmt.Sprintf("/baremetal/v1/zones/%s/servers", cfg.zone)
+	var servers []baremetalServer
+	for page := 1; ; page++ {
+		path := cfg.getListPath(apiPath, "project_id", page)
+		data, err := cfg.getAPIResponse(path)
+		if err != nil {
+			return nil, fmt.Errorf("cannot obtain baremetal servers from %q: %w", path, err)
+		}
+		var resp baremetalServersResponse
+		if err := json.Unmarshal(data, &resp); err != nil {
+			return nil, fmt.Errorf("cannot parse baremetal servers response from %q: %w", path, err)
+		}
+		servers = append(servers, resp.Servers...)
+		if len(resp.Servers) < perPage {
+			return servers, nil
+		}
+	}
+}
+
+func getBaremetalOS(cfg *apiConfig, osID string) (*baremetalOS, error) {
+	path := fmt.Sprintf("/baremetal/v1/zones/%s/os/%s", cfg.zone, osID)
+	data, err := cfg.getAPIResponse(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot obtain baremetal os from %q: %w", path, err)
+	}
+	var os baremetalOS
+	if err := json.Unmarshal(data, &os); err != nil {
+		return nil, fmt.Errorf("cannot parse baremetal os response from %q: %w", path, err)
+	}
+	return &os, nil
+}
+
+func getBaremetalLabels(cfg *apiConfig) ([]*promutil.Labels, error) {
+	servers, err := getBaremetalServers(cfg)
+	if err != nil {
+		return nil, err
+	}
+	// Servers usually share a few OS images, so cache them in order to reduce the number of API calls.
+	osByID := make(map[string]*baremetalOS)
+	for _, server := range servers {
+		if server.Install == nil || server.Install.OSID == "" {
+			continue
+		}
+		osID := server.Install.OSID
+		if _, ok := osByID[osID]; ok {
+			continue
+		}
+		os, err := getBaremetalOS(cfg, osID)
+		if err != nil {
+			return nil, err
+		}
+		osByID[osID] = os
+	}
+	return appendBaremetalLabels(nil, servers, osByID, cfg.port), nil
+}
+
+func appendBaremetalLabels(ms []*promutil.Labels, servers []baremetalServer, osByID map[string]*baremetalOS, port int) []*promutil.Labels {
+	for _, server := range servers {
+		m := promutil.NewLabels(14)
+		m.Add("__meta_scaleway_baremetal_id", server.ID)
+		m.Add("__meta_scaleway_baremetal_name", server.Name)
+		m.Add("__meta_scaleway_baremetal_project_id", server.ProjectID)
+		m.Add("__meta_scaleway_baremetal_status", server.Status)
+		m.Add("__meta_scaleway_baremetal_type", server.OfferName)
+		m.Add("__meta_scaleway_baremetal_zone", server.Zone)
+		if server.Install != nil {
+			if os := osByID[server.Install.OSID]; os != nil {
+				m.Add("__meta_scaleway_baremetal_os_name", os.Name)
+				m.Add("__meta_scaleway_baremetal_os_version", os.Version)
+			}
+		}
+		if len(server.Tags) > 0 {
+			m.Add("__meta_scaleway_baremetal_tags", joinStrings(server.Tags))
+		}
+
+		// IPv4 address is preferred over IPv6 address.
+		var publicIPv4, publicIPv6 string
+		for _, ip := range server.IPs {
+			switch ip.Version {
+			case "IPv4":
+				if publicIPv4 == "" {
+					publicIPv4 = ip.Address
+				}
+			case "IPv6":
+				if publicIPv6 == "" {
+					publicIPv6 = ip.Address
+				}
+			}
+		}
+		addr := ""
+		if publicIPv6 != "" {
+			m.Add("__meta_scaleway_baremetal_public_ipv6", publicIPv6)
+			addr = publicIPv6
+		}
+		if publicIPv4 != "" {
+			m.Add("__meta_scaleway_baremetal_public_ipv4", publicIPv4)
+			addr = publicIPv4
+		}
+		if addr == "" {
+			// Skip the server without IP addresses, since it cannot be scraped.
+			continue
+		}
+		m.Add("__address__", discoveryutil.JoinHostPort(addr, port))
+		ms = append(ms, m)
+	}
+	return ms
+}
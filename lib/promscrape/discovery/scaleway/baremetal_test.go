@@ -0,0 +1,68 @@
+package scaleway
+
+import (
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discoveryutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutil"
+)
+
+func TestGetBaremetalLabels(t *testing.T) {
+	cfg := newMockAPIConfig(t, map[string]string{
+		"/baremetal/v1/zones/fr-par-1/servers?page=1&per_page=100&project_id=project-id&tags=foo%2Cbar": `{
+  "total_count": 1,
+  "servers": [
+    {
+      "id": "5a33b4ab-2b5e-4ff4-8da6-27ff4d2bb1fb",
+      "organization_id": "cb334986-b054-4725-9d3a-40850fdc6015",
+      "project_id": "cb334986-b054-4725-9d3a-40850fdc6015",
+      "name": "scw-nervous-shirley",
+      "description": "",
+      "status": "ready",
+      "offer_id": "bd757b8d-3d32-4d2c-8cc8-9ef0b1bd3b5d",
+      "offer_name": "EM-B112X-SSD",
+      "tags": ["foo", "bar"],
+      "ips": [
+        {"id": "67fd5ff4-5e4a-4b4c-8d1e-ab3bdc54f4a3", "address": "2001:bc8:1640:1568:dc00:ff:fe21:91b", "reverse": "", "version": "IPv6", "reverse_status": "active"},
+        {"id": "bc4ff6ba-5e4a-4b4c-8d1e-ab3bdc54f4a3", "address": "51.158.183.115", "reverse": "", "version": "IPv4", "reverse_status": "active"}
+      ],
+      "domain": "5a33b4ab-2b5e-4ff4-8da6-27ff4d2bb1fb.fr-par-1.baremetal.scw.cloud",
+      "boot_type": "normal",
+      "zone": "fr-par-1",
+      "install": {
+        "os_id": "7e865c16-1a63-4dc7-8181-eb8bbac3f8b5",
+        "hostname": "scw-nervous-shirley",
+        "status": "completed"
+      }
+    }
+  ]
+}`,
+		"/baremetal/v1/zones/fr-par-1/os/7e865c16-1a63-4dc7-8181-eb8bbac3f8b5": `{
+  "id": "7e865c16-1a63-4dc7-8181-eb8bbac3f8b5",
+  "name": "Ubuntu",
+  "version": "22.04 LTS (Jammy Jellyfish)"
+}`,
+	})
+
+	labelss, err := getBaremetalLabels(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expectedLabelss := []*promutil.Labels{
+		promutil.NewLabelsFromMap(map[string]string{
+			"__address__":                           "51.158.183.115:9100",
+			"__meta_scaleway_baremetal_id":          "5a33b4ab-2b5e-4ff4-8da6-27ff4d2bb1fb",
+			"__meta_scaleway_baremetal_name":        "scw-nervous-shirley",
+			"__meta_scaleway_baremetal_os_name":     "Ubuntu",
+			"__meta_scaleway_baremetal_os_version":  "22.04 LTS (Jammy Jellyfish)",
+			"__meta_scaleway_baremetal_project_id":  "cb334986-b054-4725-9d3a-40850fdc6015",
+			"__meta_scaleway_baremetal_public_ipv4": "51.158.183.115",
+			"__meta_scaleway_baremetal_public_ipv6": "2001:bc8:1640:1568:dc00:ff:fe21:91b",
+			"__meta_scaleway_baremetal_status":      "ready",
+			"__meta_scaleway_baremetal_tags":        ",foo,bar,",
+			"__meta_scaleway_baremetal_type":        "EM-B112X-SSD",
+			"__meta_scaleway_baremetal_zone":        "fr-par-1",
+		}),
+	}
+	discoveryutil.TestEqualLabelss(t, labelss, expectedLabelss)
+}
@@ -0,0 +1,176 @@
+package scaleway
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discoveryutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutil"
+)
+
+// instanceServersResponse is the response from Scaleway Instance API for listing servers.
+//
+// See https://www.scaleway.com/en/developers/api/instance/#path-instances-list-all-instances
+type instanceServersResponse struct {
+	Servers []instanceServer `json:"servers"`
+}
+
+type instanceServer struct {
+	ID             string            `json:"id"`
+	Name           string            `json:"name"`
+	Hostname       string            `json:"hostname"`
+	Organization   string            `json:"organization"`
+	Project        string            `json:"project"`
+	CommercialType string            `json:"commercial_type"`
+	BootType       string            `json:"boot_type"`
+	State          string            `json:"state"`
+	Zone           string            `json:"zone"`
+	Tags           []string          `json:"tags"`
+	Image          *instanceImage    `json:"image"`
+	Location       *instanceLocation `json:"location"`
+	SecurityGroup  *instanceSecGroup `json:"security_group"`
+	PrivateIP      *string           `json:"private_ip"`
+	PublicIP       *instanceIP       `json:"public_ip"`
+	PublicIPs      []instanceIP      `json:"public_ips"`
+	IPv6           *instanceIPv6     `json:"ipv6"`
+}
+
+type instanceImage struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Arch string `json:"arch"`
+}
+
+type instanceLocation struct {
+	ClusterID    string `json:"cluster_id"`
+	HypervisorID string `json:"hypervisor_id"`
+	NodeID       string `json:"node_id"`
+}
+
+type instanceSecGroup struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type instanceIP struct {
+	Address string `json:"address"`
+
+	// Family is either inet or inet6.
+	Family string `json:"family"`
+}
+
+type instanceIPv6 struct {
+	Address string `json:"address"`
+}
+
+func getInstanceServers(cfg *apiConfig) ([]instanceServer, error) {
+	apiPath := fmt.Sprintf("/instance/v1/zones/%s/servers", cfg.zone)
+	var servers []instanceServer
+	for page := 1; ; page++ {
+		path := cfg.getListPath(apiPath, "project", page)
+		data, err := cfg.getAPIResponse(path)
+		if err != nil {
+			return nil, fmt.Errorf("cannot obtain instances from %q: %w", path, err)
+		}
+		var resp instanceServersResponse
+		if err := json.Unmarshal(data, &resp); err != nil {
+			return nil, fmt.Errorf("cannot parse instances response from %q: %w", path, err)
+		}
+		servers = append(servers, resp.Servers...)
+		if len(resp.Servers) < perPage {
+			return servers, nil
+		}
+	}
+}
+
+func getInstanceLabels(cfg *apiConfig) ([]*promutil.Labels, error) {
+	servers, err := getInstanceServers(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return appendInstanceLabels(nil, servers, cfg.port), nil
+}
+
+func appendInstanceLabels(ms []*promutil.Labels, servers []instanceServer, port int) []*promutil.Labels {
+	for _, server := range servers {
+		m := promutil.NewLabels(28)
+		m.Add("__meta_scaleway_instance_boot_type", server.BootType)
+		m.Add("__meta_scaleway_instance_hostname", server.Hostname)
+		m.Add("__meta_scaleway_instance_id", server.ID)
+		m.Add("__meta_scaleway_instance_name", server.Name)
+		m.Add("__meta_scaleway_instance_organization_id", server.Organization)
+		m.Add("__meta_scaleway_instance_project_id", server.Project)
+		m.Add("__meta_scaleway_instance_status", server.State)
+		m.Add("__meta_scaleway_instance_type", server.CommercialType)
+		m.Add("__meta_scaleway_instance_zone", server.Zone)
+		m.Add("__meta_scaleway_instance_region", getRegion(server.Zone))
+		if img := server.Image; img != nil {
+			m.Add("__meta_scaleway_instance_image_arch", img.Arch)
+			m.Add("__meta_scaleway_instance_image_id", img.ID)
+			m.Add("__meta_scaleway_instance_image_name", img.Name)
+		}
+		if loc := server.Location; loc != nil {
+			m.Add("__meta_scaleway_instance_location_cluster_id", loc.ClusterID)
+			m.Add("__meta_scaleway_instance_location_hypervisor_id", loc.HypervisorID)
+			m.Add("__meta_scaleway_instance_location_node_id", loc.NodeID)
+		}
+		if sg := server.SecurityGroup; sg != nil {
+			m.Add("__meta_scaleway_instance_security_group_id", sg.ID)
+			m.Add("__meta_scaleway_instance_security_group_name", sg.Name)
+		}
+		if len(server.Tags) > 0 {
+			m.Add("__meta_scaleway_instance_tags", joinStrings(server.Tags))
+		}
+
+		// The address is selected in the following order: private IPv4, public IPv4, public IPv6.
+		addr := ""
+		publicIPv6 := ""
+		if server.IPv6 != nil && server.IPv6.Address != "" {
+			publicIPv6 = server.IPv6.Address
+		}
+		publicIPv4 := ""
+		if server.PublicIP != nil && server.PublicIP.Address != "" {
+			publicIPv4 = server.PublicIP.Address
+		}
+		var publicIPv4Addrs, publicIPv6Addrs []string
+		for _, ip := range server.PublicIPs {
+			switch ip.Family {
+			case "inet":
+				publicIPv4Addrs = append(publicIPv4Addrs, ip.Address)
+			case "inet6":
+				publicIPv6Addrs = append(publicIPv6Addrs, ip.Address)
+			}
+		}
+		if publicIPv4 == "" && len(publicIPv4Addrs) > 0 {
+			publicIPv4 = publicIPv4Addrs[0]
+		}
+		if publicIPv6 == "" && len(publicIPv6Addrs) > 0 {
+			publicIPv6 = publicIPv6Addrs[0]
+		}
+		if publicIPv6 != "" {
+			m.Add("__meta_scaleway_instance_public_ipv6", publicIPv6)
+			addr = publicIPv6
+		}
+		if publicIPv4 != "" {
+			m.Add("__meta_scaleway_instance_public_ipv4", publicIPv4)
+			addr = publicIPv4
+		}
+		if len(publicIPv4Addrs) > 0 {
+			m.Add("__meta_scaleway_instance_public_ipv4_addresses", joinStrings(publicIPv4Addrs))
+		}
+		if len(publicIPv6Addrs) > 0 {
+			m.Add("__meta_scaleway_instance_public_ipv6_addresses", joinStrings(publicIPv6Addrs))
+		}
+		if server.PrivateIP != nil && *server.PrivateIP != "" {
+			m.Add("__meta_scaleway_instance_private_ipv4", *server.PrivateIP)
+			addr = *server.PrivateIP
+		}
+		if addr == "" {
+			// Skip the server without IP addresses, since it cannot be scraped.
+			continue
+		}
+		m.Add("__address__", discoveryutil.JoinHostPort(addr, port))
+		ms = append(ms, m)
+	}
+	return ms
+}
@@ -0,0 +1,145 @@
+package scaleway
+
+import (
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discoveryutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutil"
+)
+
+func TestGetInstanceLabels(t *testing.T) {
+	cfg := newMockAPIConfig(t, map[string]string{
+		"/instance/v1/zones/fr-par-1/servers?page=1&per_page=100&project=project-id&tags=foo%2Cbar": `{
+  "servers": [
+    {
+      "id": "93c18a61-b681-49d0-a1cc-62b43883ae89",
+      "name": "scw-nervous-shirley",
+      "arch": "x86_64",
+      "commercial_type": "DEV1-S",
+      "boot_type": "local",
+      "organization": "cb334986-b054-4725-9d3a-40850fdc6015",
+      "project": "cb334986-b054-4725-9d3a-40850fdc6015",
+      "hostname": "scw-nervous-shirley",
+      "image": {
+        "id": "45a86b35-eca6-4055-9b34-ca69845da146",
+        "name": "Ubuntu 20.04 Focal Fossa",
+        "arch": "x86_64"
+      },
+      "tags": ["foo", "bar"],
+      "state": "running",
+      "private_ip": "10.70.60.57",
+      "public_ip": {
+        "id": "c7f4b6f4-5e4a-4f4f-9f48-66e6df4c5e31",
+        "address": "51.158.183.115",
+        "dynamic": false,
+        "family": "inet"
+      },
+      "public_ips": [
+        {"id": "c7f4b6f4-5e4a-4f4f-9f48-66e6df4c5e31", "address": "51.158.183.115", "dynamic": false, "family": "inet"},
+        {"id": "d9b4bd41-8d34-4d4b-9c1a-9b1f2a0fb0c7", "address": "2001:bc8:630:1e1c::1", "dynamic": false, "family": "inet6"}
+      ],
+      "ipv6": {
+        "address": "2001:bc8:630:1e1c::1",
+        "gateway": "2001:bc8:630:1e1c::",
+        "netmask": "64"
+      },
+      "location": {
+        "cluster_id": "40",
+        "hypervisor_id": "1601",
+        "node_id": "29",
+        "platform_id": "14",
+        "zone_id": "par1"
+      },
+      "security_group": {
+        "id": "984414da-9fc2-49c0-a925-fed6266fe092",
+        "name": "Default security group"
+      },
+      "zone": "fr-par-1"
+    },
+    {
+      "id": "5b6198b4-c677-41b5-9c05-04557264ae1f",
+      "name": "scw-ipv6-only",
+      "commercial_type": "DEV1-S",
+      "boot_type": "local",
+      "organization": "cb334986-b054-4725-9d3a-40850fdc6015",
+      "project": "cb334986-b054-4725-9d3a-40850fdc6015",
+      "hostname": "scw-ipv6-only",
+      "image": null,
+      "tags": [],
+      "state": "running",
+      "private_ip": null,
+      "public_ip": null,
+      "public_ips": [
+        {"id": "a2b4bd41-8d34-4d4b-9c1a-9b1f2a0fb0c7", "address": "2001:bc8:630:1e1c::2", "dynamic": false, "family": "inet6"}
+      ],
+      "ipv6": null,
+      "location": null,
+      "security_group": null,
+      "zone": "fr-par-1"
+    },
+    {
+      "id": "ac3c8a61-b681-49d0-a1cc-62b43883ae89",
+      "name": "scw-stopped",
+      "commercial_type": "DEV1-S",
+      "hostname": "scw-stopped",
+      "state": "stopped",
+      "private_ip": null,
+      "public_ip": null,
+      "public_ips": [],
+      "ipv6": null,
+      "zone": "fr-par-1"
+    }
+  ]
+}`,
+	})
+
+	labelss, err := getInstanceLabels(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expectedLabelss := []*promutil.Labels{
+		promutil.NewLabelsFromMap(map[string]string{
+			"__address__":                                     "10.70.60.57:9100",
+			"__meta_scaleway_instance_boot_type":              "local",
+			"__meta_scaleway_instance_hostname":               "scw-nervous-shirley",
+			"__meta_scaleway_instance_id":                     "93c18a61-b681-49d0-a1cc-62b43883ae89",
+			"__meta_scaleway_instance_image_arch":             "x86_64",
+			"__meta_scaleway_instance_image_id":               "45a86b35-eca6-4055-9b34-ca69845da146",
+			"__meta_scaleway_instance_image_name":             "Ubuntu 20.04 Focal Fossa",
+			"__meta_scaleway_instance_location_cluster_id":    "40",
+			"__meta_scaleway_instance_location_hypervisor_id": "1601",
+			"__meta_scaleway_instance_location_node_id":       "29",
+			"__meta_scaleway_instance_name":                   "scw-nervous-shirley",
+			"__meta_scaleway_instance_organization_id":        "cb334986-b054-4725-9d3a-40850fdc6015",
+			"__meta_scaleway_instance_private_ipv4":           "10.70.60.57",
+			"__meta_scaleway_instance_project_id":             "cb334986-b054-4725-9d3a-40850fdc6015",
+			"__meta_scaleway_instance_public_ipv4":            "51.158.183.115",
+			"__meta_scaleway_instance_public_ipv6":            "2001:bc8:630:1e1c::1",
+			"__meta_scaleway_instance_public_ipv4_addresses":  ",51.158.183.115,",
+			"__meta_scaleway_instance_public_ipv6_addresses":  ",2001:bc8:630:1e1c::1,",
+			"__meta_scaleway_instance_region":                 "fr-par",
+			"__meta_scaleway_instance_security_group_id":      "984414da-9fc2-49c0-a925-fed6266fe092",
+			"__meta_scaleway_instance_security_group_name":    "Default security group",
+			"__meta_scaleway_instance_status":                 "running",
+			"__meta_scaleway_instance_tags":                   ",foo,bar,",
+			"__meta_scaleway_instance_type":                   "DEV1-S",
+			"__meta_scaleway_instance_zone":                   "fr-par-1",
+		}),
+		promutil.NewLabelsFromMap(map[string]string{
+			"__address__":                                    "[2001:bc8:630:1e1c::2]:9100",
+			"__meta_scaleway_instance_boot_type":             "local",
+			"__meta_scaleway_instance_hostname":              "scw-ipv6-only",
+			"__meta_scaleway_instance_id":                    "5b6198b4-c677-41b5-9c05-04557264ae1f",
+			"__meta_scaleway_instance_name":                  "scw-ipv6-only",
+			"__meta_scaleway_instance_organization_id":       "cb334986-b054-4725-9d3a-40850fdc6015",
+			"__meta_scaleway_instance_project_id":            "cb334986-b054-4725-9d3a-40850fdc6015",
+			"__meta_scaleway_instance_public_ipv6":           "2001:bc8:630:1e1c::2",
+			"__meta_scaleway_instance_public_ipv6_addresses": ",2001:bc8:630:1e1c::2,",
+			"__meta_scaleway_instance_region":                "fr-par",
+			"__meta_scaleway_instance_status":                "running",
+			"__meta_scaleway_instance_type":                  "DEV1-S",
+			"__meta_scaleway_instance_zone":                  "fr-par-1",
+		}),
+	}
+	discoveryutil.TestEqualLabelss(t, labelss, expectedLabelss)
+}
@@ -0,0 +1,351 @@
+package zookeeper
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+)
+
+// ZooKeeper operation codes.
+//
+// See https://github.com/apache/zookeeper/blob/master/zookeeper-server/src/main/java/org/apache/zookeeper/ZooDefs.java
+const (
+	opExists      = 3
+	opGetData     = 4
+	opGetChildren = 8
+	opPing        = 11
+	opClose       = -11
+)
+
+// Special xids used by ZooKeeper for watch notifications and pings.
+const (
+	xidWatchEvent = -1
+	xidPing       = -2
+)
+
+// errCodeNoNode is ZooKeeper error code returned when the requested node doesn't exist.
+const errCodeNoNode = -101
+
+// Watch event types.
+const (
+	eventNodeCreated         = 1
+	eventNodeDeleted         = 2
+	eventNodeDataChanged     = 3
+	eventNodeChildrenChanged = 4
+)
+
+// maxFrameSize is the maximum size of ZooKeeper response.
+//
+// It matches the default jute.maxbuffer at ZooKeeper.
+const maxFrameSize = 0xfffff
+
+// statSize is the size of the encoded Stat structure, which is returned by ZooKeeper after node data.
+const statSize = 68
+
+var (
+	errNoNode = errors.New("node doesn't exist")
+	errIdle   = errors.New("no data received")
+)
+
+// watchEvent is ZooKeeper watch notification.
+type watchEvent struct {
+	typ  int32
+	path string
+}
+
+// conn is a minimal ZooKeeper client connection, which supports only read-only operations with watches.
+//
+// conn isn't safe for concurrent use except of closing the underlying net.Conn.
+type conn struct {
+	nc net.Conn
+
+	// sessionTimeout is the session timeout negotiated with ZooKeeper server.
+	sessionTimeout time.Duration
+
+	xid      int32
+	lastRecv time.Time
+
+	// events contains watch notifications received while waiting for responses.
+	events []watchEvent
+}
+
+// dialConn establishes a new ZooKeeper session with one of the given servers.
+func dialConn(servers []string, timeout time.Duration) (*conn, error) {
+	var errs []string
+	for _, i := range rand.Perm(len(servers)) {
+		c, err := newConn(servers[i], timeout)
+		if err == nil {
+			return c, nil
+		}
+		errs = append(errs, err.Error())
+	}
+	return nil, fmt.Errorf("cannot connect to ZooKeeper servers %q: %s", servers, strings.Join(errs, "; "))
+}
+
+func newConn(addr string, timeout time.Duration) (*conn, error) {
+	nc, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	c := &conn{
+		nc:             nc,
+		sessionTimeout: timeout,
+	}
+
+	// See ConnectRequest at zookeeper.jute
+	var e encoder
+	e.int32(0) // protocolVersion
+	e.int64(0) // lastZxidSeen
+	e.int32(int32(timeout / time.Millisecond))
+	e.int64(0) // sessionId
+	e.buffer(make([]byte, 16))
+	if err := c.writeFrame(e.b); err != nil {
+		_ = nc.Close()
+		return nil, fmt.Errorf("cannot send connect request to %q: %w", addr, err)
+	}
+	data, err := c.readFrame(timeout)
+	if err != nil {
+		_ = nc.Close()
+		return nil, fmt.Errorf("cannot read connect response from %q: %w", addr, err)
+	}
+
+	// See ConnectResponse at zookeeper.jute
+	d := &decoder{b: data}
+	_ = d.int32() // protocolVersion
+	sessionTimeout := d.int32()
+	_ = d.int64() // sessionId
+	_ = d.buffer()
+	if d.err != nil {
+		_ = nc.Close()
+		return nil, fmt.Errorf("cannot parse connect response from %q: %w", addr, d.err)
+	}
+	if sessionTimeout <= 0 {
+		_ = nc.Close()
+		return nil, fmt.Errorf("ZooKeeper server at %q rejected the session", addr)
+	}
+	c.sessionTimeout = time.Duration(sessionTimeout) * time.Millisecond
+	return c, nil
+}
+
+// close closes the ZooKeeper session.
+func (c *conn) close() {
+	var e encoder
+	e.int32(c.nextXid())
+	e.int32(opClose)
+	_ = c.writeFrame(e.b)
+	_ = c.nc.Close()
+}
+
+// getChildren returns children names for the node at the given path.
+func (c *conn) getChildren(path string, watch bool) ([]string, error) {
+	var e encoder
+	e.string(path)
+	e.bool(watch)
+	d, err := c.call(opGetChildren, e.b)
+	if err != nil {
+		return nil, err
+	}
+	children := d.stringVector()
+	if d.err != nil {
+		return nil, fmt.Errorf("cannot parse getChildren response for %q: %w", path, d.err)
+	}
+	return children, nil
+}
+
+// getData returns data for the node at the given path.
+func (c *conn) getData(path string, watch bool) ([]byte, error) {
+	var e encoder
+	e.string(path)
+	e.bool(watch)
+	d, err := c.call(opGetData, e.b)
+	if err != nil {
+		return nil, err
+	}
+	data := d.buffer()
+	_ = d.next(statSize)
+	if d.err != nil {
+		return nil, fmt.Errorf("cannot parse getData response for %q: %w", path, d.err)
+	}
+	return data, nil
+}
+
+// exists returns true if the node at the given path exists.
+//
+// If watch is set, then ZooKeeper notifies about the node creation, deletion or data change.
+func (c *conn) exists(path string, watch bool) (bool, error) {
+	var e encoder
+	e.string(path)
+	e.bool(watch)
+	if _, err := c.call(opExists, e.b); err != nil {
+		if errors.Is(err, errNoNode) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// waitEvents waits for watch notifications while keeping the session alive with pings.
+func (c *conn) waitEvents() ([]watchEvent, error) {
+	pingInterval := c.sessionTimeout / 3
+	for len(c.events) == 0 {
+		data, err := c.readFrame(pingInterval)
+		if err != nil {
+			if !errors.Is(err, errIdle) {
+				return nil, err
+			}
+			if time.Since(c.lastRecv) > c.sessionTimeout {
+				return nil, fmt.Errorf("no response from ZooKeeper server during %s", c.sessionTimeout)
+			}
+			if err := c.ping(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if _, err := c.handleFrame(data); err != nil {
+			return nil, err
+		}
+	}
+	events := c.events
+	c.events = nil
+	return events, nil
+}
+
+func (c *conn) ping() error {
+	var e encoder
+	e.int32(xidPing)
+	e.int32(opPing)
+	if err := c.writeFrame(e.b); err != nil {
+		return fmt.Errorf("cannot send ping: %w", err)
+	}
+	return nil
+}
+
+// call sends the request with the given opcode and body and returns decoder for the response body.
+func (c *conn) call(opcode int32, body []byte) (*decoder, error) {
+	xid := c.nextXid()
+	var e encoder
+	e.int32(xid)
+	e.int32(opcode)
+	e.b = append(e.b, body...)
+	if err := c.writeFrame(e.b); err != nil {
+		return nil, fmt.Errorf("cannot send request: %w", err)
+	}
+	deadline := time.Now().Add(c.sessionTimeout)
+	for {
+		data, err := c.readFrame(time.Until(deadline))
+		if err != nil {
+			if errors.Is(err, errIdle) {
+				return nil, fmt.Errorf("no response from ZooKeeper server during %s", c.sessionTimeout)
+			}
+			return nil, err
+		}
+		d, err := c.handleFrame(data)
+		if err != nil {
+			return nil, err
+		}
+		if d == nil {
+			// Watch notification or ping response.
+			continue
+		}
+		respXid := d.int32()
+		_ = d.int64() // zxid
+		errCode := d.int32()
+		if d.err != nil {
+			return nil, fmt.Errorf("cannot parse response header: %w", d.err)
+		}
+		if respXid != xid {
+			return nil, fmt.Errorf("unexpected xid in response; got %d; want %d", respXid, xid)
+		}
+		switch errCode {
+		case 0:
+			return d, nil
+		case errCodeNoNode:
+			return nil, errNoNode
+		default:
+			return nil, fmt.Errorf("ZooKeeper server returned error code %d", errCode)
+		}
+	}
+}
+
+// handleFrame handles watch notifications and ping responses from data.
+//
+// It returns decoder positioned at the start of data for other responses.
+func (c *conn) handleFrame(data []byte) (*decoder, error) {
+	d := &decoder{b: data}
+	switch xid := d.int32(); xid {
+	case xidWatchEvent:
+		// See WatcherEvent at zookeeper.jute
+		_ = d.int64() // zxid
+		_ = d.int32() // err
+		typ := d.int32()
+		_ = d.int32() // state
+		path := d.string()
+		if d.err != nil {
+			return nil, fmt.Errorf("cannot parse watch event: %w", d.err)
+		}
+		c.events = append(c.events, watchEvent{
+			typ:  typ,
+			path: path,
+		})
+		return nil, nil
+	case xidPing:
+		return nil, nil
+	default:
+		return &decoder{b: data}, nil
+	}
+}
+
+func (c *conn) nextXid() int32 {
+	c.xid++
+	if c.xid <= 0 {
+		c.xid = 1
+	}
+	return c.xid
+}
+
+func (c *conn) writeFrame(data []byte) error {
+	if err := c.nc.SetWriteDeadline(time.Now().Add(c.sessionTimeout)); err != nil {
+		return err
+	}
+	buf := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	buf = append(buf, data...)
+	_, err := c.nc.Write(buf)
+	return err
+}
+
+// readFrame reads the next frame from c.
+//
+// It returns errIdle if no data is received during idleTimeout.
+func (c *conn) readFrame(idleTimeout time.Duration) ([]byte, error) {
+	if err := c.nc.SetReadDeadline(time.Now().Add(idleTimeout)); err != nil {
+		return nil, err
+	}
+	var hdr [4]byte
+	n, err := io.ReadFull(c.nc, hdr[:])
+	if err != nil {
+		var ne net.Error
+		if n == 0 && errors.As(err, &ne) && ne.Timeout() {
+			return nil, errIdle
+		}
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(hdr[:])
+	if size > maxFrameSize {
+		return nil, fmt.Errorf("too big response size: %d bytes; mustn't exceed %d bytes", size, maxFrameSize)
+	}
+	if err := c.nc.SetReadDeadline(time.Now().Add(c.sessionTimeout)); err != nil {
+		return nil, err
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(c.nc, data); err != nil {
+		return nil, err
+	}
+	c.lastRecv = time.Now()
+	return data, nil
+}
@@ -0,0 +1,307 @@
+package zookeeper
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutil"
+)
+
+// fakeServer is an in-process ZooKeeper server, which supports the subset of ZooKeeper protocol used by conn.
+type fakeServer struct {
+	ln net.Listener
+
+	mu           sync.Mutex
+	nodes        map[string][]byte
+	dataWatches  map[string]map[*fakeConn]struct{}
+	childWatches map[string]map[*fakeConn]struct{}
+	conns        map[*fakeConn]struct{}
+
+	wg sync.WaitGroup
+}
+
+type fakeConn struct {
+	nc net.Conn
+
+	// mu serializes writes to nc
+	mu sync.Mutex
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot start fake ZooKeeper server: %s", err)
+	}
+	fs := &fakeServer{
+		ln: ln,
+		nodes: map[string][]byte{
+			"/": nil,
+		},
+		dataWatches:  make(map[string]map[*fakeConn]struct{}),
+		childWatches: make(map[string]map[*fakeConn]struct{}),
+		conns:        make(map[*fakeConn]struct{}),
+	}
+	fs.wg.Add(1)
+	go func() {
+		defer fs.wg.Done()
+		for {
+			nc, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			fc := &fakeConn{
+				nc: nc,
+			}
+			fs.mu.Lock()
+			fs.conns[fc] = struct{}{}
+			fs.mu.Unlock()
+			fs.wg.Add(1)
+			go func() {
+				defer fs.wg.Done()
+				fs.serveConn(fc)
+			}()
+		}
+	}()
+	t.Cleanup(fs.close)
+	return fs
+}
+
+func (fs *fakeServer) addr() string {
+	return fs.ln.Addr().String()
+}
+
+func (fs *fakeServer) close() {
+	_ = fs.ln.Close()
+	fs.dropConnections()
+	fs.wg.Wait()
+}
+
+// dropConnections closes all the client connections.
+func (fs *fakeServer) dropConnections() {
+	fs.mu.Lock()
+	for fc := range fs.conns {
+		_ = fc.nc.Close()
+	}
+	fs.mu.Unlock()
+}
+
+// setNode creates or updates the node at the given path. Missing parent nodes are created automatically.
+func (fs *fakeServer) setNode(path, data string) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if _, ok := fs.nodes[path]; ok {
+		fs.nodes[path] = []byte(data)
+		fs.fireWatches(fs.dataWatches, path, eventNodeDataChanged)
+		return
+	}
+	parent := parentPath(path)
+	if _, ok := fs.nodes[parent]; !ok {
+		fs.mu.Unlock()
+		fs.setNode(parent, "")
+		fs.mu.Lock()
+	}
+	fs.nodes[path] = []byte(data)
+	fs.fireWatches(fs.dataWatches, path, eventNodeCreated)
+	fs.fireWatches(fs.childWatches, parent, eventNodeChildrenChanged)
+}
+
+// deleteNode deletes the node at the given path.
+func (fs *fakeServer) deleteNode(path string) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	delete(fs.nodes, path)
+	fs.fireWatches(fs.dataWatches, path, eventNodeDeleted)
+	fs.fireWatches(fs.childWatches, path, eventNodeDeleted)
+	fs.fireWatches(fs.childWatches, parentPath(path), eventNodeChildrenChanged)
+}
+
+// fireWatches sends one-shot watch notifications to the clients watching the given path.
+//
+// fs.mu must be locked.
+func (fs *fakeServer) fireWatches(watches map[string]map[*fakeConn]struct{}, path string, typ int32) {
+	for fc := range watches[path] {
+		var e encoder
+		e.int32(xidWatchEvent)
+		e.int64(-1) // zxid
+		e.int32(0)  // err
+		e.int32(typ)
+		e.int32(3) // SyncConnected state
+		e.string(path)
+		fc.writeFrame(e.b)
+	}
+	delete(watches, path)
+}
+
+func (fs *fakeServer) addWatch(watches map[string]map[*fakeConn]struct{}, path string, fc *fakeConn) {
+	m := watches[path]
+	if m == nil {
+		m = make(map[*fakeConn]struct{})
+		watches[path] = m
+	}
+	m[fc] = struct{}{}
+}
+
+func (fs *fakeServer) serveConn(fc *fakeConn) {
+	defer func() {
+		fs.mu.Lock()
+		delete(fs.conns, fc)
+		for _, watches := range []map[string]map[*fakeConn]struct{}{fs.dataWatches, fs.childWatches} {
+			for _, m := range watches {
+				delete(m, fc)
+			}
+		}
+		fs.mu.Unlock()
+		_ = fc.nc.Close()
+	}()
+
+	// Read ConnectRequest
+	data, err := fc.readFrame()
+	if err != nil {
+		return
+	}
+	d := &decoder{b: data}
+	_ = d.int32() // protocolVersion
+	_ = d.int64() // lastZxidSeen
+	timeout := d.int32()
+	var e encoder
+	e.int32(0) // protocolVersion
+	e.int32(timeout)
+	e.int64(1) // sessionId
+	e.buffer(make([]byte, 16))
+	fc.writeFrame(e.b)
+
+	for {
+		data, err := fc.readFrame()
+		if err != nil {
+			return
+		}
+		d := &decoder{b: data}
+		xid := d.int32()
+		opcode := d.int32()
+		switch opcode {
+		case opPing:
+			fc.writeReply(xidPing, 0, nil)
+		case opClose:
+			fc.writeReply(xid, 0, nil)
+			return
+		case opGetChildren, opGetData, opExists:
+			path := d.string()
+			watch := d.bool()
+			errCode, body := fs.handleRead(fc, opcode, path, watch)
+			fc.writeReply(xid, errCode, body)
+		default:
+			return
+		}
+	}
+}
+
+func (fs *fakeServer) handleRead(fc *fakeConn, opcode int32, path string, watch bool) (int32, []byte) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	data, ok := fs.nodes[path]
+	if opcode == opExists && watch {
+		// ZooKeeper sets exists watch even for missing nodes.
+		fs.addWatch(fs.dataWatches, path, fc)
+	}
+	if !ok {
+		return errCodeNoNode, nil
+	}
+	var e encoder
+	switch opcode {
+	case opGetChildren:
+		var children []string
+		prefix := strings.TrimSuffix(path, "/") + "/"
+		for p := range fs.nodes {
+			if p != "/" && strings.HasPrefix(p, prefix) && !strings.Contains(p[len(prefix):], "/") {
+				children = append(children, p[len(prefix):])
+			}
+		}
+		sort.Strings(children)
+		e.stringVector(children)
+		if watch {
+			fs.addWatch(fs.childWatches, path, fc)
+		}
+	case opGetData:
+		e.buffer(data)
+		e.b = append(e.b, make([]byte, statSize)...)
+		if watch {
+			fs.addWatch(fs.dataWatches, path, fc)
+		}
+	case opExists:
+		e.b = append(e.b, make([]byte, statSize)...)
+	}
+	return 0, e.b
+}
+
+func (fc *fakeConn) readFrame() ([]byte, error) {
+	var hdr [4]byte
+	if _, err := io.ReadFull(fc.nc, hdr[:]); err != nil {
+		return nil, err
+	}
+	data := make([]byte, binary.BigEndian.Uint32(hdr[:]))
+	if _, err := io.ReadFull(fc.nc, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (fc *fakeConn) writeReply(xid, errCode int32, body []byte) {
+	var e encoder
+	e.int32(xid)
+	e.int64(0) // zxid
+	e.int32(errCode)
+	e.b = append(e.b, body...)
+	fc.writeFrame(e.b)
+}
+
+func (fc *fakeConn) writeFrame(data []byte) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	buf := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	buf = append(buf, data...)
+	_, _ = fc.nc.Write(buf)
+}
+
+func parentPath(path string) string {
+	n := strings.LastIndexByte(path, '/')
+	if n <= 0 {
+		return "/"
+	}
+	return path[:n]
+}
+
+// waitForLabels waits until getLabels returns labelssExpected.
+func waitForLabels(t *testing.T, getLabels func(string) ([]*promutil.Labels, error), labelssExpected []*promutil.Labels) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		labelss, err := getLabels("")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		for _, labels := range labelss {
+			labels.Sort()
+		}
+		if reflect.DeepEqual(labelss, labelssExpected) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("unexpected labels:\ngot\n%v\nwant\n%v", labelss, labelssExpected)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,124 @@
+package zookeeper
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// encoder encodes ZooKeeper protocol messages in jute format.
+//
+// See https://github.com/apache/zookeeper/blob/master/zookeeper-jute/src/main/resources/zookeeper.jute
+type encoder struct {
+	b []byte
+}
+
+func (e *encoder) int32(v int32) {
+	e.b = binary.BigEndian.AppendUint32(e.b, uint32(v))
+}
+
+func (e *encoder) int64(v int64) {
+	e.b = binary.BigEndian.AppendUint64(e.b, uint64(v))
+}
+
+func (e *encoder) bool(v bool) {
+	if v {
+		e.b = append(e.b, 1)
+	} else {
+		e.b = append(e.b, 0)
+	}
+}
+
+func (e *encoder) buffer(b []byte) {
+	if b == nil {
+		e.int32(-1)
+		return
+	}
+	e.int32(int32(len(b)))
+	e.b = append(e.b, b...)
+}
+
+func (e *encoder) string(s string) {
+	e.int32(int32(len(s)))
+	e.b = append(e.b, s...)
+}
+
+func (e *encoder) stringVector(a []string) {
+	e.int32(int32(len(a)))
+	for _, s := range a {
+		e.string(s)
+	}
+}
+
+// decoder decodes ZooKeeper protocol messages in jute format.
+//
+// The first decoding error is stored in err, while the subsequent calls return zero values.
+type decoder struct {
+	b   []byte
+	err error
+}
+
+func (d *decoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(d.b) {
+		d.err = fmt.Errorf("cannot read %d bytes from %d bytes", n, len(d.b))
+		return nil
+	}
+	b := d.b[:n]
+	d.b = d.b[n:]
+	return b
+}
+
+func (d *decoder) int32() int32 {
+	b := d.next(4)
+	if b == nil {
+		return 0
+	}
+	return int32(binary.BigEndian.Uint32(b))
+}
+
+func (d *decoder) int64() int64 {
+	b := d.next(8)
+	if b == nil {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint64(b))
+}
+
+func (d *decoder) bool() bool {
+	b := d.next(1)
+	return b != nil && b[0] != 0
+}
+
+func (d *decoder) buffer() []byte {
+	n := d.int32()
+	if n < 0 {
+		return nil
+	}
+	b := d.next(int(n))
+	if b == nil {
+		return nil
+	}
+	return append([]byte{}, b...)
+}
+
+func (d *decoder) string() string {
+	n := d.int32()
+	if n < 0 {
+		return ""
+	}
+	return string(d.next(int(n)))
+}
+
+func (d *decoder) stringVector() []string {
+	n := d.int32()
+	if n < 0 || d.err != nil {
+		return nil
+	}
+	a := make([]string, 0, n)
+	for i := int32(0); i < n && d.err == nil; i++ {
+		a = append(a, d.string())
+	}
+	return a
+}
@@ -0,0 +1,34 @@
+package zookeeper
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discoveryutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutil"
+)
+
+// nerveMember is a member registered in ZooKeeper by AirBnB's Nerve.
+//
+// See https://github.com/airbnb/nerve
+type nerveMember struct {
+	Host string `json:"host"`
+	Port int    `json:"port"`
+	Name string `json:"name"`
+}
+
+func parseNerveMember(path string, data []byte) (*promutil.Labels, error) {
+	var member nerveMember
+	if err := json.Unmarshal(data, &member); err != nil {
+		return nil, fmt.Errorf("cannot parse nerve member: %w", err)
+	}
+
+	m := promutil.NewLabels(5)
+	m.Add("__address__", discoveryutil.JoinHostPort(member.Host, member.Port))
+	m.Add("__meta_nerve_path", path)
+	m.Add("__meta_nerve_endpoint_host", member.Host)
+	m.Add("__meta_nerve_endpoint_port", strconv.Itoa(member.Port))
+	m.Add("__meta_nerve_endpoint_name", member.Name)
+	return m, nil
+}
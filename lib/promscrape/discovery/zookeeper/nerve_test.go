@@ -0,0 +1,64 @@
+package zookeeper
+
+import (
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discoveryutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutil"
+)
+
+func TestParseNerveMemberSuccess(t *testing.T) {
+	f := func(data string, labelsExpected *promutil.Labels) {
+		t.Helper()
+
+		labels, err := parseNerveMember("/nerve/services/api/services/i-0123_api", []byte(data))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		discoveryutil.TestEqualLabelss(t, []*promutil.Labels{labels}, []*promutil.Labels{labelsExpected})
+	}
+
+	f(`{"host":"10.0.0.1","port":8080,"name":"i-0123"}`, promutil.NewLabelsFromMap(map[string]string{
+		"__address__":                "10.0.0.1:8080",
+		"__meta_nerve_endpoint_host": "10.0.0.1",
+		"__meta_nerve_endpoint_name": "i-0123",
+		"__meta_nerve_endpoint_port": "8080",
+		"__meta_nerve_path":          "/nerve/services/api/services/i-0123_api",
+	}))
+
+	// ipv6 host without name
+	f(`{"host":"fd00::1","port":80}`, promutil.NewLabelsFromMap(map[string]string{
+		"__address__":                "[fd00::1]:80",
+		"__meta_nerve_endpoint_host": "fd00::1",
+		"__meta_nerve_endpoint_name": "",
+		"__meta_nerve_endpoint_port": "80",
+		"__meta_nerve_path":          "/nerve/services/api/services/i-0123_api",
+	}))
+}
+
+func TestParseNerveMemberFailure(t *testing.T) {
+	if _, err := parseNerveMember("/nerve/services/api/services/i-0123_api", []byte(`[1,2]`)); err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+}
+
+func TestNerveSDConfigGetLabels(t *testing.T) {
+	fs := newFakeServer(t)
+	fs.setNode("/nerve/services/api/services/i-0123_api", `{"host":"10.0.0.1","port":8080,"name":"i-0123"}`)
+
+	sdc := &NerveSDConfig{
+		Servers: []string{"127.0.0.1:1", fs.addr()},
+		Paths:   []string{"/nerve/services/api/services"},
+	}
+	defer sdc.MustStop()
+
+	waitForLabels(t, sdc.GetLabels, []*promutil.Labels{
+		promutil.NewLabelsFromMap(map[string]string{
+			"__address__":                "10.0.0.1:8080",
+			"__meta_nerve_endpoint_host": "10.0.0.1",
+			"__meta_nerve_endpoint_name": "i-0123",
+			"__meta_nerve_endpoint_port": "8080",
+			"__meta_nerve_path":          "/nerve/services/api/services/i-0123_api",
+		}),
+	})
+}
@@ -0,0 +1,47 @@
+package zookeeper
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discoveryutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutil"
+)
+
+// serversetMember is a member of Twitter serverset.
+//
+// See https://github.com/twitter/finagle/blob/develop/finagle-serversets/src/main/thrift/com/twitter/thrift/endpoint.thrift
+type serversetMember struct {
+	ServiceEndpoint     serversetEndpoint            `json:"serviceEndpoint"`
+	AdditionalEndpoints map[string]serversetEndpoint `json:"additionalEndpoints"`
+	Status              string                       `json:"status"`
+	Shard               int                          `json:"shard"`
+}
+
+type serversetEndpoint struct {
+	Host string `json:"host"`
+	Port int    `json:"port"`
+}
+
+func parseServersetMember(path string, data []byte) (*promutil.Labels, error) {
+	var member serversetMember
+	if err := json.Unmarshal(data, &member); err != nil {
+		return nil, fmt.Errorf("cannot parse serverset member: %w", err)
+	}
+	ep := member.ServiceEndpoint
+
+	m := promutil.NewLabels(6 + 2*len(member.AdditionalEndpoints))
+	m.Add("__address__", discoveryutil.JoinHostPort(ep.Host, ep.Port))
+	m.Add("__meta_serverset_path", path)
+	m.Add("__meta_serverset_endpoint_host", ep.Host)
+	m.Add("__meta_serverset_endpoint_port", strconv.Itoa(ep.Port))
+	for name, ep := range member.AdditionalEndpoints {
+		name = discoveryutil.SanitizeLabelName(name)
+		m.Add("__meta_serverset_endpoint_host_"+name, ep.Host)
+		m.Add("__meta_serverset_endpoint_port_"+name, strconv.Itoa(ep.Port))
+	}
+	m.Add("__meta_serverset_status", member.Status)
+	m.Add("__meta_serverset_shard", strconv.Itoa(member.Shard))
+	return m, nil
+}
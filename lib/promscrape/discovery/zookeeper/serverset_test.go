@@ -0,0 +1,117 @@
+package zookeeper
+
+import (
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discoveryutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutil"
+)
+
+func TestParseServersetMemberSuccess(t *testing.T) {
+	f := func(data string, labelsExpected *promutil.Labels) {
+		t.Helper()
+
+		labels, err := parseServersetMember("/services/api/member_0000000001", []byte(data))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		discoveryutil.TestEqualLabelss(t, []*promutil.Labels{labels}, []*promutil.Labels{labelsExpected})
+	}
+
+	// service endpoint only
+	f(`{"serviceEndpoint":{"host":"10.0.0.1","port":8080},"additionalEndpoints":{},"status":"ALIVE"}`, promutil.NewLabelsFromMap(map[string]string{
+		"__address__":                    "10.0.0.1:8080",
+		"__meta_serverset_endpoint_host": "10.0.0.1",
+		"__meta_serverset_endpoint_port": "8080",
+		"__meta_serverset_path":          "/services/api/member_0000000001",
+		"__meta_serverset_shard":         "0",
+		"__meta_serverset_status":        "ALIVE",
+	}))
+
+	// additional endpoints and shard
+	f(`{"serviceEndpoint":{"host":"api-1.example.com","port":8080},"additionalEndpoints":{"http-admin":{"host":"api-1.example.com","port":9990}},"status":"ALIVE","shard":3}`, promutil.NewLabelsFromMap(map[string]string{
+		"__address__":                               "api-1.example.com:8080",
+		"__meta_serverset_endpoint_host":            "api-1.example.com",
+		"__meta_serverset_endpoint_host_http_admin": "api-1.example.com",
+		"__meta_serverset_endpoint_port":            "8080",
+		"__meta_serverset_endpoint_port_http_admin": "9990",
+		"__meta_serverset_path":                     "/services/api/member_0000000001",
+		"__meta_serverset_shard":                    "3",
+		"__meta_serverset_status":                   "ALIVE",
+	}))
+}
+
+func TestParseServersetMemberFailure(t *testing.T) {
+	f := func(data string) {
+		t.Helper()
+
+		if _, err := parseServersetMember("/services/api/member_0000000001", []byte(data)); err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+
+	f(`foobar`)
+	f(`{"serviceEndpoint":{"host":"10.0.0.1","port":"8080"}}`)
+}
+
+func TestServersetSDConfigGetLabels(t *testing.T) {
+	fs := newFakeServer(t)
+	fs.setNode("/services/api/member_0000000001", `{"serviceEndpoint":{"host":"10.0.0.1","port":8080},"status":"ALIVE"}`)
+	// Nodes with empty data and invalid nodes must be skipped.
+	fs.setNode("/services/api/empty", "")
+	fs.setNode("/services/api/invalid", "foobar")
+
+	sdc := &ServersetSDConfig{
+		Servers: []string{fs.addr()},
+		Paths:   []string{"/services/api", "/services/web"},
+	}
+	defer sdc.MustStop()
+
+	member1 := promutil.NewLabelsFromMap(map[string]string{
+		"__address__":                    "10.0.0.1:8080",
+		"__meta_serverset_endpoint_host": "10.0.0.1",
+		"__meta_serverset_endpoint_port": "8080",
+		"__meta_serverset_path":          "/services/api/member_0000000001",
+		"__meta_serverset_shard":         "0",
+		"__meta_serverset_status":        "ALIVE",
+	})
+	waitForLabels(t, sdc.GetLabels, []*promutil.Labels{member1})
+
+	// new member is added
+	fs.setNode("/services/api/member_0000000002", `{"serviceEndpoint":{"host":"10.0.0.2","port":8080},"status":"ALIVE"}`)
+	member2 := promutil.NewLabelsFromMap(map[string]string{
+		"__address__":                    "10.0.0.2:8080",
+		"__meta_serverset_endpoint_host": "10.0.0.2",
+		"__meta_serverset_endpoint_port": "8080",
+		"__meta_serverset_path":          "/services/api/member_0000000002",
+		"__meta_serverset_shard":         "0",
+		"__meta_serverset_status":        "ALIVE",
+	})
+	waitForLabels(t, sdc.GetLabels, []*promutil.Labels{member1, member2})
+
+	// member data is changed
+	fs.setNode("/services/api/member_0000000001", `{"serviceEndpoint":{"host":"10.0.0.1","port":8080},"status":"STOPPING"}`)
+	member1.Set("__meta_serverset_status", "STOPPING")
+	waitForLabels(t, sdc.GetLabels, []*promutil.Labels{member1, member2})
+
+	// member is removed
+	fs.deleteNode("/services/api/member_0000000001")
+	waitForLabels(t, sdc.GetLabels, []*promutil.Labels{member2})
+
+	// the missing path is created
+	fs.setNode("/services/web/member_0000000001", `{"serviceEndpoint":{"host":"10.0.1.1","port":80},"status":"ALIVE"}`)
+	member3 := promutil.NewLabelsFromMap(map[string]string{
+		"__address__":                    "10.0.1.1:80",
+		"__meta_serverset_endpoint_host": "10.0.1.1",
+		"__meta_serverset_endpoint_port": "80",
+		"__meta_serverset_path":          "/services/web/member_0000000001",
+		"__meta_serverset_shard":         "0",
+		"__meta_serverset_status":        "ALIVE",
+	})
+	waitForLabels(t, sdc.GetLabels, []*promutil.Labels{member2, member3})
+
+	// the connection is lost and the member is removed while the connection is re-established
+	fs.dropConnections()
+	fs.deleteNode("/services/api/member_0000000002")
+	waitForLabels(t, sdc.GetLabels, []*promutil.Labels{member3})
+}
@@ -0,0 +1,209 @@
+package zookeeper
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutil"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+var (
+	watcherErrors     = metrics.NewCounter(`vm_promscrape_discovery_zookeeper_errors_total`)
+	watcherReloads    = metrics.NewCounter(`vm_promscrape_discovery_zookeeper_reloads_total`)
+	watcherRetryDelay = time.Second
+)
+
+// memberParser must return labels for ZooKeeper member node at the given path with the given data.
+type memberParser func(path string, data []byte) (*promutil.Labels, error)
+
+// zkWatcher watches children of the given ZooKeeper paths and keeps labels for them in memory.
+type zkWatcher struct {
+	servers     []string
+	paths       []string
+	timeout     time.Duration
+	parseMember memberParser
+
+	// mu protects labelss and c
+	mu      sync.Mutex
+	labelss []*promutil.Labels
+	c       *conn
+
+	initOnce  sync.Once
+	initCh    chan struct{}
+	stopCh    chan struct{}
+	stoppedCh chan struct{}
+}
+
+// newZKWatcher starts watching children of paths at ZooKeeper servers.
+//
+// It waits until the initial list of members is loaded or until the first error.
+func newZKWatcher(servers, paths []string, timeout time.Duration, parseMember memberParser) *zkWatcher {
+	zw := &zkWatcher{
+		servers:     servers,
+		paths:       paths,
+		timeout:     timeout,
+		parseMember: parseMember,
+		initCh:      make(chan struct{}),
+		stopCh:      make(chan struct{}),
+		stoppedCh:   make(chan struct{}),
+	}
+	go zw.run()
+	<-zw.initCh
+	return zw
+}
+
+func (zw *zkWatcher) mustStop() {
+	zw.mu.Lock()
+	close(zw.stopCh)
+	if zw.c != nil {
+		// Interrupt the blocked read at the current connection.
+		_ = zw.c.nc.Close()
+	}
+	zw.mu.Unlock()
+	<-zw.stoppedCh
+}
+
+// getLabels returns a copy of the labels for the currently discovered members.
+func (zw *zkWatcher) getLabels() []*promutil.Labels {
+	zw.mu.Lock()
+	defer zw.mu.Unlock()
+
+	ms := make([]*promutil.Labels, 0, len(zw.labelss))
+	for _, m := range zw.labelss {
+		ms = append(ms, m.Clone())
+	}
+	return ms
+}
+
+func (zw *zkWatcher) markInitialized() {
+	zw.initOnce.Do(func() {
+		close(zw.initCh)
+	})
+}
+
+func (zw *zkWatcher) run() {
+	defer close(zw.stoppedCh)
+	defer zw.markInitialized()
+
+	for {
+		err := zw.watchSession()
+		select {
+		case <-zw.stopCh:
+			return
+		default:
+		}
+		watcherErrors.Inc()
+		logger.Errorf("error when watching ZooKeeper paths %q at %q: %s; retrying in %s", zw.paths, zw.servers, err, watcherRetryDelay)
+		zw.markInitialized()
+		select {
+		case <-zw.stopCh:
+			return
+		case <-time.After(watcherRetryDelay):
+		}
+	}
+}
+
+// watchSession establishes new ZooKeeper session and reloads members on every watch notification until an error occurs.
+func (zw *zkWatcher) watchSession() error {
+	c, err := dialConn(zw.servers, zw.timeout)
+	if err != nil {
+		return err
+	}
+	zw.mu.Lock()
+	select {
+	case <-zw.stopCh:
+		zw.mu.Unlock()
+		c.close()
+		return nil
+	default:
+	}
+	zw.c = c
+	zw.mu.Unlock()
+
+	defer func() {
+		zw.mu.Lock()
+		zw.c = nil
+		zw.mu.Unlock()
+		c.close()
+	}()
+
+	for {
+		labelss, err := zw.loadMembers(c)
+		if err != nil {
+			return err
+		}
+		watcherReloads.Inc()
+		zw.mu.Lock()
+		zw.labelss = labelss
+		zw.mu.Unlock()
+		zw.markInitialized()
+
+		if _, err := c.waitEvents(); err != nil {
+			return err
+		}
+	}
+}
+
+// loadMembers loads members for all the watched paths and sets watches on them.
+func (zw *zkWatcher) loadMembers(c *conn) ([]*promutil.Labels, error) {
+	var ms []*promutil.Labels
+	for _, path := range zw.paths {
+		children, err := getChildrenOrWatchCreation(c, path)
+		if err != nil {
+			return nil, fmt.Errorf("cannot obtain children for %q: %w", path, err)
+		}
+		sort.Strings(children)
+		for _, child := range children {
+			childPath := joinPath(path, child)
+			data, err := c.getData(childPath, true)
+			if err != nil {
+				if errors.Is(err, errNoNode) {
+					// The node has been deleted after obtaining the children list.
+					// The children watch will notify about this change.
+					continue
+				}
+				return nil, fmt.Errorf("cannot obtain data for %q: %w", childPath, err)
+			}
+			if len(data) == 0 {
+				continue
+			}
+			m, err := zw.parseMember(childPath, data)
+			if err != nil {
+				logger.Errorf("skipping ZooKeeper node %q: %s", childPath, err)
+				continue
+			}
+			ms = append(ms, m)
+		}
+	}
+	return ms, nil
+}
+
+// getChildrenOrWatchCreation returns children for the given path and sets children watch on it.
+//
+// If the path doesn't exist, then it sets a watch for path creation and returns empty children list.
+func getChildrenOrWatchCreation(c *conn, path string) ([]string, error) {
+	for {
+		children, err := c.getChildren(path, true)
+		if !errors.Is(err, errNoNode) {
+			return children, err
+		}
+		ok, err := c.exists(path, true)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, nil
+		}
+		// The path has been created after getChildren call. Try obtaining its children again.
+	}
+}
+
+func joinPath(path, child string) string {
+	return strings.TrimSuffix(path, "/") + "/" + child
+}
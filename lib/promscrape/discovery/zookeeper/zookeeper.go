@@ -0,0 +1,109 @@
+package zookeeper
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discoveryutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutil"
+)
+
+// ServersetSDCheckInterval defines interval for checking for changes in serverset targets.
+var ServersetSDCheckInterval = flag.Duration("promscrape.serversetSDCheckInterval", 30*time.Second, "Interval for checking for changes in ZooKeeper serverset members. "+
+	"This works only if serverset_sd_configs is configured in '-promscrape.config' file. "+
+	"See https://docs.victoriametrics.com/victoriametrics/sd_configs/#serverset_sd_configs for details")
+
+// NerveSDCheckInterval defines interval for checking for changes in nerve targets.
+var NerveSDCheckInterval = flag.Duration("promscrape.nerveSDCheckInterval", 30*time.Second, "Interval for checking for changes in ZooKeeper nerve members. "+
+	"This works only if nerve_sd_configs is configured in '-promscrape.config' file. "+
+	"See https://docs.victoriametrics.com/victoriametrics/sd_configs/#nerve_sd_configs for details")
+
+// ServersetSDConfig represents service discovery config for Twitter serversets stored in ZooKeeper.
+//
+// See https://prometheus.io/docs/prometheus/latest/configuration/configuration/#serverset_sd_config
+type ServersetSDConfig struct {
+	Servers []string           `yaml:"servers"`
+	Paths   []string           `yaml:"paths"`
+	Timeout *promutil.Duration `yaml:"timeout,omitempty"`
+
+	// refresh_interval is obtained from `-promscrape.serversetSDCheckInterval` command-line option.
+}
+
+// GetLabels returns serverset labels according to sdc.
+func (sdc *ServersetSDConfig) GetLabels(_ string) ([]*promutil.Labels, error) {
+	zw, err := getWatcher(sdc, sdc.Servers, sdc.Paths, sdc.Timeout, parseServersetMember)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get ZooKeeper watcher: %w", err)
+	}
+	return zw.getLabels(), nil
+}
+
+// MustStop stops further usage for sdc.
+func (sdc *ServersetSDConfig) MustStop() {
+	mustStopWatcher(sdc)
+}
+
+// NerveSDConfig represents service discovery config for AirBnB's Nerve stored in ZooKeeper.
+//
+// See https://prometheus.io/docs/prometheus/latest/configuration/configuration/#nerve_sd_config
+type NerveSDConfig struct {
+	Servers []string           `yaml:"servers"`
+	Paths   []string           `yaml:"paths"`
+	Timeout *promutil.Duration `yaml:"timeout,omitempty"`
+
+	// refresh_interval is obtained from `-promscrape.nerveSDCheckInterval` command-line option.
+}
+
+// GetLabels returns nerve labels according to sdc.
+func (sdc *NerveSDConfig) GetLabels(_ string) ([]*promutil.Labels, error) {
+	zw, err := getWatcher(sdc, sdc.Servers, sdc.Paths, sdc.Timeout, parseNerveMember)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get ZooKeeper watcher: %w", err)
+	}
+	return zw.getLabels(), nil
+}
+
+// MustStop stops further usage for sdc.
+func (sdc *NerveSDConfig) MustStop() {
+	mustStopWatcher(sdc)
+}
+
+var configMap = discoveryutil.NewConfigMap()
+
+func getWatcher(sdc any, servers, paths []string, timeout *promutil.Duration, parseMember memberParser) (*zkWatcher, error) {
+	v, err := configMap.Get(sdc, func() (any, error) { return newWatcher(servers, paths, timeout, parseMember) })
+	if err != nil {
+		return nil, err
+	}
+	return v.(*zkWatcher), nil
+}
+
+func newWatcher(servers, paths []string, timeout *promutil.Duration, parseMember memberParser) (*zkWatcher, error) {
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("`servers` option must contain at least a single ZooKeeper server")
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("`paths` option must contain at least a single ZooKeeper path")
+	}
+	for _, path := range paths {
+		if !strings.HasPrefix(path, "/") {
+			return nil, fmt.Errorf("ZooKeeper path %q must start with `/`", path)
+		}
+	}
+	t := timeout.Duration()
+	if t <= 0 {
+		t = 10 * time.Second
+	}
+	return newZKWatcher(servers, paths, t, parseMember), nil
+}
+
+func mustStopWatcher(sdc any) {
+	v := configMap.Delete(sdc)
+	if v != nil {
+		// v can be nil if GetLabels wasn't called yet.
+		zw := v.(*zkWatcher)
+		zw.mustStop()
+	}
+}
@@ -0,0 +1,24 @@
+package zookeeper
+
+import (
+	"testing"
+)
+
+func TestNewWatcherFailure(t *testing.T) {
+	f := func(servers, paths []string) {
+		t.Helper()
+
+		if _, err := newWatcher(servers, paths, nil, parseNerveMember); err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+
+	// missing servers
+	f(nil, []string{"/foo"})
+
+	// missing paths
+	f([]string{"localhost:2181"}, nil)
+
+	// relative path
+	f([]string{"localhost:2181"}, []string{"foo/bar"})
+}
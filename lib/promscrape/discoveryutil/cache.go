@@ -0,0 +1,316 @@
+package discoveryutil
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/cespare/xxhash/v2"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutil"
+)
+
+var (
+	cacheDir = flag.String("promscrape.discovery.cacheDir", "", "Optional path to directory for persisting the last successful service discovery results per each *_sd_configs entry "+
+		"except of file_sd_configs. "+
+		"The persisted results are used at startup while the fresh discovery runs in background. "+
+		"See https://docs.victoriametrics.com/victoriametrics/vmagent/#service-discovery-cache")
+	cacheMaxAge = flag.Duration("promscrape.discovery.cacheMaxAge", time.Hour, "The maximum age of the persisted service discovery results at -promscrape.discovery.cacheDir, "+
+		"which can be used at startup. Older results are ignored and deleted")
+)
+
+// GetLabelsWithCache returns labels obtained via getLabels for the service discovery config identified by key.
+//
+// If -promscrape.discovery.cacheDir is set, then the successfully discovered labels are persisted there.
+// The first call for the given key after the start returns the persisted labels if they aren't older than -promscrape.discovery.cacheMaxAge,
+// while getLabels is called in background. The result of the background call is returned at the next call.
+func GetLabelsWithCache(key string, getLabels func() ([]*promutil.Labels, error)) ([]*promutil.Labels, error) {
+	if *cacheDir == "" {
+		return getLabels()
+	}
+	return getDiscoveryCache().getLabels(key, getLabels)
+}
+
+// IsCacheEnabled returns true if -promscrape.discovery.cacheDir is set.
+func IsCacheEnabled() bool {
+	return *cacheDir != ""
+}
+
+// GetPersistedLabels returns labels persisted for the service discovery config identified by key.
+//
+// The labels are returned only at the first call for the given key after the start if they aren't older than -promscrape.discovery.cacheMaxAge.
+// This function is intended for service discovery mechanisms, which obtain the discovered labels in background,
+// such as kubernetes_sd_configs. Such mechanisms must persist the discovered labels via PersistLabels.
+func GetPersistedLabels(key string) ([]*promutil.Labels, bool) {
+	if *cacheDir == "" {
+		return nil, false
+	}
+	return getDiscoveryCache().getPersistedLabels(key)
+}
+
+// PersistLabels persists labels returned by getLabels for the service discovery config identified by key.
+//
+// getLabels is called at most once per persistInterval, since it may be expensive.
+// It must return false if the labels aren't discovered yet.
+func PersistLabels(key string, getLabels func() ([]*promutil.Labels, bool)) {
+	if *cacheDir == "" {
+		return
+	}
+	getDiscoveryCache().persistLabels(key, getLabels)
+}
+
+func getDiscoveryCache() *diskCache {
+	discoveryCacheOnce.Do(func() {
+		discoveryCache = newDiscoveryCache(*cacheDir, *cacheMaxAge)
+	})
+	return discoveryCache
+}
+
+var (
+	discoveryCache     *diskCache
+	discoveryCacheOnce sync.Once
+)
+
+var (
+	cacheHits   = metrics.NewCounter(`vm_promscrape_discovery_cache_hits_total`)
+	cacheWrites = metrics.NewCounter(`vm_promscrape_discovery_cache_writes_total`)
+)
+
+// diskCache persists service discovery results at dir.
+type diskCache struct {
+	dir    string
+	maxAge time.Duration
+
+	mu sync.Mutex
+	m  map[string]*diskCacheEntry
+}
+
+func newDiscoveryCache(dir string, maxAge time.Duration) *diskCache {
+	fs.MustMkdirIfNotExist(dir)
+
+	// Remove temporary files and outdated results, which cannot be used anymore.
+	deadline := time.Now().Add(-maxAge)
+	for _, de := range fs.MustReadDir(dir) {
+		if !de.Type().IsRegular() {
+			continue
+		}
+		fn := de.Name()
+		path := filepath.Join(dir, fn)
+		if fs.IsTemporaryFileName(fn) {
+			fs.MustRemovePath(path)
+			continue
+		}
+		fi, err := de.Info()
+		if err != nil {
+			continue
+		}
+		if fi.ModTime().Before(deadline) {
+			fs.MustRemovePath(path)
+		}
+	}
+
+	return &diskCache{
+		dir:    dir,
+		maxAge: maxAge,
+		m:      make(map[string]*diskCacheEntry),
+	}
+}
+
+func (dc *diskCache) getEntry(key string) *diskCacheEntry {
+	dc.mu.Lock()
+	e := dc.m[key]
+	if e == nil {
+		e = &diskCacheEntry{
+			path: filepath.Join(dc.dir, fmt.Sprintf("%016X.json", xxhash.Sum64String(key))),
+		}
+		dc.m[key] = e
+	}
+	dc.mu.Unlock()
+	return e
+}
+
+func (dc *diskCache) getLabels(key string, getLabels func() ([]*promutil.Labels, error)) ([]*promutil.Labels, error) {
+	e := dc.getEntry(key)
+	return e.getLabels(dc.maxAge, getLabels)
+}
+
+func (dc *diskCache) getPersistedLabels(key string) ([]*promutil.Labels, bool) {
+	e := dc.getEntry(key)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.initialized {
+		return nil, false
+	}
+	e.initialized = true
+	labels, dataHash, ok := readPersistedLabels(e.path, dc.maxAge)
+	if !ok {
+		return nil, false
+	}
+	e.dataHash = dataHash
+	cacheHits.Inc()
+	return labels, true
+}
+
+func (dc *diskCache) persistLabels(key string, getLabels func() ([]*promutil.Labels, bool)) {
+	e := dc.getEntry(key)
+
+	// Persist the labels at least twice per maxAge, so they aren't considered outdated.
+	persistInterval := min(dc.maxAge/2, time.Minute)
+
+	e.mu.Lock()
+	e.initialized = true
+	if time.Since(e.lastPersistTime) < persistInterval {
+		e.mu.Unlock()
+		return
+	}
+	e.mu.Unlock()
+
+	labels, ok := getLabels()
+	if !ok {
+		return
+	}
+	e.mustPersistLabels(labels)
+
+	e.mu.Lock()
+	e.lastPersistTime = time.Now()
+	e.mu.Unlock()
+}
+
+type diskCacheEntry struct {
+	// path is the path to the file with the persisted labels
+	path string
+
+	// wg is used for waiting for the background discovery in tests
+	wg sync.WaitGroup
+
+	mu sync.Mutex
+
+	// initialized is set after the first getLabels call
+	initialized bool
+
+	// pending is set while the background discovery is in progress
+	pending bool
+
+	// hasResult is set when the background discovery is complete and its result isn't returned yet
+	hasResult bool
+
+	// labels contains persisted labels while pending is set, and the result of the background discovery when hasResult is set
+	labels []*promutil.Labels
+
+	// err contains the error from the background discovery when hasResult is set
+	err error
+
+	// dataHash is the hash of the persisted data
+	dataHash uint64
+
+	// lastPersistTime is the last time the labels were persisted via persistLabels
+	lastPersistTime time.Time
+}
+
+func (e *diskCacheEntry) getLabels(maxAge time.Duration, getLabels func() ([]*promutil.Labels, error)) ([]*promutil.Labels, error) {
+	e.mu.Lock()
+	if !e.initialized {
+		e.initialized = true
+		if labels, dataHash, ok := readPersistedLabels(e.path, maxAge); ok {
+			e.pending = true
+			e.labels = labels
+			e.dataHash = dataHash
+			e.wg.Add(1)
+			go func() {
+				defer e.wg.Done()
+				e.runBackgroundDiscovery(getLabels)
+			}()
+			e.mu.Unlock()
+			cacheHits.Inc()
+			return labels, nil
+		}
+	}
+	if e.pending {
+		labels := e.labels
+		e.mu.Unlock()
+		return labels, nil
+	}
+	if e.hasResult {
+		labels, err := e.labels, e.err
+		e.hasResult = false
+		e.labels = nil
+		e.err = nil
+		e.mu.Unlock()
+		return labels, err
+	}
+	e.mu.Unlock()
+
+	labels, err := getLabels()
+	if err == nil {
+		e.mustPersistLabels(labels)
+	}
+	return labels, err
+}
+
+func (e *diskCacheEntry) runBackgroundDiscovery(getLabels func() ([]*promutil.Labels, error)) {
+	labels, err := getLabels()
+	if err == nil {
+		e.mustPersistLabels(labels)
+	}
+
+	e.mu.Lock()
+	e.pending = false
+	e.hasResult = true
+	e.labels = labels
+	e.err = err
+	e.mu.Unlock()
+}
+
+func (e *diskCacheEntry) mustPersistLabels(labels []*promutil.Labels) {
+	data, err := json.Marshal(labels)
+	if err != nil {
+		logger.Panicf("BUG: cannot marshal discovered labels: %s", err)
+	}
+	dataHash := xxhash.Sum64(data)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if dataHash == e.dataHash && fs.IsPathExist(e.path) {
+		// The labels didn't change. Just update the modification time of the persisted file,
+		// since it is used for determining the age of the persisted labels.
+		now := time.Now()
+		if err := os.Chtimes(e.path, now, now); err != nil {
+			logger.Errorf("cannot update modification time for %q: %s", e.path, err)
+		}
+		return
+	}
+	fs.MustWriteAtomic(e.path, data, true)
+	e.dataHash = dataHash
+	cacheWrites.Inc()
+}
+
+func readPersistedLabels(path string, maxAge time.Duration) ([]*promutil.Labels, uint64, bool) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, 0, false
+	}
+	if time.Since(fi.ModTime()) > maxAge {
+		return nil, 0, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		logger.Errorf("cannot read persisted discovery results: %s", err)
+		return nil, 0, false
+	}
+	var labels []*promutil.Labels
+	if err := json.Unmarshal(data, &labels); err != nil {
+		logger.Errorf("cannot unmarshal persisted discovery results from %q: %s", path, err)
+		return nil, 0, false
+	}
+	return labels, xxhash.Sum64(data), true
+}
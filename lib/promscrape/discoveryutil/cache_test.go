@@ -0,0 +1,160 @@
+package discoveryutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutil"
+)
+
+func TestDiskCacheGetLabels(t *testing.T) {
+	dir := t.TempDir()
+
+	newLabelsGetter := func(addr string, err error) func() ([]*promutil.Labels, error) {
+		return func() ([]*promutil.Labels, error) {
+			if err != nil {
+				return nil, err
+			}
+			return []*promutil.Labels{
+				promutil.NewLabelsFromMap(map[string]string{
+					"__address__": addr,
+				}),
+			}, nil
+		}
+	}
+	f := func(dc *diskCache, getLabels func() ([]*promutil.Labels, error), resultExpected string) {
+		t.Helper()
+		labels, err := dc.getLabels("foo", getLabels)
+		var a []string
+		if err != nil {
+			a = append(a, "error: "+err.Error())
+		}
+		for _, x := range labels {
+			a = append(a, x.String())
+		}
+		result := strings.Join(a, ",")
+		if result != resultExpected {
+			t.Fatalf("unexpected result; got %s; want %s", result, resultExpected)
+		}
+	}
+	waitForBackgroundDiscovery := func(dc *diskCache) {
+		t.Helper()
+		dc.mu.Lock()
+		e := dc.m["foo"]
+		dc.mu.Unlock()
+		e.wg.Wait()
+	}
+
+	// There are no persisted labels at the first start, so the discovery is performed synchronously.
+	dc := newDiscoveryCache(dir, time.Hour)
+	f(dc, newLabelsGetter("host1", nil), `{__address__="host1"}`)
+	f(dc, newLabelsGetter("host2", nil), `{__address__="host2"}`)
+
+	// Failed discovery doesn't update the persisted labels.
+	f(dc, newLabelsGetter("", fmt.Errorf("discovery error")), `error: discovery error`)
+
+	// The persisted labels are returned after the restart while the discovery is performed in background.
+	dc = newDiscoveryCache(dir, time.Hour)
+	doneCh := make(chan struct{})
+	f(dc, func() ([]*promutil.Labels, error) {
+		<-doneCh
+		return newLabelsGetter("host3", nil)()
+	}, `{__address__="host2"}`)
+	f(dc, newLabelsGetter("host4", nil), `{__address__="host2"}`)
+	close(doneCh)
+	waitForBackgroundDiscovery(dc)
+
+	// The result of the background discovery is returned at the next call.
+	f(dc, newLabelsGetter("host4", nil), `{__address__="host3"}`)
+	f(dc, newLabelsGetter("host4", nil), `{__address__="host4"}`)
+
+	// The error from background discovery is returned at the next call.
+	dc = newDiscoveryCache(dir, time.Hour)
+	f(dc, newLabelsGetter("", fmt.Errorf("discovery error")), `{__address__="host4"}`)
+	waitForBackgroundDiscovery(dc)
+	f(dc, newLabelsGetter("host5", nil), `error: discovery error`)
+	f(dc, newLabelsGetter("host5", nil), `{__address__="host5"}`)
+
+	// The persisted labels older than maxAge are ignored.
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("cannot read %q: %s", dir, err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("unexpected number of persisted files; got %d; want 1", len(entries))
+	}
+	path := filepath.Join(dir, entries[0].Name())
+	oldTime := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(path, oldTime, oldTime); err != nil {
+		t.Fatalf("cannot update modification time for %q: %s", path, err)
+	}
+	dc = newDiscoveryCache(dir, time.Hour)
+	f(dc, newLabelsGetter("host6", nil), `{__address__="host6"}`)
+}
+
+func TestDiskCachePersistLabels(t *testing.T) {
+	dir := t.TempDir()
+
+	getLabelsCalls := 0
+	newLabelsGetter := func(addr string, ok bool) func() ([]*promutil.Labels, bool) {
+		return func() ([]*promutil.Labels, bool) {
+			getLabelsCalls++
+			if !ok {
+				return nil, false
+			}
+			return []*promutil.Labels{
+				promutil.NewLabelsFromMap(map[string]string{
+					"__address__": addr,
+				}),
+			}, true
+		}
+	}
+	f := func(dc *diskCache, resultExpected string) {
+		t.Helper()
+		labels, ok := dc.getPersistedLabels("foo")
+		var a []string
+		if !ok {
+			a = append(a, "missing")
+		}
+		for _, x := range labels {
+			a = append(a, x.String())
+		}
+		result := strings.Join(a, ",")
+		if result != resultExpected {
+			t.Fatalf("unexpected result; got %s; want %s", result, resultExpected)
+		}
+	}
+
+	// There are no persisted labels at the first start.
+	dc := newDiscoveryCache(dir, time.Hour)
+	f(dc, `missing`)
+
+	// The labels aren't persisted until they are discovered.
+	dc.persistLabels("foo", newLabelsGetter("", false))
+	dc.persistLabels("foo", newLabelsGetter("host1", true))
+	if getLabelsCalls != 2 {
+		t.Fatalf("unexpected number of getLabels calls; got %d; want 2", getLabelsCalls)
+	}
+
+	// The labels aren't obtained again until the persist interval passes.
+	dc.persistLabels("foo", newLabelsGetter("host2", true))
+	if getLabelsCalls != 2 {
+		t.Fatalf("unexpected number of getLabels calls; got %d; want 2", getLabelsCalls)
+	}
+
+	// The persisted labels are returned only once after the restart.
+	dc = newDiscoveryCache(dir, time.Hour)
+	f(dc, `{__address__="host1"}`)
+	f(dc, `missing`)
+
+	// The persisted labels aren't returned after persistLabels call.
+	dc = newDiscoveryCache(dir, time.Hour)
+	dc.persistLabels("foo", newLabelsGetter("host3", true))
+	f(dc, `missing`)
+	dc = newDiscoveryCache(dir, time.Hour)
+	f(dc, `{__address__="host3"}`)
+}
@@ -0,0 +1,168 @@
+package promscrape
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os/exec"
+	"sort"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/chunkedbuffer"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promauth"
+)
+
+var execEnable = flag.Bool("promscrape.exec.enable", false, "Whether to allow `exec_configs` in -promscrape.config. "+
+	"It is disabled by default, since the configured commands are executed with the privileges of the current process. "+
+	"See https://docs.victoriametrics.com/victoriametrics/sd_configs/#exec_configs")
+
+// ExecConfig represents `exec` section at `exec_configs` entries in -promscrape.config.
+//
+// See https://docs.victoriametrics.com/victoriametrics/sd_configs/#exec_configs
+type ExecConfig struct {
+	// Command is the command to run together with its args.
+	Command []string `yaml:"command"`
+
+	// Env contains environment variables to pass to the command.
+	//
+	// Values are hidden at /config page, since they may contain secrets.
+	Env map[string]*promauth.Secret `yaml:"env,omitempty"`
+
+	// Dir is an optional working directory for the command.
+	Dir string `yaml:"dir,omitempty"`
+}
+
+func (ec *ExecConfig) validate() error {
+	if len(ec.Command) == 0 || ec.Command[0] == "" {
+		return fmt.Errorf("missing `command`")
+	}
+	return nil
+}
+
+// String returns string representation for ec.
+func (ec *ExecConfig) String() string {
+	if ec == nil {
+		return ""
+	}
+	data, err := json.Marshal(ec)
+	if err != nil {
+		logger.Panicf("BUG: cannot marshal ExecConfig: %s", err)
+	}
+	return string(data)
+}
+
+// maxExecStderrSize is the maximum size of stderr output from the command, which is included in error message.
+const maxExecStderrSize = 1024
+
+// execer runs the configured command for the given ScrapeWork and reads metrics in Prometheus text exposition format from its stdout.
+type execer struct {
+	ctx           context.Context
+	sw            *ScrapeWork
+	env           []string
+	timeout       time.Duration
+	maxScrapeSize int64
+}
+
+func newExecer(ctx context.Context, sw *ScrapeWork) *execer {
+	ec := sw.Exec
+	// Do not pass the environment of the current process to the command, since it may contain secrets.
+	env := []string{"SCRAPE_URL=" + sw.ScrapeURL, "SCRAPE_INSTANCE=" + sw.Labels.Get("instance"), "SCRAPE_JOB=" + sw.Job()}
+	envKeys := make([]string, 0, len(ec.Env))
+	for k := range ec.Env {
+		envKeys = append(envKeys, k)
+	}
+	sort.Strings(envKeys)
+	for _, k := range envKeys {
+		env = append(env, k+"="+ec.Env[k].String())
+	}
+	return &execer{
+		ctx:           ctx,
+		sw:            sw,
+		env:           env,
+		timeout:       sw.ScrapeTimeout,
+		maxScrapeSize: sw.MaxScrapeSize,
+	}
+}
+
+// ReadData runs the command and reads its stdout into dst.
+func (e *execer) ReadData(dst *chunkedbuffer.Buffer) (bool, error) {
+	ctx, cancel := context.WithTimeout(e.ctx, e.timeout)
+	defer cancel()
+
+	ec := e.sw.Exec
+	cmd := exec.CommandContext(ctx, ec.Command[0], ec.Command[1:]...)
+	cmd.Env = e.env
+	cmd.Dir = ec.Dir
+	// Do not wait for child processes, which keep stdout or stderr open after the command is killed.
+	cmd.WaitDelay = time.Second
+	var stderr limitedBuffer
+	stderr.limit = maxExecStderrSize
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return false, fmt.Errorf("cannot create stdout pipe for command %q: %w", ec.Command, err)
+	}
+
+	execRuns.Inc()
+	if err := cmd.Start(); err != nil {
+		execErrors.Inc()
+		return false, fmt.Errorf("cannot start command %q: %w", ec.Command, err)
+	}
+	_, readErr := dst.ReadFrom(io.LimitReader(stdout, e.maxScrapeSize))
+	sizeExceeded := int64(dst.Len()) >= e.maxScrapeSize
+	if sizeExceeded {
+		// Stop the command, since its output is ignored anyway.
+		cancel()
+	}
+	waitErr := cmd.Wait()
+	if sizeExceeded {
+		execErrors.Inc()
+		maxScrapeSizeExceeded.Inc()
+		return false, fmt.Errorf("the output of command %q exceeds -promscrape.maxScrapeSize or max_scrape_size in the scrape config (%d bytes). "+
+			"Possible solutions are: reduce the output size for the command, increase -promscrape.maxScrapeSize command-line flag, "+
+			"increase max_scrape_size value in scrape config for the given target", ec.Command, e.maxScrapeSize)
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		execErrors.Inc()
+		scrapesTimedout.Inc()
+		return false, fmt.Errorf("command %q didn't finish in %s", ec.Command, e.timeout)
+	}
+	if waitErr != nil {
+		execErrors.Inc()
+		return false, fmt.Errorf("command %q failed: %w; stderr: %q", ec.Command, waitErr, stderr.b.Bytes())
+	}
+	if readErr != nil {
+		execErrors.Inc()
+		return false, fmt.Errorf("cannot read the output of command %q: %w", ec.Command, readErr)
+	}
+	return false, nil
+}
+
+// limitedBuffer is an io.Writer, which stores up to limit bytes and silently drops the rest.
+type limitedBuffer struct {
+	b     bytes.Buffer
+	limit int
+}
+
+func (lb *limitedBuffer) Write(p []byte) (int, error) {
+	if n := lb.limit - lb.b.Len(); n > 0 {
+		if len(p) > n {
+			lb.b.Write(p[:n])
+		} else {
+			lb.b.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+var (
+	execRuns   = metrics.NewCounter(`vm_promscrape_exec_runs_total`)
+	execErrors = metrics.NewCounter(`vm_promscrape_exec_errors_total`)
+)
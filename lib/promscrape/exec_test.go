@@ -0,0 +1,149 @@
+package promscrape
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/chunkedbuffer"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promauth"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutil"
+)
+
+func TestExecConfigValidateFailure(t *testing.T) {
+	f := func(ec *ExecConfig) {
+		t.Helper()
+
+		if err := ec.validate(); err == nil {
+			t.Fatalf("expecting non-nil error for %s", ec)
+		}
+	}
+
+	f(&ExecConfig{})
+	f(&ExecConfig{Command: []string{""}})
+}
+
+func TestExecConfigMarshalYAML(t *testing.T) {
+	ec := &ExecConfig{
+		Command: []string{"foo"},
+		Env: map[string]*promauth.Secret{
+			"TOKEN": promauth.NewSecret("some-token"),
+		},
+	}
+	data, err := yaml.Marshal(ec)
+	if err != nil {
+		t.Fatalf("cannot marshal exec config: %s", err)
+	}
+	resultExpected := `command:
+- foo
+env:
+  TOKEN: <secret>
+`
+	if string(data) != resultExpected {
+		t.Fatalf("unexpected marshaled exec config\ngot\n%s\nwant\n%s", data, resultExpected)
+	}
+}
+
+func newTestExecScrapeWork(ec *ExecConfig) *ScrapeWork {
+	return &ScrapeWork{
+		ScrapeURL:     "http://foo/metrics",
+		ScrapeTimeout: 5 * time.Second,
+		MaxScrapeSize: 1000,
+		Labels: promutil.NewLabelsFromMap(map[string]string{
+			"instance": "foo:80",
+			"job":      "exec",
+		}),
+		Exec: ec,
+	}
+}
+
+func TestExecerReadDataSuccess(t *testing.T) {
+	f := func(ec *ExecConfig, resultExpected string) {
+		t.Helper()
+
+		e := newExecer(context.Background(), newTestExecScrapeWork(ec))
+		var cb chunkedbuffer.Buffer
+		isGzipped, err := e.ReadData(&cb)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if isGzipped {
+			t.Fatalf("the output mustn't be gzipped")
+		}
+		data, err := io.ReadAll(cb.NewReader())
+		if err != nil {
+			t.Fatalf("cannot read command output: %s", err)
+		}
+		if string(data) != resultExpected {
+			t.Fatalf("unexpected output; got %q; want %q", data, resultExpected)
+		}
+	}
+
+	f(&ExecConfig{
+		Command: []string{"sh", "-c", `echo 'foo{bar="baz"} 123'`},
+	}, "foo{bar=\"baz\"} 123\n")
+
+	// env vars
+	f(&ExecConfig{
+		Command: []string{"sh", "-c", `echo "foo{instance=\"$SCRAPE_INSTANCE\",job=\"$SCRAPE_JOB\",url=\"$SCRAPE_URL\"} $VALUE"`},
+		Env: map[string]*promauth.Secret{
+			"VALUE": promauth.NewSecret("42"),
+		},
+	}, "foo{instance=\"foo:80\",job=\"exec\",url=\"http://foo/metrics\"} 42\n")
+
+	// env vars of the current process mustn't be passed to the command
+	t.Setenv("VM_EXEC_TEST_SECRET", "secret")
+	f(&ExecConfig{
+		Command: []string{"sh", "-c", `echo "foo{secret=\"$VM_EXEC_TEST_SECRET\"} 1"`},
+	}, "foo{secret=\"\"} 1\n")
+
+	// working dir
+	f(&ExecConfig{
+		Command: []string{"sh", "-c", `echo "foo{dir=\"$(pwd)\"} 1"`},
+		Dir:     "/",
+	}, "foo{dir=\"/\"} 1\n")
+}
+
+func TestExecerReadDataFailure(t *testing.T) {
+	f := func(ec *ExecConfig, timeout time.Duration, errExpected string) {
+		t.Helper()
+
+		sw := newTestExecScrapeWork(ec)
+		if timeout > 0 {
+			sw.ScrapeTimeout = timeout
+		}
+		e := newExecer(context.Background(), sw)
+		var cb chunkedbuffer.Buffer
+		_, err := e.ReadData(&cb)
+		if err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+		if !strings.Contains(err.Error(), errExpected) {
+			t.Fatalf("unexpected error; got %q; want it containing %q", err, errExpected)
+		}
+	}
+
+	// missing command
+	f(&ExecConfig{
+		Command: []string{"/non-existing-command"},
+	}, 0, "cannot start command")
+
+	// non-zero exit code
+	f(&ExecConfig{
+		Command: []string{"sh", "-c", "echo 'some error' >&2; exit 3"},
+	}, 0, `stderr: "some error\n"`)
+
+	// timeout
+	f(&ExecConfig{
+		Command: []string{"sleep", "10"},
+	}, 100*time.Millisecond, "didn't finish in 100ms")
+
+	// too big output
+	f(&ExecConfig{
+		Command: []string{"sh", "-c", "while true; do echo 'foo 1'; done"},
+	}, 0, "exceeds -promscrape.maxScrapeSize")
+}
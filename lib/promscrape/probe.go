@@ -0,0 +1,376 @@
+package promscrape
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/chunkedbuffer"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/netutil"
+)
+
+// ProbeConfig represents `probe` section at `probe_configs` entries in -promscrape.config.
+//
+// See https://docs.victoriametrics.com/victoriametrics/sd_configs/#probe_configs
+type ProbeConfig struct {
+	// Prober is the probe type. Supported values: http, tcp, tls and dns.
+	Prober string `yaml:"prober"`
+
+	HTTP *HTTPProbeConfig `yaml:"http,omitempty"`
+	DNS  *DNSProbeConfig  `yaml:"dns,omitempty"`
+}
+
+// HTTPProbeConfig represents `http` section at ProbeConfig.
+type HTTPProbeConfig struct {
+	// Method is HTTP method to use for the probe. By default GET is used.
+	Method string `yaml:"method,omitempty"`
+
+	// ValidStatusCodes is the list of status codes, which are considered successful. By default 2xx status codes are accepted.
+	ValidStatusCodes []int `yaml:"valid_status_codes,omitempty"`
+}
+
+// DNSProbeConfig represents `dns` section at ProbeConfig.
+type DNSProbeConfig struct {
+	// QueryName is the name to resolve at the probed DNS server.
+	QueryName string `yaml:"query_name"`
+
+	// QueryType is DNS record type to resolve. Supported values: A, AAAA, CNAME, MX, NS and TXT. By default A is used.
+	QueryType string `yaml:"query_type,omitempty"`
+}
+
+var supportedProbers = []string{"http", "tcp", "tls", "dns"}
+
+func (pc *ProbeConfig) validate() error {
+	if !slices.Contains(supportedProbers, pc.Prober) {
+		return fmt.Errorf("unsupported `prober: %q`; supported values: %s", pc.Prober, strings.Join(supportedProbers, ", "))
+	}
+	if pc.HTTP != nil && pc.Prober != "http" {
+		return fmt.Errorf("`http` section can be set only for `prober: http`")
+	}
+	if pc.DNS != nil && pc.Prober != "dns" {
+		return fmt.Errorf("`dns` section can be set only for `prober: dns`")
+	}
+	if pc.Prober == "dns" {
+		if pc.DNS == nil || pc.DNS.QueryName == "" {
+			return fmt.Errorf("missing `dns.query_name` for `prober: dns`")
+		}
+		switch strings.ToUpper(pc.DNS.QueryType) {
+		case "", "A", "AAAA", "CNAME", "MX", "NS", "TXT":
+		default:
+			return fmt.Errorf("unsupported `dns.query_type: %q`; supported values: A, AAAA, CNAME, MX, NS, TXT", pc.DNS.QueryType)
+		}
+	}
+	if pc.HTTP != nil {
+		for _, code := range pc.HTTP.ValidStatusCodes {
+			if code < 100 || code > 599 {
+				return fmt.Errorf("invalid status code in `http.valid_status_codes`: %d", code)
+			}
+		}
+	}
+	return nil
+}
+
+// needsTargetPort returns true if the probed targets must contain an explicit port for pc.
+func (pc *ProbeConfig) needsTargetPort() bool {
+	return pc.Prober == "tcp" || pc.Prober == "tls"
+}
+
+// String returns string representation for pc.
+func (pc *ProbeConfig) String() string {
+	if pc == nil {
+		return ""
+	}
+	data, err := json.Marshal(pc)
+	if err != nil {
+		logger.Panicf("BUG: cannot marshal ProbeConfig: %s", err)
+	}
+	return string(data)
+}
+
+// prober performs probes for the given ScrapeWork and returns the results in Prometheus text exposition format.
+type prober struct {
+	ctx     context.Context
+	sw      *ScrapeWork
+	pc      *ProbeConfig
+	timeout time.Duration
+
+	// c is used for http probes
+	c *client
+
+	dialer *net.Dialer
+}
+
+func newProber(ctx context.Context, sw *ScrapeWork) (*prober, error) {
+	p := &prober{
+		ctx:     ctx,
+		sw:      sw,
+		pc:      sw.Probe,
+		timeout: sw.ScrapeTimeout,
+		dialer: &net.Dialer{
+			Timeout: sw.ScrapeTimeout,
+		},
+	}
+	if p.pc.Prober == "http" {
+		c, err := newClient(ctx, sw)
+		if err != nil {
+			return nil, err
+		}
+		p.c = c
+	}
+	return p, nil
+}
+
+// ReadData performs the probe and writes the results to dst.
+//
+// Probe failures aren't returned as errors - they are reported via probe_success metric instead.
+func (p *prober) ReadData(dst *chunkedbuffer.Buffer) (bool, error) {
+	ctx, cancel := context.WithTimeout(p.ctx, p.timeout)
+	defer cancel()
+
+	var pr probeResult
+	startTime := time.Now()
+	var err error
+	switch p.pc.Prober {
+	case "http":
+		err = p.probeHTTP(ctx, &pr)
+	case "tcp":
+		err = p.probeTCP(ctx, &pr)
+	case "tls":
+		err = p.probeTLS(ctx, &pr)
+	case "dns":
+		err = p.probeDNS(ctx, &pr)
+	default:
+		logger.Panicf("BUG: unexpected prober %q", p.pc.Prober)
+	}
+	duration := time.Since(startTime)
+	probesTotal.Inc()
+	if err != nil {
+		probesFailed.Inc()
+		if !*suppressScrapeErrors {
+			logger.Warnf("probe %q for job %q failed: %s; probe errors can be disabled by -promscrape.suppressScrapeErrors command-line flag", p.sw.ScrapeURL, p.sw.Job(), err)
+		}
+	}
+
+	var b []byte
+	b = appendProbeMetric(b, "probe_success", boolToFloat(err == nil))
+	b = appendProbeMetric(b, "probe_duration_seconds", duration.Seconds())
+	b = append(b, pr.metrics...)
+	dst.MustWrite(b)
+	return false, nil
+}
+
+type probeResult struct {
+	metrics []byte
+}
+
+func (pr *probeResult) add(name string, value float64) {
+	pr.metrics = appendProbeMetric(pr.metrics, name, value)
+}
+
+func (pr *probeResult) addTLSMetrics(cs *tls.ConnectionState) {
+	pr.add("probe_ssl_earliest_cert_expiry", float64(getEarliestCertExpiry(cs.PeerCertificates).Unix()))
+	pr.metrics = fmt.Appendf(pr.metrics, "probe_tls_version_info{version=%q} 1\n", tls.VersionName(cs.Version))
+}
+
+func appendProbeMetric(dst []byte, name string, value float64) []byte {
+	return fmt.Appendf(dst, "%s %g\n", name, value)
+}
+
+func boolToFloat(v bool) float64 {
+	if v {
+		return 1
+	}
+	return 0
+}
+
+func getEarliestCertExpiry(certs []*x509.Certificate) time.Time {
+	earliest := time.Unix(math.MaxInt32, 0)
+	for _, cert := range certs {
+		if cert.NotAfter.Before(earliest) {
+			earliest = cert.NotAfter
+		}
+	}
+	return earliest
+}
+
+func (p *prober) probeHTTP(ctx context.Context, pr *probeResult) error {
+	c := p.c
+	method := http.MethodGet
+	var validStatusCodes []int
+	if hc := p.pc.HTTP; hc != nil {
+		if hc.Method != "" {
+			method = strings.ToUpper(hc.Method)
+		}
+		validStatusCodes = hc.ValidStatusCodes
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.requestURL, nil)
+	if err != nil {
+		return fmt.Errorf("cannot create request for %q: %w", c.scrapeURL, err)
+	}
+	req.Header.Set("User-Agent", "vm_promscrape")
+	if err := c.setHeaders(req); err != nil {
+		return fmt.Errorf("failed to set request headers for %q: %w", c.scrapeURL, err)
+	}
+	if err := c.setProxyHeaders(req); err != nil {
+		return fmt.Errorf("failed to set proxy request headers for %q: %w", c.scrapeURL, err)
+	}
+	resp, err := c.c.Do(req)
+	if err != nil {
+		return fmt.Errorf("cannot perform request to %q: %w", c.scrapeURL, err)
+	}
+	defer resp.Body.Close()
+
+	n, err := io.Copy(io.Discard, io.LimitReader(resp.Body, c.maxScrapeSize))
+	pr.add("probe_http_status_code", float64(resp.StatusCode))
+	pr.add("probe_http_content_length", float64(n))
+	pr.add("probe_http_ssl", boolToFloat(resp.TLS != nil))
+	if resp.TLS != nil {
+		pr.addTLSMetrics(resp.TLS)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot read response body from %q: %w", c.scrapeURL, err)
+	}
+
+	if len(validStatusCodes) > 0 {
+		if !slices.Contains(validStatusCodes, resp.StatusCode) {
+			return fmt.Errorf("unexpected status code returned from %q: %d; expecting %d", c.scrapeURL, resp.StatusCode, validStatusCodes)
+		}
+	} else if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status code returned from %q: %d; expecting 2xx", c.scrapeURL, resp.StatusCode)
+	}
+	return nil
+}
+
+func (p *prober) probeTCP(ctx context.Context, _ *probeResult) error {
+	addr, err := p.getTargetAddr("")
+	if err != nil {
+		return err
+	}
+	conn, err := p.dialer.DialContext(ctx, netutil.GetTCPNetwork(), addr)
+	if err != nil {
+		return fmt.Errorf("cannot connect to %q: %w", addr, err)
+	}
+	_ = conn.Close()
+	return nil
+}
+
+func (p *prober) probeTLS(ctx context.Context, pr *probeResult) error {
+	addr, err := p.getTargetAddr("")
+	if err != nil {
+		return err
+	}
+	tlsCfg, err := p.sw.AuthConfig.GetTLSConfig()
+	if err != nil {
+		return fmt.Errorf("cannot initialize tls config: %w", err)
+	}
+	if tlsCfg == nil {
+		tlsCfg = &tls.Config{}
+	} else {
+		tlsCfg = tlsCfg.Clone()
+	}
+	if tlsCfg.ServerName == "" {
+		host, _, _ := net.SplitHostPort(addr)
+		tlsCfg.ServerName = host
+	}
+	d := &tls.Dialer{
+		NetDialer: p.dialer,
+		Config:    tlsCfg,
+	}
+	conn, err := d.DialContext(ctx, netutil.GetTCPNetwork(), addr)
+	if err != nil {
+		return fmt.Errorf("cannot establish tls connection to %q: %w", addr, err)
+	}
+	defer conn.Close()
+	cs := conn.(*tls.Conn).ConnectionState()
+	pr.addTLSMetrics(&cs)
+	return nil
+}
+
+func (p *prober) probeDNS(ctx context.Context, pr *probeResult) error {
+	addr, err := p.getTargetAddr("53")
+	if err != nil {
+		return err
+	}
+	r := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return p.dialer.DialContext(ctx, network, addr)
+		},
+	}
+	dc := p.pc.DNS
+	var answers int
+	startTime := time.Now()
+	switch strings.ToUpper(dc.QueryType) {
+	case "", "A":
+		var ips []net.IP
+		ips, err = r.LookupIP(ctx, "ip4", dc.QueryName)
+		answers = len(ips)
+	case "AAAA":
+		var ips []net.IP
+		ips, err = r.LookupIP(ctx, "ip6", dc.QueryName)
+		answers = len(ips)
+	case "CNAME":
+		var cname string
+		cname, err = r.LookupCNAME(ctx, dc.QueryName)
+		if cname != "" {
+			answers = 1
+		}
+	case "MX":
+		var mxs []*net.MX
+		mxs, err = r.LookupMX(ctx, dc.QueryName)
+		answers = len(mxs)
+	case "NS":
+		var nss []*net.NS
+		nss, err = r.LookupNS(ctx, dc.QueryName)
+		answers = len(nss)
+	case "TXT":
+		var txts []string
+		txts, err = r.LookupTXT(ctx, dc.QueryName)
+		answers = len(txts)
+	}
+	pr.add("probe_dns_lookup_time_seconds", time.Since(startTime).Seconds())
+	pr.add("probe_dns_answer_rrs", float64(answers))
+	if err != nil {
+		return fmt.Errorf("cannot resolve %s record for %q at %q: %w", dc.QueryType, dc.QueryName, addr, err)
+	}
+	if answers == 0 {
+		return fmt.Errorf("empty response for %s record for %q at %q", dc.QueryType, dc.QueryName, addr)
+	}
+	return nil
+}
+
+// getTargetAddr returns host:port address for the probed target.
+//
+// defaultPort is used if the target has no port. An error is returned if the target has no port and defaultPort is empty.
+func (p *prober) getTargetAddr(defaultPort string) (string, error) {
+	u, err := url.Parse(p.sw.ScrapeURL)
+	if err != nil {
+		return "", fmt.Errorf("cannot parse target %q: %w", p.sw.ScrapeURL, err)
+	}
+	if u.Port() != "" {
+		return u.Host, nil
+	}
+	if defaultPort == "" {
+		return "", fmt.Errorf("missing port in the target %q", u.Host)
+	}
+	return net.JoinHostPort(u.Hostname(), defaultPort), nil
+}
+
+var (
+	probesTotal  = metrics.NewCounter(`vm_promscrape_probes_total`)
+	probesFailed = metrics.NewCounter(`vm_promscrape_probes_failed_total`)
+)
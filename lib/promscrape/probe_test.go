@@ -0,0 +1,247 @@
+package promscrape
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/chunkedbuffer"
+)
+
+func TestProbeConfigValidateFailure(t *testing.T) {
+	f := func(pc *ProbeConfig) {
+		t.Helper()
+
+		if err := pc.validate(); err == nil {
+			t.Fatalf("expecting non-nil error for %s", pc)
+		}
+	}
+
+	// unsupported prober
+	f(&ProbeConfig{})
+	f(&ProbeConfig{Prober: "icmp"})
+
+	// sections for other probers
+	f(&ProbeConfig{Prober: "tcp", HTTP: &HTTPProbeConfig{}})
+	f(&ProbeConfig{Prober: "http", DNS: &DNSProbeConfig{QueryName: "foo"}})
+
+	// missing dns query_name
+	f(&ProbeConfig{Prober: "dns"})
+	f(&ProbeConfig{Prober: "dns", DNS: &DNSProbeConfig{}})
+
+	// unsupported dns query_type
+	f(&ProbeConfig{Prober: "dns", DNS: &DNSProbeConfig{QueryName: "foo", QueryType: "SRV"}})
+
+	// invalid status code
+	f(&ProbeConfig{Prober: "http", HTTP: &HTTPProbeConfig{ValidStatusCodes: []int{1000}}})
+}
+
+func TestProberReadData(t *testing.T) {
+	f := func(sw *ScrapeWork, linesExpected []string) {
+		t.Helper()
+
+		if sw.ScrapeTimeout == 0 {
+			sw.ScrapeTimeout = 5 * time.Second
+		}
+		if sw.MaxScrapeSize == 0 {
+			sw.MaxScrapeSize = 16000
+		}
+		if sw.AuthConfig == nil {
+			sw.AuthConfig = newTestAuthConfig(t, false, nil)
+		}
+		p, err := newProber(context.Background(), sw)
+		if err != nil {
+			t.Fatalf("cannot create prober: %s", err)
+		}
+		var cb chunkedbuffer.Buffer
+		isGzipped, err := p.ReadData(&cb)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if isGzipped {
+			t.Fatalf("the response mustn't be gzipped")
+		}
+		data, err := io.ReadAll(cb.NewReader())
+		if err != nil {
+			t.Fatalf("cannot read probe results: %s", err)
+		}
+		lines := strings.Split(string(data), "\n")
+		if !strings.HasPrefix(lines[1], "probe_duration_seconds ") {
+			t.Fatalf("missing probe_duration_seconds metric in the probe results:\n%s", data)
+		}
+		for _, lineExpected := range linesExpected {
+			found := false
+			for _, line := range lines {
+				if line == lineExpected || strings.HasSuffix(lineExpected, " ") && strings.HasPrefix(line, lineExpected) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				t.Fatalf("missing %q in the probe results:\n%s", lineExpected, data)
+			}
+		}
+	}
+
+	s := newClientTestServer(false, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte("hello"))
+	}))
+	defer s.Close()
+
+	tlsServer := newClientTestServer(true, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("OK"))
+	}))
+	defer tlsServer.Close()
+
+	// successful http probe
+	f(&ScrapeWork{
+		ScrapeURL: s.URL + "/",
+		Probe:     &ProbeConfig{Prober: "http"},
+	}, []string{
+		"probe_success 1",
+		"probe_http_status_code 200",
+		"probe_http_content_length 5",
+		"probe_http_ssl 0",
+	})
+
+	// http probe with unexpected status code
+	f(&ScrapeWork{
+		ScrapeURL: s.URL + "/missing",
+		Probe:     &ProbeConfig{Prober: "http"},
+	}, []string{
+		"probe_success 0",
+		"probe_http_status_code 404",
+	})
+
+	// http probe with custom valid status codes
+	f(&ScrapeWork{
+		ScrapeURL: s.URL + "/missing",
+		Probe: &ProbeConfig{
+			Prober: "http",
+			HTTP: &HTTPProbeConfig{
+				ValidStatusCodes: []int{404},
+			},
+		},
+	}, []string{
+		"probe_success 1",
+		"probe_http_status_code 404",
+	})
+
+	// https probe
+	f(&ScrapeWork{
+		ScrapeURL:  tlsServer.URL + "/",
+		AuthConfig: newTestAuthConfig(t, true, nil),
+		Probe:      &ProbeConfig{Prober: "http"},
+	}, []string{
+		"probe_success 1",
+		"probe_http_ssl 1",
+		"probe_ssl_earliest_cert_expiry ",
+		`probe_tls_version_info{version="TLS 1.3"} 1`,
+	})
+
+	// tcp probe
+	f(&ScrapeWork{
+		ScrapeURL: s.URL,
+		Probe:     &ProbeConfig{Prober: "tcp"},
+	}, []string{
+		"probe_success 1",
+	})
+
+	// tcp probe for the closed port
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot create listener: %s", err)
+	}
+	closedAddr := ln.Addr().String()
+	_ = ln.Close()
+	f(&ScrapeWork{
+		ScrapeURL: "http://" + closedAddr,
+		Probe:     &ProbeConfig{Prober: "tcp"},
+	}, []string{
+		"probe_success 0",
+	})
+
+	// tcp probe without port
+	f(&ScrapeWork{
+		ScrapeURL: "http://127.0.0.1/",
+		Probe:     &ProbeConfig{Prober: "tcp"},
+	}, []string{
+		"probe_success 0",
+	})
+
+	// tls probe
+	f(&ScrapeWork{
+		ScrapeURL:  tlsServer.URL,
+		AuthConfig: newTestAuthConfig(t, true, nil),
+		Probe:      &ProbeConfig{Prober: "tls"},
+	}, []string{
+		"probe_success 1",
+		"probe_ssl_earliest_cert_expiry ",
+	})
+
+	// tls probe without port
+	f(&ScrapeWork{
+		ScrapeURL:  "https://127.0.0.1/",
+		AuthConfig: newTestAuthConfig(t, true, nil),
+		Probe:      &ProbeConfig{Prober: "tls"},
+	}, []string{
+		"probe_success 0",
+	})
+
+	// tls probe for non-tls server
+	f(&ScrapeWork{
+		ScrapeURL:  s.URL,
+		AuthConfig: newTestAuthConfig(t, true, nil),
+		Probe:      &ProbeConfig{Prober: "tls"},
+	}, []string{
+		"probe_success 0",
+	})
+}
+
+func TestProbeConfigsTargetPort(t *testing.T) {
+	f := func(prober, target string, scrapeURLsExpected []string) {
+		t.Helper()
+
+		data := fmt.Sprintf(`
+probe_configs:
+- job_name: foo
+  probe:
+    prober: %s
+  static_configs:
+  - targets: [%q]
+`, prober, target)
+		sws, err := getStaticScrapeWork([]byte(data), "non-existing-file")
+		if err != nil {
+			t.Fatalf("cannot parse config: %s", err)
+		}
+		var scrapeURLs []string
+		for _, sw := range sws {
+			scrapeURLs = append(scrapeURLs, sw.ScrapeURL)
+		}
+		if !reflect.DeepEqual(scrapeURLs, scrapeURLsExpected) {
+			t.Fatalf("unexpected scrape urls for prober=%q, target=%q; got %q; want %q", prober, target, scrapeURLs, scrapeURLsExpected)
+		}
+	}
+
+	// tcp and tls targets without port are skipped, since the default port for the url scheme cannot be used for them
+	f("tcp", "foo.bar", nil)
+	f("tls", "foo.bar", nil)
+	f("tls", "https://foo.bar", nil)
+
+	// tcp and tls targets with port
+	f("tcp", "foo.bar:5432", []string{"http://foo.bar:5432/"})
+	f("tls", "foo.bar:8443", []string{"http://foo.bar:8443/"})
+
+	// http target without port
+	f("http", "foo.bar", []string{"http://foo.bar/"})
+}
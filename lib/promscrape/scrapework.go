@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"math"
 	"math/bits"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -43,6 +44,8 @@ var (
 		"See also -promscrape.suppressScrapeErrorsDelay")
 	suppressScrapeErrorsDelay = flag.Duration("promscrape.suppressScrapeErrorsDelay", 0, "The delay for suppressing repeated scrape errors logging per each scrape targets. "+
 		"This may be used for reducing the number of log lines related to scrape errors. See also -promscrape.suppressScrapeErrors")
+	extraAutoMetrics = flag.Bool("promscrape.extraAutoMetrics", false, "Whether to generate extra automatically generated metrics per each scrape target such as scrape_failures_streak. "+
+		"See https://docs.victoriametrics.com/victoriametrics/vmagent/#automatically-generated-metrics")
 	minResponseSizeForStreamParse = flagutil.NewBytes("promscrape.minResponseSizeForStreamParse", 1e6, "The minimum target response size for automatic switching to stream parsing mode, which can reduce memory usage. See https://docs.victoriametrics.com/victoriametrics/vmagent/#stream-parsing-mode")
 )
 
@@ -142,6 +145,10 @@ type ScrapeWork struct {
 	// The offset for the first scrape.
 	ScrapeOffset time.Duration
 
+	// The maximum interval between scrape attempts for consistently failing target.
+	// Backoff is disabled if it doesn't exceed ScrapeInterval.
+	ScrapeBackoffMax time.Duration
+
 	// Optional limit on the number of unique series the scrape target can expose.
 	SeriesLimit int
 
@@ -155,14 +162,34 @@ type ScrapeWork struct {
 	// The Tenant Info
 	AuthToken *auth.Token
 
+	// Optional probe config. If set, then the target is probed instead of scraping metrics from it.
+	// See https://docs.victoriametrics.com/victoriametrics/sd_configs/#probe_configs
+	Probe *ProbeConfig
+
+	// Optional cardinality guard config.
+	// See https://docs.victoriametrics.com/victoriametrics/vmagent/#cardinality-guard
+	CardinalityGuard *CardinalityGuardConfig
+
+	// Whether to add zero samples at creation timestamps for new series with OpenMetrics `_created` series.
+	// See https://docs.victoriametrics.com/victoriametrics/vmagent/#created-timestamps
+	CreatedTimestampZeroIngestion bool
+
+	// Optional exec config. If set, then metrics are read from the output of the configured command instead of scraping the target.
+	// See https://docs.victoriametrics.com/victoriametrics/sd_configs/#exec_configs
+	Exec *ExecConfig
+
 	// The original 'job_name'
 	jobNameOriginal string
+
+	// The key for distributing the target among cluster members when dynamic cluster membership is enabled.
+	// See filterScrapeWorkByClusterMembership.
+	clusterShardKey string
 }
 
 func (sw *ScrapeWork) canSwitchToStreamParseMode() bool {
-	// Deny switching to stream parse mode if `sample_limit` or `series_limit` options are set,
-	// since these limits cannot be applied in stream parsing mode.
-	return sw.SampleLimit <= 0 && sw.SeriesLimit <= 0
+	// Deny switching to stream parse mode if `sample_limit`, `series_limit`, `cardinality_guard`
+	// or `created_timestamp_zero_ingestion` options are set, since they cannot be applied in stream parsing mode.
+	return sw.SampleLimit <= 0 && sw.SeriesLimit <= 0 && sw.CardinalityGuard == nil && !sw.CreatedTimestampZeroIngestion
 }
 
 // key returns unique identifier for the given sw.
@@ -176,12 +203,12 @@ func (sw *ScrapeWork) key() string {
 		"HonorTimestamps=%v, DenyRedirects=%v, Labels=%s, ExternalLabels=%s, MaxScrapeSize=%d, "+
 		"ProxyURL=%s, ProxyAuthConfig=%s, AuthConfig=%s, MetricRelabelConfigs=%q, "+
 		"SampleLimit=%d, DisableCompression=%v, DisableKeepAlive=%v, StreamParse=%v, "+
-		"ScrapeAlignInterval=%s, ScrapeOffset=%s, SeriesLimit=%d, LabelLimit=%d, NoStaleMarkers=%v",
+		"ScrapeAlignInterval=%s, ScrapeOffset=%s, ScrapeBackoffMax=%s, SeriesLimit=%d, LabelLimit=%d, NoStaleMarkers=%v, Probe=%s, Exec=%s, CardinalityGuard=%s, CreatedTimestampZeroIngestion=%v",
 		sw.jobNameOriginal, sw.ScrapeURL, sw.ScrapeInterval, sw.ScrapeTimeout, sw.HonorLabels,
 		sw.HonorTimestamps, sw.DenyRedirects, sw.Labels.String(), sw.ExternalLabels.String(), sw.MaxScrapeSize,
 		sw.ProxyURL.String(), sw.ProxyAuthConfig.String(), sw.AuthConfig.String(), sw.MetricRelabelConfigs.String(),
 		sw.SampleLimit, sw.DisableCompression, sw.DisableKeepAlive, sw.StreamParse,
-		sw.ScrapeAlignInterval, sw.ScrapeOffset, sw.SeriesLimit, sw.LabelLimit, sw.NoStaleMarkers)
+		sw.ScrapeAlignInterval, sw.ScrapeOffset, sw.ScrapeBackoffMax, sw.SeriesLimit, sw.LabelLimit, sw.NoStaleMarkers, sw.Probe.String(), sw.Exec.String(), sw.CardinalityGuard.String(), sw.CreatedTimestampZeroIngestion)
 	return key
 }
 
@@ -242,6 +269,47 @@ type scrapeWork struct {
 
 	// successRequestsCount is the number of success requests during the last suppressScrapeErrorsDelay
 	successRequestsCount int
+
+	// failuresStreak is the number of consecutive failed scrapes for the given scrape work.
+	// It is exposed via scrape_failures_streak metric.
+	failuresStreak int
+
+	// backoffFailedAttempts is the number of consecutive failed scrape attempts, which weren't skipped because of backoff.
+	// It is used for calculating backoffSkipsLeft when ScrapeBackoffMax is set.
+	backoffFailedAttempts int
+
+	// backoffSkipsLeft is the number of upcoming scrapes to skip because of backoff.
+	backoffSkipsLeft int
+
+	// cardinalityBaseline contains cardinality stats for the last scrape, which didn't exceed the cardinality guard threshold.
+	// It is used for detecting metrics and labels responsible for cardinality growth.
+	cardinalityBaseline map[string]*metricCardinality
+
+	// cardinalityBaselineTimestamp is the timestamp in milliseconds when cardinalityBaseline was calculated.
+	cardinalityBaselineTimestamp int64
+
+	// cardinalityOffenders contains offenders detected by the cardinality guard while the threshold is exceeded.
+	cardinalityOffenders []cardinalityOffender
+
+	// cardinalityOffendersTimestamp is the timestamp in milliseconds when cardinalityOffenders were detected.
+	//
+	// It is set to zero when the threshold isn't exceeded.
+	cardinalityOffendersTimestamp int64
+
+	// cardinalityDroppedLabels contains metric name -> label name map for labels dropped by the cardinality guard at the last scrape.
+	cardinalityDroppedLabels map[string]string
+
+	// cardinalityStaleSkipMetrics contains metric names with labels dropped by the cardinality guard at the last two scrapes.
+	// Stale markers aren't sent for these metrics while the target is alive, since they may be still written without the dropped labels.
+	cardinalityStaleSkipMetrics map[string]struct{}
+
+	// createdTimestamps contains creation timestamps from OpenMetrics `_created` series at the last scrape.
+	createdTimestamps parser.CreatedTimestamps
+
+	// seriesCreatedTimestamps contains creation timestamps per each series, which have been seen at the last scrape.
+	// It is used for detecting new series, which need zero sample at creation timestamp.
+	seriesCreatedTimestamps     map[string]int64
+	prevSeriesCreatedTimestamps map[string]int64
 }
 
 // loadLastScrape appends last scrape response to dst and returns the result.
@@ -290,7 +358,11 @@ func (sw *scrapeWork) run(stopCh <-chan struct{}, globalStopCh <-chan struct{})
 		// scrapes replicated targets at different time offsets. This guarantees that the deduplication consistently leaves samples
 		// received from the same vmagent replica.
 		// See https://docs.victoriametrics.com/victoriametrics/vmagent/#scraping-big-number-of-targets
-		key := fmt.Sprintf("clusterName=%s, clusterMemberID=%d, ScrapeURL=%s, Labels=%s", *clusterName, clusterMemberID, sw.Config.ScrapeURL, sw.Config.Labels.String())
+		memberID := strconv.Itoa(clusterMemberID)
+		if clusterMembershipGlobal != nil {
+			memberID = *clusterMemberAddr
+		}
+		key := fmt.Sprintf("clusterName=%s, clusterMemberID=%s, ScrapeURL=%s, Labels=%s", *clusterName, memberID, sw.Config.ScrapeURL, sw.Config.Labels.String())
 		h := xxhash.Sum64(bytesutil.ToUnsafeBytes(key))
 		randSleep = uint64(float64(scrapeInterval) * (float64(h) / (1 << 64)))
 		sleepOffset := uint64(time.Now().UnixNano()) % uint64(scrapeInterval)
@@ -373,7 +445,14 @@ func (sw *scrapeWork) logError(s string) {
 }
 
 func (sw *scrapeWork) scrapeAndLogError(scrapeTimestamp, realTimestamp int64) {
+	if sw.backoffSkipsLeft > 0 {
+		// Do not scrape the target, which consistently fails, but still generate up=0 at the usual scrape_interval.
+		sw.backoffSkipsLeft--
+		sw.skipScrape(scrapeTimestamp, realTimestamp)
+		return
+	}
 	err := sw.scrapeInternal(scrapeTimestamp, realTimestamp)
+	sw.updateBackoff(err)
 	if *suppressScrapeErrors {
 		return
 	}
@@ -398,7 +477,48 @@ func (sw *scrapeWork) scrapeAndLogError(scrapeTimestamp, realTimestamp int64) {
 	sw.successRequestsCount = 0
 }
 
+// updateBackoff updates the number of upcoming scrapes to skip according to the err returned by the last scrape attempt.
+//
+// The interval between scrape attempts is doubled after every failed attempt starting from the second one,
+// until it reaches ScrapeBackoffMax. The first successful attempt resets the backoff.
+func (sw *scrapeWork) updateBackoff(err error) {
+	if err == nil {
+		sw.backoffFailedAttempts = 0
+		sw.backoffSkipsLeft = 0
+		return
+	}
+	sw.backoffFailedAttempts++
+	sw.backoffSkipsLeft = getBackoffSkips(sw.backoffFailedAttempts, sw.Config.ScrapeInterval, sw.Config.ScrapeBackoffMax)
+}
+
+// getBackoffSkips returns the number of scrapes to skip after the given number of consecutive failed scrape attempts.
+func getBackoffSkips(failedAttempts int, scrapeInterval, backoffMax time.Duration) int {
+	if backoffMax <= scrapeInterval || scrapeInterval <= 0 || failedAttempts <= 1 {
+		return 0
+	}
+	maxIntervals := int(backoffMax / scrapeInterval)
+	intervals := maxIntervals
+	if failedAttempts <= 31 {
+		intervals = min(1<<(failedAttempts-1), maxIntervals)
+	}
+	return intervals - 1
+}
+
+// skipScrape generates up=0 for the target, which isn't scraped because of backoff.
+func (sw *scrapeWork) skipScrape(scrapeTimestamp, realTimestamp int64) {
+	scrapesSkippedByBackoff.Inc()
+
+	processScrapedDataConcurrencyLimitCh <- struct{}{}
+	err := fmt.Errorf("%w after %d consecutive failed scrape attempts; the next scrape attempt will be made in %d scrape intervals",
+		errScrapeBackoff, sw.backoffFailedAttempts, sw.backoffSkipsLeft+1)
+	_ = sw.processDataOneShot(scrapeTimestamp, realTimestamp, nil, 0, err)
+	<-processScrapedDataConcurrencyLimitCh
+}
+
+var errScrapeBackoff = errors.New("the scrape is skipped because of scrape_backoff_max_interval")
+
 var (
+	scrapesSkippedByBackoff     = metrics.NewCounter("vm_promscrape_scrapes_skipped_by_backoff_total")
 	scrapeDuration              = metrics.NewHistogram("vm_promscrape_scrape_duration_seconds")
 	scrapeResponseSize          = metrics.NewHistogram("vm_promscrape_scrape_response_size_bytes")
 	scrapedSamples              = metrics.NewHistogram("vm_promscrape_scraped_samples")
@@ -518,7 +638,9 @@ func (sw *scrapeWork) processDataOneShot(scrapeTimestamp, realTimestamp int64, b
 	wc := writeRequestCtxPool.Get(sw.prevLabelsLen)
 	if err != nil {
 		up = 0
-		scrapesFailed.Inc()
+		if !errors.Is(err, errScrapeBackoff) {
+			scrapesFailed.Inc()
+		}
 	} else {
 		wc.rows.UnmarshalWithErrLogger(bodyString, sw.logError)
 	}
@@ -532,6 +654,13 @@ func (sw *scrapeWork) processDataOneShot(scrapeTimestamp, realTimestamp int64, b
 			scrapesSkippedBySampleLimit.Inc()
 			scrapeErr = fmt.Errorf("the response from %q exceeds sample_limit=%d; "+
 				"either reduce the sample count for the target or increase sample_limit", cfg.ScrapeURL, cfg.SampleLimit)
+		} else if err == nil {
+			if cfg.CardinalityGuard != nil {
+				wc.writeRequest.Timeseries = sw.applyCardinalityGuard(wc.writeRequest.Timeseries, realTimestamp)
+			}
+			if cfg.CreatedTimestampZeroIngestion {
+				sw.addCreatedTimestampZeroSamples(wc, wc.rows.Rows, scrapeTimestamp)
+			}
 		}
 	}
 	if scrapeErr != nil {
@@ -564,6 +693,7 @@ func (sw *scrapeWork) processDataOneShot(scrapeTimestamp, realTimestamp int64, b
 		samplesDropped = wc.applySeriesLimit(sw)
 	}
 	responseSize := len(bodyString)
+	sw.updateFailuresStreak(up)
 
 	am := &autoMetrics{
 		up:                        up,
@@ -573,6 +703,7 @@ func (sw *scrapeWork) processDataOneShot(scrapeTimestamp, realTimestamp int64, b
 		samplesPostRelabeling:     samplesPostRelabeling,
 		seriesAdded:               seriesAdded,
 		seriesLimitSamplesDropped: samplesDropped,
+		failuresStreak:            sw.failuresStreak,
 	}
 	wc.addAutoMetrics(sw, am, scrapeTimestamp)
 
@@ -670,6 +801,7 @@ func (sw *scrapeWork) processDataInStreamMode(scrapeTimestamp, realTimestamp int
 		seriesAdded = getSeriesAdded(lastScrapeStr, bodyString)
 	}
 	responseSize := len(bodyString)
+	sw.updateFailuresStreak(up)
 
 	am := &autoMetrics{
 		up:                        up,
@@ -679,6 +811,7 @@ func (sw *scrapeWork) processDataInStreamMode(scrapeTimestamp, realTimestamp int
 		samplesPostRelabeling:     int(samplesPostRelabeling.Load()),
 		seriesAdded:               seriesAdded,
 		seriesLimitSamplesDropped: int(samplesDroppedTotal.Load()),
+		failuresStreak:            sw.failuresStreak,
 	}
 	sw.pushAutoMetrics(am, scrapeTimestamp)
 
@@ -697,6 +830,15 @@ func (sw *scrapeWork) processDataInStreamMode(scrapeTimestamp, realTimestamp int
 	return err
 }
 
+// updateFailuresStreak updates sw.failuresStreak according to the up value for the last scrape.
+func (sw *scrapeWork) updateFailuresStreak(up int) {
+	if up == 1 {
+		sw.failuresStreak = 0
+	} else {
+		sw.failuresStreak++
+	}
+}
+
 // pushAutoMetrics pushes am with the given timestamp to sw.
 func (sw *scrapeWork) pushAutoMetrics(am *autoMetrics, timestamp int64) {
 	wc := writeRequestCtxPool.Get(sw.autoMetricsLabelsLen)
@@ -876,6 +1018,10 @@ func (sw *scrapeWork) sendStaleSeries(lastScrape, currScrape string, timestamp i
 				wc.applySeriesLimit(sw)
 			}
 
+			if sw.Config.CardinalityGuard != nil {
+				wc.writeRequest.Timeseries = sw.adjustStaleSeriesForCardinalityGuard(wc.writeRequest.Timeseries, currScrape != "")
+			}
+
 			setStaleMarkersForRows(wc.writeRequest.Timeseries)
 			sw.pushData(&wc.writeRequest)
 			return nil
@@ -934,6 +1080,7 @@ type autoMetrics struct {
 	samplesPostRelabeling     int
 	seriesAdded               int
 	seriesLimitSamplesDropped int
+	failuresStreak            int
 }
 
 func isAutoMetric(s string) bool {
@@ -945,6 +1092,7 @@ func isAutoMetric(s string) bool {
 	}
 	switch s {
 	case "scrape_duration_seconds",
+		"scrape_failures_streak",
 		"scrape_response_size_bytes",
 		"scrape_samples_limit",
 		"scrape_samples_post_metric_relabeling",
@@ -967,9 +1115,12 @@ func isAutoMetric(s string) bool {
 // sw is used as read-only config source.
 func (wc *writeRequestCtx) addAutoMetrics(sw *scrapeWork, am *autoMetrics, timestamp int64) {
 	rows := getAutoRows()
-	dst := slicesutil.SetLength(rows.Rows, 11)[:0]
+	dst := slicesutil.SetLength(rows.Rows, 12)[:0]
 
 	dst = appendRow(dst, "scrape_duration_seconds", am.scrapeDurationSeconds, timestamp)
+	if *extraAutoMetrics {
+		dst = appendRow(dst, "scrape_failures_streak", float64(am.failuresStreak), timestamp)
+	}
 	dst = appendRow(dst, "scrape_response_size_bytes", float64(am.scrapeResponseSize), timestamp)
 
 	if sampleLimit := sw.Config.SampleLimit; sampleLimit > 0 {
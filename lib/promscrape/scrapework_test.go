@@ -27,6 +27,7 @@ func TestIsAutoMetric(t *testing.T) {
 	}
 	f("up", true)
 	f("scrape_duration_seconds", true)
+	f("scrape_failures_streak", true)
 	f("scrape_samples_scraped", true)
 	f("scrape_samples_post_metric_relabeling", true)
 	f("scrape_series_added", true)
@@ -124,6 +125,142 @@ func TestScrapeWorkScrapeInternalFailure(t *testing.T) {
 	}
 }
 
+func TestScrapeWorkScrapeFailuresStreak(t *testing.T) {
+	f := func(extraAutoMetricsEnabled bool, streaksExpected string) {
+		t.Helper()
+
+		defer func(v bool) {
+			*extraAutoMetrics = v
+		}(*extraAutoMetrics)
+		*extraAutoMetrics = extraAutoMetricsEnabled
+
+		var sw scrapeWork
+		sw.Config = &ScrapeWork{
+			ScrapeTimeout: time.Second * 42,
+		}
+
+		// The target fails the first 2 scrapes and then recovers.
+		readDataCalls := 0
+		sw.ReadData = func(dst *chunkedbuffer.Buffer) (bool, error) {
+			readDataCalls++
+			if readDataCalls <= 2 {
+				return false, fmt.Errorf("error when reading data")
+			}
+			dst.MustWrite([]byte("foo 1\n"))
+			return false, nil
+		}
+		var streaks []string
+		sw.PushData = func(_ *auth.Token, wr *prompb.WriteRequest) {
+			for _, ts := range wr.Timeseries {
+				if ts.Labels[0].Value == "scrape_failures_streak" {
+					streaks = append(streaks, fmt.Sprintf("%v", ts.Samples[0].Value))
+				}
+			}
+		}
+
+		tsmGlobal.Register(&sw)
+		defer tsmGlobal.Unregister(&sw)
+		for i := 0; i < 3; i++ {
+			timestamp := int64(i) * 10_000
+			_ = sw.scrapeInternal(timestamp, timestamp)
+		}
+		if s := strings.Join(streaks, ","); s != streaksExpected {
+			t.Fatalf("unexpected scrape_failures_streak values; got %q; want %q", s, streaksExpected)
+		}
+	}
+
+	// scrape_failures_streak isn't generated by default
+	f(false, "")
+
+	// scrape_failures_streak is generated when -promscrape.extraAutoMetrics is set
+	f(true, "1,2,0")
+}
+
+func TestGetBackoffSkips(t *testing.T) {
+	f := func(failedAttempts int, scrapeInterval, backoffMax time.Duration, skipsExpected int) {
+		t.Helper()
+		skips := getBackoffSkips(failedAttempts, scrapeInterval, backoffMax)
+		if skips != skipsExpected {
+			t.Fatalf("unexpected skips for failedAttempts=%d, scrapeInterval=%s, backoffMax=%s; got %d; want %d",
+				failedAttempts, scrapeInterval, backoffMax, skips, skipsExpected)
+		}
+	}
+
+	// backoff is disabled
+	f(10, time.Second, 0, 0)
+	f(10, time.Minute, time.Second, 0)
+	f(10, time.Minute, time.Minute, 0)
+
+	// the first failure doesn't trigger backoff
+	f(0, time.Second, time.Minute, 0)
+	f(1, time.Second, time.Minute, 0)
+
+	// exponential backoff
+	f(2, time.Second, time.Minute, 1)
+	f(3, time.Second, time.Minute, 3)
+	f(4, time.Second, time.Minute, 7)
+	f(6, time.Second, time.Minute, 31)
+
+	// backoff is limited by backoffMax
+	f(7, time.Second, time.Minute, 59)
+	f(100, time.Second, time.Minute, 59)
+	f(100, 30*time.Second, 100*time.Second, 2)
+}
+
+func TestScrapeWorkScrapeBackoff(t *testing.T) {
+	var sw scrapeWork
+	sw.Config = &ScrapeWork{
+		ScrapeInterval:   10 * time.Second,
+		ScrapeTimeout:    10 * time.Second,
+		ScrapeBackoffMax: 40 * time.Second,
+	}
+
+	// The target fails the first 4 scrape attempts and then recovers.
+	readDataCalls := 0
+	sw.ReadData = func(dst *chunkedbuffer.Buffer) (bool, error) {
+		readDataCalls++
+		if readDataCalls <= 4 {
+			return false, fmt.Errorf("error when reading data")
+		}
+		dst.MustWrite([]byte("foo 1\n"))
+		return false, nil
+	}
+	var ups []string
+	sw.PushData = func(_ *auth.Token, wr *prompb.WriteRequest) {
+		for _, ts := range wr.Timeseries {
+			if ts.Labels[0].Value == "up" {
+				ups = append(ups, fmt.Sprintf("%v", ts.Samples[0].Value))
+			}
+		}
+	}
+
+	tsmGlobal.Register(&sw)
+	defer tsmGlobal.Unregister(&sw)
+	var scrapes []string
+	for i := 0; i < 14; i++ {
+		n := readDataCalls
+		timestamp := int64(i) * 10_000
+		sw.scrapeAndLogError(timestamp, timestamp)
+		if readDataCalls > n {
+			scrapes = append(scrapes, "s")
+		} else {
+			scrapes = append(scrapes, "-")
+		}
+	}
+
+	scrapesExpected := "s,s,-,s,-,-,-,s,-,-,-,s,s,s"
+	if s := strings.Join(scrapes, ","); s != scrapesExpected {
+		t.Fatalf("unexpected scrape attempts; got %s; want %s", s, scrapesExpected)
+	}
+	upsExpected := "0,0,0,0,0,0,0,0,0,0,0,1,1,1"
+	if s := strings.Join(ups, ","); s != upsExpected {
+		t.Fatalf("unexpected up values; got %s; want %s", s, upsExpected)
+	}
+	if sw.failuresStreak != 0 {
+		t.Fatalf("unexpected failuresStreak after successful scrape; got %d; want 0", sw.failuresStreak)
+	}
+}
+
 // TestScrapeWorkScrapeInternalSuccess validates that the parsing functionality, relabeling,
 // sample limits, series limits, auto metrics and so on, works correctly and
 // consistently between streaming and one-shot modes.
@@ -26,6 +26,10 @@ var maxDroppedTargets = flag.Int("promscrape.maxDroppedTargets", 10000, "The max
 	"Increase this value if your setup drops more scrape targets during relabeling and you need investigating labels for all the dropped targets. "+
 	"Note that the increased number of tracked dropped targets may result in increased memory usage")
 
+var targetHealthHistorySize = flag.Int("promscrape.targetHealthHistorySize", 10, "The number of recent scrape results to keep per each scrape target. "+
+	"These results are shown at /targets page and at /api/v1/targets?history=1 page. They help detecting flapping targets. "+
+	"Set it to 0 in order to disable scrape history tracking. Note that the increased history size may result in increased memory usage")
+
 var tsmGlobal = newTargetStatusMap()
 
 // WriteTargetResponse serves requests to /target_response?id=<id>
@@ -68,13 +72,15 @@ func WriteServiceDiscovery(w http.ResponseWriter, r *http.Request) {
 }
 
 // WriteAPIV1Targets writes /api/v1/targets to w according to https://prometheus.io/docs/prometheus/latest/querying/api/#targets
-func WriteAPIV1Targets(w io.Writer, state, scrapePool string) {
+//
+// Recent scrape results are written per each active target if showHistory is set.
+func WriteAPIV1Targets(w io.Writer, state, scrapePool string, showHistory bool) {
 	if state == "" {
 		state = "any"
 	}
 	fmt.Fprintf(w, `{"status":"success","data":{"activeTargets":`)
 	if state == "active" || state == "any" {
-		tsmGlobal.WriteActiveTargetsJSON(w, scrapePool)
+		tsmGlobal.WriteActiveTargetsJSON(w, scrapePool, showHistory)
 	} else {
 		fmt.Fprintf(w, `[]`)
 	}
@@ -204,6 +210,27 @@ func (tsm *targetStatusMap) Update(sw *scrapeWork, up bool, scrapeTime, scrapeDu
 		ts.scrapesFailed++
 	}
 	ts.err = err
+	ts.addScrapeResult(scrapeResult{
+		up:                 up,
+		scrapeTime:         scrapeTime,
+		scrapeDuration:     scrapeDuration,
+		scrapeResponseSize: scrapeResponseSize,
+		samplesScraped:     samplesScraped,
+		err:                err,
+	})
+	tsm.mu.Unlock()
+}
+
+// UpdateCardinalityReport sets the cardinality guard report for the last scrape of sw.
+//
+// cr must be nil if the last scrape didn't exceed the cardinality guard threshold.
+func (tsm *targetStatusMap) UpdateCardinalityReport(sw *scrapeWork, cr *cardinalityReport) {
+	tsm.mu.Lock()
+	ts, ok := tsm.m[sw]
+	if !ok {
+		logger.Panicf("BUG: missing Register() call for the target %q", sw.Config.jobNameOriginal)
+	}
+	ts.cardinality = cr
 	tsm.mu.Unlock()
 }
 
@@ -241,7 +268,7 @@ func (tsm *targetStatusMap) getActiveTargetStatuses() []targetStatus {
 	tsm.mu.Lock()
 	tss := make([]targetStatus, 0, len(tsm.m))
 	for _, ts := range tsm.m {
-		tss = append(tss, *ts)
+		tss = append(tss, ts.clone())
 	}
 	tsm.mu.Unlock()
 	// Sort discovered targets by __address__ label, so they stay in consistent order across calls
@@ -254,7 +281,9 @@ func (tsm *targetStatusMap) getActiveTargetStatuses() []targetStatus {
 }
 
 // WriteActiveTargetsJSON writes `activeTargets` contents to w according to https://prometheus.io/docs/prometheus/latest/querying/api/#targets
-func (tsm *targetStatusMap) WriteActiveTargetsJSON(w io.Writer, scrapePoolFilter string) {
+//
+// Recent scrape results are written per each target if showHistory is set.
+func (tsm *targetStatusMap) WriteActiveTargetsJSON(w io.Writer, scrapePoolFilter string, showHistory bool) {
 	tss := tsm.getActiveTargetStatuses()
 	fmt.Fprintf(w, `[`)
 	var needComma bool
@@ -281,16 +310,41 @@ func (tsm *targetStatusMap) WriteActiveTargetsJSON(w io.Writer, scrapePoolFilter
 		fmt.Fprintf(w, `,"lastScrape":"%s"`, time.Unix(ts.scrapeTime/1000, (ts.scrapeTime%1000)*1e6).Format(time.RFC3339Nano))
 		fmt.Fprintf(w, `,"lastScrapeDuration":%g`, (time.Millisecond * time.Duration(ts.scrapeDuration)).Seconds())
 		fmt.Fprintf(w, `,"lastSamplesScraped":%d`, ts.samplesScraped)
-		state := "up"
-		if !ts.up {
-			state = "down"
+		if showHistory {
+			fmt.Fprintf(w, `,"scrapeHistory":`)
+			writeScrapeHistoryJSON(w, ts.history)
+			fmt.Fprintf(w, `,"stateChanges":%d`, ts.getStateChanges())
 		}
-		fmt.Fprintf(w, `,"health":%s}`, stringsutil.JSONString(state))
+		fmt.Fprintf(w, `,"health":%s}`, stringsutil.JSONString(getHealth(ts.up)))
 		needComma = true
 	}
 	fmt.Fprintf(w, `]`)
 }
 
+func writeScrapeHistoryJSON(w io.Writer, history []scrapeResult) {
+	fmt.Fprintf(w, `[`)
+	for i := range history {
+		sr := &history[i]
+		fmt.Fprintf(w, `{"scrapeTime":"%s"`, sr.getScrapeTime().Format(time.RFC3339Nano))
+		fmt.Fprintf(w, `,"scrapeDuration":%g`, (time.Millisecond * time.Duration(sr.scrapeDuration)).Seconds())
+		fmt.Fprintf(w, `,"samplesScraped":%d`, sr.samplesScraped)
+		fmt.Fprintf(w, `,"responseSize":%d`, sr.scrapeResponseSize)
+		fmt.Fprintf(w, `,"error":%s`, stringsutil.JSONString(sr.getError()))
+		fmt.Fprintf(w, `,"health":%s}`, stringsutil.JSONString(getHealth(sr.up)))
+		if i+1 < len(history) {
+			fmt.Fprintf(w, `,`)
+		}
+	}
+	fmt.Fprintf(w, `]`)
+}
+
+func getHealth(up bool) string {
+	if up {
+		return "up"
+	}
+	return "down"
+}
+
 func writeLabelsJSON(w io.Writer, labels *promutil.Labels) {
 	fmt.Fprintf(w, `{`)
 	labelsList := labels.GetLabels()
@@ -313,6 +367,87 @@ type targetStatus struct {
 	scrapesTotal       int
 	scrapesFailed      int
 	err                error
+
+	// history contains up to -promscrape.targetHealthHistorySize recent scrape results.
+	//
+	// It is used as a ring buffer, where historyNext points to the oldest result after the buffer becomes full.
+	// Use clone() for obtaining targetStatus copy with history ordered from the oldest to the newest result.
+	history     []scrapeResult
+	historyNext int
+
+	// cardinality contains the cardinality guard report for the last scrape if it exceeded `series_threshold`.
+	cardinality *cardinalityReport
+}
+
+// scrapeResult contains the outcome of a single scrape.
+type scrapeResult struct {
+	up                 bool
+	scrapeTime         int64
+	scrapeDuration     int64
+	scrapeResponseSize int
+	samplesScraped     int
+	err                error
+}
+
+func (sr *scrapeResult) getScrapeTime() time.Time {
+	return time.Unix(sr.scrapeTime/1000, (sr.scrapeTime%1000)*1e6)
+}
+
+func (sr *scrapeResult) getError() string {
+	if sr.err == nil {
+		return ""
+	}
+	return sr.err.Error()
+}
+
+// String returns human-readable representation of sr.
+func (sr *scrapeResult) String() string {
+	s := fmt.Sprintf("%s: %s, duration=%dms, samples=%d, size=%.3fKiB", sr.getScrapeTime().Format(time.RFC3339), getHealth(sr.up),
+		sr.scrapeDuration, sr.samplesScraped, float64(sr.scrapeResponseSize)/1024)
+	if sr.err != nil {
+		s += ", error=" + sr.err.Error()
+	}
+	return s
+}
+
+func (ts *targetStatus) addScrapeResult(sr scrapeResult) {
+	maxItems := *targetHealthHistorySize
+	if maxItems <= 0 {
+		return
+	}
+	if len(ts.history) < maxItems {
+		ts.history = append(ts.history, sr)
+		return
+	}
+	ts.history[ts.historyNext] = sr
+	ts.historyNext = (ts.historyNext + 1) % len(ts.history)
+}
+
+// clone returns a copy of ts with history ordered from the oldest to the newest result.
+//
+// The returned copy doesn't share the history with ts, so it can be used after ts is modified.
+func (ts *targetStatus) clone() targetStatus {
+	tsCopy := *ts
+	history := make([]scrapeResult, 0, len(ts.history))
+	history = append(history, ts.history[ts.historyNext:]...)
+	history = append(history, ts.history[:ts.historyNext]...)
+	tsCopy.history = history
+	tsCopy.historyNext = 0
+	return tsCopy
+}
+
+// getStateChanges returns the number of up/down state changes in ts.history.
+//
+// Big number of state changes means the target is flapping.
+// ts must be obtained via clone() call.
+func (ts *targetStatus) getStateChanges() int {
+	n := 0
+	for i := 1; i < len(ts.history); i++ {
+		if ts.history[i].up != ts.history[i-1].up {
+			n++
+		}
+	}
+	return n
 }
 
 func (ts *targetStatus) getDurationFromLastScrape() string {
@@ -467,7 +602,7 @@ func (tsm *targetStatusMap) getTargetsStatusByJob(filter *requestFilter) *target
 		if filter.originalJobName != "" && jobName != filter.originalJobName {
 			continue
 		}
-		byJob[jobName] = append(byJob[jobName], *ts)
+		byJob[jobName] = append(byJob[jobName], ts.clone())
 	}
 	jobNames := append([]string{}, tsm.jobNames...)
 	tsm.mu.Unlock()
@@ -585,459 +585,538 @@ func streamscrapeJobTargets(qw422016 *qt422016.Writer, num int, jts *jobTargetsS
 //line lib/promscrape/targetstatus.qtpl:216
 	}
 //line lib/promscrape/targetstatus.qtpl:216
-	qw422016.N().S(`<th scope="col" title="total scrapes">Scrapes</th><th scope="col" title="total scrape errors">Errors</th><th scope="col" title="the time of the last scrape">Last Scrape</th><th scope="col" title="the duration of the last scrape">Duration</th><th scope="col" title="the size of the last scrape">Last Scrape Size</th><th scope="col" title="the number of metrics scraped during the last scrape">Samples</th><th scope="col" title="error from the last scrape (if any)">Last error</th></tr></thead><tbody>`)
-//line lib/promscrape/targetstatus.qtpl:227
+	qw422016.N().S(`<th scope="col" title="total scrapes">Scrapes</th><th scope="col" title="total scrape errors">Errors</th><th scope="col" title="recent scrape results from the oldest to the newest">History</th><th scope="col" title="the time of the last scrape">Last Scrape</th><th scope="col" title="the duration of the last scrape">Duration</th><th scope="col" title="the size of the last scrape">Last Scrape Size</th><th scope="col" title="the number of metrics scraped during the last scrape">Samples</th><th scope="col" title="error from the last scrape (if any)">Last error</th></tr></thead><tbody>`)
+//line lib/promscrape/targetstatus.qtpl:228
 	for _, ts := range jts.targetsStatus {
-//line lib/promscrape/targetstatus.qtpl:229
+//line lib/promscrape/targetstatus.qtpl:230
 		endpoint := ts.sw.Config.ScrapeURL
 		originalLabels := ts.sw.Config.OriginalLabels
 
 		// The target is uniquely identified by a pointer to its original labels.
 		targetID := getLabelsID(originalLabels)
 
-//line lib/promscrape/targetstatus.qtpl:234
-		qw422016.N().S(`<tr`)
 //line lib/promscrape/targetstatus.qtpl:235
+		qw422016.N().S(`<tr`)
+//line lib/promscrape/targetstatus.qtpl:236
 		if !ts.up {
-//line lib/promscrape/targetstatus.qtpl:235
+//line lib/promscrape/targetstatus.qtpl:236
 			qw422016.N().S(` `)
-//line lib/promscrape/targetstatus.qtpl:235
+//line lib/promscrape/targetstatus.qtpl:236
 			qw422016.N().S(`class="alert alert-danger" role="alert"`)
-//line lib/promscrape/targetstatus.qtpl:235
+//line lib/promscrape/targetstatus.qtpl:236
 		}
-//line lib/promscrape/targetstatus.qtpl:235
+//line lib/promscrape/targetstatus.qtpl:236
 		qw422016.N().S(`><td class="endpoint"><a href="`)
-//line lib/promscrape/targetstatus.qtpl:237
+//line lib/promscrape/targetstatus.qtpl:238
 		qw422016.E().S(endpoint)
-//line lib/promscrape/targetstatus.qtpl:237
+//line lib/promscrape/targetstatus.qtpl:238
 		qw422016.N().S(`" target="_blank">`)
-//line lib/promscrape/targetstatus.qtpl:237
+//line lib/promscrape/targetstatus.qtpl:238
 		qw422016.E().S(endpoint)
-//line lib/promscrape/targetstatus.qtpl:237
-		qw422016.N().S(`</a>`)
 //line lib/promscrape/targetstatus.qtpl:238
-		if hasOriginalLabels {
+		qw422016.N().S(`</a>`)
 //line lib/promscrape/targetstatus.qtpl:239
+		if hasOriginalLabels {
+//line lib/promscrape/targetstatus.qtpl:240
 			qw422016.N().S(` `)
-//line lib/promscrape/targetstatus.qtpl:239
-			qw422016.N().S(`(<a href="target_response?id=`)
 //line lib/promscrape/targetstatus.qtpl:240
+			qw422016.N().S(`(<a href="target_response?id=`)
+//line lib/promscrape/targetstatus.qtpl:241
 			qw422016.E().S(targetID)
-//line lib/promscrape/targetstatus.qtpl:240
+//line lib/promscrape/targetstatus.qtpl:241
 			qw422016.N().S(`" target="_blank"title="click to fetch target response on behalf of the scraper">response</a>)`)
-//line lib/promscrape/targetstatus.qtpl:242
+//line lib/promscrape/targetstatus.qtpl:243
 		}
-//line lib/promscrape/targetstatus.qtpl:242
+//line lib/promscrape/targetstatus.qtpl:243
 		qw422016.N().S(`</td><td>`)
-//line lib/promscrape/targetstatus.qtpl:245
+//line lib/promscrape/targetstatus.qtpl:246
 		if ts.up {
-//line lib/promscrape/targetstatus.qtpl:245
+//line lib/promscrape/targetstatus.qtpl:246
 			qw422016.N().S(`<span class="badge bg-success">UP</span>`)
-//line lib/promscrape/targetstatus.qtpl:247
+//line lib/promscrape/targetstatus.qtpl:248
 		} else {
-//line lib/promscrape/targetstatus.qtpl:247
+//line lib/promscrape/targetstatus.qtpl:248
 			qw422016.N().S(`<span class="badge bg-danger">DOWN</span>`)
-//line lib/promscrape/targetstatus.qtpl:249
+//line lib/promscrape/targetstatus.qtpl:250
 		}
-//line lib/promscrape/targetstatus.qtpl:249
+//line lib/promscrape/targetstatus.qtpl:250
 		qw422016.N().S(`</td><td class="labels"><div`)
-//line lib/promscrape/targetstatus.qtpl:253
-		if hasOriginalLabels {
 //line lib/promscrape/targetstatus.qtpl:254
+		if hasOriginalLabels {
+//line lib/promscrape/targetstatus.qtpl:255
 			qw422016.N().S(` `)
-//line lib/promscrape/targetstatus.qtpl:254
-			qw422016.N().S(`title="click to show original labels"onclick="document.getElementById('original-labels-`)
 //line lib/promscrape/targetstatus.qtpl:255
+			qw422016.N().S(`title="click to show original labels"onclick="document.getElementById('original-labels-`)
+//line lib/promscrape/targetstatus.qtpl:256
 			qw422016.E().S(targetID)
-//line lib/promscrape/targetstatus.qtpl:255
-			qw422016.N().S(`').style.display='block'"`)
 //line lib/promscrape/targetstatus.qtpl:256
+			qw422016.N().S(`').style.display='block'"`)
+//line lib/promscrape/targetstatus.qtpl:257
 		}
-//line lib/promscrape/targetstatus.qtpl:256
+//line lib/promscrape/targetstatus.qtpl:257
 		qw422016.N().S(`>`)
-//line lib/promscrape/targetstatus.qtpl:258
+//line lib/promscrape/targetstatus.qtpl:259
 		streamformatLabels(qw422016, ts.sw.Config.Labels)
-//line lib/promscrape/targetstatus.qtpl:258
+//line lib/promscrape/targetstatus.qtpl:259
 		qw422016.N().S(`</div>`)
-//line lib/promscrape/targetstatus.qtpl:260
+//line lib/promscrape/targetstatus.qtpl:261
 		if hasOriginalLabels {
-//line lib/promscrape/targetstatus.qtpl:260
-			qw422016.N().S(`<div style="display:none" id="original-labels-`)
 //line lib/promscrape/targetstatus.qtpl:261
+			qw422016.N().S(`<div style="display:none" id="original-labels-`)
+//line lib/promscrape/targetstatus.qtpl:262
 			qw422016.E().S(targetID)
-//line lib/promscrape/targetstatus.qtpl:261
-			qw422016.N().S(`">`)
 //line lib/promscrape/targetstatus.qtpl:262
+			qw422016.N().S(`">`)
+//line lib/promscrape/targetstatus.qtpl:263
 			streamformatLabels(qw422016, originalLabels)
-//line lib/promscrape/targetstatus.qtpl:262
+//line lib/promscrape/targetstatus.qtpl:263
 			qw422016.N().S(`</div>`)
-//line lib/promscrape/targetstatus.qtpl:264
+//line lib/promscrape/targetstatus.qtpl:265
 		}
-//line lib/promscrape/targetstatus.qtpl:264
+//line lib/promscrape/targetstatus.qtpl:265
 		qw422016.N().S(`</td>`)
-//line lib/promscrape/targetstatus.qtpl:266
+//line lib/promscrape/targetstatus.qtpl:267
 		if hasOriginalLabels {
-//line lib/promscrape/targetstatus.qtpl:266
+//line lib/promscrape/targetstatus.qtpl:267
 			qw422016.N().S(`<td><a href="target-relabel-debug?id=`)
-//line lib/promscrape/targetstatus.qtpl:268
+//line lib/promscrape/targetstatus.qtpl:269
 			qw422016.E().S(targetID)
-//line lib/promscrape/targetstatus.qtpl:268
+//line lib/promscrape/targetstatus.qtpl:269
 			qw422016.N().S(`" target="_blank">target</a>`)
-//line lib/promscrape/targetstatus.qtpl:268
+//line lib/promscrape/targetstatus.qtpl:269
 			qw422016.N().S(` `)
-//line lib/promscrape/targetstatus.qtpl:268
-			qw422016.N().S(`<a href="metric-relabel-debug?id=`)
 //line lib/promscrape/targetstatus.qtpl:269
+			qw422016.N().S(`<a href="metric-relabel-debug?id=`)
+//line lib/promscrape/targetstatus.qtpl:270
 			qw422016.E().S(targetID)
-//line lib/promscrape/targetstatus.qtpl:269
+//line lib/promscrape/targetstatus.qtpl:270
 			qw422016.N().S(`" target="_blank">metrics</a></td>`)
-//line lib/promscrape/targetstatus.qtpl:271
-		}
-//line lib/promscrape/targetstatus.qtpl:271
-		qw422016.N().S(`<td>`)
 //line lib/promscrape/targetstatus.qtpl:272
-		qw422016.N().D(ts.scrapesTotal)
+		}
 //line lib/promscrape/targetstatus.qtpl:272
-		qw422016.N().S(`</td><td>`)
+		qw422016.N().S(`<td>`)
 //line lib/promscrape/targetstatus.qtpl:273
-		qw422016.N().D(ts.scrapesFailed)
+		qw422016.N().D(ts.scrapesTotal)
 //line lib/promscrape/targetstatus.qtpl:273
 		qw422016.N().S(`</td><td>`)
 //line lib/promscrape/targetstatus.qtpl:274
-		qw422016.E().S(ts.getDurationFromLastScrape())
+		qw422016.N().D(ts.scrapesFailed)
 //line lib/promscrape/targetstatus.qtpl:274
 		qw422016.N().S(`</td><td>`)
 //line lib/promscrape/targetstatus.qtpl:275
-		qw422016.N().D(int(ts.scrapeDuration))
+		streamscrapeHistory(qw422016, &ts)
 //line lib/promscrape/targetstatus.qtpl:275
-		qw422016.N().S(`ms</td><td>`)
+		qw422016.N().S(`</td><td>`)
 //line lib/promscrape/targetstatus.qtpl:276
-		qw422016.E().S(ts.getSizeFromLastScrape())
+		qw422016.E().S(ts.getDurationFromLastScrape())
 //line lib/promscrape/targetstatus.qtpl:276
 		qw422016.N().S(`</td><td>`)
 //line lib/promscrape/targetstatus.qtpl:277
-		qw422016.N().D(ts.samplesScraped)
+		qw422016.N().D(int(ts.scrapeDuration))
 //line lib/promscrape/targetstatus.qtpl:277
-		qw422016.N().S(`</td><td>`)
+		qw422016.N().S(`ms</td><td>`)
 //line lib/promscrape/targetstatus.qtpl:278
-		if ts.err != nil {
+		qw422016.E().S(ts.getSizeFromLastScrape())
 //line lib/promscrape/targetstatus.qtpl:278
+		qw422016.N().S(`</td><td>`)
+//line lib/promscrape/targetstatus.qtpl:279
+		qw422016.N().D(ts.samplesScraped)
+//line lib/promscrape/targetstatus.qtpl:279
+		qw422016.N().S(`</td><td>`)
+//line lib/promscrape/targetstatus.qtpl:280
+		if ts.err != nil {
+//line lib/promscrape/targetstatus.qtpl:280
 			qw422016.E().S(ts.err.Error())
-//line lib/promscrape/targetstatus.qtpl:278
+//line lib/promscrape/targetstatus.qtpl:280
 		}
-//line lib/promscrape/targetstatus.qtpl:278
-		qw422016.N().S(`</td></tr>`)
 //line lib/promscrape/targetstatus.qtpl:280
+		qw422016.N().S(`</td></tr>`)
+//line lib/promscrape/targetstatus.qtpl:282
 	}
-//line lib/promscrape/targetstatus.qtpl:280
+//line lib/promscrape/targetstatus.qtpl:282
 	qw422016.N().S(`</tbody></table></div></div></div>`)
-//line lib/promscrape/targetstatus.qtpl:286
+//line lib/promscrape/targetstatus.qtpl:288
 }
 
-//line lib/promscrape/targetstatus.qtpl:286
+//line lib/promscrape/targetstatus.qtpl:288
 func writescrapeJobTargets(qq422016 qtio422016.Writer, num int, jts *jobTargetsStatuses, hasOriginalLabels bool) {
-//line lib/promscrape/targetstatus.qtpl:286
+//line lib/promscrape/targetstatus.qtpl:288
 	qw422016 := qt422016.AcquireWriter(qq422016)
-//line lib/promscrape/targetstatus.qtpl:286
+//line lib/promscrape/targetstatus.qtpl:288
 	streamscrapeJobTargets(qw422016, num, jts, hasOriginalLabels)
-//line lib/promscrape/targetstatus.qtpl:286
+//line lib/promscrape/targetstatus.qtpl:288
 	qt422016.ReleaseWriter(qw422016)
-//line lib/promscrape/targetstatus.qtpl:286
+//line lib/promscrape/targetstatus.qtpl:288
 }
 
-//line lib/promscrape/targetstatus.qtpl:286
+//line lib/promscrape/targetstatus.qtpl:288
 func scrapeJobTargets(num int, jts *jobTargetsStatuses, hasOriginalLabels bool) string {
-//line lib/promscrape/targetstatus.qtpl:286
+//line lib/promscrape/targetstatus.qtpl:288
 	qb422016 := qt422016.AcquireByteBuffer()
-//line lib/promscrape/targetstatus.qtpl:286
+//line lib/promscrape/targetstatus.qtpl:288
 	writescrapeJobTargets(qb422016, num, jts, hasOriginalLabels)
-//line lib/promscrape/targetstatus.qtpl:286
+//line lib/promscrape/targetstatus.qtpl:288
 	qs422016 := string(qb422016.B)
-//line lib/promscrape/targetstatus.qtpl:286
+//line lib/promscrape/targetstatus.qtpl:288
 	qt422016.ReleaseByteBuffer(qb422016)
-//line lib/promscrape/targetstatus.qtpl:286
+//line lib/promscrape/targetstatus.qtpl:288
 	return qs422016
-//line lib/promscrape/targetstatus.qtpl:286
+//line lib/promscrape/targetstatus.qtpl:288
 }
 
-//line lib/promscrape/targetstatus.qtpl:288
+//line lib/promscrape/targetstatus.qtpl:290
+func streamscrapeHistory(qw422016 *qt422016.Writer, ts *targetStatus) {
+//line lib/promscrape/targetstatus.qtpl:290
+	qw422016.N().S(`<span class="text-nowrap">`)
+//line lib/promscrape/targetstatus.qtpl:292
+	for i := range ts.history {
+//line lib/promscrape/targetstatus.qtpl:293
+		sr := &ts.history[i]
+
+//line lib/promscrape/targetstatus.qtpl:293
+		qw422016.N().S(`<span class="badge`)
+//line lib/promscrape/targetstatus.qtpl:294
+		if sr.up {
+//line lib/promscrape/targetstatus.qtpl:294
+			qw422016.N().S(`bg-success`)
+//line lib/promscrape/targetstatus.qtpl:294
+		} else {
+//line lib/promscrape/targetstatus.qtpl:294
+			qw422016.N().S(`bg-danger`)
+//line lib/promscrape/targetstatus.qtpl:294
+		}
+//line lib/promscrape/targetstatus.qtpl:294
+		qw422016.N().S(`me-1" title="`)
+//line lib/promscrape/targetstatus.qtpl:294
+		qw422016.E().S(sr.String())
+//line lib/promscrape/targetstatus.qtpl:294
+		qw422016.N().S(`">&nbsp;</span>`)
+//line lib/promscrape/targetstatus.qtpl:295
+	}
+//line lib/promscrape/targetstatus.qtpl:295
+	qw422016.N().S(`</span>`)
+//line lib/promscrape/targetstatus.qtpl:297
+	stateChanges := ts.getStateChanges()
+
+//line lib/promscrape/targetstatus.qtpl:298
+	if stateChanges > 0 {
+//line lib/promscrape/targetstatus.qtpl:298
+		qw422016.N().S(`<div class="small" title="the number of up/down state changes in the scrape history">`)
+//line lib/promscrape/targetstatus.qtpl:299
+		qw422016.N().D(stateChanges)
+//line lib/promscrape/targetstatus.qtpl:299
+		qw422016.N().S(` `)
+//line lib/promscrape/targetstatus.qtpl:299
+		qw422016.N().S(`state changes</div>`)
+//line lib/promscrape/targetstatus.qtpl:300
+	}
+//line lib/promscrape/targetstatus.qtpl:301
+}
+
+//line lib/promscrape/targetstatus.qtpl:301
+func writescrapeHistory(qq422016 qtio422016.Writer, ts *targetStatus) {
+//line lib/promscrape/targetstatus.qtpl:301
+	qw422016 := qt422016.AcquireWriter(qq422016)
+//line lib/promscrape/targetstatus.qtpl:301
+	streamscrapeHistory(qw422016, ts)
+//line lib/promscrape/targetstatus.qtpl:301
+	qt422016.ReleaseWriter(qw422016)
+//line lib/promscrape/targetstatus.qtpl:301
+}
+
+//line lib/promscrape/targetstatus.qtpl:301
+func scrapeHistory(ts *targetStatus) string {
+//line lib/promscrape/targetstatus.qtpl:301
+	qb422016 := qt422016.AcquireByteBuffer()
+//line lib/promscrape/targetstatus.qtpl:301
+	writescrapeHistory(qb422016, ts)
+//line lib/promscrape/targetstatus.qtpl:301
+	qs422016 := string(qb422016.B)
+//line lib/promscrape/targetstatus.qtpl:301
+	qt422016.ReleaseByteBuffer(qb422016)
+//line lib/promscrape/targetstatus.qtpl:301
+	return qs422016
+//line lib/promscrape/targetstatus.qtpl:301
+}
+
+//line lib/promscrape/targetstatus.qtpl:303
 func streamdiscoveredTargets(qw422016 *qt422016.Writer, tsr *targetsStatusResult) {
-//line lib/promscrape/targetstatus.qtpl:289
+//line lib/promscrape/targetstatus.qtpl:304
 	if !tsr.hasOriginalLabels {
-//line lib/promscrape/targetstatus.qtpl:289
+//line lib/promscrape/targetstatus.qtpl:304
 		qw422016.N().S(`<div class="alert alert-warning" role="alert">Discovered targets are unavailable when <b>-promscrape.dropOriginalLabels</b> command-line flag is set</div>`)
-//line lib/promscrape/targetstatus.qtpl:293
+//line lib/promscrape/targetstatus.qtpl:308
 		return
-//line lib/promscrape/targetstatus.qtpl:294
+//line lib/promscrape/targetstatus.qtpl:309
 	}
-//line lib/promscrape/targetstatus.qtpl:296
+//line lib/promscrape/targetstatus.qtpl:311
 	if n := droppedTargetsMap.getTotalTargets(); n > *maxDroppedTargets {
-//line lib/promscrape/targetstatus.qtpl:296
+//line lib/promscrape/targetstatus.qtpl:311
 		qw422016.N().S(`<div class="alert alert-warning" role="alert">Dropped targets' list below is incomplete, because the number of dropped targets exceeds <b>-promscrape.maxDroppedTargets=`)
-//line lib/promscrape/targetstatus.qtpl:298
+//line lib/promscrape/targetstatus.qtpl:313
 		qw422016.N().D(*maxDroppedTargets)
-//line lib/promscrape/targetstatus.qtpl:298
+//line lib/promscrape/targetstatus.qtpl:313
 		qw422016.N().S(`</b>.<br/>If you want to see the full list of dropped targets, then increase <b>-promscrape.maxDroppedTargets</b> command-line flag value to at least`)
-//line lib/promscrape/targetstatus.qtpl:299
+//line lib/promscrape/targetstatus.qtpl:314
 		qw422016.N().S(` `)
-//line lib/promscrape/targetstatus.qtpl:299
+//line lib/promscrape/targetstatus.qtpl:314
 		qw422016.N().S(`<b>`)
-//line lib/promscrape/targetstatus.qtpl:299
+//line lib/promscrape/targetstatus.qtpl:314
 		qw422016.N().D(n)
-//line lib/promscrape/targetstatus.qtpl:299
+//line lib/promscrape/targetstatus.qtpl:314
 		qw422016.N().S(`</b>.<br/>Note that this may increase memory usage.</div>`)
-//line lib/promscrape/targetstatus.qtpl:302
+//line lib/promscrape/targetstatus.qtpl:317
 	}
-//line lib/promscrape/targetstatus.qtpl:304
+//line lib/promscrape/targetstatus.qtpl:319
 	tljs := tsr.getTargetLabelsByJob()
 
-//line lib/promscrape/targetstatus.qtpl:304
+//line lib/promscrape/targetstatus.qtpl:319
 	qw422016.N().S(`<div class="row mt-4"><div class="col-12">`)
-//line lib/promscrape/targetstatus.qtpl:307
+//line lib/promscrape/targetstatus.qtpl:322
 	for i, tlj := range tljs {
-//line lib/promscrape/targetstatus.qtpl:308
+//line lib/promscrape/targetstatus.qtpl:323
 		streamdiscoveredJobTargets(qw422016, i, tlj)
-//line lib/promscrape/targetstatus.qtpl:309
+//line lib/promscrape/targetstatus.qtpl:324
 	}
-//line lib/promscrape/targetstatus.qtpl:309
+//line lib/promscrape/targetstatus.qtpl:324
 	qw422016.N().S(`</div></div>`)
-//line lib/promscrape/targetstatus.qtpl:312
+//line lib/promscrape/targetstatus.qtpl:327
 }
 
-//line lib/promscrape/targetstatus.qtpl:312
+//line lib/promscrape/targetstatus.qtpl:327
 func writediscoveredTargets(qq422016 qtio422016.Writer, tsr *targetsStatusResult) {
-//line lib/promscrape/targetstatus.qtpl:312
+//line lib/promscrape/targetstatus.qtpl:327
 	qw422016 := qt422016.AcquireWriter(qq422016)
-//line lib/promscrape/targetstatus.qtpl:312
+//line lib/promscrape/targetstatus.qtpl:327
 	streamdiscoveredTargets(qw422016, tsr)
-//line lib/promscrape/targetstatus.qtpl:312
+//line lib/promscrape/targetstatus.qtpl:327
 	qt422016.ReleaseWriter(qw422016)
-//line lib/promscrape/targetstatus.qtpl:312
+//line lib/promscrape/targetstatus.qtpl:327
 }
 
-//line lib/promscrape/targetstatus.qtpl:312
+//line lib/promscrape/targetstatus.qtpl:327
 func discoveredTargets(tsr *targetsStatusResult) string {
-//line lib/promscrape/targetstatus.qtpl:312
+//line lib/promscrape/targetstatus.qtpl:327
 	qb422016 := qt422016.AcquireByteBuffer()
-//line lib/promscrape/targetstatus.qtpl:312
+//line lib/promscrape/targetstatus.qtpl:327
 	writediscoveredTargets(qb422016, tsr)
-//line lib/promscrape/targetstatus.qtpl:312
+//line lib/promscrape/targetstatus.qtpl:327
 	qs422016 := string(qb422016.B)
-//line lib/promscrape/targetstatus.qtpl:312
+//line lib/promscrape/targetstatus.qtpl:327
 	qt422016.ReleaseByteBuffer(qb422016)
-//line lib/promscrape/targetstatus.qtpl:312
+//line lib/promscrape/targetstatus.qtpl:327
 	return qs422016
-//line lib/promscrape/targetstatus.qtpl:312
+//line lib/promscrape/targetstatus.qtpl:327
 }
 
-//line lib/promscrape/targetstatus.qtpl:314
+//line lib/promscrape/targetstatus.qtpl:329
 func streamdiscoveredJobTargets(qw422016 *qt422016.Writer, num int, tlj *targetLabelsByJob) {
-//line lib/promscrape/targetstatus.qtpl:314
+//line lib/promscrape/targetstatus.qtpl:329
 	qw422016.N().S(`<h4><span class="me-2">`)
-//line lib/promscrape/targetstatus.qtpl:316
+//line lib/promscrape/targetstatus.qtpl:331
 	qw422016.E().S(tlj.jobName)
-//line lib/promscrape/targetstatus.qtpl:316
+//line lib/promscrape/targetstatus.qtpl:331
 	qw422016.N().S(` `)
-//line lib/promscrape/targetstatus.qtpl:316
+//line lib/promscrape/targetstatus.qtpl:331
 	qw422016.N().S(`(`)
-//line lib/promscrape/targetstatus.qtpl:316
+//line lib/promscrape/targetstatus.qtpl:331
 	qw422016.N().D(tlj.activeTargets)
-//line lib/promscrape/targetstatus.qtpl:316
+//line lib/promscrape/targetstatus.qtpl:331
 	qw422016.N().S(`/`)
-//line lib/promscrape/targetstatus.qtpl:316
+//line lib/promscrape/targetstatus.qtpl:331
 	qw422016.N().D(tlj.activeTargets + tlj.droppedTargets)
-//line lib/promscrape/targetstatus.qtpl:316
+//line lib/promscrape/targetstatus.qtpl:331
 	qw422016.N().S(` `)
-//line lib/promscrape/targetstatus.qtpl:316
+//line lib/promscrape/targetstatus.qtpl:331
 	qw422016.N().S(`active)</span>`)
-//line lib/promscrape/targetstatus.qtpl:317
+//line lib/promscrape/targetstatus.qtpl:332
 	streamshowHideScrapeJobButtons(qw422016, num)
-//line lib/promscrape/targetstatus.qtpl:317
+//line lib/promscrape/targetstatus.qtpl:332
 	qw422016.N().S(`</h4><div id="scrape-job-`)
-//line lib/promscrape/targetstatus.qtpl:319
+//line lib/promscrape/targetstatus.qtpl:334
 	qw422016.N().D(num)
-//line lib/promscrape/targetstatus.qtpl:319
+//line lib/promscrape/targetstatus.qtpl:334
 	qw422016.N().S(`" class="scrape-job table-responsive"><table class="table table-striped table-hover table-bordered table-sm"><thead><tr><th scope="col" style="width: 5%">Status</th><th scope="col" style="width: 60%">Discovered Labels</th><th scope="col" style="width: 30%">Target Labels</th><th scope="col" stile="width: 5%">Debug relabeling</a></tr></thead><tbody>`)
-//line lib/promscrape/targetstatus.qtpl:330
+//line lib/promscrape/targetstatus.qtpl:345
 	for _, t := range tlj.targets {
-//line lib/promscrape/targetstatus.qtpl:330
+//line lib/promscrape/targetstatus.qtpl:345
 		qw422016.N().S(`<tr`)
-//line lib/promscrape/targetstatus.qtpl:332
+//line lib/promscrape/targetstatus.qtpl:347
 		if !t.up {
-//line lib/promscrape/targetstatus.qtpl:333
+//line lib/promscrape/targetstatus.qtpl:348
 			qw422016.N().S(` `)
-//line lib/promscrape/targetstatus.qtpl:333
+//line lib/promscrape/targetstatus.qtpl:348
 			qw422016.N().S(`role="alert"`)
-//line lib/promscrape/targetstatus.qtpl:333
+//line lib/promscrape/targetstatus.qtpl:348
 			qw422016.N().S(` `)
-//line lib/promscrape/targetstatus.qtpl:334
+//line lib/promscrape/targetstatus.qtpl:349
 			if t.labels.Len() > 0 {
-//line lib/promscrape/targetstatus.qtpl:334
+//line lib/promscrape/targetstatus.qtpl:349
 				qw422016.N().S(`class="alert alert-danger"`)
-//line lib/promscrape/targetstatus.qtpl:336
+//line lib/promscrape/targetstatus.qtpl:351
 			} else {
-//line lib/promscrape/targetstatus.qtpl:336
+//line lib/promscrape/targetstatus.qtpl:351
 				qw422016.N().S(`class="alert alert-warning"`)
-//line lib/promscrape/targetstatus.qtpl:338
+//line lib/promscrape/targetstatus.qtpl:353
 			}
-//line lib/promscrape/targetstatus.qtpl:339
+//line lib/promscrape/targetstatus.qtpl:354
 		}
-//line lib/promscrape/targetstatus.qtpl:339
+//line lib/promscrape/targetstatus.qtpl:354
 		qw422016.N().S(`><td>`)
-//line lib/promscrape/targetstatus.qtpl:342
+//line lib/promscrape/targetstatus.qtpl:357
 		if t.up {
-//line lib/promscrape/targetstatus.qtpl:342
+//line lib/promscrape/targetstatus.qtpl:357
 			qw422016.N().S(`<span class="badge bg-success">UP</span>`)
-//line lib/promscrape/targetstatus.qtpl:344
+//line lib/promscrape/targetstatus.qtpl:359
 		} else if t.labels.Len() > 0 {
-//line lib/promscrape/targetstatus.qtpl:344
+//line lib/promscrape/targetstatus.qtpl:359
 			qw422016.N().S(`<span class="badge bg-danger">DOWN</span>`)
-//line lib/promscrape/targetstatus.qtpl:346
+//line lib/promscrape/targetstatus.qtpl:361
 		} else {
-//line lib/promscrape/targetstatus.qtpl:346
+//line lib/promscrape/targetstatus.qtpl:361
 			qw422016.N().S(`<span class="badge bg-warning">DROPPED (`)
-//line lib/promscrape/targetstatus.qtpl:347
+//line lib/promscrape/targetstatus.qtpl:362
 			qw422016.E().S(string(t.dropReason))
-//line lib/promscrape/targetstatus.qtpl:347
+//line lib/promscrape/targetstatus.qtpl:362
 			qw422016.N().S(`)</span>`)
-//line lib/promscrape/targetstatus.qtpl:348
+//line lib/promscrape/targetstatus.qtpl:363
 			if len(t.clusterMemberNums) > 0 {
-//line lib/promscrape/targetstatus.qtpl:348
+//line lib/promscrape/targetstatus.qtpl:363
 				qw422016.N().S(`<br/><span title="The target exists at vmagent instances with the given -promscrape.cluster.memberNum values">exists at`)
-//line lib/promscrape/targetstatus.qtpl:351
+//line lib/promscrape/targetstatus.qtpl:366
 				qw422016.N().S(` `)
-//line lib/promscrape/targetstatus.qtpl:352
+//line lib/promscrape/targetstatus.qtpl:367
 				for i, memberNum := range t.clusterMemberNums {
-//line lib/promscrape/targetstatus.qtpl:353
+//line lib/promscrape/targetstatus.qtpl:368
 					if *clusterMemberURLTemplate == "" {
-//line lib/promscrape/targetstatus.qtpl:353
+//line lib/promscrape/targetstatus.qtpl:368
 						qw422016.N().S(`shard-`)
-//line lib/promscrape/targetstatus.qtpl:354
+//line lib/promscrape/targetstatus.qtpl:369
 						qw422016.N().D(memberNum)
-//line lib/promscrape/targetstatus.qtpl:355
+//line lib/promscrape/targetstatus.qtpl:370
 					} else {
-//line lib/promscrape/targetstatus.qtpl:355
+//line lib/promscrape/targetstatus.qtpl:370
 						qw422016.N().S(`<a href="`)
-//line lib/promscrape/targetstatus.qtpl:356
+//line lib/promscrape/targetstatus.qtpl:371
 						qw422016.E().S(strings.ReplaceAll(*clusterMemberURLTemplate, "%d", strconv.Itoa(memberNum)))
-//line lib/promscrape/targetstatus.qtpl:356
+//line lib/promscrape/targetstatus.qtpl:371
 						qw422016.N().S(`" target="_blank">shard-`)
-//line lib/promscrape/targetstatus.qtpl:356
+//line lib/promscrape/targetstatus.qtpl:371
 						qw422016.N().D(memberNum)
-//line lib/promscrape/targetstatus.qtpl:356
+//line lib/promscrape/targetstatus.qtpl:371
 						qw422016.N().S(`</a>`)
-//line lib/promscrape/targetstatus.qtpl:357
+//line lib/promscrape/targetstatus.qtpl:372
 					}
-//line lib/promscrape/targetstatus.qtpl:358
+//line lib/promscrape/targetstatus.qtpl:373
 					if i+1 < len(t.clusterMemberNums) {
-//line lib/promscrape/targetstatus.qtpl:358
+//line lib/promscrape/targetstatus.qtpl:373
 						qw422016.N().S(`,`)
-//line lib/promscrape/targetstatus.qtpl:358
+//line lib/promscrape/targetstatus.qtpl:373
 						qw422016.N().S(` `)
-//line lib/promscrape/targetstatus.qtpl:358
+//line lib/promscrape/targetstatus.qtpl:373
 					}
-//line lib/promscrape/targetstatus.qtpl:359
+//line lib/promscrape/targetstatus.qtpl:374
 				}
-//line lib/promscrape/targetstatus.qtpl:360
+//line lib/promscrape/targetstatus.qtpl:375
 			}
-//line lib/promscrape/targetstatus.qtpl:361
+//line lib/promscrape/targetstatus.qtpl:376
 		}
-//line lib/promscrape/targetstatus.qtpl:361
+//line lib/promscrape/targetstatus.qtpl:376
 		qw422016.N().S(`</td><td class="labels">`)
-//line lib/promscrape/targetstatus.qtpl:364
+//line lib/promscrape/targetstatus.qtpl:379
 		streamformatLabels(qw422016, t.originalLabels)
-//line lib/promscrape/targetstatus.qtpl:364
+//line lib/promscrape/targetstatus.qtpl:379
 		qw422016.N().S(`</td><td class="labels">`)
-//line lib/promscrape/targetstatus.qtpl:367
+//line lib/promscrape/targetstatus.qtpl:382
 		streamformatLabels(qw422016, t.labels)
-//line lib/promscrape/targetstatus.qtpl:367
+//line lib/promscrape/targetstatus.qtpl:382
 		qw422016.N().S(`</td><td>`)
-//line lib/promscrape/targetstatus.qtpl:370
+//line lib/promscrape/targetstatus.qtpl:385
 		targetID := getLabelsID(t.originalLabels)
 
-//line lib/promscrape/targetstatus.qtpl:370
+//line lib/promscrape/targetstatus.qtpl:385
 		qw422016.N().S(`<a href="target-relabel-debug?id=`)
-//line lib/promscrape/targetstatus.qtpl:371
+//line lib/promscrape/targetstatus.qtpl:386
 		qw422016.E().S(targetID)
-//line lib/promscrape/targetstatus.qtpl:371
+//line lib/promscrape/targetstatus.qtpl:386
 		qw422016.N().S(`" target="_blank">debug</a></td></tr>`)
-//line lib/promscrape/targetstatus.qtpl:374
+//line lib/promscrape/targetstatus.qtpl:389
 	}
-//line lib/promscrape/targetstatus.qtpl:374
+//line lib/promscrape/targetstatus.qtpl:389
 	qw422016.N().S(`</tbody></table></div>`)
-//line lib/promscrape/targetstatus.qtpl:378
+//line lib/promscrape/targetstatus.qtpl:393
 }
 
-//line lib/promscrape/targetstatus.qtpl:378
+//line lib/promscrape/targetstatus.qtpl:393
 func writediscoveredJobTargets(qq422016 qtio422016.Writer, num int, tlj *targetLabelsByJob) {
-//line lib/promscrape/targetstatus.qtpl:378
+//line lib/promscrape/targetstatus.qtpl:393
 	qw422016 := qt422016.AcquireWriter(qq422016)
-//line lib/promscrape/targetstatus.qtpl:378
+//line lib/promscrape/targetstatus.qtpl:393
 	streamdiscoveredJobTargets(qw422016, num, tlj)
-//line lib/promscrape/targetstatus.qtpl:378
+//line lib/promscrape/targetstatus.qtpl:393
 	qt422016.ReleaseWriter(qw422016)
-//line lib/promscrape/targetstatus.qtpl:378
+//line lib/promscrape/targetstatus.qtpl:393
 }
 
-//line lib/promscrape/targetstatus.qtpl:378
+//line lib/promscrape/targetstatus.qtpl:393
 func discoveredJobTargets(num int, tlj *targetLabelsByJob) string {
-//line lib/promscrape/targetstatus.qtpl:378
+//line lib/promscrape/targetstatus.qtpl:393
 	qb422016 := qt422016.AcquireByteBuffer()
-//line lib/promscrape/targetstatus.qtpl:378
+//line lib/promscrape/targetstatus.qtpl:393
 	writediscoveredJobTargets(qb422016, num, tlj)
-//line lib/promscrape/targetstatus.qtpl:378
+//line lib/promscrape/targetstatus.qtpl:393
 	qs422016 := string(qb422016.B)
-//line lib/promscrape/targetstatus.qtpl:378
+//line lib/promscrape/targetstatus.qtpl:393
 	qt422016.ReleaseByteBuffer(qb422016)
-//line lib/promscrape/targetstatus.qtpl:378
+//line lib/promscrape/targetstatus.qtpl:393
 	return qs422016
-//line lib/promscrape/targetstatus.qtpl:378
+//line lib/promscrape/targetstatus.qtpl:393
 }
 
-//line lib/promscrape/targetstatus.qtpl:380
+//line lib/promscrape/targetstatus.qtpl:395
 func streamshowHideScrapeJobButtons(qw422016 *qt422016.Writer, num int) {
-//line lib/promscrape/targetstatus.qtpl:380
+//line lib/promscrape/targetstatus.qtpl:395
 	qw422016.N().S(`<button type="button" class="btn btn-primary btn-sm me-1"onclick="document.getElementById('scrape-job-`)
-//line lib/promscrape/targetstatus.qtpl:382
+//line lib/promscrape/targetstatus.qtpl:397
 	qw422016.N().D(num)
-//line lib/promscrape/targetstatus.qtpl:382
+//line lib/promscrape/targetstatus.qtpl:397
 	qw422016.N().S(`').style.display='none'">collapse</button><button type="button" class="btn btn-secondary btn-sm me-1"onclick="document.getElementById('scrape-job-`)
-//line lib/promscrape/targetstatus.qtpl:386
+//line lib/promscrape/targetstatus.qtpl:401
 	qw422016.N().D(num)
-//line lib/promscrape/targetstatus.qtpl:386
+//line lib/promscrape/targetstatus.qtpl:401
 	qw422016.N().S(`').style.display='block'">expand</button>`)
-//line lib/promscrape/targetstatus.qtpl:389
+//line lib/promscrape/targetstatus.qtpl:404
 }
 
-//line lib/promscrape/targetstatus.qtpl:389
+//line lib/promscrape/targetstatus.qtpl:404
 func writeshowHideScrapeJobButtons(qq422016 qtio422016.Writer, num int) {
-//line lib/promscrape/targetstatus.qtpl:389
+//line lib/promscrape/targetstatus.qtpl:404
 	qw422016 := qt422016.AcquireWriter(qq422016)
-//line lib/promscrape/targetstatus.qtpl:389
+//line lib/promscrape/targetstatus.qtpl:404
 	streamshowHideScrapeJobButtons(qw422016, num)
-//line lib/promscrape/targetstatus.qtpl:389
+//line lib/promscrape/targetstatus.qtpl:404
 	qt422016.ReleaseWriter(qw422016)
-//line lib/promscrape/targetstatus.qtpl:389
+//line lib/promscrape/targetstatus.qtpl:404
 }
 
-//line lib/promscrape/targetstatus.qtpl:389
+//line lib/promscrape/targetstatus.qtpl:404
 func showHideScrapeJobButtons(num int) string {
-//line lib/promscrape/targetstatus.qtpl:389
+//line lib/promscrape/targetstatus.qtpl:404
 	qb422016 := qt422016.AcquireByteBuffer()
-//line lib/promscrape/targetstatus.qtpl:389
+//line lib/promscrape/targetstatus.qtpl:404
 	writeshowHideScrapeJobButtons(qb422016, num)
-//line lib/promscrape/targetstatus.qtpl:389
+//line lib/promscrape/targetstatus.qtpl:404
 	qs422016 := string(qb422016.B)
-//line lib/promscrape/targetstatus.qtpl:389
+//line lib/promscrape/targetstatus.qtpl:404
 	qt422016.ReleaseByteBuffer(qb422016)
-//line lib/promscrape/targetstatus.qtpl:389
+//line lib/promscrape/targetstatus.qtpl:404
 	return qs422016
-//line lib/promscrape/targetstatus.qtpl:389
+//line lib/promscrape/targetstatus.qtpl:404
 }
 
-//line lib/promscrape/targetstatus.qtpl:391
+//line lib/promscrape/targetstatus.qtpl:406
 func streamqueryArgs(qw422016 *qt422016.Writer, filter *requestFilter, override map[string]string) {
-//line lib/promscrape/targetstatus.qtpl:393
+//line lib/promscrape/targetstatus.qtpl:408
 	showOnlyUnhealthy := "false"
 	if filter.showOnlyUnhealthy {
 		showOnlyUnhealthy = "true"
@@ -1055,89 +1134,89 @@ func streamqueryArgs(qw422016 *qt422016.Writer, filter *requestFilter, override
 		qa[k] = []string{v}
 	}
 
-//line lib/promscrape/targetstatus.qtpl:410
+//line lib/promscrape/targetstatus.qtpl:425
 	qw422016.E().S(qa.Encode())
-//line lib/promscrape/targetstatus.qtpl:411
+//line lib/promscrape/targetstatus.qtpl:426
 }
 
-//line lib/promscrape/targetstatus.qtpl:411
+//line lib/promscrape/targetstatus.qtpl:426
 func writequeryArgs(qq422016 qtio422016.Writer, filter *requestFilter, override map[string]string) {
-//line lib/promscrape/targetstatus.qtpl:411
+//line lib/promscrape/targetstatus.qtpl:426
 	qw422016 := qt422016.AcquireWriter(qq422016)
-//line lib/promscrape/targetstatus.qtpl:411
+//line lib/promscrape/targetstatus.qtpl:426
 	streamqueryArgs(qw422016, filter, override)
-//line lib/promscrape/targetstatus.qtpl:411
+//line lib/promscrape/targetstatus.qtpl:426
 	qt422016.ReleaseWriter(qw422016)
-//line lib/promscrape/targetstatus.qtpl:411
+//line lib/promscrape/targetstatus.qtpl:426
 }
 
-//line lib/promscrape/targetstatus.qtpl:411
+//line lib/promscrape/targetstatus.qtpl:426
 func queryArgs(filter *requestFilter, override map[string]string) string {
-//line lib/promscrape/targetstatus.qtpl:411
+//line lib/promscrape/targetstatus.qtpl:426
 	qb422016 := qt422016.AcquireByteBuffer()
-//line lib/promscrape/targetstatus.qtpl:411
+//line lib/promscrape/targetstatus.qtpl:426
 	writequeryArgs(qb422016, filter, override)
-//line lib/promscrape/targetstatus.qtpl:411
+//line lib/promscrape/targetstatus.qtpl:426
 	qs422016 := string(qb422016.B)
-//line lib/promscrape/targetstatus.qtpl:411
+//line lib/promscrape/targetstatus.qtpl:426
 	qt422016.ReleaseByteBuffer(qb422016)
-//line lib/promscrape/targetstatus.qtpl:411
+//line lib/promscrape/targetstatus.qtpl:426
 	return qs422016
-//line lib/promscrape/targetstatus.qtpl:411
+//line lib/promscrape/targetstatus.qtpl:426
 }
 
-//line lib/promscrape/targetstatus.qtpl:413
+//line lib/promscrape/targetstatus.qtpl:428
 func streamformatLabels(qw422016 *qt422016.Writer, labels *promutil.Labels) {
-//line lib/promscrape/targetstatus.qtpl:414
+//line lib/promscrape/targetstatus.qtpl:429
 	labelsList := labels.GetLabels()
 
-//line lib/promscrape/targetstatus.qtpl:414
+//line lib/promscrape/targetstatus.qtpl:429
 	qw422016.N().S(`{`)
-//line lib/promscrape/targetstatus.qtpl:416
+//line lib/promscrape/targetstatus.qtpl:431
 	for i, label := range labelsList {
-//line lib/promscrape/targetstatus.qtpl:417
+//line lib/promscrape/targetstatus.qtpl:432
 		qw422016.E().S(label.Name)
-//line lib/promscrape/targetstatus.qtpl:417
+//line lib/promscrape/targetstatus.qtpl:432
 		qw422016.N().S(`=`)
-//line lib/promscrape/targetstatus.qtpl:417
+//line lib/promscrape/targetstatus.qtpl:432
 		qw422016.E().Q(label.Value)
-//line lib/promscrape/targetstatus.qtpl:418
+//line lib/promscrape/targetstatus.qtpl:433
 		if i+1 < len(labelsList) {
-//line lib/promscrape/targetstatus.qtpl:418
+//line lib/promscrape/targetstatus.qtpl:433
 			qw422016.N().S(`,`)
-//line lib/promscrape/targetstatus.qtpl:418
+//line lib/promscrape/targetstatus.qtpl:433
 			qw422016.N().S(` `)
-//line lib/promscrape/targetstatus.qtpl:418
+//line lib/promscrape/targetstatus.qtpl:433
 		}
-//line lib/promscrape/targetstatus.qtpl:419
+//line lib/promscrape/targetstatus.qtpl:434
 	}
-//line lib/promscrape/targetstatus.qtpl:419
+//line lib/promscrape/targetstatus.qtpl:434
 	qw422016.N().S(`}`)
-//line lib/promscrape/targetstatus.qtpl:421
+//line lib/promscrape/targetstatus.qtpl:436
 }
 
-//line lib/promscrape/targetstatus.qtpl:421
+//line lib/promscrape/targetstatus.qtpl:436
 func writeformatLabels(qq422016 qtio422016.Writer, labels *promutil.Labels) {
-//line lib/promscrape/targetstatus.qtpl:421
+//line lib/promscrape/targetstatus.qtpl:436
 	qw422016 := qt422016.AcquireWriter(qq422016)
-//line lib/promscrape/targetstatus.qtpl:421
+//line lib/promscrape/targetstatus.qtpl:436
 	streamformatLabels(qw422016, labels)
-//line lib/promscrape/targetstatus.qtpl:421
+//line lib/promscrape/targetstatus.qtpl:436
 	qt422016.ReleaseWriter(qw422016)
-//line lib/promscrape/targetstatus.qtpl:421
+//line lib/promscrape/targetstatus.qtpl:436
 }
 
-//line lib/promscrape/targetstatus.qtpl:421
+//line lib/promscrape/targetstatus.qtpl:436
 func formatLabels(labels *promutil.Labels) string {
-//line lib/promscrape/targetstatus.qtpl:421
+//line lib/promscrape/targetstatus.qtpl:436
 	qb422016 := qt422016.AcquireByteBuffer()
-//line lib/promscrape/targetstatus.qtpl:421
+//line lib/promscrape/targetstatus.qtpl:436
 	writeformatLabels(qb422016, labels)
-//line lib/promscrape/targetstatus.qtpl:421
+//line lib/promscrape/targetstatus.qtpl:436
 	qs422016 := string(qb422016.B)
-//line lib/promscrape/targetstatus.qtpl:421
+//line lib/promscrape/targetstatus.qtpl:436
 	qt422016.ReleaseByteBuffer(qb422016)
-//line lib/promscrape/targetstatus.qtpl:421
+//line lib/promscrape/targetstatus.qtpl:436
 	return qs422016
-//line lib/promscrape/targetstatus.qtpl:421
+//line lib/promscrape/targetstatus.qtpl:436
 }
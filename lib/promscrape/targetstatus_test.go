@@ -3,7 +3,9 @@ package promscrape
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutil"
@@ -35,7 +37,7 @@ func TestWriteActiveTargetsJSON(t *testing.T) {
 	f := func(scrapePoolFilter string, exp []activeTarget) {
 		t.Helper()
 		b := &bytes.Buffer{}
-		tsm.WriteActiveTargetsJSON(b, scrapePoolFilter)
+		tsm.WriteActiveTargetsJSON(b, scrapePoolFilter, false)
 
 		var got []activeTarget
 		if err := json.Unmarshal(b.Bytes(), &got); err != nil {
@@ -59,6 +61,77 @@ func TestWriteActiveTargetsJSON(t *testing.T) {
 	f("unknown", []activeTarget{})
 }
 
+func TestTargetStatusHistory(t *testing.T) {
+	defer func(n int) {
+		*targetHealthHistorySize = n
+	}(*targetHealthHistorySize)
+	*targetHealthHistorySize = 3
+
+	tsm := newTargetStatusMap()
+	sw := &scrapeWork{
+		Config: &ScrapeWork{
+			jobNameOriginal: "foo",
+			OriginalLabels: promutil.NewLabelsFromMap(map[string]string{
+				"__address__": "host1:80",
+			}),
+		},
+	}
+	tsm.Register(sw)
+
+	type scrapeHistoryEntry struct {
+		SamplesScraped int    `json:"samplesScraped"`
+		Error          string `json:"error"`
+		Health         string `json:"health"`
+	}
+	type activeTarget struct {
+		ScrapeHistory []scrapeHistoryEntry `json:"scrapeHistory"`
+		StateChanges  int                  `json:"stateChanges"`
+		Health        string               `json:"health"`
+	}
+	f := func(up bool, samplesScraped int, err error, historyExpected []scrapeHistoryEntry, stateChangesExpected int) {
+		t.Helper()
+
+		tsm.Update(sw, up, 1000, 10, 100, samplesScraped, err)
+
+		b := &bytes.Buffer{}
+		tsm.WriteActiveTargetsJSON(b, "", true)
+		var got []activeTarget
+		if err := json.Unmarshal(b.Bytes(), &got); err != nil {
+			t.Fatalf("cannot parse response: %s", err)
+		}
+		if len(got) != 1 {
+			t.Fatalf("unexpected number of targets; got %d; want 1", len(got))
+		}
+		if !reflect.DeepEqual(got[0].ScrapeHistory, historyExpected) {
+			t.Fatalf("unexpected scrape history;\ngot\n%v\nwant\n%v", got[0].ScrapeHistory, historyExpected)
+		}
+		if got[0].StateChanges != stateChangesExpected {
+			t.Fatalf("unexpected state changes; got %d; want %d", got[0].StateChanges, stateChangesExpected)
+		}
+	}
+
+	up1 := scrapeHistoryEntry{SamplesScraped: 1, Health: "up"}
+	down2 := scrapeHistoryEntry{SamplesScraped: 2, Health: "down", Error: "error 2"}
+	up3 := scrapeHistoryEntry{SamplesScraped: 3, Health: "up"}
+	down4 := scrapeHistoryEntry{SamplesScraped: 4, Health: "down", Error: "error 4"}
+	down5 := scrapeHistoryEntry{SamplesScraped: 5, Health: "down", Error: "error 5"}
+
+	f(true, 1, nil, []scrapeHistoryEntry{up1}, 0)
+	f(false, 2, fmt.Errorf("error 2"), []scrapeHistoryEntry{up1, down2}, 1)
+	f(true, 3, nil, []scrapeHistoryEntry{up1, down2, up3}, 2)
+
+	// the oldest results must be evicted from the history
+	f(false, 4, fmt.Errorf("error 4"), []scrapeHistoryEntry{down2, up3, down4}, 2)
+	f(false, 5, fmt.Errorf("error 5"), []scrapeHistoryEntry{up3, down4, down5}, 1)
+
+	// history must be missing if it isn't requested
+	b := &bytes.Buffer{}
+	tsm.WriteActiveTargetsJSON(b, "", false)
+	if strings.Contains(b.String(), "scrapeHistory") {
+		t.Fatalf("unexpected scrapeHistory in the response: %s", b.String())
+	}
+}
+
 func TestRegisterDroppedTargets(t *testing.T) {
 	type opts struct {
 		toRegister       []*promutil.Labels
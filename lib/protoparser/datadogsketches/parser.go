@@ -366,3 +366,44 @@ func f64(k int32) float64 {
 	exp := float64(int(k) - bias)
 	return math.Pow(gamma, exp)
 }
+
+// maxKey is the maximum key for finite values. See f64().
+const maxKey = (1 << 15) - 2
+
+// ValueToKey returns the key of the sketch bucket for v.
+//
+// The bucket with the returned key contains v. See KeyToBounds.
+// Values with absolute value smaller than 1e-9 are put into the bucket with zero key.
+func ValueToKey(v float64) int32 {
+	switch {
+	case math.IsNaN(v):
+		return 0
+	case v < 0:
+		return -ValueToKey(-v)
+	case v <= defaultMin:
+		return 0
+	}
+	k := math.Floor(math.Log(v)/gammaLn) + float64(bias)
+	if k > maxKey {
+		return maxKey
+	}
+	if k < 1 {
+		return 1
+	}
+	return int32(k)
+}
+
+// KeyToBounds returns the lower and the upper bounds of the sketch bucket with the given key k.
+//
+// Buckets for bigger keys contain bigger values.
+func KeyToBounds(k int32) (float64, float64) {
+	switch {
+	case k == 0:
+		return 0, defaultMin
+	case k < 0:
+		lower, upper := KeyToBounds(-k)
+		return -upper, -lower
+	}
+	lower := f64(k)
+	return lower, lower * gamma
+}
@@ -62,3 +62,41 @@ func TestSketchToSummary(t *testing.T) {
 		},
 	})
 }
+
+func TestValueToKey(t *testing.T) {
+	f := func(v float64) {
+		t.Helper()
+
+		k := ValueToKey(v)
+		lower, upper := KeyToBounds(k)
+		if v < lower || v > upper {
+			t.Fatalf("value %v is out of bounds [%v..%v] for key %d", v, lower, upper, k)
+		}
+		if v != 0 && math.Abs(upper-lower) > math.Abs(v)*2*eps+1e-15 {
+			t.Fatalf("too wide bounds [%v..%v] for value %v", lower, upper, v)
+		}
+	}
+
+	f(0)
+	f(1e-6)
+	f(0.25)
+	f(1)
+	f(1.5)
+	f(123.456)
+	f(1e12)
+	f(-1)
+	f(-123.456)
+
+	// Values close to zero are put into zero bucket
+	if k := ValueToKey(1e-12); k != 0 {
+		t.Fatalf("unexpected key for 1e-12; got %d; want 0", k)
+	}
+
+	// Bigger values must have bigger keys
+	values := []float64{-1e6, -10, -1, 0, 1e-6, 1, 10, 1e6}
+	for i := 1; i < len(values); i++ {
+		if ValueToKey(values[i-1]) >= ValueToKey(values[i]) {
+			t.Fatalf("key for %v must be smaller than the key for %v", values[i-1], values[i])
+		}
+	}
+}
@@ -297,17 +297,19 @@ func (g *Gauge) unmarshalProtobuf(src []byte) (err error) {
 
 // NumberDataPoint represents the corresponding OTEL protobuf message
 type NumberDataPoint struct {
-	Attributes   []*KeyValue
-	TimeUnixNano uint64
-	DoubleValue  *float64
-	IntValue     *int64
-	Flags        uint32
+	Attributes        []*KeyValue
+	StartTimeUnixNano uint64
+	TimeUnixNano      uint64
+	DoubleValue       *float64
+	IntValue          *int64
+	Flags             uint32
 }
 
 func (ndp *NumberDataPoint) marshalProtobuf(mm *easyproto.MessageMarshaler) {
 	for _, a := range ndp.Attributes {
 		a.marshalProtobuf(mm.AppendMessage(7))
 	}
+	mm.AppendFixed64(2, ndp.StartTimeUnixNano)
 	mm.AppendFixed64(3, ndp.TimeUnixNano)
 	switch {
 	case ndp.DoubleValue != nil:
@@ -321,6 +323,7 @@ func (ndp *NumberDataPoint) marshalProtobuf(mm *easyproto.MessageMarshaler) {
 func (ndp *NumberDataPoint) unmarshalProtobuf(src []byte) (err error) {
 	// message NumberDataPoint {
 	//   repeated KeyValue attributes = 7;
+	//   fixed64 start_time_unix_nano = 2;
 	//   fixed64 time_unix_nano = 3;
 	//   oneof value {
 	//     double as_double = 4;
@@ -345,6 +348,12 @@ func (ndp *NumberDataPoint) unmarshalProtobuf(src []byte) (err error) {
 			if err := a.unmarshalProtobuf(data); err != nil {
 				return fmt.Errorf("cannot unmarshal Attribute: %w", err)
 			}
+		case 2:
+			startTimeUnixNano, ok := fc.Fixed64()
+			if !ok {
+				return fmt.Errorf("cannot read StartTimeUnixNano")
+			}
+			ndp.StartTimeUnixNano = startTimeUnixNano
 		case 3:
 			timeUnixNano, ok := fc.Fixed64()
 			if !ok {
@@ -571,19 +580,21 @@ func (s *Summary) unmarshalProtobuf(src []byte) (err error) {
 
 // HistogramDataPoint represents the corresponding OTEL protobuf message
 type HistogramDataPoint struct {
-	Attributes     []*KeyValue
-	TimeUnixNano   uint64
-	Count          uint64
-	Sum            *float64
-	BucketCounts   []uint64
-	ExplicitBounds []float64
-	Flags          uint32
+	Attributes        []*KeyValue
+	StartTimeUnixNano uint64
+	TimeUnixNano      uint64
+	Count             uint64
+	Sum               *float64
+	BucketCounts      []uint64
+	ExplicitBounds    []float64
+	Flags             uint32
 }
 
 func (dp *HistogramDataPoint) marshalProtobuf(mm *easyproto.MessageMarshaler) {
 	for _, a := range dp.Attributes {
 		a.marshalProtobuf(mm.AppendMessage(9))
 	}
+	mm.AppendFixed64(2, dp.StartTimeUnixNano)
 	mm.AppendFixed64(3, dp.TimeUnixNano)
 	mm.AppendFixed64(4, dp.Count)
 	if dp.Sum != nil {
@@ -597,6 +608,7 @@ func (dp *HistogramDataPoint) marshalProtobuf(mm *easyproto.MessageMarshaler) {
 func (dp *HistogramDataPoint) unmarshalProtobuf(src []byte) (err error) {
 	// message HistogramDataPoint {
 	//   repeated KeyValue attributes = 9;
+	//   fixed64 start_time_unix_nano = 2;
 	//   fixed64 time_unix_nano = 3;
 	//   fixed64 count = 4;
 	//   optional double sum = 5;
@@ -621,6 +633,12 @@ func (dp *HistogramDataPoint) unmarshalProtobuf(src []byte) (err error) {
 			if err := a.unmarshalProtobuf(data); err != nil {
 				return fmt.Errorf("cannot unmarshal Attribute: %w", err)
 			}
+		case 2:
+			startTimeUnixNano, ok := fc.Fixed64()
+			if !ok {
+				return fmt.Errorf("cannot read StartTimeUnixNano")
+			}
+			dp.StartTimeUnixNano = startTimeUnixNano
 		case 3:
 			timeUnixNano, ok := fc.Fixed64()
 			if !ok {
@@ -664,24 +682,26 @@ func (dp *HistogramDataPoint) unmarshalProtobuf(src []byte) (err error) {
 
 // ExponentialHistogramDataPoint represents the corresponding OTEL protobuf message
 type ExponentialHistogramDataPoint struct {
-	Attributes    []*KeyValue
-	TimeUnixNano  uint64
-	Count         uint64
-	Sum           *float64
-	Scale         int32
-	ZeroCount     uint64
-	Positive      *Buckets
-	Negative      *Buckets
-	Flags         uint32
-	Min           *float64
-	Max           *float64
-	ZeroThreshold float64
+	Attributes        []*KeyValue
+	StartTimeUnixNano uint64
+	TimeUnixNano      uint64
+	Count             uint64
+	Sum               *float64
+	Scale             int32
+	ZeroCount         uint64
+	Positive          *Buckets
+	Negative          *Buckets
+	Flags             uint32
+	Min               *float64
+	Max               *float64
+	ZeroThreshold     float64
 }
 
 func (dp *ExponentialHistogramDataPoint) marshalProtobuf(mm *easyproto.MessageMarshaler) {
 	for _, a := range dp.Attributes {
 		a.marshalProtobuf(mm.AppendMessage(1))
 	}
+	mm.AppendFixed64(2, dp.StartTimeUnixNano)
 	mm.AppendFixed64(3, dp.TimeUnixNano)
 	mm.AppendFixed64(4, dp.Count)
 	if dp.Sum != nil {
@@ -708,6 +728,7 @@ func (dp *ExponentialHistogramDataPoint) marshalProtobuf(mm *easyproto.MessageMa
 func (dp *ExponentialHistogramDataPoint) unmarshalProtobuf(src []byte) (err error) {
 	// message ExponentialHistogramDataPoint {
 	//   repeated KeyValue attributes = 1;
+	//   fixed64 start_time_unix_nano = 2;
 	//   fixed64 time_unix_nano = 3;
 	//   fixed64 count = 4;
 	//   optional double sum = 5;
@@ -737,6 +758,12 @@ func (dp *ExponentialHistogramDataPoint) unmarshalProtobuf(src []byte) (err erro
 			if err := a.unmarshalProtobuf(data); err != nil {
 				return fmt.Errorf("cannot unmarshal Attribute: %w", err)
 			}
+		case 2:
+			startTimeUnixNano, ok := fc.Fixed64()
+			if !ok {
+				return fmt.Errorf("cannot read StartTimeUnixNano")
+			}
+			dp.StartTimeUnixNano = startTimeUnixNano
 		case 3:
 			timeUnixNano, ok := fc.Fixed64()
 			if !ok {
@@ -858,18 +885,20 @@ func (b *Buckets) unmarshalProtobuf(src []byte) (err error) {
 
 // SummaryDataPoint represents the corresponding OTEL protobuf message
 type SummaryDataPoint struct {
-	Attributes     []*KeyValue
-	TimeUnixNano   uint64
-	Count          uint64
-	Sum            float64
-	QuantileValues []*ValueAtQuantile
-	Flags          uint32
+	Attributes        []*KeyValue
+	StartTimeUnixNano uint64
+	TimeUnixNano      uint64
+	Count             uint64
+	Sum               float64
+	QuantileValues    []*ValueAtQuantile
+	Flags             uint32
 }
 
 func (dp *SummaryDataPoint) marshalProtobuf(mm *easyproto.MessageMarshaler) {
 	for _, a := range dp.Attributes {
 		a.marshalProtobuf(mm.AppendMessage(7))
 	}
+	mm.AppendFixed64(2, dp.StartTimeUnixNano)
 	mm.AppendFixed64(3, dp.TimeUnixNano)
 	mm.AppendFixed64(4, dp.Count)
 	mm.AppendDouble(5, dp.Sum)
@@ -882,6 +911,7 @@ func (dp *SummaryDataPoint) marshalProtobuf(mm *easyproto.MessageMarshaler) {
 func (dp *SummaryDataPoint) unmarshalProtobuf(src []byte) (err error) {
 	// message SummaryDataPoint {
 	//   repeated KeyValue attributes = 7;
+	//   fixed64 start_time_unix_nano = 2;
 	//   fixed64 time_unix_nano = 3;
 	//   fixed64 count = 4;
 	//   double sum = 5;
@@ -905,6 +935,12 @@ func (dp *SummaryDataPoint) unmarshalProtobuf(src []byte) (err error) {
 			if err := a.unmarshalProtobuf(data); err != nil {
 				return fmt.Errorf("cannot unmarshal Attribute: %w", err)
 			}
+		case 2:
+			startTimeUnixNano, ok := fc.Fixed64()
+			if !ok {
+				return fmt.Errorf("cannot read StartTimeUnixNano")
+			}
+			dp.StartTimeUnixNano = startTimeUnixNano
 		case 3:
 			timeUnixNano, ok := fc.Fixed64()
 			if !ok {
@@ -0,0 +1,114 @@
+package stream
+
+import (
+	"flag"
+	"sync"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/cespare/xxhash/v2"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/decimal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
+)
+
+var createdTimestampZeroIngestion = flag.Bool("opentelemetry.createdTimestampZeroIngestion", false, "Whether to add a sample with zero value at the start timestamp "+
+	"for new cumulative monotonic sums, histograms and summaries ingested via OpenTelemetry protocol. This allows calculating the increase of such metrics "+
+	"for the first interval after their start; see https://docs.victoriametrics.com/victoriametrics/single-server-victoriametrics/#sending-data-via-opentelemetry")
+
+// appendStartTimestampZeroSamples appends zero samples at the start timestamp for wr.tss[tssLen:] series,
+// which weren't seen with the given start timestamp recently.
+//
+// This allows calculating the increase for the first interval after the series start.
+func (wr *writeContext) appendStartTimestampZeroSamples(tssLen int, startTimeUnixNano uint64) {
+	if !*createdTimestampZeroIngestion || startTimeUnixNano == 0 {
+		return
+	}
+	startTimestamp := int64(startTimeUnixNano / 1e6)
+	tssEnd := len(wr.tss)
+	for i := tssLen; i < tssEnd; i++ {
+		ts := &wr.tss[i]
+		if len(ts.Samples) == 0 {
+			continue
+		}
+		sample := &ts.Samples[0]
+		if startTimestamp >= sample.Timestamp || decimal.IsStaleNaN(sample.Value) {
+			continue
+		}
+		wr.buf = marshalLabels(wr.buf[:0], ts.Labels)
+		h := xxhash.Sum64(wr.buf)
+		if !startTimestamps.registerNew(h, startTimestamp) {
+			continue
+		}
+
+		labels := ts.Labels
+		samplesLen := len(wr.samplesPool)
+		wr.samplesPool = append(wr.samplesPool, prompb.Sample{
+			Timestamp: startTimestamp,
+		})
+		wr.tss = append(wr.tss, prompb.TimeSeries{
+			Labels:  labels,
+			Samples: wr.samplesPool[samplesLen:],
+		})
+		startTimestampZeroSamples.Inc()
+	}
+}
+
+var startTimestampZeroSamples = metrics.NewCounter(`vm_protoparser_opentelemetry_start_timestamp_zero_samples_total`)
+
+func marshalLabels(dst []byte, labels []prompb.Label) []byte {
+	for _, label := range labels {
+		dst = append(dst, label.Name...)
+		dst = append(dst, 0)
+		dst = append(dst, label.Value...)
+		dst = append(dst, 0)
+	}
+	return dst
+}
+
+// startTimestamps tracks start timestamps for the recently ingested series.
+var startTimestamps startTimestampsCache
+
+// startTimestampsCacheRotationInterval is the interval in seconds for dropping start timestamps for inactive series.
+const startTimestampsCacheRotationInterval = 3600
+
+type startTimestampsCache struct {
+	shards [16]startTimestampsCacheShard
+}
+
+// registerNew registers startTimestamp for the series with the given hash h.
+//
+// It returns false if the series with the same startTimestamp has been already registered recently.
+func (stc *startTimestampsCache) registerNew(h uint64, startTimestamp int64) bool {
+	shard := &stc.shards[h%uint64(len(stc.shards))]
+	return shard.registerNew(h, startTimestamp)
+}
+
+type startTimestampsCacheShard struct {
+	mu sync.Mutex
+
+	// curr and prev contain start timestamps for series registered during the current and the previous rotation intervals.
+	curr map[uint64]int64
+	prev map[uint64]int64
+
+	lastRotation uint64
+}
+
+func (shard *startTimestampsCacheShard) registerNew(h uint64, startTimestamp int64) bool {
+	currentTime := fasttime.UnixTimestamp()
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if shard.curr == nil || currentTime-shard.lastRotation > startTimestampsCacheRotationInterval {
+		shard.prev = shard.curr
+		shard.curr = make(map[uint64]int64)
+		shard.lastRotation = currentTime
+	}
+	if ts, ok := shard.curr[h]; ok && ts == startTimestamp {
+		return false
+	}
+	ts, ok := shard.prev[h]
+	shard.curr[h] = startTimestamp
+	return !ok || ts != startTimestamp
+}
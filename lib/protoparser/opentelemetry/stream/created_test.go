@@ -0,0 +1,92 @@
+package stream
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/opentelemetry/pb"
+)
+
+func TestParseStreamStartTimestampZeroSamples(t *testing.T) {
+	prevCreatedTimestampZeroIngestion := *createdTimestampZeroIngestion
+	*createdTimestampZeroIngestion = true
+	defer func() {
+		*createdTimestampZeroIngestion = prevCreatedTimestampZeroIngestion
+	}()
+
+	f := func(metrics []*pb.Metric, resultExpected string) {
+		t.Helper()
+		req := &pb.ExportMetricsServiceRequest{
+			ResourceMetrics: []*pb.ResourceMetrics{
+				generateOTLPSamples(metrics),
+			},
+		}
+		data := req.MarshalProtobuf(nil)
+		var a []string
+		err := ParseStream(bytes.NewBuffer(data), "", nil, func(tss []prompb.TimeSeries) error {
+			for _, ts := range tss {
+				for _, s := range ts.Samples {
+					if s.Value == 0 {
+						a = append(a, fmt.Sprintf("%s@%d", getMetricName(ts.Labels), s.Timestamp))
+					}
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		sort.Strings(a)
+		result := strings.Join(a, ",")
+		if result != resultExpected {
+			t.Fatalf("unexpected zero samples;\ngot\n%s\nwant\n%s", result, resultExpected)
+		}
+	}
+
+	withStartTime := func(m *pb.Metric, startTime time.Duration) *pb.Metric {
+		switch {
+		case m.Sum != nil:
+			m.Sum.DataPoints[0].StartTimeUnixNano = uint64(startTime)
+		case m.Gauge != nil:
+			m.Gauge.DataPoints[0].StartTimeUnixNano = uint64(startTime)
+		case m.Histogram != nil:
+			m.Histogram.DataPoints[0].StartTimeUnixNano = uint64(startTime)
+		case m.Summary != nil:
+			m.Summary.DataPoints[0].StartTimeUnixNano = uint64(startTime)
+		}
+		return m
+	}
+
+	// zero samples are added for new monotonic sums, histograms and summaries.
+	// ct_histogram_bucket@30000 is the real sample for the first bucket.
+	f([]*pb.Metric{
+		withStartTime(generateSum("ct_sum", "", true), 100*time.Second),
+		withStartTime(generateSum("ct_non_monotonic_sum", "", false), 100*time.Second),
+		withStartTime(generateGauge("ct_gauge", ""), 10*time.Second),
+		withStartTime(generateHistogram("ct_histogram", "", true), 20*time.Second),
+		withStartTime(generateSummary("ct_summary", ""), 30*time.Second),
+	}, "ct_histogram_bucket@20000,ct_histogram_bucket@20000,ct_histogram_bucket@20000,ct_histogram_bucket@20000,ct_histogram_bucket@20000,"+
+		"ct_histogram_bucket@30000,ct_histogram_count@20000,ct_histogram_sum@20000,ct_sum@100000,ct_summary@30000,ct_summary@30000,ct_summary@30000,ct_summary_count@30000,ct_summary_sum@30000")
+
+	// zero samples aren't added for series with already seen start timestamp
+	f([]*pb.Metric{
+		withStartTime(generateSum("ct_sum", "", true), 100*time.Second),
+		withStartTime(generateSummary("ct_summary", ""), 30*time.Second),
+	}, "")
+
+	// zero samples are added for series with updated start timestamp
+	f([]*pb.Metric{
+		withStartTime(generateSum("ct_sum", "", true), 120*time.Second),
+	}, "ct_sum@120000")
+
+	// zero samples aren't added for series with missing start timestamp or with start timestamp after the sample timestamp
+	f([]*pb.Metric{
+		generateSum("ct_sum_without_start", "", true),
+		withStartTime(generateSum("ct_sum_future_start", "", true), 200*time.Second),
+	}, "")
+}
@@ -82,11 +82,17 @@ func (wr *writeContext) appendSamplesFromScopeMetrics(sc *pb.ScopeMetrics) {
 				continue
 			}
 			for _, p := range m.Sum.DataPoints {
+				tssLen := len(wr.tss)
 				wr.appendSampleFromNumericPoint(metricName, p)
+				if m.Sum.IsMonotonic {
+					wr.appendStartTimestampZeroSamples(tssLen, p.StartTimeUnixNano)
+				}
 			}
 		case m.Summary != nil:
 			for _, p := range m.Summary.DataPoints {
+				tssLen := len(wr.tss)
 				wr.appendSamplesFromSummary(metricName, p)
+				wr.appendStartTimestampZeroSamples(tssLen, p.StartTimeUnixNano)
 			}
 		case m.Histogram != nil:
 			if m.Histogram.AggregationTemporality != pb.AggregationTemporalityCumulative {
@@ -95,7 +101,9 @@ func (wr *writeContext) appendSamplesFromScopeMetrics(sc *pb.ScopeMetrics) {
 				continue
 			}
 			for _, p := range m.Histogram.DataPoints {
+				tssLen := len(wr.tss)
 				wr.appendSamplesFromHistogram(metricName, p)
+				wr.appendStartTimestampZeroSamples(tssLen, p.StartTimeUnixNano)
 			}
 		case m.ExponentialHistogram != nil:
 			if m.ExponentialHistogram.AggregationTemporality != pb.AggregationTemporalityCumulative {
@@ -104,7 +112,9 @@ func (wr *writeContext) appendSamplesFromScopeMetrics(sc *pb.ScopeMetrics) {
 				continue
 			}
 			for _, p := range m.ExponentialHistogram.DataPoints {
+				tssLen := len(wr.tss)
 				wr.appendSamplesFromExponentialHistogram(metricName, p)
+				wr.appendStartTimestampZeroSamples(tssLen, p.StartTimeUnixNano)
 			}
 		default:
 			rowsDroppedUnsupportedMetricType.Inc()
@@ -293,6 +303,9 @@ type writeContext struct {
 	// pools are used for reducing memory allocations when parsing time series
 	labelsPool  []prompb.Label
 	samplesPool []prompb.Sample
+
+	// buf is used for marshaling labels when calculating their hashes
+	buf []byte
 }
 
 func (wr *writeContext) reset() {
@@ -304,6 +317,7 @@ func (wr *writeContext) reset() {
 
 	wr.labelsPool = resetLabels(wr.labelsPool)
 	wr.samplesPool = wr.samplesPool[:0]
+	wr.buf = wr.buf[:0]
 }
 
 func resetLabels(labels []prompb.Label) []prompb.Label {
@@ -0,0 +1,112 @@
+package prometheus
+
+import (
+	"sort"
+	"strings"
+)
+
+// CreatedTimestamps contains creation timestamps obtained from OpenMetrics `_created` series.
+//
+// See https://github.com/prometheus/OpenMetrics/blob/main/specification/OpenMetrics.md#counter-1
+type CreatedTimestamps struct {
+	// m contains creation timestamps in milliseconds keyed by metric family name plus labels.
+	m map[string]int64
+
+	keyBuf  []byte
+	tagsBuf []Tag
+}
+
+// Reset resets ct, so it can be re-used.
+func (ct *CreatedTimestamps) Reset() {
+	clear(ct.m)
+	ct.keyBuf = ct.keyBuf[:0]
+	clear(ct.tagsBuf)
+	ct.tagsBuf = ct.tagsBuf[:0]
+}
+
+// IsCreatedMetric returns true if metric contains creation timestamp for OpenMetrics counter, histogram or summary.
+func IsCreatedMetric(metric string) bool {
+	return strings.HasSuffix(metric, "_created")
+}
+
+// Init initializes ct from `_created` series in rows.
+//
+// Rows with invalid creation timestamps are ignored.
+func (ct *CreatedTimestamps) Init(rows []Row) {
+	ct.Reset()
+	for i := range rows {
+		r := &rows[i]
+		if !IsCreatedMetric(r.Metric) {
+			continue
+		}
+		// The value for `_created` series contains unix timestamp in seconds.
+		timestamp := int64(r.Value * 1e3)
+		if timestamp <= 0 {
+			continue
+		}
+		if ct.m == nil {
+			ct.m = make(map[string]int64)
+		}
+		family := strings.TrimSuffix(r.Metric, "_created")
+		ct.keyBuf = ct.marshalKey(ct.keyBuf[:0], family, r.Tags, "")
+		ct.m[string(ct.keyBuf)] = timestamp
+	}
+}
+
+// Get returns creation timestamp in milliseconds for r.
+//
+// Zero is returned if r has no creation timestamp or if r is `_created` series itself.
+func (ct *CreatedTimestamps) Get(r *Row) int64 {
+	if len(ct.m) == 0 || IsCreatedMetric(r.Metric) {
+		return 0
+	}
+	family, skipTag := getMetricFamily(r.Metric)
+	ct.keyBuf = ct.marshalKey(ct.keyBuf[:0], family, r.Tags, skipTag)
+	if timestamp, ok := ct.m[string(ct.keyBuf)]; ok {
+		return timestamp
+	}
+	if family != r.Metric {
+		// The metric may have suffix without belonging to counter, histogram or summary family.
+		ct.keyBuf = ct.marshalKey(ct.keyBuf[:0], r.Metric, r.Tags, "")
+		return ct.m[string(ct.keyBuf)]
+	}
+	return 0
+}
+
+// getMetricFamily returns metric family name for the given metric together with the name of the label,
+// which must be ignored when matching the metric against `_created` series for the family.
+func getMetricFamily(metric string) (string, string) {
+	if s, ok := strings.CutSuffix(metric, "_total"); ok {
+		return s, ""
+	}
+	if s, ok := strings.CutSuffix(metric, "_bucket"); ok {
+		return s, "le"
+	}
+	if s, ok := strings.CutSuffix(metric, "_count"); ok {
+		return s, ""
+	}
+	if s, ok := strings.CutSuffix(metric, "_sum"); ok {
+		return s, ""
+	}
+	// Summary quantiles have no suffix.
+	return metric, "quantile"
+}
+
+func (ct *CreatedTimestamps) marshalKey(dst []byte, family string, tags []Tag, skipTag string) []byte {
+	// Sort tags, since their order may differ between the series and the corresponding `_created` series.
+	ct.tagsBuf = append(ct.tagsBuf[:0], tags...)
+	sort.Slice(ct.tagsBuf, func(i, j int) bool {
+		return ct.tagsBuf[i].Key < ct.tagsBuf[j].Key
+	})
+	dst = append(dst, family...)
+	for _, tag := range ct.tagsBuf {
+		if tag.Key == skipTag {
+			continue
+		}
+		dst = append(dst, 0)
+		dst = append(dst, tag.Key...)
+		dst = append(dst, '=')
+		dst = append(dst, tag.Value...)
+	}
+	return dst
+}
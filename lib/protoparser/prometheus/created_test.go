@@ -0,0 +1,92 @@
+package prometheus
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestIsCreatedMetric(t *testing.T) {
+	f := func(metric string, resultExpected bool) {
+		t.Helper()
+		result := IsCreatedMetric(metric)
+		if result != resultExpected {
+			t.Fatalf("unexpected result for IsCreatedMetric(%q); got %v; want %v", metric, result, resultExpected)
+		}
+	}
+
+	f("foo_created", true)
+	f("http_requests_created", true)
+
+	f("foo", false)
+	f("foo_total", false)
+	f("foo_created_total", false)
+}
+
+func TestCreatedTimestampsGet(t *testing.T) {
+	f := func(s, resultExpected string) {
+		t.Helper()
+		var rows Rows
+		rows.UnmarshalWithErrLogger(s, func(errStr string) {
+			t.Fatalf("unexpected error when parsing %q: %s", s, errStr)
+		})
+		var ct CreatedTimestamps
+		ct.Init(rows.Rows)
+		a := make([]string, 0, len(rows.Rows))
+		for i := range rows.Rows {
+			r := &rows.Rows[i]
+			if IsCreatedMetric(r.Metric) {
+				continue
+			}
+			a = append(a, fmt.Sprintf("%s=%d", r.Metric, ct.Get(r)))
+		}
+		result := strings.Join(a, ",")
+		if result != resultExpected {
+			t.Fatalf("unexpected result;\ngot\n%s\nwant\n%s", result, resultExpected)
+		}
+	}
+
+	// no _created series
+	f(`
+foo_total 1
+bar 2
+`, "foo_total=0,bar=0")
+
+	// counters
+	f(`
+foo_total{a="b"} 1
+foo_created{a="b"} 1.7e9
+foo_total{a="c"} 1
+bar_total 3
+bar_created 1700000000.123
+`, "foo_total=1700000000000,foo_total=0,bar_total=1700000000123")
+
+	// histogram
+	f(`
+foo_bucket{le="1",x="y"} 1
+foo_bucket{x="y",le="+Inf"} 2
+foo_count{x="y"} 2
+foo_sum{x="y"} 1.5
+foo_created{x="y"} 1000
+`, "foo_bucket=1000000,foo_bucket=1000000,foo_count=1000000,foo_sum=1000000")
+
+	// summary
+	f(`
+foo{quantile="0.5"} 1
+foo_count 2
+foo_sum 3
+foo_created 2000
+`, "foo=2000000,foo_count=2000000,foo_sum=2000000")
+
+	// labels in different order
+	f(`
+foo_total{a="1",b="2"} 1
+foo_created{b="2",a="1"} 10
+`, "foo_total=10000")
+
+	// invalid creation timestamp
+	f(`
+foo_total 1
+foo_created -1
+`, "foo_total=0")
+}
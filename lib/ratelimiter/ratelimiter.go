@@ -76,3 +76,85 @@ func (rl *RateLimiter) Register(count int) {
 	}
 	rl.budget -= int64(count)
 }
+
+// TryRegister registers count resources if the given per-second rate limit isn't exceeded.
+//
+// TryRegister never blocks. It returns false without registering the resources if the limit is exceeded.
+func (rl *RateLimiter) TryRegister(count int) bool {
+	if rl == nil {
+		return true
+	}
+
+	limit := rl.perSecondLimit
+	if limit <= 0 {
+		return true
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if rl.budget <= 0 {
+		d := time.Until(rl.deadline)
+		if d > 0 {
+			rl.limitReached.Inc()
+			return false
+		}
+		// Restore the budget for every second passed since the deadline.
+		// The restored budget cannot exceed the limit.
+		// n may be negative on overflow if the deadline isn't initialized yet.
+		n := 1 + int64(-d/time.Second)
+		nMax := (limit - rl.budget + limit - 1) / limit
+		if n <= 0 || n >= nMax {
+			rl.budget = limit
+		} else {
+			rl.budget += n * limit
+		}
+		rl.deadline = time.Now().Add(time.Second)
+		if rl.budget <= 0 {
+			// The budget is still exhausted by the previously registered big count.
+			rl.limitReached.Inc()
+			return false
+		}
+	}
+	rl.budget -= int64(count)
+	return true
+}
+
+// Consume registers count resources, which are already consumed.
+//
+// Consume never blocks. Subsequent TryRegister calls return false until the budget becomes positive.
+func (rl *RateLimiter) Consume(count int) {
+	if rl == nil || rl.perSecondLimit <= 0 {
+		return
+	}
+
+	rl.mu.Lock()
+	rl.budget -= int64(count)
+	rl.mu.Unlock()
+}
+
+// RetryAfter returns the duration until TryRegister calls may succeed again.
+func (rl *RateLimiter) RetryAfter() time.Duration {
+	if rl == nil {
+		return 0
+	}
+
+	limit := rl.perSecondLimit
+	if limit <= 0 {
+		return 0
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if rl.budget > 0 {
+		return 0
+	}
+	d := time.Until(rl.deadline)
+	if d < 0 {
+		d = 0
+	}
+	// The budget is increased by limit every second.
+	seconds := -rl.budget / limit
+	return d + time.Duration(seconds)*time.Second
+}
@@ -0,0 +1,160 @@
+package ratelimiter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+)
+
+func TestRateLimiterTryRegisterNoLimit(t *testing.T) {
+	var rlNil *RateLimiter
+	if !rlNil.TryRegister(100) {
+		t.Fatalf("TryRegister must succeed for nil rate limiter")
+	}
+	if d := rlNil.RetryAfter(); d != 0 {
+		t.Fatalf("unexpected RetryAfter for nil rate limiter; got %s; want 0", d)
+	}
+
+	rl := New(0, &metrics.Counter{}, nil)
+	for i := 0; i < 10; i++ {
+		if !rl.TryRegister(100) {
+			t.Fatalf("TryRegister must succeed for rate limiter without limit")
+		}
+	}
+	if d := rl.RetryAfter(); d != 0 {
+		t.Fatalf("unexpected RetryAfter for rate limiter without limit; got %s; want 0", d)
+	}
+}
+
+func TestRateLimiterTryRegister(t *testing.T) {
+	limitReached := &metrics.Counter{}
+	rl := New(10, limitReached, nil)
+
+	// The budget is initialized at the first call.
+	if !rl.TryRegister(4) {
+		t.Fatalf("TryRegister must succeed for the initial budget")
+	}
+	if !rl.TryRegister(6) {
+		t.Fatalf("TryRegister must succeed for the remaining budget")
+	}
+	if rl.TryRegister(1) {
+		t.Fatalf("TryRegister must fail for the exhausted budget")
+	}
+	if n := limitReached.Get(); n != 1 {
+		t.Fatalf("unexpected limitReached; got %d; want 1", n)
+	}
+	if d := rl.RetryAfter(); d <= 0 || d > time.Second {
+		t.Fatalf("unexpected RetryAfter; got %s; want (0s..1s]", d)
+	}
+
+	// The budget is restored after the deadline.
+	rl.deadline = time.Now().Add(-time.Millisecond)
+	if !rl.TryRegister(10) {
+		t.Fatalf("TryRegister must succeed after the budget is restored")
+	}
+	if rl.budget != 0 {
+		t.Fatalf("unexpected budget; got %d; want 0", rl.budget)
+	}
+}
+
+func TestRateLimiterTryRegisterBigCount(t *testing.T) {
+	limitReached := &metrics.Counter{}
+	rl := New(10, limitReached, nil)
+
+	// A big count exceeding the limit is registered if the budget isn't exhausted yet.
+	if !rl.TryRegister(25) {
+		t.Fatalf("TryRegister must succeed for the initial budget")
+	}
+	if rl.budget != -15 {
+		t.Fatalf("unexpected budget; got %d; want -15", rl.budget)
+	}
+
+	// The budget is restored by the limit after the deadline, but it is still exhausted.
+	rl.deadline = time.Now().Add(-time.Millisecond)
+	if rl.TryRegister(0) {
+		t.Fatalf("TryRegister must fail while the budget is still exhausted")
+	}
+	if rl.budget != -5 {
+		t.Fatalf("unexpected budget; got %d; want -5", rl.budget)
+	}
+	if n := limitReached.Get(); n != 1 {
+		t.Fatalf("unexpected limitReached; got %d; want 1", n)
+	}
+
+	rl.deadline = time.Now().Add(-time.Millisecond)
+	if !rl.TryRegister(0) {
+		t.Fatalf("TryRegister must succeed after the budget is restored")
+	}
+	if rl.budget != 5 {
+		t.Fatalf("unexpected budget; got %d; want 5", rl.budget)
+	}
+}
+
+func TestRateLimiterTryRegisterRecovery(t *testing.T) {
+	limitReached := &metrics.Counter{}
+	rl := New(10, limitReached, nil)
+
+	// The budget is exhausted by a big count for 3 seconds.
+	if !rl.TryRegister(35) {
+		t.Fatalf("TryRegister must succeed for the initial budget")
+	}
+	if rl.budget != -25 {
+		t.Fatalf("unexpected budget; got %d; want -25", rl.budget)
+	}
+	if rl.TryRegister(0) {
+		t.Fatalf("TryRegister must fail before the deadline")
+	}
+	if d := rl.RetryAfter(); d <= 2*time.Second || d > 3*time.Second {
+		t.Fatalf("unexpected RetryAfter; got %s; want (2s..3s]", d)
+	}
+
+	// The budget is restored for every second passed since the deadline, but it is still exhausted.
+	rl.deadline = time.Now().Add(-1500 * time.Millisecond)
+	if rl.TryRegister(0) {
+		t.Fatalf("TryRegister must fail while the budget is still exhausted")
+	}
+	if rl.budget != -5 {
+		t.Fatalf("unexpected budget; got %d; want -5", rl.budget)
+	}
+	if n := limitReached.Get(); n != 2 {
+		t.Fatalf("unexpected limitReached; got %d; want 2", n)
+	}
+
+	// The restored budget cannot exceed the limit.
+	rl.deadline = time.Now().Add(-time.Hour)
+	if !rl.TryRegister(0) {
+		t.Fatalf("TryRegister must succeed after the budget is restored")
+	}
+	if rl.budget != 10 {
+		t.Fatalf("unexpected budget; got %d; want 10", rl.budget)
+	}
+	if d := rl.RetryAfter(); d != 0 {
+		t.Fatalf("unexpected RetryAfter; got %s; want 0", d)
+	}
+}
+
+func TestRateLimiterConsume(t *testing.T) {
+	rl := New(10, &metrics.Counter{}, nil)
+	if !rl.TryRegister(0) {
+		t.Fatalf("TryRegister must succeed for the initial budget")
+	}
+
+	// Consume exhausts the budget for 2 seconds.
+	rl.Consume(25)
+	if rl.TryRegister(0) {
+		t.Fatalf("TryRegister must fail after the budget is consumed")
+	}
+	if d := rl.RetryAfter(); d <= time.Second || d > 2*time.Second {
+		t.Fatalf("unexpected RetryAfter; got %s; want (1s..2s]", d)
+	}
+
+	// The budget isn't restored if a single second passes since the deadline.
+	rl.deadline = time.Now().Add(-time.Millisecond)
+	if rl.TryRegister(0) {
+		t.Fatalf("TryRegister must fail while the budget is still exhausted")
+	}
+	if rl.budget != -5 {
+		t.Fatalf("unexpected budget; got %d; want -5", rl.budget)
+	}
+}
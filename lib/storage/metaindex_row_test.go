@@ -5,6 +5,8 @@ import (
 	"reflect"
 	"testing"
 	"testing/quick"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
 )
 
 func TestMetaindexRowReset(t *testing.T) {
@@ -97,3 +99,33 @@ func initTestMetaindexRow(mr *metaindexRow) {
 }
 
 var metaindexRowType = reflect.TypeOf(&metaindexRow{})
+
+func TestValidateMetaindex(t *testing.T) {
+	f := func(data []byte, resultExpected bool) {
+		t.Helper()
+		err := ValidateMetaindex(data)
+		if result := err == nil; result != resultExpected {
+			t.Fatalf("unexpected result for ValidateMetaindex(%X); got %v; want %v; err: %v", data, result, resultExpected, err)
+		}
+	}
+
+	var mr metaindexRow
+	mr.Reset()
+	mr.TSID.MetricID = 123
+	mr.BlockHeadersCount = 2
+	mr.MinTimestamp = 10
+	mr.MaxTimestamp = 20
+	mr.IndexBlockSize = 1234
+	data := mr.Marshal(nil)
+
+	f(encoding.CompressZSTDLevel(nil, data, 1), true)
+
+	// empty metaindex
+	f(encoding.CompressZSTDLevel(nil, nil, 1), false)
+
+	// uncompressed data
+	f(data, false)
+
+	// truncated data
+	f(encoding.CompressZSTDLevel(nil, data[:len(data)-1], 1), false)
+}
@@ -151,19 +151,37 @@ func (ph *partHeader) MustReadMetadata(partPath string) {
 		}
 	}
 
-	// Perform various checks
+	if err := ph.validate(); err != nil {
+		logger.Panicf("FATAL: invalid metadata at %q: %s", metadataPath, err)
+	}
+}
+
+// ValidatePartMetadata verifies that data contains valid contents of metadata.json file for the part.
+//
+// This function is used for verifying backups without opening the part.
+func ValidatePartMetadata(data []byte) error {
+	var ph partHeader
+	ph.Reset()
+	if err := json.Unmarshal(data, &ph); err != nil {
+		return fmt.Errorf("cannot parse part metadata: %w", err)
+	}
+	return ph.validate()
+}
+
+func (ph *partHeader) validate() error {
 	if ph.MinTimestamp > ph.MaxTimestamp {
-		logger.Panicf("FATAL: minTimestamp cannot exceed maxTimestamp at %q; got %d vs %d", metadataPath, ph.MinTimestamp, ph.MaxTimestamp)
+		return fmt.Errorf("minTimestamp cannot exceed maxTimestamp; got %d vs %d", ph.MinTimestamp, ph.MaxTimestamp)
 	}
 	if ph.RowsCount <= 0 {
-		logger.Panicf("FATAL: rowsCount must be greater than 0 at %q; got %d", metadataPath, ph.RowsCount)
+		return fmt.Errorf("rowsCount must be greater than 0; got %d", ph.RowsCount)
 	}
 	if ph.BlocksCount <= 0 {
-		logger.Panicf("FATAL: blocksCount must be greater than 0 at %q; got %d", metadataPath, ph.BlocksCount)
+		return fmt.Errorf("blocksCount must be greater than 0; got %d", ph.BlocksCount)
 	}
 	if ph.BlocksCount > ph.RowsCount {
-		logger.Panicf("FATAL: blocksCount cannot be bigger than rowsCount at %q; got blocksCount=%d, rowsCount=%d", metadataPath, ph.BlocksCount, ph.RowsCount)
+		return fmt.Errorf("blocksCount cannot be bigger than rowsCount; got blocksCount=%d, rowsCount=%d", ph.BlocksCount, ph.RowsCount)
 	}
+	return nil
 }
 
 func (ph *partHeader) MustWriteMetadata(partPath string) {
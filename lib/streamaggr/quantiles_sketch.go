@@ -0,0 +1,54 @@
+package streamaggr
+
+import (
+	"strconv"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/datadogsketches"
+)
+
+// quantilesSketchAggrValue calculates output=quantiles_sketch, e.g. mergeable sketch over input samples.
+//
+// The sketch is represented as a set of VictoriaMetrics histogram buckets with `vmrange` label,
+// so quantiles over it can be calculated with histogram_quantile() MetricsQL function.
+// See datadogsketches.ValueToKey for details on bucket boundaries.
+type quantilesSketchAggrValue struct {
+	buckets map[int32]uint64
+}
+
+func (av *quantilesSketchAggrValue) pushSample(_ aggrConfig, sample *pushSample, _ string, _ int64) {
+	if av.buckets == nil {
+		av.buckets = make(map[int32]uint64)
+	}
+	k := datadogsketches.ValueToKey(sample.value)
+	av.buckets[k]++
+}
+
+func (av *quantilesSketchAggrValue) flush(c aggrConfig, ctx *flushCtx, key string, _ bool) {
+	ac := c.(*quantilesSketchAggrConfig)
+	for k, count := range av.buckets {
+		lower, upper := datadogsketches.KeyToBounds(k)
+		ac.b = strconv.AppendFloat(ac.b[:0], lower, 'e', 3, 64)
+		ac.b = append(ac.b, "..."...)
+		ac.b = strconv.AppendFloat(ac.b, upper, 'e', 3, 64)
+		vmrange := bytesutil.InternBytes(ac.b)
+		ctx.appendSeriesWithExtraLabel(key, "quantiles_sketch", float64(count), "vmrange", vmrange)
+	}
+	av.buckets = nil
+}
+
+func (*quantilesSketchAggrValue) state() any {
+	return nil
+}
+
+func newQuantilesSketchAggrConfig() aggrConfig {
+	return &quantilesSketchAggrConfig{}
+}
+
+type quantilesSketchAggrConfig struct {
+	b []byte
+}
+
+func (*quantilesSketchAggrConfig) getValue(_ any) aggrValue {
+	return &quantilesSketchAggrValue{}
+}
@@ -42,6 +42,7 @@ var supportedOutputs = []string{
 	"max",
 	"min",
 	"quantiles(phi1, ..., phiN)",
+	"quantiles_sketch",
 	"rate_avg",
 	"rate_sum",
 	"stddev",
@@ -165,6 +166,12 @@ type Config struct {
 	// Interval is the interval between aggregations.
 	Interval string `yaml:"interval"`
 
+	// Window is an optional sliding window for the aggregation.
+	//
+	// If set, then the outputs are calculated over the last Window and are emitted every Interval.
+	// Window must be a multiple of Interval.
+	Window string `yaml:"window,omitempty"`
+
 	// NoAlighFlushToInterval disables aligning of flushes to multiples of Interval.
 	// By default flushes are aligned to Interval.
 	//
@@ -201,6 +208,7 @@ type Config struct {
 	// - max - the maximum sample value
 	// - min - the minimum sample value
 	// - quantiles(phi1, ..., phiN) - quantiles' estimation for phi in the range [0..1]
+	// - quantiles_sketch - creates mergeable sketch for input samples, which can be used for calculating quantiles with histogram_quantile() MetricsQL function
 	// - rate_avg - calculates average of rate for input counters
 	// - rate_sum - calculates sum of rate for input counters
 	// - stddev - standard deviation across all the samples
@@ -214,6 +222,8 @@ type Config struct {
 	//
 	//   input_name:<interval>[_by_<by_labels>][_without_<without_labels>]_<output>
 	//
+	// The <interval> is substituted with the Window if it is set.
+	//
 	// See also KeepMetricNames
 	//
 	Outputs []string `yaml:"outputs"`
@@ -419,6 +429,9 @@ type aggregator struct {
 	// dedupInterval is optional deduplication interval for incoming samples
 	dedupInterval time.Duration
 
+	// window is set to non-nil if outputs must be calculated over sliding window
+	window *slidingWindow
+
 	// da is set to non-nil if input samples must be de-duplicated
 	da *dedupAggr
 
@@ -497,6 +510,21 @@ func newAggregator(cfg *Config, path string, pushFunc PushFunc, ms *metrics.Set,
 		return nil, fmt.Errorf("interval=%s must be a multiple of dedup_interval=%s", interval, dedupInterval)
 	}
 
+	// check cfg.Window
+	var window *slidingWindow
+	if cfg.Window != "" {
+		w, err := time.ParseDuration(cfg.Window)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse `window: %q`: %w", cfg.Window, err)
+		}
+		if w != interval {
+			window, err = newSlidingWindow(w, interval, cfg.Outputs)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
 	// check cfg.StalenessInterval
 	stalenessInterval := interval * 2
 	if cfg.StalenessInterval != "" {
@@ -560,7 +588,7 @@ func newAggregator(cfg *Config, path string, pushFunc PushFunc, ms *metrics.Set,
 			return nil, fmt.Errorf("`outputs` list must contain only a single entry if `keep_metric_names` is set; got %q; "+
 				"see https://docs.victoriametrics.com/victoriametrics/stream-aggregation/#output-metric-names", cfg.Outputs)
 		}
-		if cfg.Outputs[0] == "histogram_bucket" || strings.HasPrefix(cfg.Outputs[0], "quantiles(") && strings.Contains(cfg.Outputs[0], ",") {
+		if cfg.Outputs[0] == "histogram_bucket" || cfg.Outputs[0] == "quantiles_sketch" || strings.HasPrefix(cfg.Outputs[0], "quantiles(") && strings.Contains(cfg.Outputs[0], ",") {
 			return nil, fmt.Errorf("`keep_metric_names` cannot be applied to `outputs: %q`, since they can generate multiple time series; "+
 				"see https://docs.victoriametrics.com/victoriametrics/stream-aggregation/#output-metric-names", cfg.Outputs)
 		}
@@ -619,6 +647,9 @@ func newAggregator(cfg *Config, path string, pushFunc PushFunc, ms *metrics.Set,
 
 	// initialize suffix to add to metric names after aggregation
 	suffix := ":" + cfg.Interval
+	if window != nil {
+		suffix = ":" + cfg.Window
+	}
 	if labels := removeUnderscoreName(by); len(labels) > 0 {
 		suffix += fmt.Sprintf("_by_%s", strings.Join(labels, "_"))
 	}
@@ -646,6 +677,7 @@ func newAggregator(cfg *Config, path string, pushFunc PushFunc, ms *metrics.Set,
 
 		interval:      interval,
 		dedupInterval: dedupInterval,
+		window:        window,
 
 		aggrOutputs: aggrOutputs,
 
@@ -771,6 +803,8 @@ func newOutputConfig(output string, outputsSeen map[string]struct{}, useSharedSt
 		return newMaxAggrConfig(), nil
 	case "min":
 		return newMinAggrConfig(), nil
+	case "quantiles_sketch":
+		return newQuantilesSketchAggrConfig(), nil
 	case "rate_avg":
 		return newRateAggrConfig(true), nil
 	case "rate_sum":
@@ -930,6 +964,9 @@ func (a *aggregator) flush(pushFunc PushFunc, flushTime time.Time, cs *currentSt
 		ctx.isGreen = cs.isGreen
 	}
 	ao.flushState(ctx)
+	if a.window != nil {
+		a.window.flushMissing(ctx)
+	}
 	ctx.flushSeries()
 	putFlushCtx(ctx)
 
@@ -1233,38 +1270,32 @@ func (ctx *flushCtx) flushSeries() {
 }
 
 func (ctx *flushCtx) appendSeries(key, suffix string, value float64) {
-	labelsLen := len(ctx.labels)
-	samplesLen := len(ctx.samples)
-	ctx.labels = decompressLabels(ctx.labels, key)
-	if !ctx.a.keepMetricNames {
-		ctx.labels = addMetricSuffix(ctx.labels, labelsLen, ctx.a.suffix, suffix)
-	}
-	ctx.samples = append(ctx.samples, prompb.Sample{
-		Timestamp: ctx.flushTimestamp,
-		Value:     value,
-	})
-	ctx.tss = append(ctx.tss, prompb.TimeSeries{
-		Labels:  ctx.labels[labelsLen:],
-		Samples: ctx.samples[samplesLen:],
-	})
+	ctx.appendSeriesWithExtraLabel(key, suffix, value, "", "")
+}
 
-	// Limit the maximum length of ctx.tss in order to limit memory usage.
-	if len(ctx.tss) >= 10_000 {
-		ctx.flushSeries()
+func (ctx *flushCtx) appendSeriesWithExtraLabel(key, suffix string, value float64, extraName, extraValue string) {
+	if w := ctx.a.window; w != nil {
+		value = w.update(ctx.flushTimestamp, key, suffix, extraName, extraValue, value)
 	}
+	ctx.appendOutputSeries(key, suffix, value, extraName, extraValue)
 }
 
-func (ctx *flushCtx) appendSeriesWithExtraLabel(key, suffix string, value float64, extraName, extraValue string) {
+// appendOutputSeries appends the output series to ctx.
+//
+// The extra label isn't added if extraName is empty.
+func (ctx *flushCtx) appendOutputSeries(key, suffix string, value float64, extraName, extraValue string) {
 	labelsLen := len(ctx.labels)
 	samplesLen := len(ctx.samples)
 	ctx.labels = decompressLabels(ctx.labels, key)
 	if !ctx.a.keepMetricNames {
 		ctx.labels = addMetricSuffix(ctx.labels, labelsLen, ctx.a.suffix, suffix)
 	}
-	ctx.labels = append(ctx.labels, prompb.Label{
-		Name:  extraName,
-		Value: extraValue,
-	})
+	if extraName != "" {
+		ctx.labels = append(ctx.labels, prompb.Label{
+			Name:  extraName,
+			Value: extraValue,
+		})
+	}
 	ctx.samples = append(ctx.samples, prompb.Sample{
 		Timestamp: ctx.flushTimestamp,
 		Value:     value,
@@ -634,6 +634,22 @@ cpu_usage:1m_without_cpu_quantiles{quantile="1"} 90
   outputs: ["quantiles(0, 0.5, 1)"]
 `, "1111111")
 
+	// quantiles_sketch output
+	f([]string{`
+cpu_usage{cpu="1"} 12.5
+cpu_usage{cpu="1"} 12.5
+cpu_usage{cpu="1"} 25
+cpu_usage{cpu="2"} -90
+cpu_usage{cpu="2"} 0
+`}, time.Minute, `cpu_usage:1m_quantiles_sketch{cpu="1",vmrange="1.233e+01...1.252e+01"} 2
+cpu_usage:1m_quantiles_sketch{cpu="1",vmrange="2.476e+01...2.515e+01"} 1
+cpu_usage:1m_quantiles_sketch{cpu="2",vmrange="-9.108e+01...-8.968e+01"} 1
+cpu_usage:1m_quantiles_sketch{cpu="2",vmrange="0.000e+00...1.000e-09"} 1
+`, `
+- interval: 1m
+  outputs: [quantiles_sketch]
+`, "11111")
+
 	// append additional label
 	f([]string{`
 foo{abc="123"} 4
@@ -715,6 +731,21 @@ bar 5  10
   outputs: [rate_sum, rate_avg]
 `, "11")
 
+	// sliding window
+	f([]string{`
+foo 1
+foo 3
+bar 5
+`}, time.Minute, `bar:2m_count_samples 1
+bar:2m_max 5
+foo:2m_count_samples 2
+foo:2m_max 3
+`, `
+- interval: 1m
+  window: 2m
+  outputs: [count_samples, max]
+`, "111")
+
 	// unique_samples output
 	f([]string{`
 foo 1  10
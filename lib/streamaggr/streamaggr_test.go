@@ -68,6 +68,27 @@ func TestAggregatorsFailure(t *testing.T) {
   interval: 10ms
 `)
 
+	// bad window
+	f(`
+- interval: 1m
+  window: 1foo
+  outputs: [sum_samples]
+`)
+
+	// window isn't multiple of interval
+	f(`
+- interval: 1m
+  window: 90s
+  outputs: [sum_samples]
+`)
+
+	// window is applied to unsupported output
+	f(`
+- interval: 1m
+  window: 5m
+  outputs: [sum_samples, count_series]
+`)
+
 	// bad dedup_interval
 	f(`
 - interval: 1m
@@ -0,0 +1,179 @@
+package streamaggr
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// windowCombineFunc combines per-interval output values into a single value for the whole window.
+type windowCombineFunc func(values []float64) float64
+
+// windowCombineFuncs contains outputs, which support `window` option.
+//
+// Every such output is calculated per each `interval`, and then the calculated values
+// for the last `window / interval` intervals are combined with the corresponding function.
+// This allows calculating the output over the window without storing raw samples.
+var windowCombineFuncs = map[string]windowCombineFunc{
+	"count_samples":       windowSum,
+	"histogram_bucket":    windowSum,
+	"increase":            windowSum,
+	"increase_prometheus": windowSum,
+	"last":                windowLast,
+	"max":                 windowMax,
+	"min":                 windowMin,
+	"quantiles_sketch":    windowSum,
+	"rate_avg":            windowAvg,
+	"rate_sum":            windowAvg,
+	"sum_samples":         windowSum,
+}
+
+func getWindowSupportedOutputs() []string {
+	outputs := make([]string, 0, len(windowCombineFuncs))
+	for output := range windowCombineFuncs {
+		outputs = append(outputs, output)
+	}
+	sort.Strings(outputs)
+	return outputs
+}
+
+func windowSum(values []float64) float64 {
+	sum := float64(0)
+	for _, v := range values {
+		sum += v
+	}
+	return sum
+}
+
+func windowAvg(values []float64) float64 {
+	return windowSum(values) / float64(len(values))
+}
+
+func windowMin(values []float64) float64 {
+	minValue := math.Inf(1)
+	for _, v := range values {
+		minValue = math.Min(minValue, v)
+	}
+	return minValue
+}
+
+func windowMax(values []float64) float64 {
+	maxValue := math.Inf(-1)
+	for _, v := range values {
+		maxValue = math.Max(maxValue, v)
+	}
+	return maxValue
+}
+
+func windowLast(values []float64) float64 {
+	// values are ordered by time
+	return values[len(values)-1]
+}
+
+// slidingWindow holds per-interval output values for the last window.
+//
+// It is updated only from aggregator.flush, which is called sequentially, so it doesn't need locking.
+type slidingWindow struct {
+	interval int64
+	window   int64
+	panes    int
+
+	m map[string]*windowSeries
+
+	// buf is used for collecting pane values during combining
+	buf []float64
+}
+
+type windowSeries struct {
+	key        string
+	suffix     string
+	extraName  string
+	extraValue string
+
+	combine windowCombineFunc
+
+	// lastFlushTimestamp is the last flush timestamp when the series was received from the output
+	lastFlushTimestamp int64
+
+	// values and timestamps is a ring buffer with per-interval values.
+	values     []float64
+	timestamps []int64
+}
+
+func newSlidingWindow(window, interval time.Duration, outputs []string) (*slidingWindow, error) {
+	if window < interval {
+		return nil, fmt.Errorf("window=%s cannot be smaller than interval=%s", window, interval)
+	}
+	if window%interval != 0 {
+		return nil, fmt.Errorf("window=%s must be a multiple of interval=%s", window, interval)
+	}
+	for _, output := range outputs {
+		if _, ok := windowCombineFuncs[output]; !ok {
+			return nil, fmt.Errorf("`window` cannot be applied to `outputs: [%s]`; supported outputs: %s", output, strings.Join(getWindowSupportedOutputs(), ", "))
+		}
+	}
+	return &slidingWindow{
+		interval: interval.Milliseconds(),
+		window:   window.Milliseconds(),
+		panes:    int(window / interval),
+		m:        make(map[string]*windowSeries),
+	}, nil
+}
+
+// update registers the value for the given series at flushTimestamp and returns the value combined over the window.
+func (sw *slidingWindow) update(flushTimestamp int64, key, suffix, extraName, extraValue string, value float64) float64 {
+	seriesKey := key + "\xff" + suffix + "\xff" + extraValue
+	ws := sw.m[seriesKey]
+	if ws == nil {
+		ws = &windowSeries{
+			key:        key,
+			suffix:     suffix,
+			extraName:  extraName,
+			extraValue: extraValue,
+			combine:    windowCombineFuncs[suffix],
+			values:     make([]float64, sw.panes),
+			timestamps: make([]int64, sw.panes),
+		}
+		sw.m[seriesKey] = ws
+	}
+	idx := int((flushTimestamp / sw.interval) % int64(sw.panes))
+	ws.values[idx] = value
+	ws.timestamps[idx] = flushTimestamp
+	ws.lastFlushTimestamp = flushTimestamp
+	return sw.combine(ws, flushTimestamp)
+}
+
+// flushMissing pushes combined values to ctx for series, which weren't received during the current flush,
+// but still have values inside the window. Series without values inside the window are deleted.
+func (sw *slidingWindow) flushMissing(ctx *flushCtx) {
+	for seriesKey, ws := range sw.m {
+		if ws.lastFlushTimestamp == ctx.flushTimestamp {
+			continue
+		}
+		if ws.lastFlushTimestamp <= ctx.flushTimestamp-sw.window {
+			delete(sw.m, seriesKey)
+			continue
+		}
+		value := sw.combine(ws, ctx.flushTimestamp)
+		ctx.appendOutputSeries(ws.key, ws.suffix, value, ws.extraName, ws.extraValue)
+	}
+}
+
+func (sw *slidingWindow) combine(ws *windowSeries, flushTimestamp int64) float64 {
+	minTimestamp := flushTimestamp - sw.window
+	// Start from the oldest pane, so the values are ordered by time.
+	start := int((flushTimestamp/sw.interval + 1) % int64(sw.panes))
+	values := sw.buf[:0]
+	for i := 0; i < sw.panes; i++ {
+		idx := (start + i) % sw.panes
+		if ws.timestamps[idx] == 0 || ws.timestamps[idx] <= minTimestamp {
+			// The pane is empty or it is outside the window
+			continue
+		}
+		values = append(values, ws.values[idx])
+	}
+	sw.buf = values
+	return ws.combine(values)
+}
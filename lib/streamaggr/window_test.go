@@ -0,0 +1,109 @@
+package streamaggr
+
+import (
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+func TestNewSlidingWindowFailure(t *testing.T) {
+	f := func(window, interval time.Duration, outputs []string) {
+		t.Helper()
+
+		if _, err := newSlidingWindow(window, interval, outputs); err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+
+	// window smaller than interval
+	f(time.Minute, 2*time.Minute, []string{"sum_samples"})
+
+	// window isn't a multiple of interval
+	f(90*time.Second, time.Minute, []string{"sum_samples"})
+
+	// unsupported outputs
+	f(5*time.Minute, time.Minute, []string{"sum_samples", "avg"})
+	f(5*time.Minute, time.Minute, []string{"count_series"})
+	f(5*time.Minute, time.Minute, []string{"total"})
+	f(5*time.Minute, time.Minute, []string{"quantiles(0.5)"})
+}
+
+func TestSlidingWindow(t *testing.T) {
+	sw, err := newSlidingWindow(3*time.Minute, time.Minute, []string{"sum_samples", "max", "rate_sum", "last"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	key := string(lc.Compress(nil, []prompb.Label{{Name: "__name__", Value: "foo"}}))
+
+	var tssOutput []prompb.TimeSeries
+	a := &aggregator{
+		suffix: ":3m_",
+		window: sw,
+	}
+	ao := &aggrOutputs{
+		outputSamples: metrics.NewSet().NewCounter("test_output_samples_total"),
+	}
+	pushFunc := func(tss []prompb.TimeSeries) {
+		tssOutput = appendClonedTimeseries(tssOutput, tss)
+	}
+
+	// values contains per-interval values for the given suffix; NaN means the series is missing at the given interval
+	f := func(flushTimestamp int64, values map[string]float64, outputExpected string) {
+		t.Helper()
+
+		tssOutput = tssOutput[:0]
+		ctx := getFlushCtx(a, ao, pushFunc, flushTimestamp, false)
+		for _, suffix := range []string{"last", "max", "rate_sum", "sum_samples"} {
+			if v, ok := values[suffix]; ok {
+				ctx.appendSeries(key, suffix, v)
+			}
+		}
+		sw.flushMissing(ctx)
+		ctx.flushSeries()
+		putFlushCtx(ctx)
+
+		output := timeSeriessToString(tssOutput)
+		if output != outputExpected {
+			t.Fatalf("unexpected output at %d;\ngot\n%s\nwant\n%s", flushTimestamp, output, outputExpected)
+		}
+	}
+
+	const minute = 60_000
+	f(minute, map[string]float64{"last": 1, "max": 5, "rate_sum": 2, "sum_samples": 10}, `foo:3m_last 1
+foo:3m_max 5
+foo:3m_rate_sum 2
+foo:3m_sum_samples 10
+`)
+	f(2*minute, map[string]float64{"last": 2, "max": 3, "rate_sum": 4, "sum_samples": 20}, `foo:3m_last 2
+foo:3m_max 5
+foo:3m_rate_sum 3
+foo:3m_sum_samples 30
+`)
+
+	// the series is missing at the current interval, but it is still inside the window
+	f(3*minute, map[string]float64{"sum_samples": 30}, `foo:3m_last 2
+foo:3m_max 5
+foo:3m_rate_sum 3
+foo:3m_sum_samples 60
+`)
+
+	// the first interval goes out of the window
+	f(4*minute, map[string]float64{"max": 1, "sum_samples": 40}, `foo:3m_last 2
+foo:3m_max 3
+foo:3m_rate_sum 4
+foo:3m_sum_samples 90
+`)
+
+	// skipped flush must drop the corresponding intervals
+	f(6*minute, map[string]float64{"sum_samples": 5}, `foo:3m_max 1
+foo:3m_sum_samples 45
+`)
+
+	// all the intervals for the series go out of the window
+	f(10*minute, nil, ``)
+	if n := len(sw.m); n != 0 {
+		t.Fatalf("unexpected number of series left in the window; got %d; want 0", n)
+	}
+}